@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/govmomi"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func newBackfillCommand() *cobra.Command {
+	var since, until string
+	var chunk time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Replay the vCenter events of a past window into the event sinks",
+		Long: `Page through the event history of every endpoint between --since and --until
+and write the events to the event sinks with their original timestamps, for
+incident forensics or to seed a log backend.
+
+The window is read --chunk at a time, each chunk being written before the next
+is read, so that long windows don't hold every event in memory. Times are
+RFC 3339 or durations before now, such as 72h.`,
+		Example: "  vsphere-collector backfill --since 2024-05-01T00:00:00Z --until 2024-05-02T00:00:00Z --events-es-url https://es:9200",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, to, err := backfillWindow(since, until, chunk, time.Now())
+			if err != nil {
+				return configError(err)
+			}
+
+			sinks, err := newEventSinks()
+			if err != nil {
+				return configError(err)
+			}
+			if len(sinks) == 0 {
+				return configError(errors.New("backfill requires --events-file, --events-loki-url or --events-es-url"))
+			}
+
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			ctx := cmd.Context()
+			var failures, sinkFailures int
+			for _, e := range col.Endpoints {
+				client, err := e.Client(ctx)
+				if err != nil {
+					failures++
+					slog.Warn("backfill failed", "endpoint", e.URL.Host, "err", err)
+					continue
+				}
+
+				err = replayEvents(ctx, client, from, to, chunk, func(start, end time.Time, events []collector.Event) {
+					for _, s := range sinks {
+						if err := s.WriteEvents(ctx, events); err != nil {
+							sinkFailures++
+							slog.Warn("write failed", "sink", s.name, "err", err)
+						}
+					}
+					slog.Info("replayed events", "endpoint", e.URL.Host, "since", start, "until", end, "count", len(events))
+				})
+				if err != nil {
+					failures++
+					slog.Warn("backfill failed", "endpoint", e.URL.Host, "err", err)
+				}
+			}
+
+			switch {
+			case failures != 0:
+				return &exitError{code: exitPartial, err: fmt.Errorf("backfill failed %d times", failures)}
+			case sinkFailures != 0:
+				return &exitError{code: exitSink, err: fmt.Errorf("%d event writes failed", sinkFailures)}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "24h", "Start of the window, RFC 3339 or a duration before now")
+	cmd.Flags().StringVar(&until, "until", "0s", "End of the window, RFC 3339 or a duration before now")
+	cmd.Flags().DurationVar(&chunk, "chunk", time.Hour, "Length of the parts of the window read and written at a time")
+	addEventFlags(cmd.Flags())
+	return cmd
+}
+
+// backfillWindow returns the window of since and until as of now, read in
+// chunks of chunk.
+func backfillWindow(since, until string, chunk time.Duration, now time.Time) (time.Time, time.Time, error) {
+	from, err := parseTime(since, now)
+	if err != nil {
+		return from, from, fmt.Errorf("--since: %s", err)
+	}
+	to, err := parseTime(until, now)
+	if err != nil {
+		return from, to, fmt.Errorf("--until: %s", err)
+	}
+	if !from.Before(to) || chunk <= 0 {
+		return from, to, fmt.Errorf("invalid window %s to %s of %s chunks", from.Format(time.RFC3339), to.Format(time.RFC3339), chunk)
+	}
+	return from, to, nil
+}
+
+// replayEvents reads the events of c between from and to a chunk at a time,
+// calling write with those of every chunk that has any, of its start and end,
+// until failing to read one.
+func replayEvents(ctx context.Context, c *govmomi.Client, from, to time.Time, chunk time.Duration, write func(start, end time.Time, events []collector.Event)) error {
+	// Chunks after the first overlap the previous one, events are numbered
+	// in order
+	last := int32(-1)
+	for start := from; start.Before(to); start = start.Add(chunk) {
+		end := start.Add(chunk)
+		if end.After(to) {
+			end = to
+		}
+		since := start
+		if start.After(from) {
+			since = start.Add(-collector.EventOverlap)
+		}
+		evs, err := collector.Events(ctx, c, since, end)
+		if err != nil {
+			return fmt.Errorf("events from %s until %s: %w", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+		}
+		var events []collector.Event
+		for _, ev := range evs {
+			if ev.Key > last {
+				events = append(events, ev)
+				last = ev.Key
+			}
+		}
+		if len(events) != 0 {
+			write(start, end, events)
+		}
+	}
+	return nil
+}
+
+// parseTime parses s as an RFC 3339 time or a duration before now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
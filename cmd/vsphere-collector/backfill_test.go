@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/simulator"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		s      string
+		expect time.Time
+	}{
+		{"2024-05-01T00:00:00Z", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-05-01T02:00:00+02:00", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"72h", now.Add(-72 * time.Hour)},
+		{"0s", now},
+	} {
+		got, err := parseTime(test.s, now)
+		if err != nil || !got.Equal(test.expect) {
+			t.Errorf("parseTime(%q) = %s, %v, expected %s", test.s, got, err, test.expect)
+		}
+	}
+
+	for _, s := range []string{"", "yesterday", "2024-05-01", "2024-05-01 00:00:00"} {
+		if _, err := parseTime(s, now); err == nil {
+			t.Errorf("parseTime(%q) accepted", s)
+		}
+	}
+}
+
+func TestBackfillWindow(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	from, to, err := backfillWindow("24h", "0s", time.Hour, now)
+	if err != nil || !from.Equal(now.Add(-24*time.Hour)) || !to.Equal(now) {
+		t.Errorf("window %s to %s: %v", from, to, err)
+	}
+
+	for _, test := range []struct {
+		since, until string
+		chunk        time.Duration
+	}{
+		{"1h", "24h", time.Hour},
+		{"1h", "1h", time.Hour},
+		{"24h", "0s", 0},
+		{"24h", "0s", -time.Hour},
+		{"soon", "0s", time.Hour},
+		{"24h", "later", time.Hour},
+	} {
+		if _, _, err := backfillWindow(test.since, test.until, test.chunk, now); err == nil {
+			t.Errorf("window %s to %s of %s chunks accepted", test.since, test.until, test.chunk)
+		}
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	model := simulator.VPX()
+	defer model.Remove()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	s := model.Service.NewServer()
+	defer s.Close()
+
+	ctx := context.Background()
+	c, err := govmomi.NewClient(ctx, s.URL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Logout(ctx)
+
+	now := time.Now()
+	all, err := collector.Events(ctx, c, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 {
+		t.Fatal("no events")
+	}
+
+	// Chunks bound by the time of an event, their begin time being
+	// exclusive, read it once
+	bound := all[len(all)/2]
+	from, to := bound.Time.Add(-time.Hour), bound.Time.Add(time.Hour)
+	var keys []int32
+	err = replayEvents(ctx, c, from, to, time.Hour, func(_, _ time.Time, events []collector.Event) {
+		for _, ev := range events {
+			keys = append(keys, ev.Key)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(all) {
+		t.Fatalf("%d events replayed, expected the %d events once", len(keys), len(all))
+	}
+	for i := range keys {
+		if keys[i] != all[i].Key {
+			t.Errorf("event %d is %d, expected %d", i, keys[i], all[i].Key)
+		}
+	}
+}
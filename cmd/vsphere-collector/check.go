@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+var thresholdsDescription = fmt.Sprintf("Comma separated measurement.field>warn:crit thresholds, < for lower bounds, durations such as 7d for _sec fields [%s]", envThresh)
+var thresholdsFlag []string
+
+var perfdataDescription = fmt.Sprintf("Performance data of the values breaching a threshold, problems, or of every value checked, all [%s]", envPerf)
+var perfdataFlag string
+
+// nagiosUnknown is the Nagios plugin exit code of a check that could not be
+// performed. The other codes are the collector.State of the worst result.
+const nagiosUnknown = 3
+
+func newCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check endpoints are ready to be collected, or their metrics against thresholds",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "permissions",
+		Short: "Report the privileges each collector is missing on every endpoint",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			if !collector.CheckPermissions(cmd.Context(), col.Endpoints, os.Stdout) {
+				return &exitError{code: exitFailure}
+			}
+			return nil
+		},
+	})
+
+	thresholds := &cobra.Command{
+		Use:   "thresholds",
+		Short: "Check metrics against thresholds as a Nagios or Icinga plugin",
+		Long: `Collect once and check metrics against warning and critical thresholds, as a
+Nagios or Icinga plugin: a status line with performance data is printed, then
+every breach, and the exit code is 0 for OK, 1 for WARNING, 2 for CRITICAL or
+3 for UNKNOWN when collection failed without any critical breach.`,
+		Args: cobra.NoArgs,
+		RunE: runCheckThresholds,
+	}
+	thresholds.Flags().StringSliceVar(&thresholdsFlag, "threshold", []string{"datastore.used_percent>85:95", "vm.snapshot_age_sec>7d:14d"}, thresholdsDescription)
+	thresholds.Flags().StringVar(&perfdataFlag, "perfdata", "problems", perfdataDescription)
+	cmd.AddCommand(thresholds)
+
+	return cmd
+}
+
+func runCheckThresholds(cmd *cobra.Command, args []string) error {
+	var thresholds []collector.Threshold
+	for _, s := range thresholdsFlag {
+		t, err := collector.ParseThreshold(s)
+		if err != nil {
+			return configError(err)
+		}
+		thresholds = append(thresholds, t)
+	}
+
+	if perfdataFlag != "problems" && perfdataFlag != "all" {
+		return configError(fmt.Errorf("invalid perfdata %q", perfdataFlag))
+	}
+
+	col, err := newCollector()
+	if err != nil {
+		return err
+	}
+	defer closeCollector(col)
+
+	metrics, err := col.Collect(cmd.Context())
+	errs, _ := err.(collector.Errors)
+
+	results := collector.Evaluate(metrics, thresholds)
+	code := writeNagios(os.Stdout, results, errs, perfdataFlag == "all")
+	if code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
+}
+
+// writeNagios writes results and errs as Nagios plugin output, returning the
+// exit code.
+func writeNagios(w io.Writer, results []collector.Result, errs collector.Errors, allPerfdata bool) int {
+	counts := make(map[collector.State]int)
+	worst := collector.OK
+	var perfdata []string
+	for _, r := range results {
+		counts[r.State]++
+		if r.State > worst {
+			worst = r.State
+		}
+		if allPerfdata || r.State != collector.OK {
+			perfdata = append(perfdata, fmt.Sprintf("'%s'=%g;%g;%g", perfLabel(r), r.Value, r.Threshold.Warning, r.Threshold.Critical))
+		}
+	}
+
+	code, status := int(worst), worst.String()
+	if len(errs) != 0 && worst != collector.Critical {
+		code, status = nagiosUnknown, "UNKNOWN"
+	}
+
+	fmt.Fprintf(w, "VSPHERE %s - %d critical, %d warning of %d checked", status, counts[collector.Critical], counts[collector.Warning], len(results))
+	if len(errs) != 0 {
+		fmt.Fprintf(w, ", %d collection failures", len(errs))
+	}
+	if len(perfdata) != 0 {
+		fmt.Fprintf(w, " | %s", strings.Join(perfdata, " "))
+	}
+	fmt.Fprintln(w)
+
+	for _, state := range []collector.State{collector.Critical, collector.Warning} {
+		for _, r := range results {
+			if r.State == state {
+				fmt.Fprintf(w, "%s: %s %s = %g (%s)\n", r.State, r.Metric.Name, perfLabel(r), r.Value, r.Threshold)
+			}
+		}
+	}
+	for _, err := range errs {
+		fmt.Fprintf(w, "UNKNOWN: %s\n", collector.Redact(err.Error()))
+	}
+
+	return code
+}
+
+// perfLabel labels the value of r by endpoint, entity name and field, single
+// quotes being doubled as Nagios requires.
+func perfLabel(r collector.Result) string {
+	label := r.Metric.Tag("vcenter") + "/" + r.Metric.Tag("name") + " " + r.Threshold.Field
+	return strings.ReplaceAll(label, "'", "''")
+}
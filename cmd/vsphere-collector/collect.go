@@ -0,0 +1,774 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/alert"
+	"github.com/mlabouardy/vsphere-collector/pkg/api"
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/objstore"
+	"github.com/mlabouardy/vsphere-collector/pkg/sinks"
+	"github.com/mlabouardy/vsphere-collector/pkg/vcd"
+)
+
+var intervalDescription = fmt.Sprintf("Collect every interval [%s]", envInterval)
+var intervalFlag time.Duration
+
+var formatDescription = fmt.Sprintf("Output format: line for InfluxDB line protocol, json for JSON lines, graphite for Graphite plaintext, graphite-tags for Graphite 1.1 plaintext with tags or prometheus for the Prometheus text format [%s]", envFormat)
+var formatFlag string
+
+var dryRunDescription = fmt.Sprintf("Collect and print what would be written to each sink, after a # line naming it, without writing [%s]", envDryRun)
+var dryRunFlag bool
+
+var measurementTemplateDescription = fmt.Sprintf("Go template of measurement names, such as {{.Datacenter}}_{{.Measurement}}, of fields Measurement, Type, Datacenter, VCenter, Name and Tags [%s]", envMeasTmpl)
+var measurementTemplateFlag string
+
+var tagTemplateDescription = fmt.Sprintf("Comma separated key=template tags to set from Go templates, of the fields of --measurement-template [%s]", envTagTmpl)
+var tagTemplateFlag []string
+
+var progressDescription = fmt.Sprintf("Log the entities discovered and collected, elapsed time and ETA every interval, 0 to disable [%s]", envProgress)
+var progressFlag time.Duration
+
+var forecastWindowDescription = fmt.Sprintf("Emit forecast metrics of the days until datastores and the CPU and memory of clusters are full, from their linear trend over this rolling window, such as 168h; 0 to disable [%s]", envForecast)
+var forecastWindowFlag time.Duration
+
+var idleWindowDescription = fmt.Sprintf("Emit vm_idle metrics scoring how idle virtual machines are, from their sustained low CPU, network and disk activity over this rolling window, such as 168h; 0 to disable [%s]", envIdle)
+var idleWindowFlag time.Duration
+
+var costFileDescription = fmt.Sprintf("YAML file of the monthly unit costs of vCPUs, memory and storage tiers, to emit vm_cost and resource_pool_cost metrics of [%s]", envCostFile)
+var costFileFlag string
+
+var energyDescription = fmt.Sprintf("Emit vm_energy metrics of the power draw of hosts attributed to their virtual machines by CPU usage, and the energy consumed since [%s]", envEnergy)
+var energyFlag bool
+
+var topDescription = fmt.Sprintf("Emit top metrics ranking the virtual machines of every cluster of the highest CPU ready time, ballooned memory and committed storage every cycle, this many per ranking; 0 to disable [%s]", envTopN)
+var topFlag int
+
+var migrationsDescription = fmt.Sprintf("Emit migration metrics counting and timing the vMotions and Storage vMotions completed per cluster every cycle, from vCenter events [%s]", envMigrate)
+var migrationsFlag bool
+
+var rpoEventsDescription = fmt.Sprintf("Set rpo_violated of vm_protection metrics from the vSphere Replication RPO violation events of vCenter [%s]", envRPO)
+var rpoEventsFlag bool
+
+var createdByDescription = fmt.Sprintf("Tag vm metrics with the user who created their virtual machine, as created_by, from the creation events vCenter retains and those of every cycle [%s]", envCreator)
+var createdByFlag bool
+
+var tombstonesDescription = fmt.Sprintf("Emit a tombstone metric for every datastore, host, virtual machine or cluster gone from the inventory since the previous cycle, ending its series [%s]", envTomb)
+var tombstonesFlag bool
+
+var grpcListenDescription = fmt.Sprintf("Serve the latest metrics and inventory over the gRPC API of pkg/api/query.proto on this address, such as :9090 [%s]", envGRPC)
+var grpcListenFlag string
+
+var apiListenDescription = fmt.Sprintf("Serve the latest tags and fields of every virtual machine, host and datastore as JSON at /api/v1/vms, /api/v1/hosts and /api/v1/datastores, and the Metrics and Inventory queries of the gRPC API at /api/v1/query/metrics and /api/v1/query/inventory, on this address, such as :8080 [%s]", envAPI)
+var apiListenFlag string
+
+var alertRuleDescription = fmt.Sprintf("Comma separated measurement.field>warn:crit thresholds, as of check thresholds, to alert on [%s]", envRules)
+var alertRuleFlag []string
+
+var alertWebhookDescription = fmt.Sprintf("URL to POST an alert to whenever the state of an entity against an --alert-rule changes [%s]", envHook)
+var alertWebhookFlag string
+
+var alertFormatDescription = fmt.Sprintf("Alert payload: generic, slack or teams [%s]", envHookFmt)
+var alertFormatFlag string
+
+var snmpTargetDescription = fmt.Sprintf("host[:port] to send an SNMPv2c trap to whenever an entity breaches the critical level of an --alert-rule, such as host.available<1:1, or recovers [%s]", envSNMP)
+var snmpTargetFlag string
+
+var snmpCommunityDescription = fmt.Sprintf("SNMP community of --snmp-target [%s]", envSNMPComm)
+var snmpCommunityFlag string
+
+var snmpOIDDescription = fmt.Sprintf("Enterprise OID traps are sent under [%s]", envSNMPOID)
+var snmpOIDFlag string
+
+var screenshotsDescription = fmt.Sprintf("Directory or s3://bucket/prefix URL to store the console screenshot of every virtual machine entering a problematic state in: a pending question, VMware Tools stopped or its heartbeat lost [%s]", envShots)
+var screenshotsFlag string
+
+var probeIntervalDescription = fmt.Sprintf("Probe the API latency and login time of every endpoint this often, independently of cycles, as vcenter_probe metrics, 0 to disable [%s]", envProbe)
+var probeIntervalFlag time.Duration
+
+var overrunDescription = fmt.Sprintf("When a cycle overruns the interval, skip the missed cycle or queue it to start immediately: skip or queue [%s]", envOverrun)
+var overrunFlag string
+
+var tagMappingDescription = fmt.Sprintf("CSV or JSON file mapping the tags of entities, such as their name, to the business context tags to set, such as application, owner or sla_tier [%s]", envTagMap)
+var tagMappingFlag string
+
+// addOutputFlags adds the flags of the commands writing metrics to fs.
+func addOutputFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&formatFlag, "format", "line", formatDescription)
+	fs.BoolVar(&dryRunFlag, "dry-run", false, dryRunDescription)
+	fs.StringVar(&measurementTemplateFlag, "measurement-template", "", measurementTemplateDescription)
+	fs.StringSliceVar(&tagTemplateFlag, "tag-template", nil, tagTemplateDescription)
+	fs.StringVar(&tagMappingFlag, "tag-mapping", "", tagMappingDescription)
+	addSinkFlags(fs)
+	addVCDFlags(fs)
+}
+
+func newCollectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "collect",
+		Short: "Collect once and write metrics to stdout",
+		Long: `Collect every endpoint once and write InfluxDB line protocol, JSON lines or
+Graphite plaintext to stdout.
+
+Exits 0 when every collector succeeded and 2 when some failed. When all did,
+exits 4 if every login was rejected, 5 if every endpoint was unreachable and
+1 otherwise.`,
+		Args: cobra.NoArgs,
+		RunE: runCollect,
+	}
+
+	cmd.Flags().DurationVar(&progressFlag, "progress", 0, progressDescription)
+	addOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func newServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Collect every interval and write metrics to stdout",
+		Long: `Collect every endpoint every interval and write InfluxDB line protocol or JSON
+lines to stdout, keeping sessions and interned tags across cycles. The
+inventory is enumerated again every cycle, so entities created after startup
+report from the next cycle on, without a restart.
+
+Cycles never overlap: a cycle overrunning the interval either skips the missed
+cycle or queues it to start immediately, as chosen by --overrun.
+
+With --events-file, --events-loki-url or --events-es-url, the vCenter events
+created since the previous cycle are written to those log backends, while
+metrics keep going to stdout and the remote sinks.
+
+With --inventory-dir, a snapshot of the inventory is saved every
+--inventory-interval, for the diff command, and inventory_drift metrics count
+the changes since the previous one. The inventory served by --grpc-listen and
+--api-listen is listed every --inventory-interval too.`,
+		Args: cobra.NoArgs,
+		RunE: runServe,
+	}
+
+	cmd.Flags().DurationVar(&intervalFlag, "interval", time.Minute, intervalDescription)
+	cmd.Flags().StringVar(&overrunFlag, "overrun", "skip", overrunDescription)
+	cmd.Flags().DurationVar(&probeIntervalFlag, "probe-interval", 0, probeIntervalDescription)
+	cmd.Flags().DurationVar(&forecastWindowFlag, "forecast-window", 0, forecastWindowDescription)
+	cmd.Flags().DurationVar(&idleWindowFlag, "idle-window", 0, idleWindowDescription)
+	cmd.Flags().StringVar(&costFileFlag, "cost-file", "", costFileDescription)
+	cmd.Flags().BoolVar(&energyFlag, "energy", false, energyDescription)
+	cmd.Flags().IntVar(&topFlag, "top", 0, topDescription)
+	cmd.Flags().BoolVar(&migrationsFlag, "migrations", false, migrationsDescription)
+	cmd.Flags().BoolVar(&rpoEventsFlag, "rpo-events", false, rpoEventsDescription)
+	cmd.Flags().BoolVar(&createdByFlag, "created-by", false, createdByDescription)
+	cmd.Flags().BoolVar(&tombstonesFlag, "tombstones", false, tombstonesDescription)
+	cmd.Flags().StringVar(&grpcListenFlag, "grpc-listen", "", grpcListenDescription)
+	cmd.Flags().StringVar(&apiListenFlag, "api-listen", "", apiListenDescription)
+	cmd.Flags().StringSliceVar(&alertRuleFlag, "alert-rule", nil, alertRuleDescription)
+	cmd.Flags().StringVar(&alertWebhookFlag, "alert-webhook", "", alertWebhookDescription)
+	cmd.Flags().StringVar(&alertFormatFlag, "alert-format", "generic", alertFormatDescription)
+	cmd.Flags().StringVar(&snmpTargetFlag, "snmp-target", "", snmpTargetDescription)
+	cmd.Flags().StringVar(&snmpCommunityFlag, "snmp-community", "public", snmpCommunityDescription)
+	cmd.Flags().StringVar(&snmpOIDFlag, "snmp-oid", alert.DefaultEnterpriseOID, snmpOIDDescription)
+	cmd.Flags().StringVar(&screenshotsFlag, "screenshots", "", screenshotsDescription)
+	addEventFlags(cmd.Flags())
+	addInventoryFlags(cmd.Flags())
+	addOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func runCollect(cmd *cobra.Command, args []string) error {
+	col, err := newCollector()
+	if err != nil {
+		return err
+	}
+
+	c, err := newCycle(col, 0, flagSinks())
+	if err != nil {
+		closeCollector(col)
+		return configError(err)
+	}
+
+	if progressFlag > 0 {
+		col.Progress = collector.NewProgress()
+		stop := logProgress(col.Progress, progressFlag)
+		defer stop()
+	}
+
+	errs := c.run(cmd.Context())
+	c.flush(cmd.Context())
+	closeCollector(col)
+
+	if err := collectionError(errs, len(col.Endpoints)); err != nil {
+		return err
+	}
+	if c.sinkFailures != 0 {
+		return &exitError{code: exitSink}
+	}
+	return nil
+}
+
+// logProgress logs the progress of p every interval until stopped.
+func logProgress(p *collector.Progress, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r := p.Report()
+				slog.Info("progress", "discovered", r.Discovered, "collected", r.Collected, "elapsed", r.Elapsed.Round(time.Second), "eta", r.ETA.Round(time.Second))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// collectionError returns the exitError of the failures of a collection of
+// endpoints, nil for none. The failures, already logged, are classified when
+// every collector failed for the same kind of reason.
+func collectionError(errs collector.Errors, endpoints int) error {
+	switch {
+	case len(errs) == 0:
+		return nil
+	case collector.FailedCollectors(errs) < endpoints*len(collector.Gatherers):
+		return &exitError{code: exitPartial}
+	}
+
+	kind := collector.Kind(errs[0])
+	for _, err := range errs[1:] {
+		if collector.Kind(err) != kind {
+			kind = collector.OtherError
+			break
+		}
+	}
+
+	switch kind {
+	case collector.AuthError:
+		return &exitError{code: exitAuth}
+	case collector.ConnectivityError:
+		return &exitError{code: exitConnectivity}
+	}
+	return &exitError{code: exitFailure}
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	switch overrunFlag {
+	case "skip", "queue":
+	default:
+		return configError(fmt.Errorf("invalid overrun policy %q", overrunFlag))
+	}
+	if intervalFlag <= 0 {
+		return configError(fmt.Errorf("invalid interval %s", intervalFlag))
+	}
+
+	col, err := newCollector()
+	if err != nil {
+		return err
+	}
+	defer closeCollector(col)
+
+	c, err := newCycle(col, intervalFlag, flagSinks())
+	if err != nil {
+		return configError(err)
+	}
+
+	if c.alerts, err = newDispatchers(); err != nil {
+		return configError(err)
+	}
+
+	if probeIntervalFlag > 0 {
+		c.prober = collector.NewProber(col.Endpoints, probeIntervalFlag)
+		go c.prober.Run(cmd.Context())
+	}
+
+	if screenshotsFlag != "" {
+		store, err := objstore.Open(screenshotsFlag)
+		if err != nil {
+			return configError(err)
+		}
+		c.screenshots = alert.NewScreenshots(col, store)
+	}
+
+	if grpcListenFlag != "" {
+		lis, err := net.Listen("tcp", grpcListenFlag)
+		if err != nil {
+			return configError(err)
+		}
+
+		c.store = &api.Store{}
+		s := grpc.NewServer()
+		api.Register(s, c.store)
+		defer s.Stop()
+
+		slog.Info("serving gRPC API", "addr", lis.Addr())
+		go func() {
+			if err := s.Serve(lis); err != nil {
+				exit(err)
+			}
+		}()
+	}
+
+	if apiListenFlag != "" {
+		lis, err := net.Listen("tcp", apiListenFlag)
+		if err != nil {
+			return configError(err)
+		}
+
+		if c.store == nil {
+			c.store = &api.Store{}
+		}
+		srv := &http.Server{Handler: api.NewHandler(c.store)}
+		defer srv.Close()
+
+		slog.Info("serving REST API", "addr", lis.Addr())
+		go func() {
+			if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+				exit(err)
+			}
+		}()
+	}
+
+	c.loop(cmd.Context())
+	c.flush(cmd.Context())
+	return nil
+}
+
+// cycle runs collection cycles, writing metrics to stdout.
+type cycle struct {
+	col      *collector.Collector
+	interval time.Duration
+
+	cw       *countingWriter
+	renamer  *collector.Renamer
+	sink     sinks.Sink
+	remotes  []namedSink
+	overruns int
+	// sinkFailures counts the failed writes to remotes and events
+	sinkFailures int
+	// store, if set, is updated with the metrics of every cycle and the
+	// inventory every --inventory-interval
+	store *api.Store
+	// alerts are dispatched the metrics of every cycle
+	alerts []*alert.Dispatcher
+	// prober, if set, probes endpoints between cycles, its latest probes
+	// being added to every cycle
+	prober *collector.Prober
+	// screenshots, if set, captures the consoles of virtual machines in trouble
+	screenshots *alert.Screenshots
+	// events, if any, are written the events of every cycle
+	events []namedEventSink
+	// tenants, if set, tags the metrics of virtual machines with their tenant
+	tenants *vcd.Tenants
+	// mapper, if set, tags the metrics of entities with their business context
+	mapper *collector.TagMapper
+	// forecaster, if set, adds the forecast metrics of every cycle
+	forecaster *collector.Forecaster
+	// idle, if set, adds the idle scores of virtual machines every cycle
+	idle *collector.IdleDetector
+	// chargeback, if set, adds the costs of virtual machines every cycle
+	chargeback *collector.Chargeback
+	// energy, if set, adds the power attributed to virtual machines every cycle
+	energy *collector.EnergyMeter
+	// ranker, if set, adds the top virtual machines of clusters every cycle
+	ranker *collector.Ranker
+	// migrations, if set, adds the migrations of the events of every cycle
+	migrations *collector.MigrationTracker
+	// rpo, if set, marks the virtual machines violating their RPO every cycle
+	rpo *collector.RPOTracker
+	// creators, if set, tags the metrics of virtual machines with their creator
+	creators *collector.CreatorTracker
+	// tombstones, if set, marks the end of the series of deleted entities
+	tombstones *collector.Tombstoner
+	// drift, if set, snapshots the inventory and adds its drift metrics
+	drift *driftRecorder
+}
+
+// newCycle returns the cycle of col, every interval, writing to stdout and the
+// remote sinks of the flags, the archive and Parquet ones as of config.
+func newCycle(col *collector.Collector, interval time.Duration, config sinkConfig) (*cycle, error) {
+	enc, err := sinks.NewEncoder(formatFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	renamer, err := collector.NewRenamer(measurementTemplateFlag, tagTemplateFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cycle{
+		col:      col,
+		interval: interval,
+		cw:       &countingWriter{w: os.Stdout},
+		renamer:  renamer,
+	}
+
+	if dryRunFlag {
+		c.sink = sinks.NewDryRun(c.cw, "stdout", enc)
+	} else {
+		c.sink = sinks.NewWriter(c.cw, enc)
+	}
+
+	if c.remotes, err = newRemoteSinks(config); err != nil {
+		return nil, err
+	}
+	if c.events, err = newEventSinks(); err != nil {
+		return nil, err
+	}
+	if c.tenants, err = newTenants(); err != nil {
+		return nil, err
+	}
+	if tagMappingFlag != "" {
+		if c.mapper, err = collector.LoadTagMappings(tagMappingFlag); err != nil {
+			return nil, err
+		}
+	}
+	if forecastWindowFlag > 0 {
+		c.forecaster = collector.NewForecaster(forecastWindowFlag)
+		c.forecaster.Filter = col.Filter
+	}
+	if costFileFlag != "" {
+		costs, err := collector.LoadUnitCosts(costFileFlag)
+		if err != nil {
+			return nil, err
+		}
+		c.chargeback = collector.NewChargeback(costs)
+		c.chargeback.Filter = col.Filter
+	}
+	if energyFlag {
+		c.energy = collector.NewEnergyMeter()
+		c.energy.Filter = col.Filter
+	}
+	if topFlag > 0 {
+		c.ranker = collector.NewRanker(topFlag)
+		c.ranker.Filter = col.Filter
+	}
+	if migrationsFlag {
+		c.migrations = collector.NewMigrationTracker()
+		c.migrations.Filter = col.Filter
+	}
+	if rpoEventsFlag {
+		c.rpo = collector.NewRPOTracker()
+	}
+	if createdByFlag {
+		c.creators = collector.NewCreatorTracker()
+	}
+	if tombstonesFlag {
+		c.tombstones = collector.NewTombstoner()
+	}
+	if c.drift, err = newDriftRecorder(); err != nil {
+		return nil, err
+	}
+	if idleWindowFlag > 0 {
+		c.idle = collector.NewIdleDetector(idleWindowFlag)
+		c.idle.Filter = col.Filter
+	}
+	if dryRunFlag {
+		for i, s := range c.remotes {
+			c.remotes[i].Sink = sinks.NewDryRun(c.cw, s.name, enc)
+		}
+		for i, s := range c.events {
+			c.events[i].EventSink = sinks.NewEventDryRun(c.cw, s.name)
+		}
+	}
+	if err := c.route(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loop runs a cycle every interval until ctx is done.
+func (c *cycle) loop(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	// Cycles never overlap: a tick that fired while a cycle overran is either
+	// dropped or starts the next cycle right away
+	for {
+		c.run(ctx)
+		if overrunFlag == "skip" {
+			select {
+			case <-ticker.C:
+			default:
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// run runs a single cycle, returning its failures. Failing to write to stdout
+// is fatal, failing to write to a remote sink is logged and counted.
+func (c *cycle) run(ctx context.Context) collector.Errors {
+
+	// Every point of a cycle shares the timestamp of its start
+	start := time.Now()
+	written := c.cw.n
+
+	ctx, span := tracer.Start(ctx, "cycle")
+	defer span.End()
+
+	metrics, err := c.col.CollectAt(ctx, start)
+	errs, _ := err.(collector.Errors)
+	if c.tombstones != nil {
+		metrics = append(metrics, c.tombstones.Bury(metrics, errs, start)...)
+	}
+
+	duration := time.Since(start)
+	if c.interval != 0 && duration > c.interval {
+		c.overruns++
+		slog.Warn("collection cycle overran interval", "duration", duration, "interval", c.interval, "policy", overrunFlag, "overruns", c.overruns)
+	}
+
+	records := map[string]interface{}{
+		"duration_sec": duration.Seconds(),
+		"endpoints":    len(c.col.Endpoints),
+		"failures":     len(errs),
+		"overruns":     c.overruns,
+		// Counted once the stages of the cycle added their metrics
+		"series":         0,
+		"series_dropped": 0,
+	}
+	m, err := collector.NewMetric("cycle", nil, records, start)
+	if err != nil {
+		exit(err)
+	}
+	metrics = append(metrics, m)
+
+	// Identifies the build that produced the data
+	m, err = collector.NewMetric("build_info", buildTags(), map[string]interface{}{"value": 1}, start)
+	if err != nil {
+		exit(err)
+	}
+	metrics = append(metrics, m)
+
+	if c.prober != nil {
+		metrics = append(metrics, c.prober.Metrics()...)
+	}
+
+	// Events are collected up to the start of the cycle
+	var events []collector.Event
+	if len(c.events) != 0 || c.migrations != nil || c.rpo != nil || c.creators != nil {
+		var eerrs collector.Errors
+		events, eerrs = c.col.CollectEvents(ctx, start)
+		for _, err := range eerrs {
+			slog.Warn("collecting events failed", "err", err)
+		}
+	}
+
+	if c.rpo != nil {
+		c.rpo.Track(events, metrics)
+	}
+	if c.creators != nil {
+		for _, err := range c.creators.Load(ctx, c.col) {
+			slog.Warn("listing virtual machine creators failed", "err", err)
+		}
+		c.creators.Track(events, metrics)
+	}
+	if c.forecaster != nil {
+		metrics = append(metrics, c.forecaster.Forecast(metrics)...)
+	}
+	if c.idle != nil {
+		metrics = append(metrics, c.idle.Score(metrics)...)
+	}
+	if c.chargeback != nil {
+		metrics = append(metrics, c.chargeback.Cost(metrics)...)
+	}
+	if c.energy != nil {
+		metrics = append(metrics, c.energy.Attribute(metrics)...)
+	}
+	if c.ranker != nil {
+		metrics = append(metrics, c.ranker.Rank(metrics)...)
+	}
+	if c.migrations != nil {
+		metrics = append(metrics, c.migrations.Track(events, start)...)
+	}
+	if c.drift != nil {
+		metrics = append(metrics, c.drift.record(ctx, c.col, start)...)
+	}
+
+	if c.tenants != nil {
+		if err := c.tenants.Tag(ctx, metrics); err != nil {
+			slog.Warn("listing cloud director tenants failed", "err", err)
+		}
+	}
+	if c.mapper != nil {
+		c.mapper.Tag(metrics)
+	}
+
+	for _, d := range c.alerts {
+		if err := d.Dispatch(ctx, metrics); err != nil {
+			slog.Warn("alert dispatch failed", "err", err)
+		}
+	}
+	if c.screenshots != nil {
+		if err := c.screenshots.Capture(ctx, metrics); err != nil {
+			slog.Warn("capturing virtual machine consoles failed", "err", err)
+		}
+	}
+
+	if c.store != nil {
+		// Renaming sets the names of the metrics, not of their copies
+		c.store.SetMetrics(start, append([]collector.Metric(nil), metrics...))
+		// Listing the inventory costs more than a cycle, so it is listed
+		// again every inventory interval only
+		if _, ts := c.store.Inventory(); ts.IsZero() || start.Sub(ts) >= inventoryIntervalFlag {
+			c.store.SetInventory(start, c.inventory(ctx))
+		}
+	}
+
+	var dropped int
+	metrics, dropped = collector.LimitSeries(metrics, maxSeriesFlag)
+	series := 0
+	for _, m := range metrics {
+		series += len(m.Fields)
+	}
+	for i, m := range metrics {
+		if m.Name != "cycle" {
+			continue
+		}
+		// The fields of the stored metric are left alone, as it is served
+		fields := make(map[string]interface{}, len(m.Fields)+2)
+		for k, v := range m.Fields {
+			fields[k] = v
+		}
+		fields["series"] = int64(series)
+		fields["series_dropped"] = int64(dropped)
+		metrics[i].Fields = fields
+	}
+
+	// The metrics the templates fail to name keep their name rather than
+	// stopping a daemon
+	if err := c.renamer.Rename(metrics); err != nil {
+		slog.Warn("renaming metrics failed", "err", err)
+	}
+
+	if err := c.write(ctx, "stdout", c.sink, metrics); err != nil {
+		exit(&exitError{code: exitSink, err: fmt.Errorf("sink stdout: %w", err)})
+	}
+	slog.Debug("wrote points", "sink", "stdout", "bytes", c.cw.n-written)
+
+	for _, s := range c.remotes {
+		if err := c.write(ctx, s.name, s, metrics); err != nil {
+			c.sinkFailures++
+			slog.Warn("write failed", "sink", s.name, "err", err)
+		}
+	}
+
+	if len(c.events) != 0 {
+		for _, s := range c.events {
+			if err := c.writeEvents(ctx, s, events); err != nil {
+				c.sinkFailures++
+				slog.Warn("write failed", "sink", s.name, "err", err)
+			}
+		}
+		slog.Debug("wrote events", "count", len(events))
+	}
+
+	for _, err := range errs {
+		var ce *collector.CollectorError
+		if errors.As(err, &ce) {
+			slog.Warn("collection failed", "endpoint", ce.Endpoint, "collector", ce.Collector, "err", ce.Err)
+		} else {
+			slog.Warn("collection failed", "err", err)
+		}
+	}
+	slog.Info("collection finished", "endpoints", len(c.col.Endpoints), "failures", len(errs), "duration", duration)
+	span.SetAttributes(attribute.Int("vsphere.endpoints", len(c.col.Endpoints)), attribute.Int("vsphere.failures", len(errs)))
+
+	return errs
+}
+
+// write writes metrics to the sink named name, in a span.
+func (c *cycle) write(ctx context.Context, name string, s sinks.Sink, metrics []collector.Metric) (err error) {
+	ctx, span := tracer.Start(ctx, "write "+name, trace.WithAttributes(attribute.Int("vsphere.metrics", len(metrics))))
+	defer func() { endSpan(span, err) }()
+
+	return s.Write(ctx, metrics)
+}
+
+// writeEvents writes events to s, in a span.
+func (c *cycle) writeEvents(ctx context.Context, s namedEventSink, events []collector.Event) (err error) {
+	ctx, span := tracer.Start(ctx, "write events "+s.name, trace.WithAttributes(attribute.Int("vsphere.events", len(events))))
+	defer func() { endSpan(span, err) }()
+
+	return s.WriteEvents(ctx, events)
+}
+
+// newDispatchers returns the alert dispatchers configured by the flags, one
+// per notifier.
+func newDispatchers() ([]*alert.Dispatcher, error) {
+	if alertWebhookFlag == "" && snmpTargetFlag == "" {
+		if len(alertRuleFlag) != 0 {
+			return nil, errors.New("--alert-rule requires --alert-webhook or --snmp-target")
+		}
+		return nil, nil
+	}
+
+	var thresholds []collector.Threshold
+	for _, s := range alertRuleFlag {
+		t, err := collector.ParseThreshold(s)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+
+	var dispatchers []*alert.Dispatcher
+	if alertWebhookFlag != "" {
+		// Webhook URLs, such as Slack's, embed their credentials
+		collector.AddSecret(alertWebhookFlag)
+
+		hook, err := alert.NewWebhook(alertWebhookFlag, alertFormatFlag)
+		if err != nil {
+			return nil, err
+		}
+		dispatchers = append(dispatchers, alert.NewDispatcher(thresholds, hook))
+	}
+	if snmpTargetFlag != "" {
+		trap, err := alert.NewSNMPTrap(snmpTargetFlag, snmpCommunityFlag, snmpOIDFlag)
+		if err != nil {
+			return nil, err
+		}
+		dispatchers = append(dispatchers, alert.NewDispatcher(thresholds, trap))
+	}
+	return dispatchers, nil
+}
+
+// inventory lists the inventory of every endpoint, logging failures.
+func (c *cycle) inventory(ctx context.Context) []collector.InventoryEntry {
+	var entries []collector.InventoryEntry
+	for _, e := range c.col.Endpoints {
+		client, err := e.Client(ctx)
+		if err != nil {
+			slog.Warn("listing inventory failed", "endpoint", e.URL.Host, "err", err)
+			continue
+		}
+
+		inv, err := collector.Inventory(ctx, client)
+		if err != nil {
+			slog.Warn("listing inventory failed", "endpoint", e.URL.Host, "err", err)
+			continue
+		}
+		entries = append(entries, inv...)
+	}
+	return entries
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	config := "strict: warn\nlog-level: debug\ntimeout: 1m\nurl:\n  - https://vc1/sdk\n  - https://vc2/sdk\n"
+	if err := os.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envLogLevel, "error")
+	t.Setenv(envInsecure, "yes")
+
+	cmd := newRootCommand()
+	if err := cmd.ParseFlags([]string{"--config", file, "--timeout", "2m"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if strictFlag != "warn" {
+		t.Errorf("strict=%q, expected config file value", strictFlag)
+	}
+	if logLevelFlag != "error" {
+		t.Errorf("log-level=%q, expected environment value", logLevelFlag)
+	}
+	if timeoutFlag != 2*time.Minute {
+		t.Errorf("timeout=%s, expected command line value", timeoutFlag)
+	}
+	if !insecureFlag {
+		t.Error("insecure=false, expected environment value")
+	}
+	if urlFlag != "https://vc1/sdk,https://vc2/sdk" {
+		t.Errorf("url=%q, expected joined list", urlFlag)
+	}
+}
+
+func TestLoadConfigUnknown(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("urls: https://vc/sdk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadConfig(file, knownFlags(newRootCommand())); err == nil {
+		t.Error("expected unknown setting error")
+	}
+}
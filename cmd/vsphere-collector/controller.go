@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/sinks"
+)
+
+// targetResource is the VSphereTarget custom resource of
+// deploy/kubernetes/vspheretarget-crd.yaml.
+var targetResource = schema.GroupVersionResource{
+	Group:    "vspherecollector.mlabouardy.github.io",
+	Version:  "v1alpha1",
+	Resource: "vspheretargets",
+}
+
+func newControllerCommand() *cobra.Command {
+	var kubeconfig, namespace string
+
+	cmd := &cobra.Command{
+		Use:   "controller",
+		Short: "Serve the VSphereTarget resources of a Kubernetes cluster",
+		Long: `Watch the VSphereTarget resources of a Kubernetes cluster, serving each as
+its own collector: one is started for every target created, restarted when
+its spec changes and stopped when it is deleted. A target that fails to start
+is retried on the next resync.
+
+Targets are collected as by serve, with the flags given here, overridden by
+the spec of each:
+
+  apiVersion: vspherecollector.mlabouardy.github.io/v1alpha1
+  kind: VSphereTarget
+  metadata:
+    name: vc01
+  spec:
+    url: https://vc01.example.com/sdk
+    credentialsSecret: vc01   # Secret of username and password keys
+    insecure: false
+    interval: 1m
+    format: line
+    allowFields: []
+    denyFields: []
+    archive: s3://metrics/vsphere   # or a directory
+    archiveFormat: parquet
+    archiveInterval: 1h
+    parquetDir: /data/parquet
+    parquetRotation: day
+
+The archived objects and Parquet files of every target are partitioned by
+it, as target=<namespace>.<name>/, so that targets sharing a store or
+directory don't overwrite each other. Changes to the Secret of a target take
+effect when it is restarted.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := kubeConfig(kubeconfig)
+			if err != nil {
+				return configError(err)
+			}
+			dyn, err := dynamic.NewForConfig(config)
+			if err != nil {
+				return configError(err)
+			}
+			kube, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				return configError(err)
+			}
+
+			c := &controller{
+				ctx:     cmd.Context(),
+				secrets: kube.CoreV1(),
+				targets: make(map[string]*target),
+			}
+
+			factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dyn, 10*time.Minute, namespace, nil)
+			factory.ForResource(targetResource).Informer().AddEventHandler(c.handlers())
+
+			slog.Info("watching targets", "resource", targetResource.String(), "namespace", namespace)
+			factory.Start(cmd.Context().Done())
+			<-cmd.Context().Done()
+
+			c.removeAll()
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "Kubeconfig file, empty for the in-cluster config")
+	cmd.Flags().StringVar(&namespace, "namespace", "", "Namespace of the targets, empty for all")
+	return cmd
+}
+
+// kubeConfig returns the config of path, the in-cluster config if empty.
+func kubeConfig(path string) (*rest.Config, error) {
+	if path == "" {
+		return rest.InClusterConfig()
+	}
+	return clientcmd.BuildConfigFromFlags("", path)
+}
+
+// targetSpec is the spec of a VSphereTarget.
+type targetSpec struct {
+	URL               string
+	CredentialsSecret string
+	Insecure          bool
+	Interval          time.Duration
+	Format            string
+	AllowFields       []string
+	DenyFields        []string
+	// The sinks of the target, those of the flags when empty
+	Archive         string
+	ArchiveFormat   string
+	ArchiveInterval time.Duration
+	ParquetDir      string
+	ParquetRotation string
+}
+
+// parseTargetSpec returns the spec of u.
+func parseTargetSpec(u *unstructured.Unstructured) (targetSpec, error) {
+	var spec targetSpec
+	var err error
+
+	str := func(field string) string {
+		v, _, ferr := unstructured.NestedString(u.Object, "spec", field)
+		if ferr != nil && err == nil {
+			err = ferr
+		}
+		return v
+	}
+	strs := func(field string) []string {
+		v, _, ferr := unstructured.NestedStringSlice(u.Object, "spec", field)
+		if ferr != nil && err == nil {
+			err = ferr
+		}
+		return v
+	}
+
+	spec.URL = str("url")
+	spec.CredentialsSecret = str("credentialsSecret")
+	spec.Format = str("format")
+	spec.AllowFields = strs("allowFields")
+	spec.DenyFields = strs("denyFields")
+	spec.Archive = str("archive")
+	spec.ArchiveFormat = str("archiveFormat")
+	spec.ParquetDir = str("parquetDir")
+	spec.ParquetRotation = str("parquetRotation")
+	interval := str("interval")
+	archiveInterval := str("archiveInterval")
+
+	insecure, _, ferr := unstructured.NestedBool(u.Object, "spec", "insecure")
+	if ferr != nil && err == nil {
+		err = ferr
+	}
+	spec.Insecure = insecure
+	if err != nil {
+		return spec, err
+	}
+
+	if spec.URL == "" {
+		return spec, fmt.Errorf("missing spec.url")
+	}
+
+	spec.Interval = time.Minute
+	if interval != "" {
+		if spec.Interval, err = time.ParseDuration(interval); err != nil || spec.Interval <= 0 {
+			return spec, fmt.Errorf("invalid spec.interval %q", interval)
+		}
+	}
+	if archiveInterval != "" {
+		if spec.ArchiveInterval, err = time.ParseDuration(archiveInterval); err != nil || spec.ArchiveInterval <= 0 {
+			return spec, fmt.Errorf("invalid spec.archiveInterval %q", archiveInterval)
+		}
+	}
+	return spec, nil
+}
+
+// controller serves a collector per VSphereTarget.
+type controller struct {
+	ctx     context.Context
+	secrets corev1.SecretsGetter
+
+	// out serializes the writes of the targets to stdout
+	out sync.Mutex
+
+	mu      sync.Mutex
+	targets map[string]*target
+	// running counts the targets yet to stop, including those removed and
+	// still finishing their cycle
+	running sync.WaitGroup
+}
+
+// target is the collector serving a VSphereTarget.
+type target struct {
+	generation int64
+	cancel     context.CancelFunc
+}
+
+func targetKey(u *unstructured.Unstructured) string {
+	return u.GetNamespace() + "/" + u.GetName()
+}
+
+// handlers returns the handlers of the events of the informer of the
+// targets.
+func (c *controller) handlers() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.reconcile(obj.(*unstructured.Unstructured))
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			c.reconcile(obj.(*unstructured.Unstructured))
+		},
+		DeleteFunc: func(obj interface{}) {
+			if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = d.Obj
+			}
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				c.remove(targetKey(u))
+			}
+		},
+	}
+}
+
+// reconcile starts the target of u, restarting it if its spec changed.
+func (c *controller) reconcile(u *unstructured.Unstructured) {
+	key := targetKey(u)
+
+	c.mu.Lock()
+	t, ok := c.targets[key]
+	c.mu.Unlock()
+
+	if ok && t.generation == u.GetGeneration() {
+		return
+	}
+	if ok {
+		slog.Info("restarting target", "target", key)
+		c.remove(key)
+	}
+
+	spec, err := parseTargetSpec(u)
+	if err != nil {
+		slog.Error("invalid target", "target", key, "err", err)
+		return
+	}
+
+	t, err = c.start(key, u.GetNamespace(), spec)
+	if err != nil {
+		slog.Error("starting target failed", "target", key, "err", err)
+		return
+	}
+	t.generation = u.GetGeneration()
+
+	c.mu.Lock()
+	c.targets[key] = t
+	c.mu.Unlock()
+	slog.Info("started target", "target", key, "interval", spec.Interval)
+}
+
+// urls returns the URLs of the endpoints of spec, of a target in namespace,
+// with the credentials of its Secret.
+func (c *controller) urls(namespace string, spec targetSpec) ([]*url.URL, error) {
+	urls, err := collector.ParseURLs(spec.URL)
+	if err != nil {
+		return nil, err
+	}
+	if spec.CredentialsSecret != "" {
+		s, err := c.secrets.Secrets(namespace).Get(c.ctx, spec.CredentialsSecret, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range urls {
+			u.User = url.UserPassword(string(s.Data["username"]), string(s.Data["password"]))
+		}
+	}
+	return urls, nil
+}
+
+// start starts serving spec, of a target in namespace.
+func (c *controller) start(key, namespace string, spec targetSpec) (*target, error) {
+	urls, err := c.urls(namespace, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	col, err := newCollectorOf(urls)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range col.Endpoints {
+		e.Options.Insecure = e.Options.Insecure || spec.Insecure
+	}
+	if len(spec.AllowFields) != 0 || len(spec.DenyFields) != 0 {
+		if col.Filter, err = collector.NewFieldFilter(spec.AllowFields, spec.DenyFields); err != nil {
+			return nil, err
+		}
+	}
+
+	config := flagSinks()
+	config.target = strings.Replace(key, "/", ".", 1)
+	if spec.Archive != "" {
+		config.archive = spec.Archive
+	}
+	if spec.ArchiveFormat != "" {
+		config.archiveFormat = spec.ArchiveFormat
+	}
+	if spec.ArchiveInterval != 0 {
+		config.archiveInterval = spec.ArchiveInterval
+	}
+	if spec.ParquetDir != "" {
+		config.parquetDir = spec.ParquetDir
+	}
+	if spec.ParquetRotation != "" {
+		config.parquetRotation = spec.ParquetRotation
+	}
+
+	cy, err := newCycle(col, spec.Interval, config)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Format != "" {
+		enc, err := sinks.NewEncoder(spec.Format)
+		if err != nil {
+			return nil, err
+		}
+		if dryRunFlag {
+			cy.sink = sinks.NewDryRun(cy.cw, key, enc)
+		} else {
+			cy.sink = sinks.NewWriter(cy.cw, enc)
+		}
+	}
+	cy.sink = &lockedSink{mu: &c.out, sink: cy.sink}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	t := &target{cancel: cancel}
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		defer closeCollector(col)
+		cy.loop(ctx)
+		cy.flush(ctx)
+		slog.Info("stopped target", "target", key)
+	}()
+	return t, nil
+}
+
+// remove stops the target key, without waiting for its cycle in progress to
+// finish, so as not to hold up the events of the other targets.
+func (c *controller) remove(key string) {
+	c.mu.Lock()
+	t, ok := c.targets[key]
+	delete(c.targets, key)
+	c.mu.Unlock()
+
+	if ok {
+		t.cancel()
+	}
+}
+
+// removeAll stops every target, waiting for them and those removed before to
+// finish their cycle and flush their sinks.
+func (c *controller) removeAll() {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.targets))
+	for key := range c.targets {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		c.remove(key)
+	}
+	c.running.Wait()
+}
+
+// lockedSink is a Sink holding mu while writing.
+type lockedSink struct {
+	mu   *sync.Mutex
+	sink sinks.Sink
+}
+
+func (s *lockedSink) Write(ctx context.Context, metrics []collector.Metric) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sink.Write(ctx, metrics)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseTargetSpec(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"url":             "https://vc01/sdk",
+			"insecure":        true,
+			"interval":        "30s",
+			"denyFields":      []interface{}{"vm.uptime_sec"},
+			"archive":         "s3://metrics/vsphere",
+			"archiveInterval": "2h",
+		},
+	}}
+
+	spec, err := parseTargetSpec(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.URL != "https://vc01/sdk" || !spec.Insecure || spec.Interval != 30*time.Second || len(spec.DenyFields) != 1 ||
+		spec.Archive != "s3://metrics/vsphere" || spec.ArchiveInterval != 2*time.Hour {
+		t.Errorf("spec %+v", spec)
+	}
+
+	unstructured.RemoveNestedField(u.Object, "spec", "url")
+	if _, err := parseTargetSpec(u); err == nil {
+		t.Error("expected missing url error")
+	}
+}
+
+func TestControllerURLs(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vc", Namespace: "default"},
+		Data:       map[string][]byte{"username": []byte("user"), "password": []byte("p,ss@")},
+	}
+	c := &controller{ctx: context.Background(), secrets: kubefake.NewSimpleClientset(secret).CoreV1()}
+
+	// The credentials apply to every URL, whatever their password
+	urls, err := c.urls("default", targetSpec{URL: "https://vc01/sdk, https://vc02/sdk", CredentialsSecret: "vc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 2 {
+		t.Fatalf("urls %v", urls)
+	}
+	for _, u := range urls {
+		if password, _ := u.User.Password(); u.User.Username() != "user" || password != "p,ss@" {
+			t.Errorf("%s: user %v", u.Host, u.User)
+		}
+	}
+
+	if _, err := c.urls("default", targetSpec{URL: "https://vc01/sdk", CredentialsSecret: "missing"}); err == nil {
+		t.Error("expected missing secret error")
+	}
+}
+
+func TestControllerReconcile(t *testing.T) {
+	// The defaults of the flags
+	newRootCommand()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	archive := t.TempDir()
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "vspherecollector.mlabouardy.github.io/v1alpha1",
+		"kind":       "VSphereTarget",
+		"metadata":   map[string]interface{}{"name": "vc01", "namespace": "default"},
+		"spec": map[string]interface{}{
+			// Cycles fail to connect, still writing the metrics of the collector
+			"url":               "https://127.0.0.1:1/sdk",
+			"credentialsSecret": "vc01",
+			"interval":          "1h",
+			"archive":           archive,
+			"archiveFormat":     "ndjson",
+		},
+	}}
+	u.SetGeneration(1)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vc01", Namespace: "default"},
+		Data:       map[string][]byte{"username": []byte("user"), "password": []byte("pass")},
+	}
+
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{targetResource: "VSphereTargetList"}, u)
+	c := &controller{ctx: ctx, secrets: kubefake.NewSimpleClientset(secret).CoreV1(), targets: make(map[string]*target)}
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(dyn, 0)
+	factory.ForResource(targetResource).Informer().AddEventHandler(c.handlers())
+	factory.Start(ctx.Done())
+
+	// generation returns that of the target served, 0 for none
+	generation := func() int64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if t, ok := c.targets["default/vc01"]; ok {
+			return t.generation
+		}
+		return 0
+	}
+	waitFor := func(what string, f func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(10 * time.Second); !f(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for the target to %s", what)
+			}
+		}
+	}
+	waitFor("start", func() bool { return generation() == 1 })
+
+	targets := dyn.Resource(targetResource).Namespace("default")
+	u.SetGeneration(2)
+	if _, err := targets.Update(ctx, u, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("restart", func() bool { return generation() == 2 })
+
+	if err := targets.Delete(ctx, "vc01", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("stop", func() bool { return generation() == 0 })
+
+	// The stopped targets flush their archive, partitioned by target
+	waited := make(chan struct{})
+	go func() {
+		c.removeAll()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the targets to flush")
+	}
+	matches, err := filepath.Glob(filepath.Join(archive, "target=default.vc01", "measurement=cycle", "date=*", "*.ndjson.gz"))
+	if err != nil || len(matches) == 0 {
+		t.Errorf("archived cycles %q: %v", matches, err)
+	}
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err != nil || fi.Size() == 0 {
+			t.Errorf("%s: %v", m, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/govmomi/performance"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func newCountersCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "counters",
+		Short: "List the performance counters of every endpoint",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ENDPOINT\tCOUNTER\tUNIT\tLEVEL\tDESCRIPTION")
+
+			var errs collector.Errors
+			for _, e := range col.Endpoints {
+				c, err := e.Client(cmd.Context())
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				counters, err := performance.NewManager(c.Client).CounterInfoByName(cmd.Context())
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				names := make([]string, 0, len(counters))
+				for name := range counters {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				for _, name := range names {
+					info := counters[name]
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", e.URL.Host, name, info.UnitInfo.GetElementDescription().Key, info.Level, info.NameInfo.GetElementDescription().Summary)
+				}
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if len(errs) != 0 {
+				return errs
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/sinks"
+)
+
+func newDashboardsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dashboards",
+		Short: "Generate Grafana dashboards of the metrics",
+	}
+
+	cmd.AddCommand(newDashboardsExportCommand())
+	return cmd
+}
+
+func newDashboardsExportCommand() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write Grafana dashboards of the metrics as JSON files",
+		Long: `Write a Grafana dashboard of the datastores, hosts and virtual machines, with a
+panel per field kept by --allow-field and --deny-field, and one of the
+collector itself, as JSON files of --dir for a Grafana dashboard provider.
+
+Dashboards query the metrics as written with --format, --measurement-template
+and --tag-template: line from an InfluxDB 1.x data source or graphite-tags
+from a Graphite one. They have variables of the data source, vCenter,
+datacenter, cluster of hosts and entity names, extracted from the vcenter and
+path tags.`,
+		Example: "  vsphere-collector dashboards export --format graphite-tags --dir /etc/grafana/dashboards",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := collector.NewFieldFilter(allowFieldFlag, denyFieldFlag)
+			if err != nil {
+				return configError(err)
+			}
+			renamer, err := collector.NewRenamer(measurementTemplateFlag, tagTemplateFlag)
+			if err != nil {
+				return configError(err)
+			}
+
+			schema := collector.Schema(filter)
+			// Dashboards filter on the vcenter and path tags
+			names, err := measurementNames(renamer, schema, "vcenter", "path")
+			if err != nil {
+				return configError(err)
+			}
+			dashboards, err := sinks.GrafanaDashboards(formatFlag, schema, names)
+			if err != nil {
+				return configError(err)
+			}
+
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			for _, d := range dashboards {
+				b, err := json.MarshalIndent(d, "", "  ")
+				if err != nil {
+					return err
+				}
+				path := filepath.Join(dir, d.UID+".json")
+				if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+					return err
+				}
+				slog.Info("wrote dashboard", "title", d.Title, "path", path)
+			}
+			return nil
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&formatFlag, "format", "line", formatDescription)
+	fs.StringVar(&measurementTemplateFlag, "measurement-template", "", measurementTemplateDescription)
+	fs.StringSliceVar(&tagTemplateFlag, "tag-template", nil, tagTemplateDescription)
+	fs.StringVar(&dir, "dir", ".", "Directory to write the dashboards to")
+	return cmd
+}
+
+// entityTypes are the managed object types of the entities of measurements,
+// for templates renaming by type.
+var entityTypes = map[string]string{
+	"datastore":        "Datastore",
+	"datastore_host":   "Datastore",
+	"cluster":          "ClusterComputeResource",
+	"cluster_power":    "ClusterComputeResource",
+	"host":             "HostSystem",
+	"host_power":       "HostSystem",
+	"host_firmware":    "HostSystem",
+	"host_nic":         "HostSystem",
+	"host_hba":         "HostSystem",
+	"host_hardware":    "HostSystem",
+	"host_pmem":        "HostSystem",
+	"host_memory_tier": "HostSystem",
+	"host_numa":        "HostSystem",
+	"host_numa_node":   "HostSystem",
+	"host_sched":       "HostSystem",
+	"host_security":    "HostSystem",
+	"vm":               "VirtualMachine",
+	"vm_perf":          "VirtualMachine",
+	"vm_idle":          "VirtualMachine",
+	"vm_security":      "VirtualMachine",
+	"vm_protection":    "VirtualMachine",
+	"vm_disk":          "VirtualMachine",
+	"vm_energy":        "VirtualMachine",
+}
+
+// measurementNames returns the names renamer gives the measurements of
+// schema, for generating queries. Queries need a single name per measurement,
+// and the tags of fixed not to be changed by tag templates.
+func measurementNames(renamer *collector.Renamer, schema []collector.MeasurementSchema, fixed ...string) (map[string]string, error) {
+	names := make(map[string]string, len(schema))
+	for _, ms := range schema {
+		var metrics []collector.Metric
+		for _, vc := range []string{"vc1", "vc2"} {
+			m := collector.Metric{
+				Name: ms.Measurement,
+				Tags: map[string]string{"vcenter": vc, "name": vc + "-entity", "path": "/" + vc + "-dc/" + vc + "-entity"},
+			}
+			if t, ok := entityTypes[ms.Measurement]; ok {
+				m.Entity = &collector.EntityRef{VCenter: vc, Type: t}
+			}
+			metrics = append(metrics, m)
+		}
+
+		original := []map[string]string{metrics[0].Tags, metrics[1].Tags}
+		if err := renamer.Rename(metrics); err != nil {
+			return nil, err
+		}
+		if metrics[0].Name != metrics[1].Name {
+			return nil, fmt.Errorf("measurement template: the name of %s depends on the entity, queries need a single one", ms.Measurement)
+		}
+		for i, m := range metrics {
+			for _, k := range fixed {
+				if m.Tags[k] != original[i][k] {
+					return nil, fmt.Errorf("tag template: queries filter on the %s tag, it cannot be set", k)
+				}
+			}
+		}
+		names[ms.Measurement] = metrics[0].Name
+	}
+	return names, nil
+}
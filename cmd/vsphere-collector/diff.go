@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+var inventoryDirDescription = fmt.Sprintf("Save a snapshot of the inventory to this directory every --inventory-interval and emit inventory_drift metrics of the changes since the previous one [%s]", envInvDir)
+var inventoryDirFlag string
+
+var inventoryIntervalDescription = fmt.Sprintf("Interval of the inventory snapshots of --inventory-dir, and of the inventory served by --grpc-listen and --api-listen [%s]", envInvIntvl)
+var inventoryIntervalFlag time.Duration
+
+func addInventoryFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&inventoryDirFlag, "inventory-dir", "", inventoryDirDescription)
+	fs.DurationVar(&inventoryIntervalFlag, "inventory-interval", time.Hour, inventoryIntervalDescription)
+}
+
+// driftRecorder snapshots the inventory of every endpoint to a directory,
+// with the changes since the previous snapshot as inventory_drift metrics.
+type driftRecorder struct {
+	dir      string
+	interval time.Duration
+	prev     *collector.InventorySnapshot
+}
+
+// newDriftRecorder returns the driftRecorder of the flags, nil for none,
+// diffing the first snapshot with the latest one of the directory.
+func newDriftRecorder() (*driftRecorder, error) {
+	if inventoryDirFlag == "" {
+		return nil, nil
+	}
+
+	r := &driftRecorder{dir: inventoryDirFlag, interval: inventoryIntervalFlag}
+	p, err := collector.FindInventorySnapshot(r.dir, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if p != "" {
+		if r.prev, err = collector.LoadInventorySnapshot(p); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// record snapshots the inventory of the endpoints of col at ts, unless the
+// previous snapshot is less than an interval old, returning the drift
+// metrics of the changes since the previous one. Endpoints that cannot be
+// listed keep their previous entities.
+func (r *driftRecorder) record(ctx context.Context, col *collector.Collector, ts time.Time) []collector.Metric {
+	if r.prev != nil && ts.Sub(r.prev.Time) < r.interval {
+		return nil
+	}
+
+	var entries []collector.InventoryEntry
+	var failed []string
+	for _, e := range col.Endpoints {
+		c, err := e.Client(ctx)
+		if err == nil {
+			var inv []collector.InventoryEntry
+			if inv, err = collector.Inventory(ctx, c); err == nil {
+				entries = append(entries, inv...)
+				continue
+			}
+		}
+		slog.Warn("inventory snapshot failed", "endpoint", e.URL.Host, "err", err)
+		failed = append(failed, e.URL.Host)
+	}
+
+	snapshot := collector.NewInventorySnapshot(ts, entries)
+	snapshot.Carry(r.prev, failed)
+	p, err := collector.SaveInventorySnapshot(r.dir, snapshot)
+	if err != nil {
+		slog.Warn("saving inventory snapshot failed", "err", err)
+	} else {
+		slog.Debug("saved inventory snapshot", "path", p, "entities", len(snapshot.Entities))
+	}
+
+	prev := r.prev
+	r.prev = snapshot
+	if prev == nil {
+		return nil
+	}
+
+	metrics, err := collector.DriftMetrics(snapshot, collector.DiffInventory(prev, snapshot))
+	if err != nil {
+		slog.Warn("inventory drift failed", "err", err)
+		return nil
+	}
+	for _, m := range metrics {
+		for k := range m.Fields {
+			if !col.Filter.Keep("inventory_drift", k) {
+				delete(m.Fields, k)
+			}
+		}
+	}
+	return metrics
+}
+
+func newDiffCommand() *cobra.Command {
+	var dir, from, to, output string
+
+	cmd := &cobra.Command{
+		Use:   "diff [old new]",
+		Short: "Show the inventory changes between two inventory snapshots",
+		Long: `Show the virtual machines and hosts created, deleted or moved to another folder
+or cluster, virtual machines whose vCPUs or memory changed and hosts moved to
+another cluster, between two inventory snapshots as saved by serve
+--inventory-dir.
+
+Snapshots are either the two files given as arguments or the latest ones of
+--dir taken at or before --from and --to, times such as 2024-05-01T00:00:00Z
+or durations ago such as 168h.`,
+		Example: "  vsphere-collector diff --dir /var/lib/vsphere-collector/inventory --from 168h",
+		Args:    cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				return configError(fmt.Errorf("diff takes either two snapshots or none"))
+			}
+			if output != "table" && output != "json" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+
+			paths := args
+			if len(paths) == 0 {
+				if dir == "" {
+					return configError(fmt.Errorf("either two snapshots or --dir is required"))
+				}
+				for _, at := range []string{from, to} {
+					ts, err := parseTimeAgo(at)
+					if err != nil {
+						return configError(err)
+					}
+					p, err := collector.FindInventorySnapshot(dir, ts)
+					if err != nil {
+						return err
+					}
+					if p == "" {
+						return fmt.Errorf("no inventory snapshot of %s at or before %s", dir, ts.Format(time.RFC3339))
+					}
+					paths = append(paths, p)
+				}
+			}
+
+			var snapshots [2]*collector.InventorySnapshot
+			for i, p := range paths {
+				s, err := collector.LoadInventorySnapshot(p)
+				if err != nil {
+					return err
+				}
+				snapshots[i] = s
+			}
+			changes := collector.DiffInventory(snapshots[0], snapshots[1])
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(changes)
+			}
+			return writeChanges(os.Stdout, changes)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&dir, "dir", "", "Directory of the inventory snapshots")
+	fs.StringVar(&from, "from", "24h", "Time of the old snapshot")
+	fs.StringVar(&to, "to", "0s", "Time of the new snapshot")
+	fs.StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	return cmd
+}
+
+// parseTimeAgo parses an RFC 3339 time or a duration ago.
+func parseTimeAgo(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: neither RFC 3339 nor a duration", s)
+	}
+	return ts, nil
+}
+
+// writeChanges writes changes as a table.
+func writeChanges(w io.Writer, changes []collector.InventoryChange) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tKIND\tNAME\tCHANGE\tDETAIL")
+	for _, c := range changes {
+		var detail []string
+		if c.From != "" {
+			detail = append(detail, c.From)
+		}
+		if c.To != "" {
+			detail = append(detail, c.To)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Endpoint, c.Kind, c.Name, c.Change, strings.Join(detail, " -> "))
+	}
+	return tw.Flush()
+}
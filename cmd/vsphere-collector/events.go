@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/sinks"
+)
+
+var eventsFileDescription = fmt.Sprintf("Append the vCenter events of every cycle to this file as JSON lines [%s]", envEvFile)
+var eventsFileFlag string
+
+var eventsLokiURLDescription = fmt.Sprintf("Base URL of a Grafana Loki to push the vCenter events of every cycle to [%s]", envEvLoki)
+var eventsLokiURLFlag string
+
+var eventsESURLDescription = fmt.Sprintf("Base URL of an Elasticsearch or OpenSearch cluster to index the vCenter events of every cycle into, with any credentials [%s]", envEvES)
+var eventsESURLFlag string
+
+var eventsESIndexDescription = fmt.Sprintf("Index of --events-es-url [%s]", envEvIndex)
+var eventsESIndexFlag string
+
+// addEventFlags adds the flags of the event sinks to fs.
+func addEventFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&eventsFileFlag, "events-file", "", eventsFileDescription)
+	fs.StringVar(&eventsLokiURLFlag, "events-loki-url", "", eventsLokiURLDescription)
+	fs.StringVar(&eventsESURLFlag, "events-es-url", "", eventsESURLDescription)
+	fs.StringVar(&eventsESIndexFlag, "events-es-index", "vsphere-events", eventsESIndexDescription)
+}
+
+// namedEventSink is an event sink, named in logs.
+type namedEventSink struct {
+	name string
+	sinks.EventSink
+}
+
+// newEventSinks returns the event sinks configured by the flags.
+func newEventSinks() ([]namedEventSink, error) {
+	var events []namedEventSink
+
+	if eventsFileFlag != "" {
+		events = append(events, namedEventSink{name: "file", EventSink: sinks.NewEventFile(eventsFileFlag)})
+	}
+	if eventsLokiURLFlag != "" {
+		loki, err := sinks.NewLoki(eventsLokiURLFlag)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, namedEventSink{name: "loki", EventSink: loki})
+	}
+	if eventsESURLFlag != "" {
+		es, err := sinks.NewElasticsearch(eventsESURLFlag, eventsESIndexFlag)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, namedEventSink{name: "elasticsearch", EventSink: es})
+	}
+	return events, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/vmware/govmomi/find"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/sinks"
+)
+
+// explanation is a collector.Explanation with the serialization of its
+// renamed metric.
+type explanation struct {
+	*collector.Explanation
+	Serialized string `json:"serialized"`
+}
+
+func newExplainCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "explain KIND NAME",
+		Short: "Show everything collected for a single entity",
+		Long: `Show everything collected for a single datastore, host or virtual machine
+named NAME, or at inventory path NAME: the properties retrieved, the tags and
+fields of its metric, the fields dropped by --allow-field and --deny-field,
+the performance counters available on it and its serialization as written
+with --format, --measurement-template and --tag-template.
+
+A relative NAME is looked up in the default datacenter of every endpoint.`,
+		Example:   "  vsphere-collector explain vm my-vm-01",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: collector.ExplainKinds,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+
+			enc, err := sinks.NewEncoder(formatFlag)
+			if err != nil {
+				return configError(err)
+			}
+			renamer, err := collector.NewRenamer(measurementTemplateFlag, tagTemplateFlag)
+			if err != nil {
+				return configError(err)
+			}
+
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			var xs []explanation
+			var errs collector.Errors
+			for _, e := range col.Endpoints {
+				x, err := col.Explain(cmd.Context(), e, args[0], args[1])
+				var nf *find.NotFoundError
+				if errors.As(err, &nf) {
+					continue
+				} else if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", e.URL.Host, err))
+					continue
+				}
+
+				metrics := []collector.Metric{x.Metric}
+				if err := renamer.Rename(metrics); err != nil {
+					return err
+				}
+				var buf bytes.Buffer
+				if err := enc.Encode(&buf, metrics); err != nil {
+					return err
+				}
+				xs = append(xs, explanation{Explanation: x, Serialized: buf.String()})
+			}
+
+			if len(xs) == 0 && len(errs) == 0 {
+				return fmt.Errorf("%s %s not found", args[0], args[1])
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(xs)
+			} else {
+				err = writeExplanations(os.Stdout, xs)
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(errs) != 0 {
+				return errs
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	return cmd
+}
+
+// writeExplanations writes xs as text, tags and fields sorted by name.
+func writeExplanations(w io.Writer, xs []explanation) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for i, x := range xs {
+		if i != 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "Entity:\t%s\n", x.Entity.String())
+		fmt.Fprintf(tw, "Path:\t%s\n", x.Path)
+		fmt.Fprintf(tw, "Measurement:\t%s\n", x.Metric.Name)
+
+		fmt.Fprintln(tw, "\nTags:")
+		for _, k := range sortedKeys(x.Metric.Tags) {
+			fmt.Fprintf(tw, "  %s\t%s\n", k, x.Metric.Tags[k])
+		}
+
+		fmt.Fprintln(tw, "\nFields:")
+		fields := make([]string, 0, len(x.Metric.Fields))
+		for k := range x.Metric.Fields {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		for _, k := range fields {
+			fmt.Fprintf(tw, "  %s\t%v\t%s\n", k, x.Metric.Fields[k], collector.Fields[x.Metric.Name][k])
+		}
+		if len(x.Dropped) != 0 {
+			fmt.Fprintf(tw, "\nDropped fields:\t%s\n", strings.Join(x.Dropped, " "))
+		}
+
+		// Flush before the untabulated sections
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		props, err := json.MarshalIndent(x.Properties, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nProperties:\n  %s\n", props)
+
+		fmt.Fprintln(w, "\nPerformance counters available, not collected:")
+		for _, name := range x.Counters {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+
+		fmt.Fprintf(w, "\nSerialized as %s:\n%s", formatFlag, x.Serialized)
+	}
+	return tw.Flush()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
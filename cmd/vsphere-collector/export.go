@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/cmdb"
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+var snowInstanceDescription = fmt.Sprintf("Base URL of the ServiceNow instance, such as https://example.service-now.com [%s]", envSnowURL)
+var snowInstanceFlag string
+
+var snowUsernameDescription = fmt.Sprintf("ServiceNow username [%s]", envSnowUser)
+var snowUsernameFlag string
+
+var snowPasswordDescription = fmt.Sprintf("ServiceNow password [%s]", envSnowPass)
+var snowPasswordFlag string
+
+var snowAPIDescription = fmt.Sprintf("ServiceNow API: table to upsert CIs by correlation_id or import-set to insert them into --snow-import-table [%s]", envSnowAPI)
+var snowAPIFlag string
+
+var snowImportTableDescription = fmt.Sprintf("Import set staging table of --snow-api import-set [%s]", envSnowTbl)
+var snowImportTableFlag string
+
+func newExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the inventory or configuration of every endpoint",
+	}
+
+	cmd.AddCommand(newExportCMDBCommand(), newExportAlarmsCommand())
+	return cmd
+}
+
+func newExportAlarmsCommand() *cobra.Command {
+	var baseline, output string
+
+	cmd := &cobra.Command{
+		Use:   "alarms",
+		Short: "Export the alarm definitions of every endpoint as JSON, or their drift from a baseline",
+		Long: `Export the alarm definitions of every endpoint as JSON, with the inventory path
+of the entity each is defined on, its expressions and its actions, to keep
+alarm configuration under version control.
+
+With --baseline, a file as exported, print the alarms created, deleted or
+changed since instead, and exit with 1 if any drifted. The alarms of endpoints
+that could not be exported are not compared.`,
+		Example: `  vsphere-collector export alarms > alarms.json
+  vsphere-collector export alarms --baseline alarms.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+			var base []collector.AlarmDefinition
+			if baseline != "" {
+				var err error
+				if base, err = collector.LoadAlarms(baseline); err != nil {
+					return configError(err)
+				}
+			}
+
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			defs := []collector.AlarmDefinition{}
+			var errs collector.Errors
+			for _, e := range col.Endpoints {
+				c, err := e.Client(cmd.Context())
+				if err == nil {
+					var alarms []collector.AlarmDefinition
+					if alarms, err = collector.Alarms(cmd.Context(), c); err == nil {
+						defs = append(defs, alarms...)
+						continue
+					}
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", e.URL.Host, err))
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if baseline == "" {
+				if err := enc.Encode(defs); err != nil {
+					return err
+				}
+			} else {
+				drift := collector.DiffAlarms(base, defs)
+				if output == "json" {
+					err = enc.Encode(drift)
+				} else {
+					err = writeAlarmDrift(os.Stdout, drift)
+				}
+				if err != nil {
+					return err
+				}
+				if len(drift) != 0 {
+					return &exitError{code: exitFailure}
+				}
+			}
+
+			if len(errs) != 0 {
+				return errs
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&baseline, "baseline", "", "Exported alarm definitions to report the drift from")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format of the drift of --baseline: table or json")
+	return cmd
+}
+
+// writeAlarmDrift writes drift as a table.
+func writeAlarmDrift(w io.Writer, drift []collector.AlarmDrift) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tENTITY\tALARM\tCHANGE\tFIELDS")
+	for _, d := range drift {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Endpoint, d.Entity, d.Name, d.Change, strings.Join(d.Fields, ","))
+	}
+	return tw.Flush()
+}
+
+func newExportCMDBCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "cmdb",
+		Short: "Export the inventory of every endpoint to the ServiceNow CMDB",
+		Long: `Export the datacenters, clusters, hosts, datastores and virtual machines of
+every endpoint to the ServiceNow CMDB as CIs of the cmdb_ci_vcenter_datacenter,
+cmdb_ci_vcenter_cluster, cmdb_ci_esx_server, cmdb_ci_vcenter_datastore and
+cmdb_ci_vmware_instance classes, correlated by endpoint/MOID.
+
+With --snow-api table, CIs are created or updated in the table of their class.
+With --snow-api import-set, they are inserted into --snow-import-table, of
+their class in sys_class_name, for its transform map to reconcile.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := cmdb.ParseAPI(snowAPIFlag)
+			if err != nil {
+				return configError(err)
+			}
+			if snowInstanceFlag == "" && !dryRun {
+				return configError(fmt.Errorf("--snow-instance is required"))
+			}
+			collector.AddSecret(snowPasswordFlag)
+
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			entries, errs := listInventory(cmd.Context(), col)
+			cis := cmdb.FromInventory(entries)
+
+			if dryRun {
+				enc := json.NewEncoder(os.Stdout)
+				for _, ci := range cis {
+					if err := enc.Encode(ci); err != nil {
+						return err
+					}
+				}
+			} else {
+				client := &cmdb.Client{
+					Instance:       snowInstanceFlag,
+					Username:       snowUsernameFlag,
+					Password:       snowPasswordFlag,
+					API:            api,
+					ImportSetTable: snowImportTableFlag,
+				}
+
+				failed := 0
+				for _, ci := range cis {
+					if err := client.Upsert(cmd.Context(), ci); err != nil {
+						failed++
+						slog.Warn("exporting CI failed", "class", ci.Class, "correlation_id", ci.CorrelationID, "err", err)
+					}
+				}
+				slog.Info("exported CIs", "count", len(cis)-failed, "failed", failed)
+				if failed != 0 {
+					return &exitError{code: exitSink, err: fmt.Errorf("%d of %d CIs failed to export", failed, len(cis))}
+				}
+			}
+
+			if len(errs) != 0 {
+				return errs
+			}
+			return nil
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&snowInstanceFlag, "snow-instance", "", snowInstanceDescription)
+	fs.StringVar(&snowUsernameFlag, "snow-username", "", snowUsernameDescription)
+	fs.StringVar(&snowPasswordFlag, "snow-password", "", snowPasswordDescription)
+	fs.StringVar(&snowAPIFlag, "snow-api", "table", snowAPIDescription)
+	fs.StringVar(&snowImportTableFlag, "snow-import-table", "u_vsphere_collector_import", snowImportTableDescription)
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the CIs as JSON lines instead of exporting them")
+	return cmd
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/ansible"
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func newInventoryCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "List the datacenters, clusters, hosts, datastores and virtual machines of every endpoint",
+		Long: `List the datacenters, clusters, hosts, datastores and virtual machines of
+every endpoint with their key summary fields, to verify connectivity and what
+the collector can see before wiring up sinks.
+
+With --format ansible, print the virtual machines as an Ansible dynamic
+inventory, grouped by cluster, power state and vSphere tag, with their guest
+IP address as ansible_host and their custom attributes and tags as variables.
+Listing tags requires credentials in the URL of the endpoints.`,
+		Example: "  vsphere-collector inventory --format ansible > inventory.json",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "table" && format != "json" && format != "ansible" {
+				return configError(fmt.Errorf("invalid inventory format %q", format))
+			}
+
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			entries, errs := listInventory(cmd.Context(), col)
+
+			switch format {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(entries)
+			case "ansible":
+				for _, e := range col.Endpoints {
+					// Groups of tags are left out rather than failing
+					if err := collector.InventoryTags(cmd.Context(), e, entries); err != nil {
+						slog.Warn("listing tags failed", "endpoint", e.URL.Host, "err", err)
+					}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(ansible.FromInventory(entries))
+			default:
+				err = writeInventory(os.Stdout, entries)
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(errs) != 0 {
+				return errs
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "Inventory format: table, json or ansible")
+	return cmd
+}
+
+// listInventory lists the inventory of every endpoint of col, returning the
+// failures of those that could not be listed.
+func listInventory(ctx context.Context, col *collector.Collector) ([]collector.InventoryEntry, collector.Errors) {
+	var entries []collector.InventoryEntry
+	var errs collector.Errors
+	for _, e := range col.Endpoints {
+		c, err := e.Client(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		inv, err := collector.Inventory(ctx, c)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entries = append(entries, inv...)
+	}
+	return entries, errs
+}
+
+// writeInventory writes entries as a table, summary fields sorted by name.
+func writeInventory(w io.Writer, entries []collector.InventoryEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tKIND\tPATH\tMOID\tSUMMARY")
+
+	for _, e := range entries {
+		keys := make([]string, 0, len(e.Summary))
+		for k := range e.Summary {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		summary := make([]string, len(keys))
+		for i, k := range keys {
+			summary[i] = fmt.Sprintf("%s=%v", k, e.Summary[k])
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Endpoint, e.Kind, e.Path, e.MOID, strings.Join(summary, " "))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// NewLogger returns a logger writing to w at the given level ("debug",
+// "info", "warn" or "error") in the given format ("console" or "json").
+// Secrets are redacted from everything it logs.
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch strings.ToLower(format) {
+	case "console", "text", "":
+		return slog.New(collector.NewRedactHandler(slog.NewTextHandler(w, opts))), nil
+	case "json":
+		return slog.New(collector.NewRedactHandler(slog.NewJSONHandler(w, opts))), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q", format)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
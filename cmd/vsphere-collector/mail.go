@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/report"
+)
+
+var smtpAddrDescription = fmt.Sprintf("host:port of the SMTP server to mail reports through, upgraded with STARTTLS when supported [%s]", envSMTPAddr)
+var smtpAddrFlag string
+
+var smtpUsernameDescription = fmt.Sprintf("SMTP username, none for no authentication [%s]", envSMTPUser)
+var smtpUsernameFlag string
+
+var smtpPasswordDescription = fmt.Sprintf("SMTP password [%s]", envSMTPPass)
+var smtpPasswordFlag string
+
+var mailFromDescription = fmt.Sprintf("Sender of the report mails [%s]", envMailFrom)
+var mailFromFlag string
+
+var mailToDescription = fmt.Sprintf("Comma separated recipients of the report mails [%s]", envMailTo)
+var mailToFlag []string
+
+var reportScheduleDescription = fmt.Sprintf("Cron schedule of the report mails in local time, such as \"0 7 * * 1\" for Mondays at 7:00 [%s]", envSchedule)
+var reportScheduleFlag string
+
+// mailReports are the reports report mail can send.
+var mailReports = []string{"capacity", "snapshots", "rightsizing"}
+
+func newReportMailCommand() *cobra.Command {
+	var (
+		reports []string
+		inputs  []string
+		subject string
+		once    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "mail",
+		Short: "Mail reports on a cron schedule",
+		Long: `Mail the --reports to --mail-to every --schedule, until interrupted: the
+capacity and snapshots reports as HTML and the rightsizing report as CSV
+attachments, with the defaults of their report commands.
+
+The capacity and rightsizing reports are of the metrics of the files matching
+the --input patterns, matched again for every mail so files written since
+are included. The snapshots report lists the snapshots of every endpoint.
+With --once, the reports are mailed right away instead.`,
+		Example: `  vsphere-collector report mail --schedule "0 7 * * 1" --input '/var/lib/vsphere-collector/metrics-*.json' \
+    --smtp-addr smtp.example.com:587 --mail-from vsphere@example.com --mail-to ops@example.com`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, r := range reports {
+				if !slices.Contains(mailReports, r) {
+					return configError(fmt.Errorf("unknown report %q, expected one of %s", r, strings.Join(mailReports, ", ")))
+				}
+				if r != "snapshots" && len(inputs) == 0 {
+					return configError(fmt.Errorf("the %s report requires --input", r))
+				}
+			}
+			if smtpAddrFlag == "" || mailFromFlag == "" || len(mailToFlag) == 0 {
+				return configError(fmt.Errorf("--smtp-addr, --mail-from and --mail-to are required"))
+			}
+			var schedule *report.Schedule
+			if !once {
+				var err error
+				if schedule, err = report.ParseSchedule(reportScheduleFlag); err != nil {
+					return configError(err)
+				}
+			}
+			collector.AddSecret(smtpPasswordFlag)
+
+			mailer := &report.Mailer{
+				Addr:     smtpAddrFlag,
+				Username: smtpUsernameFlag,
+				Password: smtpPasswordFlag,
+				From:     mailFromFlag,
+				To:       mailToFlag,
+			}
+			send := func(ctx context.Context) error {
+				attachments, err := renderReports(ctx, reports, inputs)
+				if err != nil {
+					return err
+				}
+				body := fmt.Sprintf("Reports of vsphere-collector, generated %s:\n", time.Now().Format(time.RFC1123))
+				for _, a := range attachments {
+					body += "- " + a.Name + "\n"
+				}
+				if err := mailer.Send(subject, body, attachments); err != nil {
+					return &exitError{code: exitSink, err: fmt.Errorf("mailing reports: %s", collector.Redact(err.Error()))}
+				}
+				slog.Info("mailed reports", "reports", reports, "to", mailToFlag)
+				return nil
+			}
+
+			ctx := cmd.Context()
+			if once {
+				return send(ctx)
+			}
+			for {
+				next := schedule.Next(time.Now())
+				if next.IsZero() {
+					return configError(fmt.Errorf("schedule %q never matches", reportScheduleFlag))
+				}
+				slog.Info("next report mail", "at", next)
+
+				t := time.NewTimer(time.Until(next))
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return nil
+				case <-t.C:
+				}
+				if err := send(ctx); err != nil {
+					slog.Warn("mailing reports failed", "err", err)
+				}
+			}
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringSliceVar(&reports, "reports", mailReports, "Comma separated reports to mail: "+strings.Join(mailReports, ", "))
+	fs.StringSliceVar(&inputs, "input", nil, "Comma separated patterns of the files of metrics of the capacity and rightsizing reports")
+	fs.StringVar(&subject, "subject", "vSphere reports", "Subject of the report mails")
+	fs.BoolVar(&once, "once", false, "Mail the reports right away and exit")
+	fs.StringVar(&reportScheduleFlag, "schedule", "0 7 * * 1", reportScheduleDescription)
+	fs.StringVar(&smtpAddrFlag, "smtp-addr", "", smtpAddrDescription)
+	fs.StringVar(&smtpUsernameFlag, "smtp-username", "", smtpUsernameDescription)
+	fs.StringVar(&smtpPasswordFlag, "smtp-password", "", smtpPasswordDescription)
+	fs.StringVar(&mailFromFlag, "mail-from", "", mailFromDescription)
+	fs.StringSliceVar(&mailToFlag, "mail-to", nil, mailToDescription)
+	return cmd
+}
+
+// renderReports renders reports as attachments, reading the metrics of the
+// files matching inputs.
+func renderReports(ctx context.Context, reports, inputs []string) ([]report.Attachment, error) {
+	now := time.Now()
+	stamp := now.Format("2006-01-02")
+
+	var metrics []collector.Metric
+	if slices.Contains(reports, "capacity") || slices.Contains(reports, "rightsizing") {
+		var files []string
+		for _, pattern := range inputs {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, configError(err)
+			}
+			files = append(files, matches...)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no file matches %s", strings.Join(inputs, ", "))
+		}
+
+		var err error
+		if metrics, err = readReportMetrics(files); err != nil {
+			return nil, err
+		}
+	}
+
+	var attachments []report.Attachment
+	for _, r := range reports {
+		var b bytes.Buffer
+		a := report.Attachment{Name: r + "-" + stamp + ".html", ContentType: "text/html; charset=utf-8"}
+
+		switch r {
+		case "capacity":
+			if err := report.CapacityHTML(&b, report.CapacityReport(metrics, report.DefaultTop, now)); err != nil {
+				return nil, err
+			}
+		case "rightsizing":
+			var recs []report.Recommendation
+			for _, rec := range report.Rightsizing(metrics, report.DefaultRightsizing) {
+				if rec.Verdict != report.RightSized {
+					recs = append(recs, rec)
+				}
+			}
+			if err := writeRightsizing(&b, recs); err != nil {
+				return nil, err
+			}
+			a.Name, a.ContentType = r+"-"+stamp+".csv", "text/csv; charset=utf-8"
+		case "snapshots":
+			col, err := newCollector()
+			if err != nil {
+				return nil, err
+			}
+			entries, errs := listSnapshots(ctx, col)
+			closeCollector(col)
+			if len(errs) != 0 {
+				slog.Warn("listing snapshots failed", "err", errs)
+			}
+			groups := report.Snapshots(entries, report.DefaultSnapshotPolicy, now)
+			if err := report.SnapshotsHTML(&b, groups, report.DefaultSnapshotPolicy, now); err != nil {
+				return nil, err
+			}
+		}
+
+		a.Data = b.Bytes()
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
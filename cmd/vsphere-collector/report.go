@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/report"
+)
+
+func newReportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report on the metrics of past collection cycles",
+		Long: `Report on the metrics of past collection cycles, read as JSON lines from the
+files given as arguments, as written by serve --format json, or from standard
+input, or on the endpoints themselves.`,
+	}
+
+	cmd.AddCommand(newReportRightsizingCommand(), newReportIdleCommand(), newReportSnapshotsCommand(), newReportCapacityCommand(), newReportShowbackCommand(), newReportHeatmapCommand(), newReportMailCommand())
+	return cmd
+}
+
+func newReportRightsizingCommand() *cobra.Command {
+	var (
+		output string
+		opts   report.RightsizingOptions
+		all    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rightsizing [file...]",
+		Short: "List over-provisioned and under-provisioned virtual machines with suggested sizes",
+		Long: `List the virtual machines with more or fewer vCPUs or memory than they
+demand, with the number of vCPUs and memory suggested for the --percentile of
+their CPU demand and active guest memory over the cycles read, plus
+--headroom. Virtual machines with fewer than --min-samples powered on samples
+are left out.`,
+		Example: "  vsphere-collector report rightsizing -o csv metrics-*.json > rightsizing.csv",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "csv" && output != "json" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+			if opts.Percentile <= 0 || opts.Percentile > 100 {
+				return configError(fmt.Errorf("invalid percentile %g", opts.Percentile))
+			}
+			if opts.Headroom < 0 {
+				return configError(fmt.Errorf("invalid headroom %g", opts.Headroom))
+			}
+
+			metrics, err := readReportMetrics(args)
+			if err != nil {
+				return err
+			}
+
+			var recs []report.Recommendation
+			for _, r := range report.Rightsizing(metrics, opts) {
+				if all || r.Verdict != report.RightSized {
+					recs = append(recs, r)
+				}
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(recs)
+			}
+			return writeRightsizing(os.Stdout, recs)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&output, "output", "o", "csv", "Output format: csv or json")
+	fs.Float64Var(&opts.Percentile, "percentile", report.DefaultRightsizing.Percentile, "Percentile of demand to size for")
+	fs.Float64Var(&opts.Headroom, "headroom", report.DefaultRightsizing.Headroom, "Fraction of demand to add to suggested sizes")
+	fs.IntVar(&opts.MinSamples, "min-samples", report.DefaultRightsizing.MinSamples, "Samples a virtual machine needs to be sized")
+	fs.BoolVar(&all, "all", false, "Also list right-sized virtual machines")
+	return cmd
+}
+
+func newReportIdleCommand() *cobra.Command {
+	var (
+		output string
+		opts   report.IdleOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "idle [file...]",
+		Short: "List idle virtual machines as candidates for reclamation",
+		Long: `List the virtual machines with an idle score of at least --min-score over the
+cycles read, most idle first: the percentage of their samples with less than
+5% of CPU usage, 10 KBps of network and 10 KBps of disk activity, as the
+vm_idle metrics of serve --idle-window. Network and disk activity are those
+of the vm_perf metrics, none for powered off virtual machines.`,
+		Example: "  vsphere-collector report idle --window 720h metrics-*.json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "csv" && output != "json" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+
+			metrics, err := readReportMetrics(args)
+			if err != nil {
+				return err
+			}
+			candidates := report.Idle(metrics, opts)
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(candidates)
+			}
+			return writeIdle(os.Stdout, candidates)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&output, "output", "o", "csv", "Output format: csv or json")
+	fs.DurationVar(&opts.Window, "window", 0, "Rolling window of the idle scores as of the latest cycle, 0 for every cycle read")
+	fs.Float64Var(&opts.MinScore, "min-score", 90, "Idle score of candidates, in percent")
+	fs.IntVar(&opts.MinSamples, "min-samples", 12, "Samples a virtual machine needs to be a candidate")
+	return cmd
+}
+
+func newReportSnapshotsCommand() *cobra.Command {
+	var (
+		output    string
+		policy    report.SnapshotPolicy
+		maxSizeGB float64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "snapshots",
+		Short: "List the snapshots of every endpoint over the policy limits",
+		Long: `List the snapshots of the virtual machines of every endpoint older than
+--max-age or larger than --max-size-gb, grouped by owner and datastore, for
+cleanup campaigns.
+
+The owner of a virtual machine is its custom attribute called --owner, else
+its vSphere tag of the category called --owner; listing tags requires
+credentials in the URL of the endpoints. The size of a snapshot is that of
+its state and memory files and of the delta disks it froze.`,
+		Example: "  vsphere-collector report snapshots --max-age 168h --max-size-gb 50 -o html > snapshots.html",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "csv" && output != "json" && output != "html" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+			policy.MaxSizeBytes = int64(maxSizeGB * (1 << 30))
+
+			col, err := newCollector()
+			if err != nil {
+				return err
+			}
+			defer closeCollector(col)
+
+			entries, errs := listSnapshots(cmd.Context(), col)
+			now := time.Now()
+			groups := report.Snapshots(entries, policy, now)
+
+			switch output {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(groups)
+			case "html":
+				err = report.SnapshotsHTML(os.Stdout, groups, policy, now)
+			default:
+				err = writeSnapshots(os.Stdout, groups)
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(errs) != 0 {
+				return errs
+			}
+			return nil
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&output, "output", "o", "csv", "Output format: csv, json or html")
+	fs.DurationVar(&policy.MaxAge, "max-age", report.DefaultSnapshotPolicy.MaxAge, "Age of snapshots over the policy, 0 for no limit")
+	fs.Float64Var(&maxSizeGB, "max-size-gb", 0, "Size of snapshots over the policy in GiB, 0 for no limit")
+	fs.StringVar(&policy.Owner, "owner", report.DefaultSnapshotPolicy.Owner, "Custom attribute or tag category naming the owner of virtual machines")
+	return cmd
+}
+
+func newReportCapacityCommand() *cobra.Command {
+	var (
+		format string
+		top    int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "capacity [file...]",
+		Short: "Report the utilization and growth of clusters and datastores",
+		Long: `Report the CPU and memory utilization of clusters and the utilization of
+datastores as of the latest cycle read, their growth as the linear trend over
+every cycle read, as forecast metrics, and the --top virtual machines using
+the most CPU, memory and storage.
+
+With --format html, the report is a self-contained HTML page suitable for
+mailing.`,
+		Example: "  vsphere-collector report capacity --format html metrics-*.json > capacity.html",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "html" {
+				return configError(fmt.Errorf("invalid report format %q", format))
+			}
+
+			metrics, err := readReportMetrics(args)
+			if err != nil {
+				return err
+			}
+			c := report.CapacityReport(metrics, top, time.Now())
+
+			if format == "html" {
+				return report.CapacityHTML(os.Stdout, c)
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(c)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&format, "format", "json", "Report format: json or html")
+	fs.IntVar(&top, "top", report.DefaultTop, "Number of top consumers of every resource")
+	return cmd
+}
+
+func newReportShowbackCommand() *cobra.Command {
+	var output, groupBy, month string
+
+	cmd := &cobra.Command{
+		Use:   "showback [file...]",
+		Short: "Report the monthly costs of virtual machines by folder or tag",
+		Long: `Report the costs of the virtual machines of the cycles read within --month,
+from the unit costs of --cost-file, grouped by folder or by a tag of their vm
+metrics such as resource_pool or vcd_org. Virtual machines are charged their
+monthly costs prorated by the share of the cycles of the month they were in.`,
+		Example: "  vsphere-collector report showback --cost-file costs.yaml --group-by resource_pool --month 2024-05 metrics-*.json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "csv" && output != "json" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+			if costFileFlag == "" {
+				return configError(fmt.Errorf("--cost-file is required"))
+			}
+			costs, err := collector.LoadUnitCosts(costFileFlag)
+			if err != nil {
+				return configError(err)
+			}
+
+			// The last complete month by default
+			start := time.Now().UTC()
+			start = time.Date(start.Year(), start.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+			if month != "" {
+				if start, err = time.Parse("2006-01", month); err != nil {
+					return configError(fmt.Errorf("invalid month %q, expected such as 2024-05", month))
+				}
+			}
+
+			metrics, err := readReportMetrics(args)
+			if err != nil {
+				return err
+			}
+			s := report.ShowbackReport(metrics, report.ShowbackOptions{Costs: costs, GroupBy: groupBy, Month: start})
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(s)
+			}
+			return writeShowback(os.Stdout, s)
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&output, "output", "o", "csv", "Output format: csv or json")
+	fs.StringVar(&costFileFlag, "cost-file", "", costFileDescription)
+	fs.StringVar(&groupBy, "group-by", "folder", "Group virtual machines by folder or by a tag of their vm metrics")
+	fs.StringVar(&month, "month", "", "Month of the report, such as 2024-05, the last complete one by default")
+	return cmd
+}
+
+func newReportHeatmapCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "heatmap [file...]",
+		Short: "Report the virtual machines and their allocation per host and datastore",
+		Long: `Report the virtual machines of the latest cycle read per host and datastore, and
+the vCPUs, memory and storage allocated to them, for capacity planning
+spreadsheets.
+
+As CSV, a row per host and datastore pair running virtual machines, the
+datastore of a virtual machine being that of its configuration file, with the
+CPU cores and memory of the host and the capacity of the datastore to pivot
+into matrices. As JSON, the pairs along with the totals of every host and
+datastore, such as their vCPUs per core and provisioned percentage.`,
+		Example: "  vsphere-collector report heatmap -o csv metrics.json > heatmap.csv",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "csv" && output != "json" {
+				return configError(fmt.Errorf("invalid output format %q", output))
+			}
+
+			metrics, err := readReportMetrics(args)
+			if err != nil {
+				return err
+			}
+			h := report.HeatmapReport(metrics, time.Now())
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(h)
+			}
+			return writeHeatmap(os.Stdout, h)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "csv", "Output format: csv or json")
+	return cmd
+}
+
+// listSnapshots lists the snapshots of every endpoint of col with the tags of
+// their virtual machine, returning the failures of those that could not be
+// listed. Tags are left out rather than failing.
+func listSnapshots(ctx context.Context, col *collector.Collector) ([]collector.SnapshotEntry, collector.Errors) {
+	var entries []collector.SnapshotEntry
+	var errs collector.Errors
+	for _, e := range col.Endpoints {
+		c, err := e.Client(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		snapshots, err := collector.Snapshots(ctx, c)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		vms := make([]collector.InventoryEntry, 0, len(snapshots))
+		index := make(map[string]int)
+		for _, s := range snapshots {
+			if _, ok := index[s.VMMOID]; !ok {
+				index[s.VMMOID] = len(vms)
+				vms = append(vms, collector.InventoryEntry{Endpoint: c.URL().Host, Kind: "vm", MOID: s.VMMOID})
+			}
+		}
+		if err := collector.InventoryTags(ctx, e, vms); err != nil {
+			slog.Warn("listing tags failed", "endpoint", e.URL.Host, "err", err)
+		}
+		for i := range snapshots {
+			snapshots[i].Tags = vms[index[snapshots[i].VMMOID]].Tags
+		}
+		entries = append(entries, snapshots...)
+	}
+	return entries, errs
+}
+
+// readReportMetrics reads the metrics of files, or of standard input when
+// there are none or for "-".
+func readReportMetrics(files []string) ([]collector.Metric, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var metrics []collector.Metric
+	for _, name := range files {
+		var r io.Reader = os.Stdin
+		if name != "-" {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		m, err := report.ReadMetrics(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		metrics = append(metrics, m...)
+	}
+	return metrics, nil
+}
+
+func writeRightsizing(w io.Writer, recs []report.Recommendation) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"vcenter", "name", "moid", "samples",
+		"cpus", "cpu_demand_mhz", "suggested_cpus",
+		"memory_mb", "memory_demand_mb", "suggested_memory_mb", "verdict",
+	})
+	for _, r := range recs {
+		cw.Write([]string{
+			r.VCenter, r.Name, r.MOID, strconv.Itoa(r.Samples),
+			strconv.FormatInt(r.CPUs, 10), strconv.FormatFloat(r.CPUDemandMHz, 'f', 0, 64), strconv.FormatInt(r.SuggestedCPUs, 10),
+			strconv.FormatInt(r.MemoryMB, 10), strconv.FormatFloat(r.MemoryDemandMB, 'f', 0, 64), strconv.FormatInt(r.SuggestedMemoryMB, 10),
+			r.Verdict,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSnapshots(w io.Writer, groups []report.SnapshotGroup) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"owner", "datastore", "vcenter", "vm", "vm_moid", "snapshot", "moid",
+		"created", "age_sec", "size_bytes", "reasons",
+	})
+	for _, g := range groups {
+		for _, s := range g.Snapshots {
+			cw.Write([]string{
+				g.Owner, g.Datastore, s.Endpoint, s.VMPath, s.VMMOID, s.Name, s.MOID,
+				s.Created.UTC().Format(time.RFC3339), strconv.FormatInt(s.AgeSec, 10), strconv.FormatInt(s.SizeBytes, 10),
+				strings.Join(s.Reasons, " "),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeShowback(w io.Writer, s *report.Showback) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"month", "group", "vms", "cpu_cost", "memory_cost", "storage_cost", "total_cost", "currency"})
+	for _, g := range s.Groups {
+		cw.Write([]string{
+			s.Month, g.Group, strconv.Itoa(g.VMs),
+			strconv.FormatFloat(g.CPUCost, 'f', 2, 64), strconv.FormatFloat(g.MemoryCost, 'f', 2, 64),
+			strconv.FormatFloat(g.StorageCost, 'f', 2, 64), strconv.FormatFloat(g.TotalCost, 'f', 2, 64),
+			s.Currency,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeHeatmap(w io.Writer, h *report.Heatmap) error {
+	type key [2]string
+	hosts := make(map[key]report.HostHeat, len(h.Hosts))
+	for _, host := range h.Hosts {
+		hosts[key{host.VCenter, host.Name}] = host
+	}
+	datastores := make(map[key]report.DatastoreHeat, len(h.Datastores))
+	for _, ds := range h.Datastores {
+		datastores[key{ds.VCenter, ds.Name}] = ds
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"vcenter", "cluster", "host", "datastore", "vms", "powered_on_vms", "vcpus", "memory_mb", "provisioned_bytes",
+		"host_cpu_cores", "host_memory_mb", "datastore_capacity_bytes",
+	})
+	for _, c := range h.Cells {
+		host := hosts[key{c.VCenter, c.Host}]
+		ds := datastores[key{c.VCenter, c.Datastore}]
+		cw.Write([]string{
+			c.VCenter, c.Cluster, c.Host, c.Datastore, strconv.Itoa(c.VMs), strconv.Itoa(c.PoweredOnVMs),
+			strconv.FormatInt(c.VCPUs, 10), strconv.FormatInt(c.MemoryMB, 10), strconv.FormatInt(c.ProvisionedBytes, 10),
+			strconv.FormatInt(host.CPUCores, 10), strconv.FormatInt(host.MemoryCapacityMB, 10), strconv.FormatInt(ds.CapacityBytes, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeIdle(w io.Writer, candidates []report.Candidate) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"vcenter", "name", "moid", "path", "samples",
+		"idle_score", "cpu_usage_percent", "net_usage_kbps", "disk_usage_kbps",
+	})
+	for _, c := range candidates {
+		cw.Write([]string{
+			c.VCenter, c.Name, c.MOID, c.Path, strconv.FormatInt(c.Samples, 10),
+			strconv.FormatFloat(c.IdleScore, 'f', 1, 64), strconv.FormatFloat(c.CPUUsagePercent, 'f', 1, 64),
+			strconv.FormatFloat(c.NetUsageKBps, 'f', 1, 64), strconv.FormatFloat(c.DiskUsageKBps, 'f', 1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
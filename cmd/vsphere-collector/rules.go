@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/alert"
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func newRulesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Generate alerting rules of the metrics",
+	}
+
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Print a starter set of Prometheus alerting rules",
+		Long: `Print a Prometheus rules file alerting on datastores predicted to fill up
+within 7 days, disconnected hosts and, with a warning and a critical rule
+each, the values breaching --threshold, of the fields kept by --allow-field
+and --deny-field.
+
+Series are named measurement_field, as renamed by --measurement-template,
+with the tags as labels, as Telegraf or the InfluxDB exporter expose line
+protocol to Prometheus. No rule on CPU ready time is generated, as it is not
+collected.`,
+		Example: "  vsphere-collector rules export --threshold 'vm.snapshot_age_sec>3d:7d' > vsphere.rules.yml",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := collector.NewFieldFilter(allowFieldFlag, denyFieldFlag)
+			if err != nil {
+				return configError(err)
+			}
+			renamer, err := collector.NewRenamer(measurementTemplateFlag, tagTemplateFlag)
+			if err != nil {
+				return configError(err)
+			}
+			var thresholds []collector.Threshold
+			for _, s := range thresholdsFlag {
+				t, err := collector.ParseThreshold(s)
+				if err != nil {
+					return configError(err)
+				}
+				thresholds = append(thresholds, t)
+			}
+
+			schema := collector.Schema(filter)
+			names, err := measurementNames(renamer, schema)
+			if err != nil {
+				return configError(err)
+			}
+
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			if err := enc.Encode(alert.PrometheusRules(schema, thresholds, names)); err != nil {
+				return err
+			}
+			return enc.Close()
+		},
+	}
+
+	fs := export.Flags()
+	fs.StringSliceVar(&thresholdsFlag, "threshold", []string{"datastore.used_percent>85:95", "vm.snapshot_age_sec>7d:14d"}, thresholdsDescription)
+	fs.StringVar(&measurementTemplateFlag, "measurement-template", "", measurementTemplateDescription)
+	fs.StringSliceVar(&tagTemplateFlag, "tag-template", nil, tagTemplateDescription)
+
+	cmd.AddCommand(export)
+	return cmd
+}
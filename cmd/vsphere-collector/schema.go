@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func newSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print every metric that can be emitted as JSON",
+		Long: `Print every measurement that can be emitted, with its tags and the name, type
+and unit of its fields as kept by --allow-field and --deny-field, as JSON.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := collector.NewFieldFilter(allowFieldFlag, denyFieldFlag)
+			if err != nil {
+				return configError(err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(collector.Schema(filter))
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/sinks"
+)
+
+var listenDescription = fmt.Sprintf("Address to serve the metrics of a collection on at /metrics [%s]", envListen)
+var listenFlag string
+
+var cacheTTLDescription = fmt.Sprintf("Serve the metrics of the latest collection to the requests within this long of it rather than collecting again [%s]", envCacheTTL)
+var cacheTTLFlag time.Duration
+
+func newScrapeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scrape",
+		Short: "Collect whenever metrics are requested over HTTP",
+		Long: `Serve metrics at /metrics as an exporter does, collecting every endpoint when
+they are requested rather than every interval, in the Prometheus text format
+unless --format is set.
+
+A single collection runs at a time: concurrent requests wait for the one in
+progress and share its metrics, which are served again to the requests within
+--cache-ttl of it. A collection failing for every endpoint is served with
+status 503, failing the scrape.`,
+		Args: cobra.NoArgs,
+		RunE: runScrape,
+	}
+
+	cmd.Flags().StringVar(&listenFlag, "listen", ":9272", listenDescription)
+	cmd.Flags().DurationVar(&cacheTTLFlag, "cache-ttl", 30*time.Second, cacheTTLDescription)
+	addOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func runScrape(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("format") {
+		formatFlag = "prometheus"
+	}
+	enc, err := sinks.NewEncoder(formatFlag)
+	if err != nil {
+		return configError(err)
+	}
+
+	col, err := newCollector()
+	if err != nil {
+		return err
+	}
+	defer closeCollector(col)
+
+	c, err := newCycle(col, 0, flagSinks())
+	if err != nil {
+		return configError(err)
+	}
+
+	s := &scraper{cycle: c, contentType: enc.ContentType(), ttl: cacheTTLFlag}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s)
+	srv := &http.Server{Addr: listenFlag, Handler: mux}
+
+	shutdown := make(chan struct{})
+	go func() {
+		<-cmd.Context().Done()
+		srv.Shutdown(context.Background())
+		close(shutdown)
+	}()
+
+	slog.Info("serving metrics", "addr", listenFlag, "format", formatFlag, "cache_ttl", cacheTTLFlag)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return configError(err)
+	}
+
+	// The collections of the requests in progress complete before flushing
+	<-shutdown
+	c.flush(cmd.Context())
+	return nil
+}
+
+// scraper runs a cycle for the requests of its metrics, caching them for ttl.
+// A collection every collector of which failed is served with status 503, for
+// Prometheus to mark the scrape failed rather than empty.
+type scraper struct {
+	cycle       *cycle
+	contentType string
+	ttl         time.Duration
+
+	// mu guards the cycle, serializing collections
+	mu        sync.Mutex
+	collected time.Time
+	body      []byte
+	status    int
+}
+
+func (s *scraper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.body == nil || time.Since(s.collected) >= s.ttl {
+		var b bytes.Buffer
+		s.cycle.cw.w = &b
+		// A request going away doesn't cancel the collection others wait for
+		errs := s.cycle.run(context.WithoutCancel(r.Context()))
+		s.collected, s.body, s.status = time.Now(), b.Bytes(), http.StatusOK
+
+		var exit *exitError
+		if err := collectionError(errs, len(s.cycle.col.Endpoints)); errors.As(err, &exit) && exit.code != exitPartial {
+			s.status = http.StatusServiceUnavailable
+		}
+	}
+	body, status := s.body, s.status
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", s.contentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "github.com/spf13/cobra"
+
+// serviceCommands returns the commands controlling the Windows service, none
+// elsewhere.
+func serviceCommands() []*cobra.Command {
+	return nil
+}
@@ -0,0 +1,257 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceCommands returns the commands controlling the Windows service.
+func serviceCommands() []*cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Install and control serve as a Windows service",
+		Long: `Install, uninstall, start and stop serve as a Windows service through the
+service control manager. These need an elevated prompt.`,
+	}
+	cmd.PersistentFlags().StringVar(&name, "name", "vsphere-collector", "Name of the service")
+
+	cmd.AddCommand(
+		newServiceInstallCommand(&name),
+		newServiceUninstallCommand(&name),
+		newServiceStartCommand(&name),
+		newServiceStopCommand(&name),
+		newServiceRunCommand(&name),
+	)
+	return []*cobra.Command{cmd}
+}
+
+func newServiceInstallCommand(name *string) *cobra.Command {
+	var output, logFile string
+
+	cmd := &cobra.Command{
+		Use:   "install [flags] -- [serve flags]",
+		Short: "Install serve as a service started automatically",
+		Long: `Install serve, run with the given serve flags, as a service started
+automatically at boot. As a service has no console, metrics are appended to
+--output and logs to --log.`,
+		Example: `  vsphere-collector service install --output C:\vsphere\metrics.lp --log C:\vsphere\collector.log -- --config C:\vsphere\collector.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			// Services start in the system directory
+			if output, err = filepath.Abs(output); err != nil {
+				return err
+			}
+			if logFile, err = filepath.Abs(logFile); err != nil {
+				return err
+			}
+
+			m, err := mgr.Connect()
+			if err != nil {
+				return err
+			}
+			defer m.Disconnect()
+
+			if s, err := m.OpenService(*name); err == nil {
+				s.Close()
+				return fmt.Errorf("service %s already installed", *name)
+			}
+
+			runArgs := append([]string{"service", "run", "--name", *name, "--output", output, "--log", logFile, "--"}, args...)
+			s, err := m.CreateService(*name, exe, mgr.Config{
+				DisplayName: "vSphere collector",
+				Description: "Collects the metrics of ESX and vCenter endpoints.",
+				StartType:   mgr.StartAutomatic,
+			}, runArgs...)
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			slog.Info("installed service", "name", *name, "exe", exe)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "", "File metrics are appended to")
+	cmd.Flags().StringVar(&logFile, "log", "", "File logs are appended to")
+	cmd.MarkFlagRequired("output")
+	cmd.MarkFlagRequired("log")
+	return cmd
+}
+
+func newServiceUninstallCommand(name *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "uninstall",
+		Short: "Uninstall the service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return controlService(*name, func(s *mgr.Service) error {
+				if err := s.Delete(); err != nil {
+					return err
+				}
+				slog.Info("uninstalled service", "name", *name)
+				return nil
+			})
+		},
+	}
+}
+
+func newServiceStartCommand(name *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "start",
+		Short: "Start the service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return controlService(*name, func(s *mgr.Service) error {
+				return s.Start()
+			})
+		},
+	}
+}
+
+func newServiceStopCommand(name *string) *cobra.Command {
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the service, waiting for it to log out",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return controlService(*name, func(s *mgr.Service) error {
+				status, err := s.Control(svc.Stop)
+				if err != nil {
+					return err
+				}
+
+				deadline := time.Now().Add(timeout)
+				for status.State != svc.Stopped {
+					if time.Now().After(deadline) {
+						return fmt.Errorf("service %s did not stop within %s", *name, timeout)
+					}
+					time.Sleep(300 * time.Millisecond)
+
+					if status, err = s.Query(); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "How long to wait for the service to stop")
+	return cmd
+}
+
+// newServiceRunCommand returns the command run by the service control
+// manager.
+func newServiceRunCommand(name *string) *cobra.Command {
+	var output, logFile string
+
+	cmd := &cobra.Command{
+		Use:    "run [flags] -- [serve flags]",
+		Short:  "Run serve under the service control manager",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stdout, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+			if err != nil {
+				return err
+			}
+			defer stdout.Close()
+
+			stderr, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+			if err != nil {
+				return err
+			}
+			defer stderr.Close()
+
+			os.Stdout, os.Stderr = stdout, stderr
+			return svc.Run(*name, &service{args: args})
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "", "File metrics are appended to")
+	cmd.Flags().StringVar(&logFile, "log", "", "File logs are appended to")
+	return cmd
+}
+
+// controlService calls f with the installed service name.
+func controlService(name string, f func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	return f(s)
+}
+
+// service runs serve with args until stopped by the service control manager.
+type service struct {
+	args []string
+}
+
+func (s *service) Execute(_ []string, r <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		root := newRootCommand()
+		root.SetArgs(append([]string{"serve"}, s.args...))
+		done <- root.ExecuteContext(ctx)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				status <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// serve returns once its cycle is done
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		case err := <-done:
+			if err == nil {
+				return false, 0
+			}
+
+			code := exitFailure
+			var ee *exitError
+			if errors.As(err, &ee) {
+				code = ee.code
+			}
+			if ee == nil || ee.err != nil {
+				slog.Error("fatal error", "err", err)
+			}
+			return true, uint32(code)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/objstore"
+	"github.com/mlabouardy/vsphere-collector/pkg/sinks"
+)
+
+var ariaURLDescription = fmt.Sprintf("Base URL of an Aria Operations deployment to push the numeric fields of entities to, as stats of a custom metric group [%s]", envAriaURL)
+var ariaURLFlag string
+
+var ariaUsernameDescription = fmt.Sprintf("Aria Operations username [%s]", envAriaUser)
+var ariaUsernameFlag string
+
+var ariaPasswordDescription = fmt.Sprintf("Aria Operations password [%s]", envAriaPass)
+var ariaPasswordFlag string
+
+var ariaAuthSourceDescription = fmt.Sprintf("Aria Operations authentication source of --aria-username, empty for local users [%s]", envAriaAuth)
+var ariaAuthSourceFlag string
+
+var ariaGroupDescription = fmt.Sprintf("Aria Operations custom metric group of the stats pushed [%s]", envAriaGrp)
+var ariaGroupFlag string
+
+var archiveDescription = fmt.Sprintf("Directory or s3://bucket/prefix URL to archive batches of metrics in, partitioned by measurement and day, for long-term retention and offline analytics [%s]", envArchive)
+var archiveFlag string
+
+var archiveFormatDescription = fmt.Sprintf("Format of the archived batches: parquet, or ndjson for gzip-compressed JSON lines [%s]", envArchFmt)
+var archiveFormatFlag string
+
+var archiveIntervalDescription = fmt.Sprintf("Archive the metrics buffered every interval, and on shutdown [%s]", envArchIntv)
+var archiveIntervalFlag time.Duration
+
+var parquetDirDescription = fmt.Sprintf("Directory to write metrics to as Parquet files, one per entity type and --parquet-rotation, partitioned by day [%s]", envParqDir)
+var parquetDirFlag string
+
+var parquetRotationDescription = fmt.Sprintf("Start a new Parquet file every cycle or day: cycle or day [%s]", envParqRot)
+var parquetRotationFlag string
+
+var routeDescription = fmt.Sprintf("Comma separated sink=glob rules writing only the metrics of the measurements matching a glob to a sink, stdout, aria, archive or parquet, a leading ! excluding them instead, such as aria=vm_perf or stdout=!vm_perf; sinks without rules get every metric, as written after --measurement-template [%s]", envRoute)
+var routeFlag []string
+
+// addSinkFlags adds the flags of the remote sinks to fs.
+func addSinkFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&routeFlag, "route", nil, routeDescription)
+	fs.StringVar(&ariaURLFlag, "aria-url", "", ariaURLDescription)
+	fs.StringVar(&ariaUsernameFlag, "aria-username", "", ariaUsernameDescription)
+	fs.StringVar(&ariaPasswordFlag, "aria-password", "", ariaPasswordDescription)
+	fs.StringVar(&ariaAuthSourceFlag, "aria-auth-source", "", ariaAuthSourceDescription)
+	fs.StringVar(&ariaGroupFlag, "aria-group", "vsphere-collector", ariaGroupDescription)
+	fs.StringVar(&archiveFlag, "archive", "", archiveDescription)
+	fs.StringVar(&archiveFormatFlag, "archive-format", sinks.ArchiveParquet, archiveFormatDescription)
+	fs.DurationVar(&archiveIntervalFlag, "archive-interval", time.Hour, archiveIntervalDescription)
+	fs.StringVar(&parquetDirFlag, "parquet-dir", "", parquetDirDescription)
+	fs.StringVar(&parquetRotationFlag, "parquet-rotation", sinks.RotateDay, parquetRotationDescription)
+}
+
+// namedSink is a remote sink, named in logs.
+type namedSink struct {
+	name string
+	sinks.Sink
+}
+
+// sinkConfig configures the archive and Parquet sinks, as the flags do or
+// the spec of a controller target overrides them.
+type sinkConfig struct {
+	archive         string
+	archiveFormat   string
+	archiveInterval time.Duration
+	parquetDir      string
+	parquetRotation string
+	// target, if set, partitions the objects and files of the target of a
+	// controller
+	target string
+}
+
+// flagSinks returns the sinkConfig of the flags.
+func flagSinks() sinkConfig {
+	return sinkConfig{
+		archive:         archiveFlag,
+		archiveFormat:   archiveFormatFlag,
+		archiveInterval: archiveIntervalFlag,
+		parquetDir:      parquetDirFlag,
+		parquetRotation: parquetRotationFlag,
+	}
+}
+
+// newRemoteSinks returns the remote sinks configured by the flags, the
+// archive and Parquet ones by config.
+func newRemoteSinks(config sinkConfig) ([]namedSink, error) {
+	var remotes []namedSink
+
+	if ariaURLFlag != "" {
+		collector.AddSecret(ariaPasswordFlag)
+
+		aria, err := sinks.NewAria(ariaURLFlag, ariaUsernameFlag, ariaPasswordFlag, ariaAuthSourceFlag, ariaGroupFlag)
+		if err != nil {
+			return nil, err
+		}
+		remotes = append(remotes, namedSink{name: "aria", Sink: aria})
+	}
+
+	if config.archive != "" {
+		store, err := objstore.Open(config.archive)
+		if err != nil {
+			return nil, err
+		}
+		archive, err := sinks.NewArchive(store, config.archiveFormat, config.archiveInterval)
+		if err != nil {
+			return nil, err
+		}
+		archive.Target = config.target
+		remotes = append(remotes, namedSink{name: "archive", Sink: archive})
+	}
+
+	if config.parquetDir != "" {
+		files, err := sinks.NewParquetFiles(config.parquetDir, config.parquetRotation)
+		if err != nil {
+			return nil, err
+		}
+		files.Target = config.target
+		remotes = append(remotes, namedSink{name: "parquet", Sink: files})
+	}
+	return remotes, nil
+}
+
+// flush flushes the remote sinks buffering metrics, such as the archive,
+// once the last cycle ran, even if canceled by a shutdown.
+func (c *cycle) flush(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	for _, s := range c.remotes {
+		if err := sinks.Flush(ctx, s.Sink); err != nil {
+			c.sinkFailures++
+			slog.Warn("flush failed", "sink", s.name, "err", err)
+		}
+	}
+}
+
+// route restricts the sinks of c to the metrics of their --route rules.
+func (c *cycle) route() error {
+	routes, err := sinks.ParseRoutes(routeFlag)
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{"stdout": true}
+	for _, s := range c.remotes {
+		known[s.name] = true
+	}
+	for name := range routes {
+		if !known[name] {
+			return fmt.Errorf("route of unknown sink %q", name)
+		}
+	}
+
+	if r := routes["stdout"]; r != nil {
+		c.sink = &sinks.Routed{Sink: c.sink, Route: r}
+	}
+	for i, s := range c.remotes {
+		if r := routes[s.name]; r != nil {
+			c.remotes[i].Sink = &sinks.Routed{Sink: s.Sink, Route: r}
+		}
+	}
+	return nil
+}
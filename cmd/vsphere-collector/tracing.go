@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces collection cycles and sink writes.
+var tracer = otel.Tracer("github.com/mlabouardy/vsphere-collector/cmd/vsphere-collector")
+
+// tracerProvider is the provider of --otlp-endpoint, shut down on exit.
+var tracerProvider *sdktrace.TracerProvider
+
+// newTracerProvider returns a tracer provider exporting to the OTLP gRPC
+// endpoint rawURL, set as the global one the collector library traces
+// through. An http URL is exported to without TLS.
+func newTracerProvider(ctx context.Context, rawURL string) (*sdktrace.TracerProvider, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: expected http://host:port or https://host:port", rawURL)
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "vsphere-collector"),
+			attribute.String("service.version", version),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	return tp, nil
+}
+
+// endSpan ends span, recording err unless nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, collector.Redact(err.Error()))
+	}
+	span.End()
+}
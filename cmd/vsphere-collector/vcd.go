@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/vcd"
+)
+
+var vcdURLDescription = fmt.Sprintf("Base URL of a Cloud Director to tag the metrics of virtual machines with their vcd_org, vcd_vdc and vcd_vapp from [%s]", envVCDURL)
+var vcdURLFlag string
+
+var vcdUsernameDescription = fmt.Sprintf("Cloud Director system administrator username [%s]", envVCDUser)
+var vcdUsernameFlag string
+
+var vcdPasswordDescription = fmt.Sprintf("Cloud Director password [%s]", envVCDPass)
+var vcdPasswordFlag string
+
+var vcdRefreshDescription = fmt.Sprintf("List the tenants of virtual machines from Cloud Director every interval [%s]", envVCDRefr)
+var vcdRefreshFlag time.Duration
+
+// addVCDFlags adds the flags of the Cloud Director tenant tags to fs.
+func addVCDFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&vcdURLFlag, "vcd-url", "", vcdURLDescription)
+	fs.StringVar(&vcdUsernameFlag, "vcd-username", "", vcdUsernameDescription)
+	fs.StringVar(&vcdPasswordFlag, "vcd-password", "", vcdPasswordDescription)
+	fs.DurationVar(&vcdRefreshFlag, "vcd-refresh", 15*time.Minute, vcdRefreshDescription)
+}
+
+// newTenants returns the tenant tagger configured by the flags, nil without
+// --vcd-url.
+func newTenants() (*vcd.Tenants, error) {
+	if vcdURLFlag == "" {
+		return nil, nil
+	}
+
+	client, err := vcd.NewClient(vcdURLFlag, vcdUsernameFlag, vcdPasswordFlag)
+	if err != nil {
+		return nil, err
+	}
+	return vcd.NewTenants(client, vcdRefreshFlag), nil
+}
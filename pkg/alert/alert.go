@@ -0,0 +1,114 @@
+// Package alert posts alerts to a webhook or sends SNMP traps when the
+// metrics of a collection cycle breach thresholds, for sites without an
+// alerting backend.
+//
+//	hook, err := alert.NewWebhook("https://hooks.slack.com/services/...", "slack")
+//	...
+//	d := alert.NewDispatcher(thresholds, hook)
+//	for each cycle {
+//		err := d.Dispatch(ctx, metrics)
+//	}
+package alert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Alert is a change of the state of an entity against a threshold. A State
+// of OK resolves a previous alert.
+type Alert struct {
+	Rule        string               `json:"rule"`
+	State       string               `json:"state"`
+	Previous    string               `json:"previous"`
+	Measurement string               `json:"measurement"`
+	Field       string               `json:"field"`
+	Value       float64              `json:"value"`
+	Name        string               `json:"name"`
+	Entity      *collector.EntityRef `json:"entity,omitempty"`
+	Tags        map[string]string    `json:"tags,omitempty"`
+	Time        time.Time            `json:"timestamp"`
+}
+
+func (a Alert) String() string {
+	state := a.State
+	if state == collector.OK.String() {
+		state = "RESOLVED"
+	}
+	return fmt.Sprintf("%s %s %s %s=%g (%s)", state, a.Measurement, a.Name, a.Field, a.Value, a.Rule)
+}
+
+// A Notifier sends alerts, such as a Webhook or an SNMPTrap.
+type Notifier interface {
+	Send(ctx context.Context, alerts []Alert) error
+}
+
+// Dispatcher evaluates thresholds against the metrics of every cycle, sending
+// an alert when the state of an entity against one changes. Entities start
+// out OK, so only breaches are sent for the first cycle.
+type Dispatcher struct {
+	Thresholds []collector.Threshold
+	Notifier   Notifier
+
+	// states is the last state sent of each rule and entity
+	states map[string]collector.State
+}
+
+// NewDispatcher returns a Dispatcher of thresholds sending to n.
+func NewDispatcher(thresholds []collector.Threshold, n Notifier) *Dispatcher {
+	return &Dispatcher{
+		Thresholds: thresholds,
+		Notifier:   n,
+		states:     make(map[string]collector.State),
+	}
+}
+
+// Dispatch sends the alerts of the changes of state in metrics. When sending
+// fails, the same changes are sent again with those of the next cycle.
+// Entities missing from metrics are forgotten without an alert.
+func (d *Dispatcher) Dispatch(ctx context.Context, metrics []collector.Metric) error {
+	var alerts []Alert
+	states := make(map[string]collector.State)
+
+	for _, r := range collector.Evaluate(metrics, d.Thresholds) {
+		key := r.Threshold.String() + " " + entityKey(r.Metric)
+		states[key] = r.State
+
+		previous := d.states[key]
+		if r.State == previous {
+			continue
+		}
+
+		alerts = append(alerts, Alert{
+			Rule:        r.Threshold.String(),
+			State:       r.State.String(),
+			Previous:    previous.String(),
+			Measurement: r.Metric.Name,
+			Field:       r.Threshold.Field,
+			Value:       r.Value,
+			Name:        r.Metric.Tag("name"),
+			Entity:      r.Metric.Entity,
+			Tags:        r.Metric.Tags,
+			Time:        r.Metric.Time,
+		})
+	}
+
+	if len(alerts) != 0 {
+		if err := d.Notifier.Send(ctx, alerts); err != nil {
+			return err
+		}
+	}
+	d.states = states
+	return nil
+}
+
+// entityKey identifies the entity of m across cycles.
+func entityKey(m collector.Metric) string {
+	if m.Entity != nil {
+		return m.Entity.String()
+	}
+	return m.Name + ":" + m.Tag("vcenter") + ":" + m.Tag("name")
+}
@@ -0,0 +1,121 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestDispatch(t *testing.T) {
+	var posted [][]Alert
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Alerts []Alert }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		posted = append(posted, body.Alerts)
+	}))
+	defer s.Close()
+
+	th, err := collector.ParseThreshold("datastore.used_percent>85:95")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook, err := NewWebhook(s.URL, "generic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher([]collector.Threshold{th}, hook)
+
+	cycle := func(used float64) {
+		t.Helper()
+		records := map[string]interface{}{"capacity": 100, "freespace": 100 - int(used), "used_percent": used, "sioc_enabled": false, "sioc_congestion_threshold_ms": 0}
+		m, err := collector.NewMetric("datastore", map[string]string{"name": "ds0"}, records, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Dispatch(context.Background(), []collector.Metric{m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cycle(96)
+	cycle(97)
+	cycle(50)
+
+	if len(posted) != 2 {
+		t.Fatalf("%d posts, expected 2: %v", len(posted), posted)
+	}
+	if a := posted[0]; len(a) != 1 || a[0].State != "CRITICAL" || a[0].Previous != "OK" {
+		t.Errorf("breach %v", a)
+	}
+	if a := posted[1]; len(a) != 1 || a[0].State != "OK" || a[0].Previous != "CRITICAL" {
+		t.Errorf("resolution %v", a)
+	}
+}
+
+func TestSNMPTrapCriticalOnly(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	trap, err := NewSNMPTrap(conn.LocalAddr().String(), "public", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alerts := []Alert{
+		{Rule: "datastore.used_percent>85:95", State: "WARNING", Previous: "OK"},
+		{Rule: "host.available<1:1", State: "CRITICAL", Previous: "OK", Name: "h0"},
+	}
+	if err := trap.Send(context.Background(), alerts); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf[:n]), "host.available<1:1") {
+		t.Errorf("trap of the wrong alert: %q", buf[:n])
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := conn.ReadFrom(buf); err == nil {
+		t.Error("warning sent as a trap")
+	}
+}
+
+func TestPrometheusRules(t *testing.T) {
+	threshold, err := collector.ParseThreshold("vm.snapshot_age_sec>7d:14d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := []collector.MeasurementSchema{
+		{Measurement: "host", Fields: []collector.FieldSchema{{Name: "available"}}},
+		{Measurement: "vm", Fields: []collector.FieldSchema{{Name: "snapshot_age_sec"}}},
+	}
+
+	rules := PrometheusRules(schema, []collector.Threshold{threshold}, map[string]string{"vm": "vsphere_vm"}).Groups[0].Rules
+	var alerts []string
+	for _, r := range rules {
+		alerts = append(alerts, r.Alert)
+	}
+	if got := strings.Join(alerts, ","); got != "VSphereHostDisconnected,VSphereVmSnapshotAgeSecWarning,VSphereVmSnapshotAgeSecCritical" {
+		t.Fatalf("alerts %s", got)
+	}
+	if expr := rules[1].Expr; expr != "vsphere_vm_snapshot_age_sec > 604800 and vsphere_vm_snapshot_age_sec <= 1.2096e+06" {
+		t.Errorf("expr %s", expr)
+	}
+}
@@ -0,0 +1,139 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// RuleFile is a Prometheus rules file.
+type RuleFile struct {
+	Groups []RuleGroup `json:"groups" yaml:"groups"`
+}
+
+// RuleGroup is a group of rules of a RuleFile.
+type RuleGroup struct {
+	Name  string `json:"name" yaml:"name"`
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule is a Prometheus alerting rule.
+type Rule struct {
+	Alert       string            `json:"alert" yaml:"alert"`
+	Expr        string            `json:"expr" yaml:"expr"`
+	For         string            `json:"for,omitempty" yaml:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// PrometheusRules returns a starter set of Prometheus alerting rules of the
+// fields of schema: datastores predicted to fill up within a week, hosts
+// disconnected and a warning and a critical rule per threshold, such as the
+// age of snapshots. Thresholds of fields schema does not have are left out.
+//
+// Series are named measurement_field with the tags as labels, as line
+// protocol is exposed by Telegraf or the InfluxDB exporter. names maps the
+// measurements of schema to their name in the output, if renamed.
+func PrometheusRules(schema []collector.MeasurementSchema, thresholds []collector.Threshold, names map[string]string) RuleFile {
+	has := make(map[string]bool)
+	for _, ms := range schema {
+		for _, f := range ms.Fields {
+			has[ms.Measurement+"."+f.Name] = true
+		}
+	}
+	series := func(measurement, field string) string {
+		if name, ok := names[measurement]; ok {
+			measurement = name
+		}
+		return prometheusName(measurement + "_" + field)
+	}
+
+	var rules []Rule
+	if has["datastore.freespace"] {
+		rules = append(rules, Rule{
+			Alert:  "VSphereDatastoreFillPredicted",
+			Expr:   fmt.Sprintf("predict_linear(%s[1d], 7 * 86400) < 0", series("datastore", "freespace")),
+			For:    "1h",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "Datastore {{ $labels.name }} on {{ $labels.vcenter }} is predicted to fill up within 7 days",
+			},
+		})
+	}
+	if has["host.available"] {
+		rules = append(rules, Rule{
+			Alert:  "VSphereHostDisconnected",
+			Expr:   series("host", "available") + ` == 0`,
+			For:    "5m",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": "Host {{ $labels.name }} on {{ $labels.vcenter }} is {{ $labels.connection_state }}",
+			},
+		})
+	}
+
+	for _, t := range thresholds {
+		if !has[t.Measurement+"."+t.Field] {
+			continue
+		}
+		s := series(t.Measurement, t.Field)
+		op, notOp := ">", "<="
+		if t.Below {
+			op, notOp = "<", ">="
+		}
+		name := "VSphere" + camelCase(t.Measurement) + camelCase(t.Field)
+		summary := fmt.Sprintf("%s {{ $labels.name }} on {{ $labels.vcenter }} has %s %s %%s: {{ $value }}", t.Measurement, t.Field, op)
+
+		rules = append(rules,
+			Rule{
+				Alert:       name + "Warning",
+				Expr:        fmt.Sprintf("%s %s %s and %s %s %s", s, op, level(t.Warning), s, notOp, level(t.Critical)),
+				For:         "15m",
+				Labels:      map[string]string{"severity": "warning"},
+				Annotations: map[string]string{"summary": fmt.Sprintf(summary, level(t.Warning))},
+			},
+			Rule{
+				Alert:       name + "Critical",
+				Expr:        fmt.Sprintf("%s %s %s", s, op, level(t.Critical)),
+				For:         "15m",
+				Labels:      map[string]string{"severity": "critical"},
+				Annotations: map[string]string{"summary": fmt.Sprintf(summary, level(t.Critical))},
+			},
+		)
+	}
+
+	return RuleFile{Groups: []RuleGroup{{Name: "vsphere-collector", Rules: rules}}}
+}
+
+func level(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// prometheusName replaces the characters Prometheus metric names cannot have
+// by underscores.
+func prometheusName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, s)
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
+
+// camelCase returns snake_case as CamelCase.
+func camelCase(s string) string {
+	var b strings.Builder
+	for _, w := range strings.Split(s, "_") {
+		if w != "" {
+			b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+		}
+	}
+	return b.String()
+}
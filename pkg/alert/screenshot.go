@@ -0,0 +1,69 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/objstore"
+)
+
+// Screenshots captures the console of virtual machines entering a problematic
+// state, as of collector.VMProblem, storing it for triage as
+// <vcenter>/<name>-<moid>/<timestamp>-<problem>.png.
+type Screenshots struct {
+	Collector *collector.Collector
+	Store     objstore.Store
+
+	// problems is the last problem of each virtual machine
+	problems map[collector.EntityRef]string
+}
+
+// NewScreenshots returns Screenshots taken through the endpoints of c and
+// stored in store.
+func NewScreenshots(c *collector.Collector, store objstore.Store) *Screenshots {
+	return &Screenshots{Collector: c, Store: store, problems: make(map[collector.EntityRef]string)}
+}
+
+// unsafeKey matches the characters of virtual machine names left out of keys.
+var unsafeKey = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Capture takes a screenshot of every virtual machine of metrics whose problem
+// changed to another than none. Failed captures are not retried, the problem
+// having been seen. Virtual machines missing from metrics are forgotten.
+func (s *Screenshots) Capture(ctx context.Context, metrics []collector.Metric) error {
+	var errs []error
+	problems := make(map[collector.EntityRef]string)
+
+	for _, m := range metrics {
+		if m.Name != "vm" || m.Entity == nil {
+			continue
+		}
+		problem := collector.VMProblem(m)
+		if problem == "" {
+			continue
+		}
+		problems[*m.Entity] = problem
+		if s.problems[*m.Entity] == problem {
+			continue
+		}
+
+		png, err := s.Collector.Screenshot(ctx, *m.Entity)
+		if err == nil {
+			key := fmt.Sprintf("%s/%s-%s/%s-%s.png", m.Entity.VCenter, unsafeKey.ReplaceAllString(m.Tag("name"), "_"),
+				m.Entity.MOID, m.Time.UTC().Format("20060102T150405Z"), problem)
+			if err = s.Store.Put(ctx, key, png, "image/png"); err == nil {
+				slog.Info("captured virtual machine console", "vm", m.Tag("name"), "problem", problem, "key", key)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s screenshot: %w", m.Tag("name"), err))
+		}
+	}
+
+	s.problems = problems
+	return errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// DefaultEnterpriseOID is the OID traps are sent under by default, the
+// netSnmpPlaypen experimental subtree.
+const DefaultEnterpriseOID = ".1.3.6.1.4.1.8072.9999.9999"
+
+// snmpTrapOID is the snmpTrapOID.0 varbind naming the notification of a trap.
+const snmpTrapOID = ".1.3.6.1.6.3.1.1.4.1.0"
+
+// SNMPTrap sends critical alerts, and their resolution to a lesser state, as
+// SNMPv2c traps. Warnings are not sent.
+//
+// Under the enterprise OID, notification .0.1 is a critical alert and .0.2
+// its resolution, of varbinds .1.1 rule, .1.2 state, .1.3 measurement, .1.4
+// name, .1.5 value, .1.6 vcenter and .1.7 MOID, all strings.
+type SNMPTrap struct {
+	// Target is the host:port of the trap receiver.
+	Target        string
+	Community     string
+	EnterpriseOID string
+	Timeout       time.Duration
+}
+
+// NewSNMPTrap returns an SNMPTrap sending to target, host or host:port with
+// port 162 by default, under DefaultEnterpriseOID unless oid is set.
+func NewSNMPTrap(target, community, oid string) (*SNMPTrap, error) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, "162")
+	}
+	if oid == "" {
+		oid = DefaultEnterpriseOID
+	}
+	for _, n := range strings.Split(strings.TrimPrefix(oid, "."), ".") {
+		if _, err := strconv.ParseUint(n, 10, 32); err != nil {
+			return nil, fmt.Errorf("invalid enterprise OID %q", oid)
+		}
+	}
+	return &SNMPTrap{Target: target, Community: community, EnterpriseOID: oid, Timeout: 5 * time.Second}, nil
+}
+
+// Send sends a trap per critical alert or resolution of one.
+func (s *SNMPTrap) Send(ctx context.Context, alerts []Alert) error {
+	host, port, err := net.SplitHostPort(s.Target)
+	if err != nil {
+		return err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid SNMP target %q", s.Target)
+	}
+
+	g := &gosnmp.GoSNMP{
+		Target:    host,
+		Port:      uint16(p),
+		Community: s.Community,
+		Version:   gosnmp.Version2c,
+		Timeout:   s.Timeout,
+		Context:   ctx,
+	}
+	if err := g.Connect(); err != nil {
+		return err
+	}
+	defer g.Conn.Close()
+
+	critical := collector.Critical.String()
+	for _, a := range alerts {
+		notification := ""
+		switch {
+		case a.State == critical:
+			notification = s.EnterpriseOID + ".0.1"
+		case a.Previous == critical:
+			notification = s.EnterpriseOID + ".0.2"
+		default:
+			continue
+		}
+
+		var vcenter, moid string
+		if a.Entity != nil {
+			vcenter, moid = a.Entity.VCenter, a.Entity.MOID
+		}
+
+		vars := []gosnmp.SnmpPDU{{Name: snmpTrapOID, Type: gosnmp.ObjectIdentifier, Value: notification}}
+		for i, v := range []string{a.Rule, a.State, a.Measurement, a.Name, strconv.FormatFloat(a.Value, 'g', -1, 64), vcenter, moid} {
+			vars = append(vars, gosnmp.SnmpPDU{Name: fmt.Sprintf("%s.1.%d", s.EnterpriseOID, i+1), Type: gosnmp.OctetString, Value: v})
+		}
+
+		if _, err := g.SendTrap(gosnmp.SnmpTrap{Variables: vars}); err != nil {
+			return fmt.Errorf("snmp trap to %s: %w", s.Target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Webhook posts alerts as JSON to a URL.
+type Webhook struct {
+	URL string
+	// Format is the payload: generic, slack or teams.
+	Format string
+}
+
+// NewWebhook returns a Webhook posting to url in format: generic for
+// {"alerts": [...]}, slack for a Slack incoming webhook message or teams for a
+// Microsoft Teams connector card.
+func NewWebhook(url, format string) (*Webhook, error) {
+	switch format {
+	case "generic", "slack", "teams":
+	case "":
+		format = "generic"
+	default:
+		return nil, fmt.Errorf("invalid webhook format %q", format)
+	}
+	return &Webhook{URL: url, Format: format}, nil
+}
+
+// payload returns the body posting alerts.
+func (w *Webhook) payload(alerts []Alert) interface{} {
+	lines := make([]string, len(alerts))
+	for i, a := range alerts {
+		lines[i] = a.String()
+	}
+	text := strings.Join(lines, "\n")
+
+	switch w.Format {
+	case "slack":
+		return map[string]string{"text": text}
+	case "teams":
+		return map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  fmt.Sprintf("%d vSphere alerts", len(alerts)),
+			// Teams renders text as markdown, where single newlines are ignored
+			"text": strings.Join(lines, "\n\n"),
+		}
+	}
+	return map[string]interface{}{"alerts": alerts}
+}
+
+// Send posts alerts in a single request.
+func (w *Webhook) Send(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(w.payload(alerts))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := collector.Outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("webhook: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Package ansible maps the virtual machines of the inventory of endpoints to
+// an Ansible dynamic inventory, as printed by inventory scripts for --list.
+//
+//	entries, err := collector.Inventory(ctx, c)
+//	...
+//	err = json.NewEncoder(os.Stdout).Encode(ansible.FromInventory(entries))
+package ansible
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Inventory is an Ansible dynamic inventory: groups by name, and the
+// variables of hosts under _meta.
+type Inventory map[string]interface{}
+
+// Group is a group of an Inventory.
+type Group struct {
+	Hosts    []string `json:"hosts,omitempty"`
+	Children []string `json:"children,omitempty"`
+}
+
+// Meta holds the variables of every host, sparing Ansible a --host call per
+// host.
+type Meta struct {
+	HostVars map[string]map[string]interface{} `json:"hostvars"`
+}
+
+// FromInventory returns the inventory of the virtual machines of entries,
+// grouped by cluster as cluster_NAME, by power state as power_STATE and by
+// vSphere tag as tag_CATEGORY_TAG, those in none of them as ungrouped, with
+// their guest IP address as ansible_host and their custom attributes and tags
+// as variables.
+//
+// Virtual machines are named by name, or name_MOID for those named as
+// another one.
+func FromInventory(entries []collector.InventoryEntry) Inventory {
+	// Hosts are inventoried under their cluster, VMs reference their host
+	clusters := make(map[string]string)
+	for _, e := range entries {
+		if e.Kind == "cluster" {
+			clusters[e.Endpoint+e.Path] = e.Name
+		}
+	}
+	hostClusters := make(map[string]string)
+	for _, e := range entries {
+		if e.Kind == "host" {
+			if cluster, ok := clusters[e.Endpoint+path.Dir(e.Path)]; ok {
+				hostClusters[e.Endpoint+"/"+e.MOID] = cluster
+			}
+		}
+	}
+
+	groups := make(map[string]*Group)
+	grouped := make(map[string]bool)
+	add := func(group, host string) {
+		grouped[host] = true
+		group = groupName(group)
+		g, ok := groups[group]
+		if !ok {
+			g = &Group{}
+			groups[group] = g
+		}
+		g.Hosts = append(g.Hosts, host)
+	}
+
+	meta := Meta{HostVars: make(map[string]map[string]interface{})}
+	for _, e := range entries {
+		if e.Kind != "vm" {
+			continue
+		}
+
+		name := e.Name
+		if _, ok := meta.HostVars[name]; ok {
+			name += "_" + e.MOID
+		}
+
+		vars := map[string]interface{}{
+			"vsphere_endpoint":   e.Endpoint,
+			"vsphere_datacenter": e.Datacenter,
+			"vsphere_path":       e.Path,
+			"vsphere_moid":       e.MOID,
+		}
+		if ip, ok := e.Summary["ip_address"].(string); ok {
+			vars["ansible_host"] = ip
+		}
+		if state, ok := e.Summary["power_state"].(string); ok {
+			vars["vsphere_power_state"] = state
+			add("power_"+state, name)
+		}
+		if guest, ok := e.Summary["guest_full_name"].(string); ok && guest != "" {
+			vars["vsphere_guest_full_name"] = guest
+		}
+		if host, ok := e.Summary["host"].(string); ok {
+			if cluster, ok := hostClusters[e.Endpoint+"/"+host]; ok {
+				vars["vsphere_cluster"] = cluster
+				add("cluster_"+cluster, name)
+			}
+		}
+		if len(e.Attributes) != 0 {
+			vars["vsphere_attributes"] = e.Attributes
+		}
+		if len(e.Tags) != 0 {
+			vars["vsphere_tags"] = e.Tags
+		}
+		for _, tag := range e.Tags {
+			add("tag_"+strings.Replace(tag, ":", "_", 1), name)
+		}
+
+		meta.HostVars[name] = vars
+		if !grouped[name] {
+			add("ungrouped", name)
+		}
+	}
+
+	inv := Inventory{"_meta": meta}
+	all := &Group{}
+	for name, g := range groups {
+		all.Children = append(all.Children, name)
+		inv[name] = g
+	}
+	sort.Strings(all.Children)
+	inv["all"] = all
+	return inv
+}
+
+// groupName replaces the characters Ansible group names cannot have by
+// underscores.
+func groupName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
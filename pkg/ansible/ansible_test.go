@@ -0,0 +1,48 @@
+package ansible
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestFromInventory(t *testing.T) {
+	inv := FromInventory([]collector.InventoryEntry{
+		{Endpoint: "vc", Kind: "cluster", Name: "prod-1", Path: "/DC0/host/prod-1", MOID: "domain-c1"},
+		{Endpoint: "vc", Kind: "host", Name: "esx01", Path: "/DC0/host/prod-1/esx01", MOID: "host-21"},
+		{
+			Endpoint: "vc", Kind: "vm", Name: "web", Path: "/DC0/vm/web", MOID: "vm-42",
+			Summary:    map[string]interface{}{"power_state": "poweredOn", "host": "host-21", "ip_address": "10.0.0.10"},
+			Attributes: map[string]string{"owner": "web-team"},
+			Tags:       []string{"env:prod"},
+		},
+		{Endpoint: "vc", Kind: "vm", Name: "web", Path: "/DC0/vm/old/web", MOID: "vm-43", Summary: map[string]interface{}{"power_state": "poweredOff"}},
+		{Endpoint: "vc", Kind: "vm", Name: "orphan", Path: "/DC0/vm/orphan", MOID: "vm-44"},
+	})
+
+	for group, hosts := range map[string][]string{
+		"cluster_prod_1":   {"web"},
+		"power_poweredOn":  {"web"},
+		"power_poweredOff": {"web_vm-43"},
+		"tag_env_prod":     {"web"},
+		"ungrouped":        {"orphan"},
+	} {
+		g, ok := inv[group].(*Group)
+		if !ok || !reflect.DeepEqual(g.Hosts, hosts) {
+			t.Errorf("group %s: %+v, expected hosts %v", group, inv[group], hosts)
+		}
+	}
+
+	if all := inv["all"].(*Group); !reflect.DeepEqual(all.Children, []string{"cluster_prod_1", "power_poweredOff", "power_poweredOn", "tag_env_prod", "ungrouped"}) {
+		t.Errorf("all children %v", all.Children)
+	}
+
+	vars := inv["_meta"].(Meta).HostVars["web"]
+	if vars["ansible_host"] != "10.0.0.10" || vars["vsphere_cluster"] != "prod-1" {
+		t.Errorf("hostvars %v", vars)
+	}
+	if attrs, _ := vars["vsphere_attributes"].(map[string]string); attrs["owner"] != "web-team" {
+		t.Errorf("attributes %v", vars["vsphere_attributes"])
+	}
+}
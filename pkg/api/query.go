@@ -0,0 +1,131 @@
+package api
+
+//go:generate protoc -I . -I third_party --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. --grpc-gateway_out=paths=source_relative,omit_package_doc=true:. query.proto
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Register registers the Query service of store on s.
+func Register(s *grpc.Server, store *Store) {
+	RegisterQueryServer(s, &queryServer{store: store})
+}
+
+// queryServer is the QueryServer of the snapshots of store.
+type queryServer struct {
+	UnimplementedQueryServer
+	store *Store
+}
+
+func (s *queryServer) Metrics(ctx context.Context, req *MetricsRequest) (*MetricsResponse, error) {
+	metrics, ts := s.store.Metrics()
+	if ts.IsZero() {
+		return nil, status.Error(codes.Unavailable, "no metrics collected yet")
+	}
+
+	resp := &MetricsResponse{Time: timestamppb.New(ts)}
+	for _, m := range metrics {
+		if req.Measurement != "" && m.Name != req.Measurement {
+			continue
+		}
+		if matchTags(m.Tags, req.Tags) {
+			resp.Metrics = append(resp.Metrics, newMetric(m))
+		}
+	}
+	return resp, nil
+}
+
+func (s *queryServer) Inventory(ctx context.Context, req *InventoryRequest) (*InventoryResponse, error) {
+	entries, ts := s.store.Inventory()
+	if ts.IsZero() {
+		return nil, status.Error(codes.Unavailable, "no inventory listed yet")
+	}
+
+	resp := &InventoryResponse{Time: timestamppb.New(ts)}
+	for _, e := range entries {
+		if req.Endpoint != "" && e.Endpoint != req.Endpoint {
+			continue
+		}
+		if req.Kind != "" && e.Kind != req.Kind {
+			continue
+		}
+		if matchSummary(e.Summary, req.Summary) {
+			resp.Entries = append(resp.Entries, newInventoryEntry(e))
+		}
+	}
+	return resp, nil
+}
+
+func matchTags(tags, want map[string]string) bool {
+	for k, v := range want {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSummary compares summary fields by their printed values, as those of
+// the request are strings.
+func matchSummary(summary map[string]interface{}, want map[string]string) bool {
+	for k, v := range want {
+		got, ok := summary[k]
+		if !ok || fmt.Sprint(got) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func newMetric(m collector.Metric) *Metric {
+	pm := &Metric{
+		Name:   m.Name,
+		Tags:   m.Tags,
+		Fields: newValues(m.Fields),
+		Time:   timestamppb.New(m.Time),
+	}
+	if m.Entity != nil {
+		pm.Entity = &EntityRef{Vcenter: m.Entity.VCenter, Type: m.Entity.Type, Moid: m.Entity.MOID}
+	}
+	return pm
+}
+
+func newInventoryEntry(e collector.InventoryEntry) *InventoryEntry {
+	return &InventoryEntry{
+		Endpoint:   e.Endpoint,
+		Datacenter: e.Datacenter,
+		Kind:       e.Kind,
+		Name:       e.Name,
+		Path:       e.Path,
+		Moid:       e.MOID,
+		Summary:    newValues(e.Summary),
+		Attributes: e.Attributes,
+		Tags:       e.Tags,
+	}
+}
+
+// newValues returns the Values of fields, printing those of types a Value
+// can't hold.
+func newValues(fields map[string]interface{}) map[string]*structpb.Value {
+	if len(fields) == 0 {
+		return nil
+	}
+	values := make(map[string]*structpb.Value, len(fields))
+	for k, v := range fields {
+		pv, err := structpb.NewValue(v)
+		if err != nil {
+			pv = structpb.NewStringValue(fmt.Sprint(v))
+		}
+		values[k] = pv
+	}
+	return values
+}
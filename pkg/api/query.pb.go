@@ -0,0 +1,647 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: query.proto
+
+package api
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MetricsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The measurement of the metrics returned, any if empty.
+	Measurement string `protobuf:"bytes,1,opt,name=measurement,proto3" json:"measurement,omitempty"`
+	// Tags every metric returned has.
+	Tags          map[string]string `protobuf:"bytes,2,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricsRequest) Reset() {
+	*x = MetricsRequest{}
+	mi := &file_query_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricsRequest) ProtoMessage() {}
+
+func (x *MetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_query_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricsRequest.ProtoReflect.Descriptor instead.
+func (*MetricsRequest) Descriptor() ([]byte, []int) {
+	return file_query_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MetricsRequest) GetMeasurement() string {
+	if x != nil {
+		return x.Measurement
+	}
+	return ""
+}
+
+func (x *MetricsRequest) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type MetricsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// When the snapshot was collected.
+	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Metrics       []*Metric              `protobuf:"bytes,2,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricsResponse) Reset() {
+	*x = MetricsResponse{}
+	mi := &file_query_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricsResponse) ProtoMessage() {}
+
+func (x *MetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_query_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricsResponse.ProtoReflect.Descriptor instead.
+func (*MetricsResponse) Descriptor() ([]byte, []int) {
+	return file_query_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MetricsResponse) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *MetricsResponse) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+// Metric is a point of a measurement, such as vm.
+type Metric struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Tags  map[string]string      `protobuf:"bytes,2,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The fields of the point, of the types the schema command describes.
+	Fields map[string]*structpb.Value `protobuf:"bytes,3,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Time   *timestamppb.Timestamp     `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	// The managed object of the point, unset for those of none, such as
+	// cycle.
+	Entity        *EntityRef `protobuf:"bytes,5,opt,name=entity,proto3" json:"entity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metric) Reset() {
+	*x = Metric{}
+	mi := &file_query_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metric) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metric) ProtoMessage() {}
+
+func (x *Metric) ProtoReflect() protoreflect.Message {
+	mi := &file_query_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metric.ProtoReflect.Descriptor instead.
+func (*Metric) Descriptor() ([]byte, []int) {
+	return file_query_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Metric) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Metric) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Metric) GetFields() map[string]*structpb.Value {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *Metric) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Metric) GetEntity() *EntityRef {
+	if x != nil {
+		return x.Entity
+	}
+	return nil
+}
+
+// EntityRef is a managed object of a vCenter.
+type EntityRef struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Vcenter string                 `protobuf:"bytes,1,opt,name=vcenter,proto3" json:"vcenter,omitempty"`
+	// The managed object type, such as VirtualMachine.
+	Type          string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Moid          string `protobuf:"bytes,3,opt,name=moid,proto3" json:"moid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EntityRef) Reset() {
+	*x = EntityRef{}
+	mi := &file_query_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EntityRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EntityRef) ProtoMessage() {}
+
+func (x *EntityRef) ProtoReflect() protoreflect.Message {
+	mi := &file_query_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EntityRef.ProtoReflect.Descriptor instead.
+func (*EntityRef) Descriptor() ([]byte, []int) {
+	return file_query_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EntityRef) GetVcenter() string {
+	if x != nil {
+		return x.Vcenter
+	}
+	return ""
+}
+
+func (x *EntityRef) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *EntityRef) GetMoid() string {
+	if x != nil {
+		return x.Moid
+	}
+	return ""
+}
+
+type InventoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The endpoint of the entries returned, any if empty.
+	Endpoint string `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	// The kind of the entries returned, such as vm, any if empty.
+	Kind string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	// Summary fields every entry returned has, compared by their printed
+	// values.
+	Summary       map[string]string `protobuf:"bytes,3,rep,name=summary,proto3" json:"summary,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InventoryRequest) Reset() {
+	*x = InventoryRequest{}
+	mi := &file_query_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InventoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InventoryRequest) ProtoMessage() {}
+
+func (x *InventoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_query_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InventoryRequest.ProtoReflect.Descriptor instead.
+func (*InventoryRequest) Descriptor() ([]byte, []int) {
+	return file_query_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *InventoryRequest) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *InventoryRequest) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *InventoryRequest) GetSummary() map[string]string {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+type InventoryResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// When the snapshot was listed.
+	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Entries       []*InventoryEntry      `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InventoryResponse) Reset() {
+	*x = InventoryResponse{}
+	mi := &file_query_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InventoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InventoryResponse) ProtoMessage() {}
+
+func (x *InventoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_query_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InventoryResponse.ProtoReflect.Descriptor instead.
+func (*InventoryResponse) Descriptor() ([]byte, []int) {
+	return file_query_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *InventoryResponse) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *InventoryResponse) GetEntries() []*InventoryEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+// InventoryEntry is a datacenter, cluster, host, datastore or virtual
+// machine of the inventory of an endpoint.
+type InventoryEntry struct {
+	state      protoimpl.MessageState     `protogen:"open.v1"`
+	Endpoint   string                     `protobuf:"bytes,1,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Datacenter string                     `protobuf:"bytes,2,opt,name=datacenter,proto3" json:"datacenter,omitempty"`
+	Kind       string                     `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name       string                     `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Path       string                     `protobuf:"bytes,5,opt,name=path,proto3" json:"path,omitempty"`
+	Moid       string                     `protobuf:"bytes,6,opt,name=moid,proto3" json:"moid,omitempty"`
+	Summary    map[string]*structpb.Value `protobuf:"bytes,7,rep,name=summary,proto3" json:"summary,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The custom attributes of virtual machines, by name.
+	Attributes map[string]string `protobuf:"bytes,8,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The vSphere tags of virtual machines, as category:tag.
+	Tags          []string `protobuf:"bytes,9,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InventoryEntry) Reset() {
+	*x = InventoryEntry{}
+	mi := &file_query_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InventoryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InventoryEntry) ProtoMessage() {}
+
+func (x *InventoryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_query_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InventoryEntry.ProtoReflect.Descriptor instead.
+func (*InventoryEntry) Descriptor() ([]byte, []int) {
+	return file_query_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *InventoryEntry) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *InventoryEntry) GetDatacenter() string {
+	if x != nil {
+		return x.Datacenter
+	}
+	return ""
+}
+
+func (x *InventoryEntry) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *InventoryEntry) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *InventoryEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *InventoryEntry) GetMoid() string {
+	if x != nil {
+		return x.Moid
+	}
+	return ""
+}
+
+func (x *InventoryEntry) GetSummary() map[string]*structpb.Value {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+func (x *InventoryEntry) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *InventoryEntry) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+var File_query_proto protoreflect.FileDescriptor
+
+const file_query_proto_rawDesc = "" +
+	"\n" +
+	"\vquery.proto\x12\x13vspherecollector.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xae\x01\n" +
+	"\x0eMetricsRequest\x12 \n" +
+	"\vmeasurement\x18\x01 \x01(\tR\vmeasurement\x12A\n" +
+	"\x04tags\x18\x02 \x03(\v2-.vspherecollector.v1.MetricsRequest.TagsEntryR\x04tags\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"x\n" +
+	"\x0fMetricsResponse\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x125\n" +
+	"\ametrics\x18\x02 \x03(\v2\x1b.vspherecollector.v1.MetricR\ametrics\"\x8c\x03\n" +
+	"\x06Metric\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x129\n" +
+	"\x04tags\x18\x02 \x03(\v2%.vspherecollector.v1.Metric.TagsEntryR\x04tags\x12?\n" +
+	"\x06fields\x18\x03 \x03(\v2'.vspherecollector.v1.Metric.FieldsEntryR\x06fields\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x126\n" +
+	"\x06entity\x18\x05 \x01(\v2\x1e.vspherecollector.v1.EntityRefR\x06entity\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aQ\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x05value:\x028\x01\"M\n" +
+	"\tEntityRef\x12\x18\n" +
+	"\avcenter\x18\x01 \x01(\tR\avcenter\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x12\n" +
+	"\x04moid\x18\x03 \x01(\tR\x04moid\"\xcc\x01\n" +
+	"\x10InventoryRequest\x12\x1a\n" +
+	"\bendpoint\x18\x01 \x01(\tR\bendpoint\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12L\n" +
+	"\asummary\x18\x03 \x03(\v22.vspherecollector.v1.InventoryRequest.SummaryEntryR\asummary\x1a:\n" +
+	"\fSummaryEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x82\x01\n" +
+	"\x11InventoryResponse\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12=\n" +
+	"\aentries\x18\x02 \x03(\v2#.vspherecollector.v1.InventoryEntryR\aentries\"\xe4\x03\n" +
+	"\x0eInventoryEntry\x12\x1a\n" +
+	"\bendpoint\x18\x01 \x01(\tR\bendpoint\x12\x1e\n" +
+	"\n" +
+	"datacenter\x18\x02 \x01(\tR\n" +
+	"datacenter\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x12\n" +
+	"\x04path\x18\x05 \x01(\tR\x04path\x12\x12\n" +
+	"\x04moid\x18\x06 \x01(\tR\x04moid\x12J\n" +
+	"\asummary\x18\a \x03(\v20.vspherecollector.v1.InventoryEntry.SummaryEntryR\asummary\x12S\n" +
+	"\n" +
+	"attributes\x18\b \x03(\v23.vspherecollector.v1.InventoryEntry.AttributesEntryR\n" +
+	"attributes\x12\x12\n" +
+	"\x04tags\x18\t \x03(\tR\x04tags\x1aR\n" +
+	"\fSummaryEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x05value:\x028\x01\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xff\x01\n" +
+	"\x05Query\x12v\n" +
+	"\aMetrics\x12#.vspherecollector.v1.MetricsRequest\x1a$.vspherecollector.v1.MetricsResponse\" \x82\xd3\xe4\x93\x02\x1a:\x01*\"\x15/api/v1/query/metrics\x12~\n" +
+	"\tInventory\x12%.vspherecollector.v1.InventoryRequest\x1a&.vspherecollector.v1.InventoryResponse\"\"\x82\xd3\xe4\x93\x02\x1c:\x01*\"\x17/api/v1/query/inventoryB1Z/github.com/mlabouardy/vsphere-collector/pkg/apib\x06proto3"
+
+var (
+	file_query_proto_rawDescOnce sync.Once
+	file_query_proto_rawDescData []byte
+)
+
+func file_query_proto_rawDescGZIP() []byte {
+	file_query_proto_rawDescOnce.Do(func() {
+		file_query_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_query_proto_rawDesc), len(file_query_proto_rawDesc)))
+	})
+	return file_query_proto_rawDescData
+}
+
+var file_query_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_query_proto_goTypes = []any{
+	(*MetricsRequest)(nil),        // 0: vspherecollector.v1.MetricsRequest
+	(*MetricsResponse)(nil),       // 1: vspherecollector.v1.MetricsResponse
+	(*Metric)(nil),                // 2: vspherecollector.v1.Metric
+	(*EntityRef)(nil),             // 3: vspherecollector.v1.EntityRef
+	(*InventoryRequest)(nil),      // 4: vspherecollector.v1.InventoryRequest
+	(*InventoryResponse)(nil),     // 5: vspherecollector.v1.InventoryResponse
+	(*InventoryEntry)(nil),        // 6: vspherecollector.v1.InventoryEntry
+	nil,                           // 7: vspherecollector.v1.MetricsRequest.TagsEntry
+	nil,                           // 8: vspherecollector.v1.Metric.TagsEntry
+	nil,                           // 9: vspherecollector.v1.Metric.FieldsEntry
+	nil,                           // 10: vspherecollector.v1.InventoryRequest.SummaryEntry
+	nil,                           // 11: vspherecollector.v1.InventoryEntry.SummaryEntry
+	nil,                           // 12: vspherecollector.v1.InventoryEntry.AttributesEntry
+	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
+	(*structpb.Value)(nil),        // 14: google.protobuf.Value
+}
+var file_query_proto_depIdxs = []int32{
+	7,  // 0: vspherecollector.v1.MetricsRequest.tags:type_name -> vspherecollector.v1.MetricsRequest.TagsEntry
+	13, // 1: vspherecollector.v1.MetricsResponse.time:type_name -> google.protobuf.Timestamp
+	2,  // 2: vspherecollector.v1.MetricsResponse.metrics:type_name -> vspherecollector.v1.Metric
+	8,  // 3: vspherecollector.v1.Metric.tags:type_name -> vspherecollector.v1.Metric.TagsEntry
+	9,  // 4: vspherecollector.v1.Metric.fields:type_name -> vspherecollector.v1.Metric.FieldsEntry
+	13, // 5: vspherecollector.v1.Metric.time:type_name -> google.protobuf.Timestamp
+	3,  // 6: vspherecollector.v1.Metric.entity:type_name -> vspherecollector.v1.EntityRef
+	10, // 7: vspherecollector.v1.InventoryRequest.summary:type_name -> vspherecollector.v1.InventoryRequest.SummaryEntry
+	13, // 8: vspherecollector.v1.InventoryResponse.time:type_name -> google.protobuf.Timestamp
+	6,  // 9: vspherecollector.v1.InventoryResponse.entries:type_name -> vspherecollector.v1.InventoryEntry
+	11, // 10: vspherecollector.v1.InventoryEntry.summary:type_name -> vspherecollector.v1.InventoryEntry.SummaryEntry
+	12, // 11: vspherecollector.v1.InventoryEntry.attributes:type_name -> vspherecollector.v1.InventoryEntry.AttributesEntry
+	14, // 12: vspherecollector.v1.Metric.FieldsEntry.value:type_name -> google.protobuf.Value
+	14, // 13: vspherecollector.v1.InventoryEntry.SummaryEntry.value:type_name -> google.protobuf.Value
+	0,  // 14: vspherecollector.v1.Query.Metrics:input_type -> vspherecollector.v1.MetricsRequest
+	4,  // 15: vspherecollector.v1.Query.Inventory:input_type -> vspherecollector.v1.InventoryRequest
+	1,  // 16: vspherecollector.v1.Query.Metrics:output_type -> vspherecollector.v1.MetricsResponse
+	5,  // 17: vspherecollector.v1.Query.Inventory:output_type -> vspherecollector.v1.InventoryResponse
+	16, // [16:18] is the sub-list for method output_type
+	14, // [14:16] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_query_proto_init() }
+func file_query_proto_init() {
+	if File_query_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_query_proto_rawDesc), len(file_query_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_query_proto_goTypes,
+		DependencyIndexes: file_query_proto_depIdxs,
+		MessageInfos:      file_query_proto_msgTypes,
+	}.Build()
+	File_query_proto = out.File
+	file_query_proto_goTypes = nil
+	file_query_proto_depIdxs = nil
+}
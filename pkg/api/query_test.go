@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestQueryInventory(t *testing.T) {
+	store := &Store{}
+	s := &queryServer{store: store}
+	ctx := context.Background()
+
+	req := &InventoryRequest{Kind: "vm", Summary: map[string]string{"host": "host-21"}}
+	if _, err := s.Inventory(ctx, req); status.Code(err) != codes.Unavailable {
+		t.Errorf("empty store: %v", err)
+	}
+
+	store.SetInventory(time.Now(), []collector.InventoryEntry{
+		{Kind: "host", Name: "h0", MOID: "host-21"},
+		{Kind: "vm", Name: "vm0", MOID: "vm-1", Summary: map[string]interface{}{"host": "host-21", "num_cpu": int32(2)}},
+		{Kind: "vm", Name: "vm1", MOID: "vm-2", Summary: map[string]interface{}{"host": "host-22"}},
+	})
+
+	resp, err := s.Inventory(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Name != "vm0" || resp.Entries[0].Summary["num_cpu"].GetNumberValue() != 2 {
+		t.Errorf("entries %v, expected vm0", resp.Entries)
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	store := &Store{}
+	s := &queryServer{store: store}
+
+	m, err := collector.NewMetric("build_info", map[string]string{"name": "vm0"}, map[string]interface{}{"value": 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetMetrics(m.Time, []collector.Metric{m})
+
+	resp, err := s.Metrics(context.Background(), &MetricsRequest{Tags: map[string]string{"name": "vm1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(resp.Metrics); n != 0 {
+		t.Errorf("%d metrics, expected none", n)
+	}
+}
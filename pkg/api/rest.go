@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// restKinds are the managed object types of the entities of each REST path.
+var restKinds = map[string]string{
+	"/api/v1/vms":        "VirtualMachine",
+	"/api/v1/hosts":      "HostSystem",
+	"/api/v1/datastores": "Datastore",
+}
+
+// restMeasurements are the measurements whose tags are those of the
+// entities of each managed object type.
+var restMeasurements = map[string]string{
+	"VirtualMachine": "vm",
+	"HostSystem":     "host",
+	"Datastore":      "datastore",
+}
+
+// Entity is the latest snapshot of a managed object served by the REST API:
+// the tags of its primary measurement, such as vm, and the fields of every
+// measurement of it, by measurement.
+type Entity struct {
+	VCenter string                            `json:"vcenter"`
+	MOID    string                            `json:"moid"`
+	Tags    map[string]string                 `json:"tags"`
+	Records map[string]map[string]interface{} `json:"records"`
+}
+
+// NewHandler returns the handler of the REST API of store, serving
+// {"time", "entities"} at /api/v1/vms, /api/v1/hosts and /api/v1/datastores,
+// of the entities whose tags have the values of every query parameter, such
+// as /api/v1/vms?cluster=prod, and the Metrics and Inventory methods of the
+// Query service at /api/v1/query/metrics and /api/v1/query/inventory.
+func NewHandler(store *Store) http.Handler {
+	mux := http.NewServeMux()
+
+	// The Query service is mapped as of the google.api.http options of
+	// query.proto, of its field names in responses
+	gw := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{UseProtoNames: true},
+	}))
+	RegisterQueryHandlerServer(context.Background(), gw, &queryServer{store: store})
+	mux.Handle("/api/v1/query/", gw)
+
+	for p, kind := range restKinds {
+		kind := kind
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+
+			metrics, ts := store.Metrics()
+			if ts.IsZero() {
+				http.Error(w, "no metrics collected yet", http.StatusServiceUnavailable)
+				return
+			}
+
+			want := make(map[string]string)
+			for k, v := range r.URL.Query() {
+				want[k] = v[0]
+			}
+			writeJSON(w, ts, entities(metrics, kind, want))
+		})
+	}
+	return mux
+}
+
+// entities returns the Entity of every managed object of kind in metrics
+// whose tags match want, ordered by vCenter and managed object ID.
+func entities(metrics []collector.Metric, kind string, want map[string]string) []Entity {
+	byRef := make(map[collector.EntityRef]*Entity)
+	for _, m := range metrics {
+		if m.Entity == nil || m.Entity.Type != kind {
+			continue
+		}
+		e := byRef[*m.Entity]
+		if e == nil {
+			e = &Entity{VCenter: m.Entity.VCenter, MOID: m.Entity.MOID, Records: make(map[string]map[string]interface{})}
+			byRef[*m.Entity] = e
+		}
+		e.Records[m.Name] = m.Fields
+		if m.Name == restMeasurements[kind] {
+			e.Tags = m.Tags
+		}
+	}
+
+	matched := []Entity{}
+	for _, e := range byRef {
+		// Entities only of secondary measurements have no tags to match
+		if e.Tags != nil && matchTags(e.Tags, want) {
+			matched = append(matched, *e)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].VCenter != matched[j].VCenter {
+			return matched[i].VCenter < matched[j].VCenter
+		}
+		return matched[i].MOID < matched[j].MOID
+	})
+	return matched
+}
+
+func writeJSON(w http.ResponseWriter, ts time.Time, entities []Entity) {
+	b, err := json.Marshal(map[string]interface{}{"time": ts, "entities": entities})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestREST(t *testing.T) {
+	store := &Store{}
+	h := NewHandler(store)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/vms", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("empty store: status %d", rec.Code)
+	}
+
+	vm := func(moid string) *collector.EntityRef {
+		return &collector.EntityRef{VCenter: "vc0", Type: "VirtualMachine", MOID: moid}
+	}
+	now := time.Now()
+	store.SetMetrics(now, []collector.Metric{
+		{Name: "vm", Tags: map[string]string{"name": "vm1", "cluster": "prod"}, Fields: map[string]interface{}{"num_cpu": 2}, Entity: vm("vm-2")},
+		{Name: "vm_perf", Tags: map[string]string{"name": "vm1"}, Fields: map[string]interface{}{"cpu_ready_pct": 1.5}, Entity: vm("vm-2")},
+		{Name: "vm", Tags: map[string]string{"name": "vm0", "cluster": "test"}, Fields: map[string]interface{}{"num_cpu": 1}, Entity: vm("vm-1")},
+		{Name: "host", Tags: map[string]string{"name": "h0"}, Fields: map[string]interface{}{"available": 1}, Entity: &collector.EntityRef{VCenter: "vc0", Type: "HostSystem", MOID: "host-21"}},
+		{Name: "cycle", Fields: map[string]interface{}{"duration_sec": 1.0}},
+	})
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/vms?cluster=prod", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Entities []Entity `json:"entities"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entities) != 1 {
+		t.Fatalf("entities %+v, expected vm-2", resp.Entities)
+	}
+	e := resp.Entities[0]
+	if e.MOID != "vm-2" || e.Tags["name"] != "vm1" || len(e.Records) != 2 || e.Records["vm_perf"]["cpu_ready_pct"] != 1.5 {
+		t.Errorf("entity %+v, expected vm-2 of its vm and vm_perf records", e)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/vms", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entities) != 2 || resp.Entities[0].MOID != "vm-1" {
+		t.Errorf("entities %+v, expected vm-1 then vm-2", resp.Entities)
+	}
+}
+
+func TestRESTQuery(t *testing.T) {
+	store := &Store{}
+	h := NewHandler(store)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return rec
+	}
+	if rec := post("/api/v1/query/inventory", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("empty store: status %d", rec.Code)
+	}
+
+	now := time.Now()
+	store.SetInventory(now, []collector.InventoryEntry{
+		{Kind: "vm", Name: "vm0", MOID: "vm-1", Summary: map[string]interface{}{"host": "host-21"}},
+		{Kind: "vm", Name: "vm1", MOID: "vm-2", Summary: map[string]interface{}{"host": "host-22"}},
+	})
+	store.SetMetrics(now, []collector.Metric{
+		{Name: "vm", Tags: map[string]string{"name": "vm0"}, Fields: map[string]interface{}{"num_cpu": 2}},
+		{Name: "host", Tags: map[string]string{"name": "h0"}, Fields: map[string]interface{}{"available": 1}},
+	})
+
+	rec := post("/api/v1/query/inventory", `{"kind": "vm", "summary": {"host": "host-21"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var inventory struct {
+		Entries []collector.InventoryEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &inventory); err != nil {
+		t.Fatal(err)
+	}
+	if len(inventory.Entries) != 1 || inventory.Entries[0].Name != "vm0" {
+		t.Errorf("entries %+v, expected vm0", inventory.Entries)
+	}
+
+	rec = post("/api/v1/query/metrics", `{"measurement": "host"}`)
+	var metrics struct {
+		Metrics []map[string]interface{} `json:"metrics"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics.Metrics) != 1 || metrics.Metrics[0]["name"] != "host" {
+		t.Errorf("metrics %+v, expected host", metrics.Metrics)
+	}
+
+	if rec := post("/api/v1/query/metrics", `{"tags": "vm0"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid request: status %d", rec.Code)
+	}
+	// grpc-gateway answers methods of no mapping as Unimplemented
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/query/metrics", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("GET: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,61 @@
+// Package api serves the latest metrics and inventory collected by a daemon
+// over gRPC, and the latest snapshot of its entities over a JSON REST API,
+// so that other tools can query them without their own vCenter integration.
+//
+//	store := &api.Store{}
+//	s := grpc.NewServer()
+//	api.Register(s, store)
+//	go s.Serve(lis)
+//	go http.Serve(restLis, api.NewHandler(store))
+//	...
+//	store.SetMetrics(time.Now(), metrics)
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Store holds the latest snapshots of metrics and inventory. It is safe for
+// concurrent use.
+type Store struct {
+	mu            sync.RWMutex
+	metrics       []collector.Metric
+	metricsTime   time.Time
+	inventory     []collector.InventoryEntry
+	inventoryTime time.Time
+}
+
+// SetMetrics replaces the metrics snapshot with metrics collected at ts. The
+// metrics must not be modified afterwards.
+func (s *Store) SetMetrics(ts time.Time, metrics []collector.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics, s.metricsTime = metrics, ts
+}
+
+// Metrics returns the metrics snapshot and when it was collected, the zero
+// time if never.
+func (s *Store) Metrics() ([]collector.Metric, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.metrics, s.metricsTime
+}
+
+// SetInventory replaces the inventory snapshot with entries listed at ts. The
+// entries must not be modified afterwards.
+func (s *Store) SetInventory(ts time.Time, entries []collector.InventoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inventory, s.inventoryTime = entries, ts
+}
+
+// Inventory returns the inventory snapshot and when it was listed, the zero
+// time if never.
+func (s *Store) Inventory() ([]collector.InventoryEntry, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inventory, s.inventoryTime
+}
@@ -0,0 +1,123 @@
+package cmdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// API is the ServiceNow API CIs are sent through.
+type API string
+
+const (
+	// TableAPI upserts CIs into the table of their class directly, matched
+	// by correlation_id.
+	TableAPI API = "table"
+	// ImportSetAPI inserts CIs into an import set staging table, leaving
+	// reconciliation to its transform map. The class is in the
+	// sys_class_name field.
+	ImportSetAPI API = "import-set"
+)
+
+// ParseAPI parses an API.
+func ParseAPI(s string) (API, error) {
+	switch API(s) {
+	case TableAPI, ImportSetAPI:
+		return API(s), nil
+	}
+	return "", fmt.Errorf("invalid ServiceNow API %q: expected table or import-set", s)
+}
+
+// Client sends CIs to a ServiceNow instance.
+type Client struct {
+	// Instance is the base URL of the instance.
+	Instance string
+	Username string
+	Password string
+	API      API
+	// ImportSetTable is the staging table of ImportSetAPI.
+	ImportSetTable string
+}
+
+// Upsert creates ci, or updates the CI of its correlation ID with the Table
+// API.
+func (c *Client) Upsert(ctx context.Context, ci CI) error {
+	if c.API == ImportSetAPI {
+		fields := make(map[string]interface{}, len(ci.Fields)+1)
+		for k, v := range ci.Fields {
+			fields[k] = v
+		}
+		fields["sys_class_name"] = ci.Class
+		return c.do(ctx, http.MethodPost, "/api/now/import/"+url.PathEscape(c.ImportSetTable), nil, fields, nil)
+	}
+
+	query := url.Values{
+		"sysparm_query":  {"correlation_id=" + ci.CorrelationID},
+		"sysparm_fields": {"sys_id"},
+		"sysparm_limit":  {"1"},
+	}
+	var found struct {
+		Result []struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}
+	path := "/api/now/table/" + url.PathEscape(ci.Class)
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &found); err != nil {
+		return err
+	}
+
+	if len(found.Result) == 0 {
+		return c.do(ctx, http.MethodPost, path, nil, ci.Fields, nil)
+	}
+	return c.do(ctx, http.MethodPatch, path+"/"+url.PathEscape(found.Result[0].SysID), nil, ci.Fields, nil)
+}
+
+// do sends the JSON of body, nil for none, to path, decoding the JSON
+// response into res unless nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, res interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	u := strings.TrimSuffix(c.Instance, "/") + path
+	if query != nil {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := collector.Outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
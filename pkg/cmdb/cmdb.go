@@ -0,0 +1,91 @@
+// Package cmdb maps the inventory of endpoints to ServiceNow CMDB
+// configuration items and upserts them through the Table or Import Set API.
+//
+//	entries, err := collector.Inventory(ctx, c)
+//	...
+//	client := &cmdb.Client{Instance: "https://example.service-now.com", API: cmdb.TableAPI}
+//	for _, ci := range cmdb.FromInventory(entries) {
+//		err := client.Upsert(ctx, ci)
+//	}
+package cmdb
+
+import (
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// CI is a configuration item of a CMDB class, such as cmdb_ci_esx_server.
+type CI struct {
+	Class string `json:"class"`
+	// CorrelationID identifies the CI across exports, endpoint/MOID.
+	CorrelationID string                 `json:"correlation_id"`
+	Fields        map[string]interface{} `json:"fields"`
+}
+
+// Classes maps the kinds of inventory entries to CMDB classes.
+var Classes = map[string]string{
+	"datacenter": "cmdb_ci_vcenter_datacenter",
+	"cluster":    "cmdb_ci_vcenter_cluster",
+	"host":       "cmdb_ci_esx_server",
+	"datastore":  "cmdb_ci_vcenter_datastore",
+	"vm":         "cmdb_ci_vmware_instance",
+}
+
+// summaryFields maps the summary fields of inventory entries to CI fields,
+// by kind.
+var summaryFields = map[string]map[string]string{
+	"cluster": {
+		"num_hosts":   "hosts",
+		"drs_enabled": "drs_enabled",
+		"ha_enabled":  "ha_enabled",
+	},
+	"host": {
+		"model":         "model_id",
+		"version":       "os_version",
+		"num_cpu_cores": "cpu_core_count",
+		"mem_size":      "ram",
+	},
+	"datastore": {
+		"type":      "type",
+		"capacity":  "capacity",
+		"freespace": "free_space",
+	},
+	"vm": {
+		"power_state":     "state",
+		"guest_full_name": "guest_os_fullname",
+		"num_cpu":         "cpus",
+		"mem_mb":          "memory",
+	},
+}
+
+// FromInventory returns the CIs of entries, in order. Entries of kinds
+// without a class are left out.
+func FromInventory(entries []collector.InventoryEntry) []CI {
+	var cis []CI
+	for _, e := range entries {
+		class, ok := Classes[e.Kind]
+		if !ok {
+			continue
+		}
+
+		ci := CI{
+			Class:         class,
+			CorrelationID: e.Endpoint + "/" + e.MOID,
+			Fields: map[string]interface{}{
+				"name":      e.Name,
+				"object_id": e.MOID,
+			},
+		}
+		for k, v := range e.Summary {
+			if f, ok := summaryFields[e.Kind][k]; ok {
+				ci.Fields[f] = v
+			}
+		}
+		// Host memory is in bytes, the CMDB's in MB
+		if ram, ok := ci.Fields["ram"].(int64); ok {
+			ci.Fields["ram"] = ram >> 20
+		}
+		ci.Fields["correlation_id"] = ci.CorrelationID
+		cis = append(cis, ci)
+	}
+	return cis
+}
@@ -0,0 +1,52 @@
+package cmdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestFromInventory(t *testing.T) {
+	cis := FromInventory([]collector.InventoryEntry{
+		{Endpoint: "vc", Kind: "host", Name: "esx01", MOID: "host-21", Summary: map[string]interface{}{"mem_size": int64(64 << 30), "uptime": 1}},
+		{Endpoint: "vc", Kind: "unknown", Name: "x"},
+	})
+
+	if len(cis) != 1 {
+		t.Fatalf("%d CIs, expected 1", len(cis))
+	}
+	ci := cis[0]
+	if ci.Class != "cmdb_ci_esx_server" || ci.CorrelationID != "vc/host-21" {
+		t.Errorf("CI %+v", ci)
+	}
+	if ci.Fields["ram"] != int64(64<<10) {
+		t.Errorf("ram=%v, expected MB", ci.Fields["ram"])
+	}
+	if _, ok := ci.Fields["uptime"]; ok {
+		t.Error("unmapped summary field exported")
+	}
+}
+
+func TestUpsertTable(t *testing.T) {
+	var methods []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"result": [{"sys_id": "abc"}]}`))
+		}
+	}))
+	defer s.Close()
+
+	c := &Client{Instance: s.URL, API: TableAPI}
+	ci := CI{Class: "cmdb_ci_esx_server", CorrelationID: "vc/host-21", Fields: map[string]interface{}{"name": "esx01"}}
+	if err := c.Upsert(context.Background(), ci); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(methods) != 2 || methods[1] != "PATCH /api/now/table/cmdb_ci_esx_server/abc" {
+		t.Errorf("requests %v, expected a lookup and an update", methods)
+	}
+}
@@ -0,0 +1,266 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// AlarmDefinition is an alarm defined on an entity of an endpoint, as
+// exported for version control.
+type AlarmDefinition struct {
+	Endpoint string `json:"endpoint"`
+	// Entity is the inventory path of the entity the alarm is defined on,
+	// and applies to the descendants of.
+	Entity      string `json:"entity"`
+	Name        string `json:"name"`
+	SystemName  string `json:"system_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	// Expression and Action are the spec of the alarm, typed by the vSphere
+	// API type of every expression and action.
+	Expression *AlarmNode `json:"expression,omitempty"`
+	Action     *AlarmNode `json:"action,omitempty"`
+	// ActionFrequency is the seconds between repeated actions, 0 for none.
+	ActionFrequency int32               `json:"action_frequency,omitempty"`
+	Setting         *types.AlarmSetting `json:"setting,omitempty"`
+
+	// Modified and ModifiedBy are when and by whom the alarm was last
+	// changed, left out of comparisons.
+	Modified   string `json:"modified,omitempty"`
+	ModifiedBy string `json:"modified_by,omitempty"`
+}
+
+// AlarmNode is an alarm expression or action: the operands of and, or and
+// group nodes, or the spec of any other.
+type AlarmNode struct {
+	Type  string      `json:"type"`
+	Nodes []AlarmNode `json:"nodes,omitempty"`
+	Spec  interface{} `json:"spec,omitempty"`
+}
+
+// alarmExpression returns the node of an alarm expression, nil for none.
+func alarmExpression(e types.BaseAlarmExpression) *AlarmNode {
+	if e == nil || reflect.ValueOf(e).IsNil() {
+		return nil
+	}
+	var operands []types.BaseAlarmExpression
+	switch x := e.(type) {
+	case *types.AndAlarmExpression:
+		operands = x.Expression
+	case *types.OrAlarmExpression:
+		operands = x.Expression
+	default:
+		return &AlarmNode{Type: typeName(e), Spec: e}
+	}
+	n := &AlarmNode{Type: typeName(e), Nodes: []AlarmNode{}}
+	for _, o := range operands {
+		if on := alarmExpression(o); on != nil {
+			n.Nodes = append(n.Nodes, *on)
+		}
+	}
+	return n
+}
+
+// alarmAction returns the node of an alarm action, nil for none.
+func alarmAction(a types.BaseAlarmAction) *AlarmNode {
+	if a == nil || reflect.ValueOf(a).IsNil() {
+		return nil
+	}
+	g, ok := a.(*types.GroupAlarmAction)
+	if !ok {
+		return &AlarmNode{Type: typeName(a), Spec: a}
+	}
+	n := &AlarmNode{Type: typeName(a), Nodes: []AlarmNode{}}
+	for _, o := range g.Action {
+		if on := alarmAction(o); on != nil {
+			n.Nodes = append(n.Nodes, *on)
+		}
+	}
+	return n
+}
+
+func typeName(v interface{}) string {
+	return reflect.Indirect(reflect.ValueOf(v)).Type().Name()
+}
+
+// Alarms returns the alarm definitions visible to the session of c, sorted
+// by entity and name.
+func Alarms(ctx context.Context, c *govmomi.Client) ([]AlarmDefinition, error) {
+	if c.ServiceContent.AlarmManager == nil {
+		return nil, fmt.Errorf("endpoint has no alarm manager")
+	}
+	res, err := methods.GetAlarm(ctx, c.Client, &types.GetAlarm{This: *c.ServiceContent.AlarmManager})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Returnval) == 0 {
+		return []AlarmDefinition{}, nil
+	}
+
+	var alarms []mo.Alarm
+	pc := property.DefaultCollector(c.Client)
+	if err := pc.Retrieve(ctx, res.Returnval, []string{"info"}, &alarms); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[types.ManagedObjectReference]string)
+	defs := make([]AlarmDefinition, 0, len(alarms))
+	for _, a := range alarms {
+		info := a.Info
+		p, ok := paths[info.Entity]
+		if !ok {
+			if p, err = find.InventoryPath(ctx, c.Client, info.Entity); err != nil {
+				return nil, err
+			}
+			paths[info.Entity] = p
+		}
+
+		def := AlarmDefinition{
+			Endpoint:        c.URL().Host,
+			Entity:          p,
+			Name:            info.Name,
+			SystemName:      info.SystemName,
+			Description:     info.Description,
+			Enabled:         info.Enabled,
+			Expression:      alarmExpression(info.Expression),
+			Action:          alarmAction(info.Action),
+			ActionFrequency: info.ActionFrequency,
+			Setting:         info.Setting,
+			ModifiedBy:      info.LastModifiedUser,
+		}
+		if !info.LastModifiedTime.IsZero() {
+			def.Modified = info.LastModifiedTime.UTC().Format(time.RFC3339)
+		}
+		defs = append(defs, def)
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		if defs[i].Entity != defs[j].Entity {
+			return defs[i].Entity < defs[j].Entity
+		}
+		return defs[i].Name < defs[j].Name
+	})
+	return defs, nil
+}
+
+// LoadAlarms reads the alarm definitions of the JSON file at p, as exported.
+func LoadAlarms(p string) ([]AlarmDefinition, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var defs []AlarmDefinition
+	if err := json.Unmarshal(b, &defs); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return defs, nil
+}
+
+// AlarmChanged is the kind of AlarmDrift of a definition changed since its
+// baseline, those created or deleted since being ChangeCreated and
+// ChangeDeleted.
+const AlarmChanged = "changed"
+
+// AlarmDrift is a difference of an alarm definition from a baseline: a
+// definition created or deleted since, or changed, with the fields of its
+// spec that differ.
+type AlarmDrift struct {
+	Change   string   `json:"change"`
+	Endpoint string   `json:"endpoint"`
+	Entity   string   `json:"entity"`
+	Name     string   `json:"name"`
+	Fields   []string `json:"fields,omitempty"`
+}
+
+// DiffAlarms returns the drift of current from baseline, sorted by endpoint,
+// entity and name. Definitions are those of an endpoint, entity and name;
+// those of endpoints not in current, which could not be exported, are
+// assumed unchanged.
+func DiffAlarms(baseline, current []AlarmDefinition) []AlarmDrift {
+	type key struct{ endpoint, entity, name string }
+	endpoints := make(map[string]bool)
+	for _, d := range current {
+		endpoints[d.Endpoint] = true
+	}
+	before := make(map[key]AlarmDefinition, len(baseline))
+	for _, d := range baseline {
+		if endpoints[d.Endpoint] {
+			before[key{d.Endpoint, d.Entity, d.Name}] = d
+		}
+	}
+
+	var drift []AlarmDrift
+	for _, d := range current {
+		k := key{d.Endpoint, d.Entity, d.Name}
+		b, ok := before[k]
+		if !ok {
+			drift = append(drift, AlarmDrift{Change: ChangeCreated, Endpoint: d.Endpoint, Entity: d.Entity, Name: d.Name})
+			continue
+		}
+		delete(before, k)
+		if fields := alarmChanges(b, d); len(fields) != 0 {
+			drift = append(drift, AlarmDrift{Change: AlarmChanged, Endpoint: d.Endpoint, Entity: d.Entity, Name: d.Name, Fields: fields})
+		}
+	}
+	for _, b := range before {
+		drift = append(drift, AlarmDrift{Change: ChangeDeleted, Endpoint: b.Endpoint, Entity: b.Entity, Name: b.Name})
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		a, b := drift[i], drift[j]
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		if a.Entity != b.Entity {
+			return a.Entity < b.Entity
+		}
+		return a.Name < b.Name
+	})
+	return drift
+}
+
+// alarmChanges returns the JSON names of the fields of the spec of b and d
+// that differ, compared as JSON so that exported and loaded definitions
+// compare equal.
+func alarmChanges(b, d AlarmDefinition) []string {
+	bv, dv := alarmFields(b), alarmFields(d)
+	var fields []string
+	for k, v := range dv {
+		if !reflect.DeepEqual(v, bv[k]) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range bv {
+		if _, ok := dv[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// alarmFields returns the fields of the JSON of d, but for those of its
+// identity and modification.
+func alarmFields(d AlarmDefinition) map[string]interface{} {
+	d.Modified, d.ModifiedBy = "", ""
+	// Definitions are plain data, which always marshal
+	b, _ := json.Marshal(d)
+	var fields map[string]interface{}
+	json.Unmarshal(b, &fields)
+	for _, k := range []string{"endpoint", "entity", "name"} {
+		delete(fields, k)
+	}
+	return fields
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// AuditLog writes an audit trail of vCenter API calls as JSON lines.
+type AuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// AuditRecord is a single vCenter API call of the audit trail.
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	Endpoint string    `json:"endpoint"`
+	Method   string    `json:"method"`
+	Target   string    `json:"target,omitempty"`
+	Duration float64   `json:"duration_ms"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// OpenAuditLog opens or creates the audit log file, appending to it.
+func OpenAuditLog(file string) (*AuditLog, error) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{enc: json.NewEncoder(f), c: f}, nil
+}
+
+// Write appends r to the audit log.
+func (a *AuditLog) Write(r AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.enc.Encode(r)
+}
+
+// Close closes the audit log file.
+func (a *AuditLog) Close() error {
+	return a.c.Close()
+}
+
+// auditRoundTripper records every call made through its soap.RoundTripper.
+type auditRoundTripper struct {
+	rt       soap.RoundTripper
+	log      *AuditLog
+	endpoint string
+}
+
+func (a *auditRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	start := time.Now()
+	err := a.rt.RoundTrip(ctx, req, res)
+
+	r := AuditRecord{
+		Time:     start,
+		Endpoint: a.endpoint,
+		Method:   methodName(req),
+		Target:   auditTarget(req),
+		Duration: float64(time.Since(start)) / float64(time.Millisecond),
+		Outcome:  "ok",
+	}
+	switch {
+	case err != nil:
+		r.Outcome = "error"
+		r.Error = Redact(err.Error())
+	case res.Fault() != nil:
+		r.Outcome = "fault"
+		r.Error = Redact(res.Fault().String)
+	}
+
+	// Auditing must not fail the call it audits
+	_ = a.log.Write(r)
+
+	return err
+}
+
+// methodName returns the name of the method of a method body.
+func methodName(req soap.HasFault) string {
+	return strings.TrimSuffix(reflect.Indirect(reflect.ValueOf(req)).Type().Name(), "Body")
+}
+
+// auditTarget returns the managed object a method body is invoked on, from the
+// This field of its request.
+func auditTarget(req soap.HasFault) string {
+	v := reflect.Indirect(reflect.ValueOf(req))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+
+	body := v.FieldByName("Req")
+	if body.Kind() != reflect.Ptr || body.IsNil() {
+		return ""
+	}
+
+	this := body.Elem().FieldByName("This")
+	if !this.IsValid() {
+		return ""
+	}
+	if ref, ok := this.Interface().(types.ManagedObjectReference); ok {
+		return ref.String()
+	}
+	return ""
+}
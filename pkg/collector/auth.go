@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/sts"
+)
+
+// An Authenticator logs a client in to an endpoint, connected but not yet
+// logged in. User is the credentials of the URL of the endpoint or of its
+// CredentialSource, nil for none. Organizations with their own auth brokers
+// register an Authenticator and select it by name in ClientOptions, rather
+// than patching NewClient.
+type Authenticator interface {
+	Authenticate(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error
+}
+
+// AuthenticatorFunc is an Authenticator of a function.
+type AuthenticatorFunc func(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error {
+	return f(ctx, c, user, opts)
+}
+
+var (
+	authMu         sync.RWMutex
+	authenticators = map[string]Authenticator{
+		"password":     AuthenticatorFunc(passwordAuth),
+		"token":        AuthenticatorFunc(tokenAuth),
+		"certificate":  AuthenticatorFunc(certificateAuth),
+		"session":      AuthenticatorFunc(sessionAuth),
+		"clone-ticket": AuthenticatorFunc(cloneTicketAuth),
+	}
+)
+
+// RegisterAuthenticator registers a under name, for the Authenticator of
+// ClientOptions, typically from the init function of the package of an auth
+// broker. It panics if name is already registered, as database/sql drivers
+// do.
+func RegisterAuthenticator(name string, a Authenticator) {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if _, ok := authenticators[name]; ok {
+		panic("collector: authenticator " + name + " registered twice")
+	}
+	authenticators[name] = a
+}
+
+// Authenticators returns the names of the registered authenticators, sorted.
+func Authenticators() []string {
+	authMu.RLock()
+	defer authMu.RUnlock()
+	names := make([]string, 0, len(authenticators))
+	for name := range authenticators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupAuthenticator returns the Authenticator registered under name.
+func LookupAuthenticator(name string) (Authenticator, error) {
+	authMu.RLock()
+	a, ok := authenticators[name]
+	authMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown authenticator %q, expected one of %s", name, strings.Join(Authenticators(), ", "))
+	}
+	return a, nil
+}
+
+// authenticator returns the Authenticator of opts: the one it names, or else
+// the built-in one its other options select, nil for none.
+func authenticator(opts ClientOptions, user *url.Userinfo) (Authenticator, error) {
+	name := opts.Authenticator
+	if name == "" {
+		switch {
+		case opts.SessionID != "":
+			name = "session"
+		case opts.CloneTicket != "":
+			name = "clone-ticket"
+		case user != nil && opts.SSO != SSONone:
+			name = "token"
+		case user != nil:
+			name = "password"
+		case opts.Certificate != "":
+			name = "certificate"
+		default:
+			return nil, nil
+		}
+	}
+	return LookupAuthenticator(name)
+}
+
+// passwordAuth logs in with the username and password of user.
+func passwordAuth(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error {
+	if user == nil {
+		return fmt.Errorf("password authentication requires credentials")
+	}
+	return c.Login(ctx, user)
+}
+
+// tokenAuth logs in with a SAML token of the vCenter STS issued for user, a
+// holder-of-key one for SSOHolderOfKey, bound to the client certificate or
+// to an ephemeral one, and a bearer one otherwise.
+func tokenAuth(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error {
+	if user == nil {
+		return fmt.Errorf("token authentication requires credentials")
+	}
+	if opts.SSO != SSOHolderOfKey {
+		return loginByToken(ctx, c, sts.TokenRequest{Userinfo: user})
+	}
+	if c.Certificate() == nil {
+		cert, err := ephemeralCertificate()
+		if err != nil {
+			return err
+		}
+		c.SetCertificate(cert)
+	}
+	return loginByToken(ctx, c, sts.TokenRequest{Userinfo: user, Certificate: c.Certificate(), Delegatable: true})
+}
+
+// certificateAuth logs in with the client certificate, as the extension of
+// ExtensionKey if set, or else as a solution user by a holder-of-key token.
+func certificateAuth(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error {
+	if c.Certificate() == nil {
+		return fmt.Errorf("certificate authentication requires a client certificate")
+	}
+	if opts.ExtensionKey != "" {
+		return c.SessionManager.LoginExtensionByCertificate(ctx, opts.ExtensionKey)
+	}
+	return loginByToken(ctx, c, sts.TokenRequest{Certificate: c.Certificate(), Delegatable: true})
+}
+
+// sessionAuth reuses the session of SessionID.
+func sessionAuth(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error {
+	return reuseSession(ctx, c, opts.SessionID)
+}
+
+// cloneTicketAuth clones the session that issued CloneTicket.
+func cloneTicketAuth(ctx context.Context, c *govmomi.Client, user *url.Userinfo, opts ClientOptions) error {
+	return c.SessionManager.CloneSession(ctx, opts.CloneTicket)
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/pbm"
+	"github.com/vmware/govmomi/vapi/rest"
+)
+
+// Capabilities are the API version and services of an endpoint, detected on
+// connecting, selecting the gatherers, features and properties collected of
+// it rather than failing against older ESXi and vCenter versions, or vCenters
+// some services of which are down.
+type Capabilities struct {
+	APIVersion string `json:"api_version"`
+	VCenter    bool   `json:"vcenter"`
+	// Tagging is whether the vAPI service of vSphere tags answers, as that
+	// of vCenter 6.5 or later does.
+	Tagging bool `json:"tagging"`
+	// StoragePolicies is whether the SPBM service of storage policies
+	// answers, on vCenter alone.
+	StoragePolicies bool `json:"storage_policies"`
+}
+
+// DetectCapabilities returns the capabilities of the endpoint of c, probing
+// the vAPI tagging and SPBM services of a vCenter.
+func DetectCapabilities(ctx context.Context, c *govmomi.Client) Capabilities {
+	caps := Capabilities{
+		APIVersion: c.ServiceContent.About.ApiVersion,
+		VCenter:    c.IsVC(),
+	}
+	if !caps.VCenter {
+		return caps
+	}
+
+	rc := rest.NewClient(c.Client)
+	if err := probeVAPI(ctx, rc, "/com/vmware/cis/tagging/tag"); err != nil {
+		slog.Debug("vAPI tagging service unavailable", "endpoint", c.URL().Host, "err", err)
+	} else {
+		caps.Tagging = true
+	}
+
+	if _, err := pbm.NewClient(ctx, c.Client); err != nil {
+		slog.Debug("SPBM service unavailable", "endpoint", c.URL().Host, "err", err)
+	} else {
+		caps.StoragePolicies = true
+	}
+	return caps
+}
+
+// probeVAPI returns an error unless the vAPI service of path answers. A vAPI
+// service refuses a client not logged in to it with 401, unlike failing to
+// connect or a missing service.
+func probeVAPI(ctx context.Context, rc *rest.Client, path string) error {
+	err := rc.Do(ctx, rc.Resource(path).Request(http.MethodGet), nil)
+	if err == nil || rest.IsStatusError(err, http.StatusUnauthorized) || rest.IsStatusError(err, http.StatusForbidden) {
+		return nil
+	}
+	return err
+}
+
+// Disabled returns the gatherers and features not supported by caps, as
+// logged on connecting.
+func (caps Capabilities) Disabled() []string {
+	var disabled []string
+	for _, g := range Gatherers {
+		if caps.unsupported(g.Name) != "" {
+			disabled = append(disabled, g.Name+" collector")
+		}
+	}
+	for _, f := range []struct {
+		name string
+		ok   bool
+	}{
+		{"storage policies", caps.StoragePolicies},
+		{"vSphere tags", caps.Tagging},
+	} {
+		if !f.ok {
+			disabled = append(disabled, f.name)
+		}
+	}
+	return disabled
+}
+
+// unsupported returns why the gatherer called name is not supported by caps,
+// "" if it is.
+func (caps Capabilities) unsupported(name string) string {
+	if name == "cluster" && !caps.VCenter {
+		return "standalone hosts have no clusters"
+	}
+	return ""
+}
+
+// logCapabilities logs the gatherers and features disabled for the
+// capabilities of the endpoint called endpoint.
+func logCapabilities(endpoint string, caps Capabilities) {
+	if disabled := caps.Disabled(); len(disabled) != 0 {
+		slog.Info("disabling unsupported collectors and features", "endpoint", endpoint, "api_version", caps.APIVersion, "vcenter", caps.VCenter, "disabled", disabled)
+	}
+}
+
+// propertySince maps the properties retrieved of entities that older API
+// versions don't have to the version they were introduced in, retrieving
+// them failing the whole retrieval with InvalidProperty.
+var propertySince = map[string]string{
+	"hardware.persistentMemoryInfo": "6.7",
+	"capability.uefiSecureBoot":     "6.7",
+	"hardware.memoryTieringType":    "7.0.3.0",
+	"hardware.memoryTierInfo":       "7.0.3.0",
+}
+
+// supportedProperties returns the properties of props the API version of c
+// supports.
+func supportedProperties(c *govmomi.Client, props []string) []string {
+	version := c.ServiceContent.About.ApiVersion
+	supported := make([]string, 0, len(props))
+	for _, p := range props {
+		if since, ok := propertySince[p]; ok && !apiAtLeast(version, since) {
+			continue
+		}
+		supported = append(supported, p)
+	}
+	return supported
+}
+
+// apiAtLeast reports whether the API version version, such as 7.0.3.0, is
+// min or later, missing components being 0. Versions that don't parse are
+// assumed recent.
+func apiAtLeast(version, min string) bool {
+	v, m := strings.Split(version, "."), strings.Split(min, ".")
+	for i := range m {
+		mi, _ := strconv.Atoi(m[i])
+		vi := 0
+		if i < len(v) {
+			var err error
+			if vi, err = strconv.Atoi(v[i]); err != nil {
+				return true
+			}
+		}
+		if vi != mi {
+			return vi > mi
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// ClientOptions configures how connections to an endpoint are established.
+type ClientOptions struct {
+	// Insecure disables verification of the server's certificate chain.
+	Insecure bool
+	// CACert is a file of PEM encoded certificates to verify the server with,
+	// instead of the system roots.
+	CACert string
+	// Thumbprints maps hosts, with or without port, to the SHA-1 or SHA-256
+	// thumbprint their certificate must match. The empty host applies to every
+	// endpoint.
+	Thumbprints map[string]string
+	// Certificate and PrivateKey are PEM files of a client certificate used
+	// to log in to endpoints whose URL has no credentials.
+	Certificate string
+	PrivateKey  string
+	// ExtensionKey logs in with the client certificate as this extension,
+	// instead of as a solution user by a token from the vCenter STS.
+	ExtensionKey string
+	// SSO exchanges the URL credentials for a vCenter SSO token to log in.
+	SSO SSOMode
+	// SessionID reuses an existing session, e.g. one managed by an
+	// orchestration system, instead of logging in. The session is not logged
+	// out by the collector.
+	SessionID string
+	// CloneTicket logs in by cloning the session that issued the ticket.
+	CloneTicket string
+	// Credentials, when set, replaces the credentials of the URL.
+	Credentials CredentialSource
+	// Authenticator is the name of the registered Authenticator to log in
+	// with, such as password or that of an auth broker. By default it is
+	// selected by the other options, see NewClient.
+	Authenticator string
+	// Proxy selects the proxy to connect through, see ProxyFunc.
+	Proxy func(*http.Request) (*url.URL, error)
+	// TLS restricts the TLS versions and cipher suites of the connection.
+	TLS TLSPolicy
+	// Audit, when set, records every API call made with the client.
+	Audit *AuditLog
+}
+
+// OwnsSession reports whether sessions created with these options belong to
+// the collector, and should be logged out by it.
+func (o ClientOptions) OwnsSession() bool {
+	return o.SessionID == ""
+}
+
+// ParseThumbprints parses a comma separated list of thumbprints, either bare
+// or as host=thumbprint pairs.
+func ParseThumbprints(s string) (map[string]string, error) {
+	thumbprints := make(map[string]string)
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+
+		host := ""
+		if i := strings.Index(t, "="); i >= 0 {
+			host, t = t[:i], t[i+1:]
+		}
+		if _, ok := thumbprints[host]; ok {
+			return nil, fmt.Errorf("duplicate thumbprint for %q", host)
+		}
+		thumbprints[host] = t
+	}
+	return thumbprints, nil
+}
+
+// NewClient connects and logs in to the ESX or vCenter at u, with the
+// Authenticator of opts or else the built-in one selected by the first of its
+// SessionID, CloneTicket, URL credentials, with SSO for token, and client
+// Certificate; without any, the client is not logged in.
+func NewClient(ctx context.Context, u *url.URL, opts ClientOptions) (*govmomi.Client, error) {
+	sc := soap.NewClient(u, opts.Insecure)
+
+	if opts.Proxy != nil {
+		sc.DefaultTransport().Proxy = opts.Proxy
+	}
+	opts.TLS.Apply(sc.DefaultTransport().TLSClientConfig)
+
+	if opts.CACert != "" {
+		if err := sc.SetRootCAs(opts.CACert); err != nil {
+			return nil, err
+		}
+	}
+
+	if thumbprint := hostThumbprint(opts.Thumbprints, u); thumbprint != "" {
+		pinCertificate(sc.DefaultTransport().TLSClientConfig, thumbprint)
+	}
+
+	if opts.Certificate != "" {
+		cert, err := tls.LoadX509KeyPair(opts.Certificate, opts.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		sc.SetCertificate(cert)
+	}
+
+	var rt soap.RoundTripper = &tracingRoundTripper{rt: sc, endpoint: u.Host}
+	if opts.Audit != nil {
+		rt = &auditRoundTripper{rt: rt, log: opts.Audit, endpoint: u.Host}
+	}
+
+	vc, err := newVimClient(ctx, sc, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &govmomi.Client{
+		Client:         vc,
+		SessionManager: session.NewManager(vc),
+	}
+
+	user := u.User
+	if opts.Credentials != nil && opts.SessionID == "" && opts.CloneTicket == "" {
+		if user, err = opts.Credentials.Credentials(ctx); err != nil {
+			return nil, fmt.Errorf("credentials: %s", err)
+		}
+	}
+	if user != nil {
+		if password, ok := user.Password(); ok {
+			AddSecret(password)
+		}
+	}
+
+	auth, err := authenticator(opts, user)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		if err := auth.Authenticate(ctx, c, user, opts); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// newVimClient returns the vim25.Client of sc making its calls through rt, as
+// vim25.NewClient does through sc, so that retrieving the service content is
+// traced and audited too.
+func newVimClient(ctx context.Context, sc *soap.Client, rt soap.RoundTripper) (*vim25.Client, error) {
+	if sc.Namespace == "" {
+		sc.Namespace = "urn:" + vim25.Namespace
+	}
+	if sc.Version == "" {
+		sc.Version = vim25.Version
+	}
+
+	content, err := methods.GetServiceContent(ctx, rt)
+	if err != nil {
+		return nil, err
+	}
+	return &vim25.Client{Client: sc, RoundTripper: rt, ServiceContent: content}, nil
+}
+
+// reuseSession authenticates c with the cookie of an existing session.
+func reuseSession(ctx context.Context, c *govmomi.Client, id string) error {
+	cookie := &http.Cookie{
+		Name:  soap.SessionCookieName,
+		Value: id,
+		Path:  "/",
+	}
+	c.Jar.SetCookies(c.URL(), []*http.Cookie{cookie})
+
+	s, err := c.SessionManager.UserSession(ctx)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("session is not authenticated")
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// clusterProperties are the properties retrieved of clusters.
+var clusterProperties = []string{"name", "summary", "host", "configurationEx"}
+
+// clusterHostProperties are the properties retrieved of the hosts of clusters.
+var clusterHostProperties = []string{"summary.config.product", "summary.rebootRequired", "runtime.inMaintenanceMode", "runtime.connectionState", "runtime.powerState", "config.powerSystemInfo"}
+
+// GatherClusterMetrics adds the metrics of clusters to acc. Clusters are not
+// counted by the progress of acc, which counts the entities of the other
+// gatherers.
+func GatherClusterMetrics(ctx context.Context, c *govmomi.Client, pc *property.Collector, clusters []*object.ClusterComputeResource, tc *TagCache, acc *Accumulator) error {
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(clusters))
+	for _, cluster := range clusters {
+		refs = append(refs, cluster.Reference())
+		paths[cluster.Reference()] = cluster.InventoryPath
+	}
+
+	var ccr []mo.ClusterComputeResource
+	if err := pc.Retrieve(ctx, refs, clusterProperties, &ccr); err != nil {
+		return err
+	}
+	slog.Debug("retrieved clusters", "endpoint", c.URL().Host, "count", len(ccr))
+
+	var hostRefs []types.ManagedObjectReference
+	for _, cluster := range ccr {
+		hostRefs = append(hostRefs, cluster.Host...)
+	}
+	hosts := make(map[types.ManagedObjectReference]mo.HostSystem, len(hostRefs))
+	if len(hostRefs) != 0 {
+		var hst []mo.HostSystem
+		if err := pc.Retrieve(ctx, hostRefs, clusterHostProperties, &hst); err != nil {
+			return err
+		}
+		for _, host := range hst {
+			hosts[host.Reference()] = host
+		}
+	}
+
+	scratch := make(map[string]string)
+	for _, cluster := range ccr {
+		resetTags(scratch)
+		members := make([]mo.HostSystem, 0, len(cluster.Host))
+		for _, ref := range cluster.Host {
+			if host, ok := hosts[ref]; ok {
+				members = append(members, host)
+			}
+		}
+		records := ClusterRecords(cluster, members, scratch)
+		scratch["vcenter"] = c.URL().Host
+		// Names are not unique across folders and datacenters
+		scratch["moid"] = cluster.Reference().Value
+		scratch["path"] = paths[cluster.Reference()]
+
+		tags := tc.Intern(cluster.Reference(), scratch)
+		if err := acc.add("cluster", NewEntityRef(c.URL().Host, cluster.Reference()), tags, records, false); err != nil {
+			return err
+		}
+
+		powerTags := map[string]string{"name": cluster.Name}
+		for _, k := range entityTags {
+			powerTags[k] = tags[k]
+		}
+		if err := acc.add("cluster_power", NewEntityRef(c.URL().Host, cluster.Reference()), powerTags, ClusterPowerRecords(cluster, members, powerTags), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClusterRecords fills tags and returns the records of a single cluster with
+// its hosts, for upgrade planning: its EVC mode, the number of distinct ESXi
+// builds of its connected hosts, and the number of its hosts in maintenance
+// mode or pending a reboot.
+func ClusterRecords(cluster mo.ClusterComputeResource, hosts []mo.HostSystem, tags map[string]string) map[string]interface{} {
+	tags["name"] = cluster.Name
+
+	evc := ""
+	if s, ok := cluster.Summary.(*types.ClusterComputeResourceSummary); ok {
+		evc = s.CurrentEVCModeKey
+	}
+	if evc != "" {
+		tags["evc_mode"] = evc
+	}
+
+	builds := make(map[string]bool)
+	var maintenance, reboot int
+	for _, host := range hosts {
+		if host.Runtime.InMaintenanceMode {
+			maintenance++
+		}
+		if host.Summary.RebootRequired {
+			reboot++
+		}
+		if p := host.Summary.Config.Product; p != nil && host.Runtime.ConnectionState == types.HostSystemConnectionStateConnected {
+			builds[p.Version+"-"+p.Build] = true
+		}
+	}
+
+	return map[string]interface{}{
+		"num_hosts":             len(hosts),
+		"evc_enabled":           evc != "",
+		"esxi_builds":           len(builds),
+		"mixed_versions":        len(builds) > 1,
+		"hosts_in_maintenance":  maintenance,
+		"hosts_reboot_required": reboot,
+	}
+}
+
+// hostPowerPolicies are the fields of ClusterPowerRecords counting the hosts
+// of a power policy, by its short name.
+var hostPowerPolicies = map[string]string{
+	"static":  "hosts_high_performance",
+	"dynamic": "hosts_balanced",
+	"low":     "hosts_low_power",
+	"custom":  "hosts_custom_policy",
+}
+
+// ClusterPowerRecords sets the dpm_behavior tag and returns the records of
+// the power management of a single cluster with its hosts, for capacity
+// planning: whether Distributed Power Management is enabled and its
+// threshold, the rating of the recommendations it acts on from 1, the most
+// aggressive, to 5, the hosts DPM is disabled on, the hosts in standby or
+// powered off, and the hosts of every host power policy.
+func ClusterPowerRecords(cluster mo.ClusterComputeResource, hosts []mo.HostSystem, tags map[string]string) map[string]interface{} {
+	records := map[string]interface{}{
+		"dpm_enabled":            false,
+		"dpm_threshold":          0,
+		"hosts_dpm_disabled":     0,
+		"hosts_standby":          0,
+		"hosts_powered_off":      0,
+		"hosts_high_performance": 0,
+		"hosts_balanced":         0,
+		"hosts_low_power":        0,
+		"hosts_custom_policy":    0,
+	}
+
+	if cfg, ok := cluster.ConfigurationEx.(*types.ClusterConfigInfoEx); ok {
+		if dpm := cfg.DpmConfigInfo; dpm != nil {
+			records["dpm_enabled"] = dpm.Enabled != nil && *dpm.Enabled
+			records["dpm_threshold"] = dpm.HostPowerActionRate
+			if dpm.DefaultDpmBehavior != "" {
+				tags["dpm_behavior"] = string(dpm.DefaultDpmBehavior)
+			}
+		}
+		disabled := 0
+		for _, h := range cfg.DpmHostConfig {
+			if h.Enabled != nil && !*h.Enabled {
+				disabled++
+			}
+		}
+		records["hosts_dpm_disabled"] = disabled
+	}
+
+	counts := make(map[string]int)
+	for _, host := range hosts {
+		switch host.Runtime.PowerState {
+		case types.HostSystemPowerStateStandBy:
+			counts["hosts_standby"]++
+		case types.HostSystemPowerStatePoweredOff:
+			counts["hosts_powered_off"]++
+		}
+		if host.Config == nil || host.Config.PowerSystemInfo == nil {
+			continue
+		}
+		if field, ok := hostPowerPolicies[host.Config.PowerSystemInfo.CurrentPolicy.ShortName]; ok {
+			counts[field]++
+		}
+	}
+	for k, n := range counts {
+		records[k] = n
+	}
+	return records
+}
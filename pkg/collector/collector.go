@@ -0,0 +1,309 @@
+// Package collector collects the metrics of ESX and vCenter endpoints.
+//
+//	endpoints, err := collector.ParseEndpoints("https://user:pass@vc/sdk", collector.ClientOptions{})
+//	...
+//	c := collector.New(endpoints)
+//	defer c.Close(ctx)
+//	metrics, err := c.Collect(ctx)
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Collector collects the metrics of a set of ESX or vCenter endpoints.
+type Collector struct {
+	Endpoints []*Endpoint
+	// Timeout bounds the collection of each endpoint, 0 for no limit.
+	Timeout time.Duration
+	// Strict is how schema drift is handled.
+	Strict StrictMode
+	// Filter selects the fields kept, nil for all.
+	Filter *FieldFilter
+	// Progress counts the entities collected, nil for none.
+	Progress *Progress
+	// MaxMemory, if set, is the bytes of heap beyond which cycles skip the
+	// performance statistics of hosts and virtual machines.
+	MaxMemory int64
+}
+
+// New returns a Collector of endpoints.
+func New(endpoints []*Endpoint) *Collector {
+	return &Collector{
+		Endpoints: endpoints,
+		Strict:    StrictOff,
+	}
+}
+
+// Collect collects every endpoint, stamping all metrics with the current time.
+func (c *Collector) Collect(ctx context.Context) ([]Metric, error) {
+	return c.CollectAt(ctx, time.Now())
+}
+
+// CollectAt collects every endpoint in its own goroutine, stamping all metrics
+// with ts. A failing endpoint or gatherer does not affect the others: the
+// metrics collected are returned along with an Errors of all the failures.
+func (c *Collector) CollectAt(ctx context.Context, ts time.Time) ([]Metric, error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var metrics []Metric
+	var errs Errors
+
+	lowMemory := false
+	if heap := heapBytes(); c.MaxMemory > 0 && heap > c.MaxMemory {
+		slog.Warn("heap exceeds the memory limit, skipping performance statistics", "heap_bytes", heap, "max_memory", c.MaxMemory)
+		lowMemory = true
+	}
+
+	for _, e := range c.Endpoints {
+		e.lowMemory = lowMemory
+		wg.Add(1)
+		go func(e *Endpoint) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("endpoint collection panicked", "endpoint", e.URL.Host, "panic", r, "stack", string(debug.Stack()))
+
+					mu.Lock()
+					errs = append(errs, &CollectorError{Endpoint: e.URL.Host, Collector: "connect", Err: &PanicError{Value: r}})
+					mu.Unlock()
+				}
+			}()
+
+			ctx := ctx
+			if c.Timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+				defer cancel()
+			}
+
+			ctx, span := tracer.Start(ctx, "collect endpoint", trace.WithAttributes(attribute.String("vsphere.endpoint", e.URL.Host)))
+			acc := &Accumulator{Time: ts, Strict: c.Strict, Filter: c.Filter, Progress: c.Progress}
+			eerrs := c.collectEndpoint(ctx, e, acc)
+			span.SetAttributes(attribute.Int("vsphere.metrics", len(acc.Metrics())), attribute.Int("vsphere.errors", len(eerrs)))
+			span.End()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			metrics = append(metrics, acc.Metrics()...)
+			errs = append(errs, eerrs...)
+		}(e)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return metrics, errs
+	}
+	return metrics, nil
+}
+
+// Close logs out of the sessions of all endpoints.
+func (c *Collector) Close(ctx context.Context) error {
+	var errs Errors
+	for _, e := range c.Endpoints {
+		if err := e.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// A Gatherer gathers the metrics of one kind of entity.
+type Gatherer struct {
+	Name   string
+	Gather func(ctx context.Context, c *govmomi.Client, f *find.Finder, e *Endpoint, acc *Accumulator) error
+	// Privileges lists the privileges the gatherer needs on the inventory.
+	Privileges []string
+}
+
+// Gatherers lists the gatherers run against every endpoint, in order.
+var Gatherers = []Gatherer{
+	{"datastore", gatherDataStores, []string{"System.View", "System.Read"}},
+	{"host", gatherHosts, []string{"System.View", "System.Read"}},
+	{"vm", gatherVMs, []string{"System.View", "System.Read"}},
+	{"cluster", gatherClusters, []string{"System.View", "System.Read"}},
+}
+
+// collectEndpoint runs every gatherer against a single ESX or vCenter, adding
+// their metrics to acc followed by a "collector" metric per gatherer counting
+// its errors and panics.
+func (c *Collector) collectEndpoint(ctx context.Context, e *Endpoint, acc *Accumulator) []error {
+	var errs []error
+	failed := make(map[string]int)
+	panics := make(map[string]int)
+
+	fail := func(gatherer string, err error) {
+		errs = append(errs, &CollectorError{Endpoint: e.URL.Host, Collector: gatherer, Err: err})
+		failed[gatherer]++
+
+		var pe *PanicError
+		if errors.As(err, &pe) {
+			panics[gatherer]++
+		}
+	}
+
+	if err := runGatherers(ctx, e, acc, fail); err != nil {
+		errs = append(errs, &CollectorError{Endpoint: e.URL.Host, Collector: "connect", Err: err})
+		for _, g := range Gatherers {
+			failed[g.Name]++
+		}
+	}
+
+	for _, g := range Gatherers {
+		tags := map[string]string{"vcenter": e.URL.Host, "collector": g.Name}
+		records := map[string]interface{}{"errors": failed[g.Name], "panics": panics[g.Name]}
+		if err := acc.Add("collector", nil, tags, records); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if e.MaxVMs > 0 || c.MaxMemory > 0 {
+		tags := map[string]string{"vcenter": e.URL.Host}
+		records := map[string]interface{}{"vms_skipped": e.vmsSkipped, "perf_skipped": e.lowMemory, "heap_bytes": heapBytes()}
+		if err := acc.Add("guardrail", nil, tags, records); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func runGatherers(ctx context.Context, e *Endpoint, acc *Accumulator, fail func(string, error)) error {
+	// Connect and log in to ESX or vCenter, or reuse the previous session
+	c, err := e.Client(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The capabilities of the cycle, as the prober may connect again
+	// concurrently
+	caps := e.Capabilities()
+
+	f := find.NewFinder(c.Client, true)
+
+	// Find one and only datacenter
+	dc, err := f.DefaultDatacenter(ctx)
+	if err != nil {
+		if isNotFound(err) || isNoPermission(err) {
+			logDiagnostics(ctx, c, e.URL.Host, "no datacenter found")
+		}
+		return err
+	}
+
+	// Make future calls local to this datacenter
+	f.SetDatacenter(dc)
+
+	diagnosed := false
+	for _, g := range Gatherers {
+		if caps.unsupported(g.Name) != "" {
+			continue
+		}
+		start := time.Now()
+		err := runGatherer(ctx, g, c, f, e, acc)
+		slog.Debug("collector finished", "endpoint", e.URL.Host, "collector", g.Name, "duration", time.Since(start), "err", err)
+
+		if err == nil {
+			continue
+		}
+
+		// Nothing to collect is not a failure, but is worth explaining,
+		// as is a permission error
+		notFound := isNotFound(err)
+		if !notFound {
+			fail(g.Name, err)
+		}
+
+		if !diagnosed && notFound {
+			diagnosed = true
+			logDiagnostics(ctx, c, e.URL.Host, g.Name+" collector found no entities")
+		} else if !diagnosed && isNoPermission(err) {
+			diagnosed = true
+			logDiagnostics(ctx, c, e.URL.Host, g.Name+" collector was denied permission")
+		}
+	}
+
+	e.Tags.Prune()
+	return nil
+}
+
+// runGatherer runs g, recovering from any panic so that an unexpected API
+// shape only fails this gatherer.
+func runGatherer(ctx context.Context, g Gatherer, c *govmomi.Client, f *find.Finder, e *Endpoint, acc *Accumulator) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("collector panicked", "endpoint", e.URL.Host, "collector", g.Name, "panic", r, "stack", string(debug.Stack()))
+			err = &PanicError{Value: r}
+		}
+	}()
+
+	ctx, span := tracer.Start(ctx, "gather "+g.Name, trace.WithAttributes(attribute.String("vsphere.endpoint", e.URL.Host)))
+	defer func() { endSpan(span, err) }()
+
+	return g.Gather(ctx, c, f, e, acc)
+}
+
+func gatherDataStores(ctx context.Context, c *govmomi.Client, f *find.Finder, e *Endpoint, acc *Accumulator) error {
+	dss, err := f.DatastoreList(ctx, "*")
+	if err != nil {
+		return err
+	}
+	acc.Progress.Discover(len(dss))
+
+	pc := property.DefaultCollector(c.Client)
+	return GatherDataStoreMetrics(ctx, c, pc, dss, e.Tags, acc)
+}
+
+func gatherHosts(ctx context.Context, c *govmomi.Client, f *find.Finder, e *Endpoint, acc *Accumulator) error {
+	hosts, err := f.HostSystemList(ctx, "*")
+	if err != nil {
+		return err
+	}
+	acc.Progress.Discover(len(hosts))
+
+	pc := property.DefaultCollector(c.Client)
+	return gatherHostMetrics(ctx, c, pc, hosts, e.Tags, e.HostHardware, e.BMCAttribute, e.perfLimit(), acc)
+}
+
+func gatherClusters(ctx context.Context, c *govmomi.Client, f *find.Finder, e *Endpoint, acc *Accumulator) error {
+	// ESX and vCenters of standalone hosts have no cluster
+	clusters, err := f.ClusterComputeResourceList(ctx, "*")
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	pc := property.DefaultCollector(c.Client)
+	return GatherClusterMetrics(ctx, c, pc, clusters, e.Tags, acc)
+}
+
+func gatherVMs(ctx context.Context, c *govmomi.Client, f *find.Finder, e *Endpoint, acc *Accumulator) error {
+	// Find virtual machines in datacenter
+	vms, err := f.VirtualMachineList(ctx, "*")
+	if err != nil {
+		return err
+	}
+	if vms, e.vmsSkipped = sampleVMs(vms, e.MaxVMs); e.vmsSkipped != 0 {
+		slog.Warn("virtual machines exceed the limit, collecting a subset", "endpoint", e.URL.Host, "max_vms", e.MaxVMs, "skipped", e.vmsSkipped)
+	}
+	acc.Progress.Discover(len(vms))
+
+	pc := property.DefaultCollector(c.Client)
+	return gatherVMMetrics(ctx, c, pc, vms, e.Tags, e.StoragePolicies && e.Capabilities().StoragePolicies, e.DiskLimits, e.PoweredOnOnly, e.perfLimit(), acc)
+}
@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// UnitCosts are the monthly unit costs of the resources of virtual machines,
+// as read from a cost file:
+//
+//	currency: USD
+//	vcpu: 12         # per vCPU
+//	memory_gb: 4     # per GiB of memory
+//	storage_gb:      # per GiB of committed storage, by tier
+//	  default: 0.10
+//	  gold: 0.30
+//	tiers:           # datastore name patterns to tiers, first match
+//	  - datastore: "ssd-*"
+//	    tier: gold
+type UnitCosts struct {
+	Currency  string             `yaml:"currency" json:"currency"`
+	VCPU      float64            `yaml:"vcpu" json:"vcpu"`
+	MemoryGB  float64            `yaml:"memory_gb" json:"memory_gb"`
+	StorageGB map[string]float64 `yaml:"storage_gb" json:"storage_gb"`
+	Tiers     []StorageTier      `yaml:"tiers" json:"tiers"`
+}
+
+// StorageTier maps the datastores matching a path.Match pattern to a tier of
+// UnitCosts.StorageGB.
+type StorageTier struct {
+	Datastore string `yaml:"datastore" json:"datastore"`
+	Tier      string `yaml:"tier" json:"tier"`
+}
+
+// defaultTier is the tier of datastores no StorageTier matches.
+const defaultTier = "default"
+
+// LoadUnitCosts reads the cost file at p.
+func LoadUnitCosts(p string) (*UnitCosts, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var u UnitCosts
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&u); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	for _, t := range u.Tiers {
+		if _, err := path.Match(t.Datastore, ""); err != nil {
+			return nil, fmt.Errorf("%s: tier %s: invalid datastore pattern %q", p, t.Tier, t.Datastore)
+		}
+		if _, ok := u.StorageGB[t.Tier]; !ok {
+			return nil, fmt.Errorf("%s: tier %s has no storage_gb cost", p, t.Tier)
+		}
+	}
+	return &u, nil
+}
+
+// Tier returns the storage tier of datastore.
+func (u *UnitCosts) Tier(datastore string) string {
+	for _, t := range u.Tiers {
+		if ok, _ := path.Match(t.Datastore, datastore); ok {
+			return t.Tier
+		}
+	}
+	return defaultTier
+}
+
+// VMCost returns the monthly costs of the CPU, memory and storage of the
+// virtual machine of m, a vm metric, and the storage tier of the datastore
+// of its configuration file. ok is false for metrics without hardware.
+func (u *UnitCosts) VMCost(m Metric) (cpu, memory, storage float64, tier string, ok bool) {
+	cpus, ok1 := m.Float("num_cpu")
+	mem, ok2 := m.Float("mem_mb")
+	if !ok1 || !ok2 {
+		return 0, 0, 0, "", false
+	}
+	committed, _ := m.Float("storage_committed")
+
+	tier = u.Tier(datastoreName(m.Tag("vm_path_name")))
+	return cpus * u.VCPU, mem / 1024 * u.MemoryGB, committed / (1 << 30) * u.StorageGB[tier], tier, true
+}
+
+// Chargeback adds the monthly costs of virtual machines, and of the resource
+// pools they run in, to collection cycles.
+type Chargeback struct {
+	Costs *UnitCosts
+	// Filter, if set, drops cost fields.
+	Filter *FieldFilter
+}
+
+// NewChargeback returns a Chargeback of costs.
+func NewChargeback(costs *UnitCosts) *Chargeback {
+	return &Chargeback{Costs: costs}
+}
+
+// Cost returns a vm_cost metric per virtual machine of metrics, those of a
+// cycle, followed by a resource_pool_cost metric per resource pool summing
+// those of its virtual machines, sorted by vcenter and name.
+func (c *Chargeback) Cost(metrics []Metric) []Metric {
+	type pool struct {
+		vcenter, name        string
+		vms                  int
+		ts                   time.Time
+		cpu, memory, storage float64
+	}
+	pools := make(map[[2]string]*pool)
+
+	var costs []Metric
+	add := func(measurement string, tags map[string]string, records map[string]interface{}, entity *EntityRef, ts time.Time) {
+		cm, err := NewMetric(measurement, tags, records, ts)
+		if err != nil {
+			slog.Warn("cost failed", "name", tags["name"], "err", err)
+			return
+		}
+		for k := range cm.Fields {
+			if !c.Filter.Keep(measurement, k) {
+				delete(cm.Fields, k)
+			}
+		}
+		cm.Entity = entity
+		costs = append(costs, cm)
+	}
+
+	for _, m := range metrics {
+		if m.Name != "vm" {
+			continue
+		}
+		cpu, memory, storage, tier, ok := c.Costs.VMCost(m)
+		if !ok {
+			continue
+		}
+
+		tags := map[string]string{
+			"name":          m.Tag("name"),
+			"vcenter":       m.Tag("vcenter"),
+			"moid":          m.Tag("moid"),
+			"path":          m.Tag("path"),
+			"resource_pool": m.Tag("resource_pool"),
+			"tier":          tier,
+			"currency":      c.Costs.Currency,
+		}
+		add("vm_cost", tags, costRecords(cpu, memory, storage), m.Entity, m.Time)
+
+		key := [2]string{m.Tag("vcenter"), m.Tag("resource_pool")}
+		if key[1] == "" {
+			continue
+		}
+		p, ok := pools[key]
+		if !ok {
+			p = &pool{vcenter: key[0], name: key[1], ts: m.Time}
+			pools[key] = p
+		}
+		p.vms++
+		p.cpu += cpu
+		p.memory += memory
+		p.storage += storage
+	}
+
+	keys := make([][2]string, 0, len(pools))
+	for k := range pools {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		p := pools[k]
+		records := costRecords(p.cpu, p.memory, p.storage)
+		records["vms"] = p.vms
+		tags := map[string]string{"vcenter": p.vcenter, "resource_pool": p.name, "currency": c.Costs.Currency}
+		add("resource_pool_cost", tags, records, nil, p.ts)
+	}
+	return costs
+}
+
+func costRecords(cpu, memory, storage float64) map[string]interface{} {
+	return map[string]interface{}{
+		"cpu_cost":     cpu,
+		"memory_cost":  memory,
+		"storage_cost": storage,
+		"total_cost":   cpu + memory + storage,
+	}
+}
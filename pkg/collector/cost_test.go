@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChargeback(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "costs.yaml")
+	if err := os.WriteFile(p, []byte(`currency: USD
+vcpu: 10
+memory_gb: 5
+storage_gb:
+  default: 0.1
+  gold: 0.5
+tiers:
+  - datastore: "ssd-*"
+    tier: gold
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	costs, err := LoadUnitCosts(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := func(moid, datastore string) Metric {
+		return Metric{
+			Name: "vm",
+			Tags: map[string]string{"name": moid, "vcenter": "vc", "moid": moid, "resource_pool": "Resources", "vm_path_name": "[" + datastore + "] " + moid + "/" + moid + ".vmx"},
+			Fields: map[string]interface{}{
+				"num_cpu": int64(2), "mem_mb": int64(4096), "storage_committed": int64(10 << 30), "available": int64(1),
+			},
+			Time:   time.Now(),
+			Entity: &EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: moid},
+		}
+	}
+
+	metrics := NewChargeback(costs).Cost([]Metric{vm("vm-1", "ssd-01"), vm("vm-2", "nfs-01")})
+	if len(metrics) != 3 {
+		t.Fatalf("%d metrics, expected 3", len(metrics))
+	}
+
+	expect := []struct {
+		name, tier string
+		total      float64
+	}{
+		{"vm_cost", "gold", 20 + 20 + 5},
+		{"vm_cost", "default", 20 + 20 + 1},
+		{"resource_pool_cost", "", 86},
+	}
+	for i, e := range expect {
+		m := metrics[i]
+		total, _ := m.Float("total_cost")
+		if m.Name != e.name || m.Tag("tier") != e.tier || total != e.total {
+			t.Errorf("%s tier=%q total_cost=%g, expected %s tier=%q total_cost=%g", m.Name, m.Tag("tier"), total, e.name, e.tier, e.total)
+		}
+	}
+	if vms, _ := metrics[2].Float("vms"); vms != 2 {
+		t.Errorf("vms=%g, expected 2", vms)
+	}
+}
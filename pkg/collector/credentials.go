@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	envUserName = "GOVMOMI_USERNAME"
+	envPassword = "GOVMOMI_PASSWORD"
+)
+
+// A CredentialSource returns the credentials to log in with. It is asked
+// again on every login, so that rotated credentials are picked up without
+// restarting the collector.
+type CredentialSource interface {
+	Credentials(ctx context.Context) (*url.Userinfo, error)
+}
+
+// ParseCredentialSource parses a credential source: "env" for the
+// GOVMOMI_USERNAME and GOVMOMI_PASSWORD environment variables, "file:PATH"
+// for a file holding username:password, or "vault:PATH" for a Vault secret
+// with username and password keys, read with VAULT_ADDR and VAULT_TOKEN.
+// The empty string selects no source, the credentials of the URL are used.
+func ParseCredentialSource(s string) (CredentialSource, error) {
+	kind, arg := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		kind, arg = s[:i], s[i+1:]
+	}
+
+	switch kind {
+	case "":
+		return nil, nil
+	case "env":
+		return envCredentials{}, nil
+	case "file":
+		if arg != "" {
+			return fileCredentials(arg), nil
+		}
+	case "vault":
+		if arg != "" {
+			return vaultCredentials(arg), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid credential source %q", s)
+}
+
+type envCredentials struct{}
+
+func (envCredentials) Credentials(ctx context.Context) (*url.Userinfo, error) {
+	user := os.Getenv(envUserName)
+	if user == "" {
+		return nil, fmt.Errorf("%s is not set", envUserName)
+	}
+	return url.UserPassword(user, os.Getenv(envPassword)), nil
+}
+
+type fileCredentials string
+
+func (f fileCredentials) Credentials(ctx context.Context) (*url.Userinfo, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	s := strings.TrimSpace(string(b))
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("%s: expected username:password", f)
+	}
+	return url.UserPassword(s[:i], s[i+1:]), nil
+}
+
+type vaultCredentials string
+
+func (v vaultCredentials) Credentials(ctx context.Context) (*url.Userinfo, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+strings.TrimPrefix(string(v), "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	AddSecret(token)
+	req.Header.Set("X-Vault-Token", token)
+
+	res, err := Outbound.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault %s: %s", v, res.Status)
+	}
+
+	// KV version 2 nests the secret in a second data object
+	var secret struct {
+		Data struct {
+			Data     map[string]string `json:"data"`
+			Username string            `json:"username"`
+			Password string            `json:"password"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&secret); err != nil {
+		return nil, err
+	}
+
+	user, pass := secret.Data.Username, secret.Data.Password
+	if secret.Data.Data != nil {
+		user, pass = secret.Data.Data["username"], secret.Data.Data["password"]
+	}
+	if user == "" {
+		return nil, fmt.Errorf("vault %s: no username", v)
+	}
+	return url.UserPassword(user, pass), nil
+}
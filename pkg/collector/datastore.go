@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// dataStoreProperties are the properties retrieved of datastores.
+var dataStoreProperties = []string{"summary", "host", "iormConfiguration"}
+
+// GatherDataStoreMetrics adds the metrics of datastores to acc.
+func GatherDataStoreMetrics(ctx context.Context, c *govmomi.Client, pc *property.Collector, dss []*object.Datastore, tc *TagCache, acc *Accumulator) error {
+	// Convert datastores into list of references
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(dss))
+	for _, ds := range dss {
+		refs = append(refs, ds.Reference())
+		paths[ds.Reference()] = ds.InventoryPath
+	}
+
+	// Retrieve summary property for all datastores
+	var dst []mo.Datastore
+	err := pc.Retrieve(ctx, refs, dataStoreProperties, &dst)
+	if err != nil {
+		return err
+	}
+	slog.Debug("retrieved datastores", "endpoint", c.URL().Host, "count", len(dst))
+
+	dsTags := make(map[types.ManagedObjectReference]map[string]string, len(dst))
+	scratch := make(map[string]string)
+	for _, ds := range dst {
+		resetTags(scratch)
+		records := DataStoreRecords(ds, scratch)
+		scratch["vcenter"] = c.URL().Host
+		// Names are not unique across folders and datacenters
+		scratch["moid"] = ds.Reference().Value
+		scratch["path"] = paths[ds.Reference()]
+
+		tags := tc.Intern(ds.Reference(), scratch)
+		if err := acc.Add("datastore", NewEntityRef(c.URL().Host, ds.Reference()), tags, records); err != nil {
+			return err
+		}
+		dsTags[ds.Reference()] = tags
+	}
+
+	return gatherDatastoreHosts(ctx, c, pc, dst, dsTags, acc)
+}
+
+// datastoreMount is whether a host mounts a datastore and can access it.
+type datastoreMount struct {
+	mounted, accessible bool
+	accessMode          string
+}
+
+// gatherDatastoreHosts adds a datastore_host metric per datastore of dst and
+// host mounting it, or of the clusters of those hosts, whether it mounts and
+// can access it, with the tags of its datastore metric of tags. Hosts of a
+// cluster not mounting a datastore others do, which cannot run the virtual
+// machines of the others, are reported as not mounted. Failing to retrieve
+// hosts is logged rather than failing the cycle.
+func gatherDatastoreHosts(ctx context.Context, c *govmomi.Client, pc *property.Collector, dst []mo.Datastore, tags map[types.ManagedObjectReference]map[string]string, acc *Accumulator) error {
+	seen := make(map[types.ManagedObjectReference]bool)
+	var refs []types.ManagedObjectReference
+	for _, ds := range dst {
+		for _, m := range ds.Host {
+			if !seen[m.Key] {
+				seen[m.Key] = true
+				refs = append(refs, m.Key)
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	hosts, err := clusterHosts(ctx, pc, refs)
+	if err != nil {
+		slog.Warn("retrieving datastore hosts failed", "endpoint", c.URL().Host, "err", err)
+		return nil
+	}
+
+	for _, ds := range dst {
+		mounts := make(map[types.ManagedObjectReference]datastoreMount, len(ds.Host))
+		clusters := make(map[types.ManagedObjectReference]bool)
+		for _, m := range ds.Host {
+			mounts[m.Key] = datastoreMount{
+				mounted:    m.MountInfo.Mounted == nil || *m.MountInfo.Mounted,
+				accessible: m.MountInfo.Accessible == nil || *m.MountInfo.Accessible,
+				accessMode: m.MountInfo.AccessMode,
+			}
+			if h, ok := hosts[m.Key]; ok && h.cluster != nil {
+				clusters[*h.cluster] = true
+			}
+		}
+		for ref, h := range hosts {
+			if _, ok := mounts[ref]; !ok && h.cluster != nil && clusters[*h.cluster] {
+				mounts[ref] = datastoreMount{}
+			}
+		}
+
+		keys := make([]types.ManagedObjectReference, 0, len(mounts))
+		for ref := range mounts {
+			keys = append(keys, ref)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Value < keys[j].Value })
+		for _, ref := range keys {
+			m := mounts[ref]
+			pairTags := map[string]string{
+				"name":        tags[ds.Reference()]["name"],
+				"host":        hosts[ref].name,
+				"host_moid":   ref.Value,
+				"cluster":     hosts[ref].clusterName,
+				"access_mode": m.accessMode,
+			}
+			for _, k := range entityTags {
+				pairTags[k] = tags[ds.Reference()][k]
+			}
+			records := map[string]interface{}{"mounted": m.mounted, "accessible": m.accessible}
+			if err := acc.add("datastore_host", NewEntityRef(c.URL().Host, ds.Reference()), pairTags, records, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clusterHost is a host and its cluster, if any.
+type clusterHost struct {
+	name        string
+	cluster     *types.ManagedObjectReference
+	clusterName string
+}
+
+// clusterHosts returns the hosts of refs and every other host of their
+// clusters, by reference.
+func clusterHosts(ctx context.Context, pc *property.Collector, refs []types.ManagedObjectReference) (map[types.ManagedObjectReference]clusterHost, error) {
+	var hst []mo.HostSystem
+	if err := pc.Retrieve(ctx, refs, []string{"name", "parent"}, &hst); err != nil {
+		return nil, err
+	}
+
+	var clusterRefs []types.ManagedObjectReference
+	seen := make(map[types.ManagedObjectReference]bool)
+	for _, h := range hst {
+		if p := h.Parent; p != nil && p.Type == "ClusterComputeResource" && !seen[*p] {
+			seen[*p] = true
+			clusterRefs = append(clusterRefs, *p)
+		}
+	}
+	var ccr []mo.ClusterComputeResource
+	if len(clusterRefs) != 0 {
+		if err := pc.Retrieve(ctx, clusterRefs, []string{"name", "host"}, &ccr); err != nil {
+			return nil, err
+		}
+	}
+
+	hosts := make(map[types.ManagedObjectReference]clusterHost)
+	for _, h := range hst {
+		hosts[h.Reference()] = clusterHost{name: h.Name}
+	}
+	var missing []types.ManagedObjectReference
+	for _, cluster := range ccr {
+		ref := cluster.Reference()
+		for _, h := range cluster.Host {
+			ch, ok := hosts[h]
+			if !ok {
+				missing = append(missing, h)
+			}
+			ch.cluster, ch.clusterName = &ref, cluster.Name
+			hosts[h] = ch
+		}
+	}
+	if len(missing) != 0 {
+		var more []mo.HostSystem
+		if err := pc.Retrieve(ctx, missing, []string{"name"}, &more); err != nil {
+			return nil, err
+		}
+		for _, h := range more {
+			ch := hosts[h.Reference()]
+			ch.name = h.Name
+			hosts[h.Reference()] = ch
+		}
+	}
+	return hosts, nil
+}
+
+// DataStoreRecords fills tags and returns the records of a single datastore.
+func DataStoreRecords(ds mo.Datastore, tags map[string]string) map[string]interface{} {
+	records := make(map[string]interface{}, len(Fields["datastore"]))
+
+	tags["name"] = ds.Summary.Name
+	tags["type"] = ds.Summary.Type
+	tags["url"] = ds.Summary.Url
+
+	records["capacity"] = ds.Summary.Capacity
+	records["freespace"] = ds.Summary.FreeSpace
+
+	used := 0.0
+	if ds.Summary.Capacity > 0 {
+		used = float64(ds.Summary.Capacity-ds.Summary.FreeSpace) / float64(ds.Summary.Capacity) * 100
+	}
+	records["used_percent"] = used
+	datastoreIORMRecords(ds, records)
+
+	return records
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// DiagnosticKinds are the entity types counted by Diagnose, in order.
+var DiagnosticKinds = []string{"Datacenter", "ClusterComputeResource", "HostSystem", "Datastore", "VirtualMachine"}
+
+// Diagnostics describes what the session of a client can see and do, to
+// explain why a collection produced no data.
+type Diagnostics struct {
+	// Counts maps entity types to the number of entities visible.
+	Counts map[string]int
+	// Missing lists the required privileges not granted on the inventory root.
+	Missing []string
+}
+
+// Diagnose counts the entities visible to the session of c and checks the
+// session holds privileges on the inventory root.
+func Diagnose(ctx context.Context, c *govmomi.Client, privileges []string) (*Diagnostics, error) {
+	d := &Diagnostics{Counts: make(map[string]int)}
+	root := c.ServiceContent.RootFolder
+
+	m := view.NewManager(c.Client)
+	v, err := m.CreateContainerView(ctx, root, DiagnosticKinds, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(context.Background())
+
+	for _, kind := range DiagnosticKinds {
+		refs, err := v.Find(ctx, []string{kind}, nil)
+		if err != nil {
+			return nil, err
+		}
+		d.Counts[kind] = len(refs)
+	}
+
+	if len(privileges) == 0 || c.ServiceContent.AuthorizationManager == nil {
+		return d, nil
+	}
+
+	d.Missing, err = MissingPrivileges(ctx, c, root, privileges)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// MissingPrivileges returns the privileges, out of privileges, the session of
+// c is not granted on entity.
+func MissingPrivileges(ctx context.Context, c *govmomi.Client, entity types.ManagedObjectReference, privileges []string) ([]string, error) {
+	s, err := c.SessionManager.UserSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("session is not authenticated")
+	}
+
+	req := types.HasPrivilegeOnEntities{
+		This:      *c.ServiceContent.AuthorizationManager,
+		Entity:    []types.ManagedObjectReference{entity},
+		SessionId: s.Key,
+		PrivId:    privileges,
+	}
+	res, err := methods.HasPrivilegeOnEntities(ctx, c.Client, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, e := range res.Returnval {
+		for _, p := range e.PrivAvailability {
+			if !p.IsGranted {
+				missing = append(missing, p.PrivId)
+			}
+		}
+	}
+	sort.Strings(missing)
+
+	return missing, nil
+}
+
+// RequiredPrivileges returns the privileges needed by all gatherers.
+func RequiredPrivileges() []string {
+	seen := make(map[string]bool)
+	var privileges []string
+	for _, g := range Gatherers {
+		for _, p := range g.Privileges {
+			if !seen[p] {
+				seen[p] = true
+				privileges = append(privileges, p)
+			}
+		}
+	}
+	sort.Strings(privileges)
+	return privileges
+}
+
+// logDiagnostics runs Diagnose and logs its outcome, prefixed by msg.
+func logDiagnostics(ctx context.Context, c *govmomi.Client, endpoint, msg string) {
+	d, err := Diagnose(ctx, c, RequiredPrivileges())
+	if err != nil {
+		slog.Warn(msg, "endpoint", endpoint, "diagnostics_err", err)
+		return
+	}
+
+	attrs := []any{"endpoint", endpoint}
+	for _, kind := range DiagnosticKinds {
+		attrs = append(attrs, kind, d.Counts[kind])
+	}
+	if len(d.Missing) != 0 {
+		attrs = append(attrs, "missing_privileges", d.Missing)
+	}
+	slog.Warn(msg, attrs...)
+}
+
+func isNotFound(err error) bool {
+	var nf *find.NotFoundError
+	return errors.As(err, &nf)
+}
+
+func isInvalidName(err error) bool {
+	if soap.IsSoapFault(err) {
+		_, ok := soap.ToSoapFault(err).VimFault().(types.InvalidName)
+		return ok
+	}
+	if soap.IsVimFault(err) {
+		_, ok := soap.ToVimFault(err).(*types.InvalidName)
+		return ok
+	}
+	return false
+}
+
+func isNoPermission(err error) bool {
+	if soap.IsSoapFault(err) {
+		_, ok := soap.ToSoapFault(err).VimFault().(types.NoPermission)
+		return ok
+	}
+	if soap.IsVimFault(err) {
+		_, ok := soap.ToVimFault(err).(*types.NoPermission)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// checkDirect checks that c, connected in direct mode, is an ESXi host,
+// logging the vCenter managing it, if any, and its lockdown mode.
+func checkDirect(ctx context.Context, c *govmomi.Client, endpoint string) error {
+	if c.IsVC() {
+		return fmt.Errorf("%s is a vCenter: direct mode collects standalone ESXi hosts", endpoint)
+	}
+
+	m := view.NewManager(c.Client)
+	v, err := m.CreateContainerView(ctx, c.ServiceContent.RootFolder, []string{"HostSystem"}, true)
+	if err != nil {
+		return err
+	}
+	defer v.Destroy(context.Background())
+
+	var hosts []mo.HostSystem
+	if err := v.Retrieve(ctx, []string{"HostSystem"}, []string{"summary.managementServerIp", "config.lockdownMode"}, &hosts); err != nil {
+		return err
+	}
+
+	attrs := []any{"endpoint", endpoint}
+	for _, h := range hosts {
+		if h.Summary.ManagementServerIp != "" {
+			attrs = append(attrs, "managed_by", h.Summary.ManagementServerIp)
+		}
+		if h.Config != nil && h.Config.LockdownMode != "" && h.Config.LockdownMode != types.HostLockdownModeLockdownDisabled {
+			// Only the exception users of a host in lockdown mode log in
+			attrs = append(attrs, "lockdown_mode", h.Config.LockdownMode)
+		}
+	}
+	slog.Info("collecting ESXi host directly", attrs...)
+	return nil
+}
+
+// directLoginError returns err of failing to log in to a host in direct
+// mode, explaining that of a host in lockdown mode.
+func directLoginError(err error) error {
+	if Kind(err) != AuthError {
+		return err
+	}
+	return fmt.Errorf("%w: hosts in lockdown mode accept the logins of their exception users only", err)
+}
@@ -0,0 +1,268 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InventorySnapshot is the inventory of every endpoint at a point in time,
+// for diffing with another.
+type InventorySnapshot struct {
+	Time     time.Time          `json:"time"`
+	Entities []InventorySnapped `json:"entities"`
+}
+
+// InventorySnapped is an entity of an InventorySnapshot.
+type InventorySnapped struct {
+	Endpoint string `json:"endpoint"`
+	Kind     string `json:"kind"`
+	MOID     string `json:"moid"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	// Cluster is the path of the cluster of hosts, and of the host of
+	// virtual machines.
+	Cluster string `json:"cluster,omitempty"`
+	// NumCPU and MemoryMB are the hardware of virtual machines.
+	NumCPU   int64 `json:"num_cpu,omitempty"`
+	MemoryMB int64 `json:"mem_mb,omitempty"`
+}
+
+// NewInventorySnapshot returns the snapshot of entries at ts.
+func NewInventorySnapshot(ts time.Time, entries []InventoryEntry) *InventorySnapshot {
+	// Hosts are inventoried under their cluster, or their own compute
+	// resource when standalone
+	clusters := make(map[string]string)
+	for _, e := range entries {
+		if e.Kind == "host" {
+			clusters[e.Endpoint+"/"+e.MOID] = path.Dir(e.Path)
+		}
+	}
+
+	s := &InventorySnapshot{Time: ts, Entities: make([]InventorySnapped, 0, len(entries))}
+	for _, e := range entries {
+		snapped := InventorySnapped{Endpoint: e.Endpoint, Kind: e.Kind, MOID: e.MOID, Name: e.Name, Path: e.Path}
+		switch e.Kind {
+		case "host":
+			snapped.Cluster = path.Dir(e.Path)
+		case "vm":
+			if host, ok := e.Summary["host"].(string); ok {
+				snapped.Cluster = clusters[e.Endpoint+"/"+host]
+			}
+			snapped.NumCPU = summaryInt(e.Summary["num_cpu"])
+			snapped.MemoryMB = summaryInt(e.Summary["mem_mb"])
+		}
+		s.Entities = append(s.Entities, snapped)
+	}
+	return s
+}
+
+func summaryInt(v interface{}) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Carry adds the entities of prev on endpoints, those that could not be
+// listed, so their entities are not reported deleted.
+func (s *InventorySnapshot) Carry(prev *InventorySnapshot, endpoints []string) {
+	if prev == nil {
+		return
+	}
+	failed := make(map[string]bool, len(endpoints))
+	for _, e := range endpoints {
+		failed[e] = true
+	}
+	for _, e := range prev.Entities {
+		if failed[e.Endpoint] {
+			s.Entities = append(s.Entities, e)
+		}
+	}
+}
+
+// Kinds of InventoryChange.
+const (
+	ChangeCreated      = "created"
+	ChangeDeleted      = "deleted"
+	ChangeMoved        = "moved"
+	ChangeReconfigured = "reconfigured"
+	ChangeMembership   = "membership"
+)
+
+// InventoryChange is a difference between two inventory snapshots: an entity
+// created or deleted, moved to another folder or cluster, a virtual machine
+// with a changed number of vCPUs or memory, or a host moved to another
+// cluster, as membership.
+type InventoryChange struct {
+	Change   string `json:"change"`
+	Endpoint string `json:"endpoint"`
+	Kind     string `json:"kind"`
+	MOID     string `json:"moid"`
+	Name     string `json:"name"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+// DiffInventory returns the changes from old to new, sorted by endpoint,
+// kind and name.
+func DiffInventory(old, new *InventorySnapshot) []InventoryChange {
+	key := func(e InventorySnapped) string { return e.Endpoint + "/" + e.Kind + "/" + e.MOID }
+	before := make(map[string]InventorySnapped, len(old.Entities))
+	for _, e := range old.Entities {
+		before[key(e)] = e
+	}
+
+	var changes []InventoryChange
+	change := func(c string, e InventorySnapped, from, to string) {
+		changes = append(changes, InventoryChange{Change: c, Endpoint: e.Endpoint, Kind: e.Kind, MOID: e.MOID, Name: e.Name, From: from, To: to})
+	}
+	for _, e := range new.Entities {
+		b, ok := before[key(e)]
+		if !ok {
+			change(ChangeCreated, e, "", e.Path)
+			continue
+		}
+		delete(before, key(e))
+
+		switch {
+		case e.Kind == "host" && b.Cluster != e.Cluster:
+			change(ChangeMembership, e, b.Cluster, e.Cluster)
+		case b.Path != e.Path:
+			change(ChangeMoved, e, b.Path, e.Path)
+		case e.Kind == "vm" && b.Cluster != e.Cluster && b.Cluster != "" && e.Cluster != "":
+			change(ChangeMoved, e, b.Cluster, e.Cluster)
+		}
+		if b.NumCPU != e.NumCPU || b.MemoryMB != e.MemoryMB {
+			change(ChangeReconfigured, e, hardware(b), hardware(e))
+		}
+	}
+	for _, b := range before {
+		change(ChangeDeleted, b, b.Path, "")
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Change < b.Change
+	})
+	return changes
+}
+
+func hardware(e InventorySnapped) string {
+	return fmt.Sprintf("%d vCPU %d MB", e.NumCPU, e.MemoryMB)
+}
+
+// DriftMetrics returns an inventory_drift metric per endpoint of snapshot,
+// counting the changes to it, stamped with the time of snapshot.
+func DriftMetrics(snapshot *InventorySnapshot, changes []InventoryChange) ([]Metric, error) {
+	counts := make(map[string]map[string]int)
+	for _, e := range snapshot.Entities {
+		counts[e.Endpoint] = make(map[string]int)
+	}
+	for _, c := range changes {
+		if counts[c.Endpoint] == nil {
+			counts[c.Endpoint] = make(map[string]int)
+		}
+		counts[c.Endpoint][c.Change]++
+	}
+
+	endpoints := make([]string, 0, len(counts))
+	for e := range counts {
+		endpoints = append(endpoints, e)
+	}
+	sort.Strings(endpoints)
+
+	metrics := make([]Metric, 0, len(endpoints))
+	for _, e := range endpoints {
+		records := make(map[string]interface{}, 5)
+		for _, c := range []string{ChangeCreated, ChangeDeleted, ChangeMoved, ChangeReconfigured, ChangeMembership} {
+			records[c] = counts[e][c]
+		}
+		m, err := NewMetric("inventory_drift", map[string]string{"vcenter": e}, records, snapshot.Time)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// inventorySnapshotLayout is the time layout of the names of the files of
+// inventory snapshots.
+const inventorySnapshotLayout = "20060102T150405Z"
+
+// SaveInventorySnapshot writes s to dir as inventory-<time>.json, returning
+// its path.
+func SaveInventorySnapshot(dir string, s *InventorySnapshot) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	p := filepath.Join(dir, "inventory-"+s.Time.UTC().Format(inventorySnapshotLayout)+".json")
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return "", err
+	}
+	return p, os.Rename(tmp, p)
+}
+
+// LoadInventorySnapshot reads the inventory snapshot of the file at p.
+func LoadInventorySnapshot(p string) (*InventorySnapshot, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var s InventorySnapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return &s, nil
+}
+
+// FindInventorySnapshot returns the path of the latest inventory snapshot of
+// dir taken at or before at, "" if there is none.
+func FindInventorySnapshot(dir string, at time.Time) (string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "inventory-*.json"))
+	if err != nil {
+		return "", err
+	}
+
+	var found string
+	var latest time.Time
+	for _, name := range names {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "inventory-"), ".json")
+		ts, err := time.Parse(inventorySnapshotLayout, stamp)
+		if err != nil || ts.After(at) {
+			continue
+		}
+		if found == "" || ts.After(latest) {
+			found, latest = name, ts
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestDiffInventory(t *testing.T) {
+	entries := []InventoryEntry{
+		{Endpoint: "vc", Kind: "host", MOID: "host-1", Name: "h1", Path: "/DC0/host/C0/h1"},
+		{Endpoint: "vc", Kind: "vm", MOID: "vm-1", Name: "a", Path: "/DC0/vm/a", Summary: map[string]interface{}{"host": "host-1", "num_cpu": int32(2), "mem_mb": int32(4096)}},
+		{Endpoint: "vc", Kind: "vm", MOID: "vm-2", Name: "b", Path: "/DC0/vm/b"},
+	}
+	old := NewInventorySnapshot(time.Now().Add(-time.Hour), entries)
+
+	entries[0].Path = "/DC0/host/C1/h1"
+	entries[1].Summary["num_cpu"] = int32(4)
+	entries[2] = InventoryEntry{Endpoint: "vc", Kind: "vm", MOID: "vm-3", Name: "c", Path: "/DC0/vm/c"}
+	new := NewInventorySnapshot(time.Now(), entries)
+
+	changes := DiffInventory(old, new)
+	expect := []string{ChangeMembership, ChangeMoved, ChangeReconfigured, ChangeDeleted, ChangeCreated}
+	if len(changes) != len(expect) {
+		t.Fatalf("changes %+v, expected %v", changes, expect)
+	}
+	for i, c := range changes {
+		if c.Change != expect[i] {
+			t.Errorf("change %d %+v, expected %s", i, c, expect[i])
+		}
+	}
+
+	metrics, err := DriftMetrics(new, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics[0].Fields["moved"] != int64(1) || metrics[0].Fields["created"] != int64(1) {
+		t.Errorf("drift metrics %+v", metrics)
+	}
+
+	// An endpoint that could not be listed keeps its entities
+	failed := NewInventorySnapshot(time.Now(), nil)
+	failed.Carry(new, []string{"vc"})
+	if changes := DiffInventory(new, failed); len(changes) != 0 {
+		t.Errorf("changes %+v, expected none", changes)
+	}
+}
+
+func TestDiffAlarms(t *testing.T) {
+	alarm := func(endpoint, name, red string) AlarmDefinition {
+		return AlarmDefinition{
+			Endpoint: endpoint,
+			Entity:   "/",
+			Name:     name,
+			Enabled:  true,
+			Expression: alarmExpression(&types.OrAlarmExpression{Expression: []types.BaseAlarmExpression{
+				&types.StateAlarmExpression{Operator: types.StateAlarmOperatorIsEqual, Type: "VirtualMachine", StatePath: "runtime.powerState", Red: red},
+			}}),
+			Action: alarmAction(&types.GroupAlarmAction{}),
+		}
+	}
+
+	// The baseline is read back from a file
+	b, err := json.Marshal([]AlarmDefinition{alarm("vc", "same", "poweredOff"), alarm("vc", "changed", "poweredOff"), alarm("vc", "deleted", "poweredOff"), alarm("down", "kept", "poweredOff")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var baseline []AlarmDefinition
+	if err := json.Unmarshal(b, &baseline); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := alarm("vc", "changed", "suspended")
+	changed.Enabled = false
+	changed.ModifiedBy = "admin"
+	current := []AlarmDefinition{alarm("vc", "same", "poweredOff"), changed, alarm("vc", "created", "poweredOff")}
+
+	drift := DiffAlarms(baseline, current)
+	expect := []AlarmDrift{
+		{Change: AlarmChanged, Endpoint: "vc", Entity: "/", Name: "changed", Fields: []string{"enabled", "expression"}},
+		{Change: ChangeCreated, Endpoint: "vc", Entity: "/", Name: "created"},
+		{Change: ChangeDeleted, Endpoint: "vc", Entity: "/", Name: "deleted"},
+	}
+	if !reflect.DeepEqual(drift, expect) {
+		t.Errorf("drift %+v, expected %+v", drift, expect)
+	}
+	if n := current[0].Expression; n.Type != "OrAlarmExpression" || len(n.Nodes) != 1 || n.Nodes[0].Type != "StateAlarmExpression" {
+		t.Errorf("expression %+v", n)
+	}
+}
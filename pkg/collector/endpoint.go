@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi"
+)
+
+// Endpoint is an ESX or vCenter to collect, along with the state kept for it
+// across collection cycles.
+type Endpoint struct {
+	URL     *url.URL
+	Options ClientOptions
+	Tags    *TagCache
+	// StoragePolicies resolves the SPBM storage policies of virtual machines
+	// and their disks, tagging their metrics with them.
+	StoragePolicies bool
+	// DiskLimits emits the vm_disk metrics of virtual disks, with their
+	// storage I/O allocation, storage policies or not.
+	DiskLimits bool
+	// HostHardware emits the host_hardware info metrics of hosts, with the
+	// BMC addresses of their BMCAttribute custom attribute, if set.
+	HostHardware bool
+	BMCAttribute string
+	// MaxVMs, if set, collects the same subset of at most this many
+	// virtual machines every cycle, see guardrail metrics.
+	MaxVMs int
+	// PoweredOnOnly retrieves the config, snapshots and quick stats of
+	// powered on virtual machines only, emitting the state of powered off
+	// ones alone.
+	PoweredOnOnly bool
+	// Direct collects a standalone ESXi host rather than a vCenter, for
+	// small sites or when vCenter is down, the gatherers and features of
+	// vCenter services being disabled by its Capabilities.
+	Direct bool
+
+	mu     sync.Mutex
+	client *govmomi.Client
+	// maxQueryMetrics is the limit of the metrics of a performance query
+	// of client, as of MaxQueryMetrics
+	maxQueryMetrics int
+	// caps are the capabilities of client
+	caps Capabilities
+	// vmsSkipped are the virtual machines left out by MaxVMs in the
+	// current cycle, and lowMemory whether its statistics are skipped, the
+	// heap exceeding the MaxMemory of the collector
+	vmsSkipped int
+	lowMemory  bool
+
+	// eventsSince is the end of the last window of events collected, and
+	// lastEventKey the key of the newest of them.
+	eventsSince  time.Time
+	lastEventKey int32
+}
+
+// Client returns the client of the endpoint, connecting and logging in when
+// there is none yet or its session is no longer valid.
+func (e *Endpoint) Client(ctx context.Context) (*govmomi.Client, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client != nil {
+		s, err := e.client.SessionManager.UserSession(ctx)
+		if err == nil && s != nil {
+			return e.client, nil
+		}
+		slog.Info("session lost, logging in again", "endpoint", e.URL.Host, "err", err)
+		e.client = nil
+	}
+
+	c, err := NewClient(ctx, e.URL, e.Options)
+	if err != nil {
+		if e.Direct {
+			err = directLoginError(err)
+		}
+		return nil, err
+	}
+	if e.Direct {
+		if err := checkDirect(ctx, c, e.URL.Host); err != nil {
+			if e.Options.OwnsSession() {
+				c.Logout(ctx)
+			}
+			return nil, err
+		}
+	}
+	slog.Info("connected", "endpoint", e.URL.Host, "api_version", c.ServiceContent.About.ApiVersion)
+
+	if e.maxQueryMetrics, err = MaxQueryMetrics(ctx, c); err != nil {
+		slog.Warn("reading the performance query limit failed", "endpoint", e.URL.Host, "max_query_metrics", e.maxQueryMetrics, "err", err)
+	}
+	if n := maxPerfCounters(); e.maxQueryMetrics > 0 && n > e.maxQueryMetrics {
+		slog.Warn("performance counters exceed the metrics of a query, their statistics can't be collected", "endpoint", e.URL.Host, "counters", n, "max_query_metrics", e.maxQueryMetrics)
+	}
+
+	e.caps = DetectCapabilities(ctx, c)
+	logCapabilities(e.URL.Host, e.caps)
+	if e.StoragePolicies && !e.caps.StoragePolicies {
+		slog.Warn("storage policies requested but the SPBM service doesn't answer, leaving them out", "endpoint", e.URL.Host)
+	}
+
+	e.client = c
+	return c, nil
+}
+
+// Capabilities returns the capabilities of the endpoint, as detected on
+// connecting, none before.
+func (e *Endpoint) Capabilities() Capabilities {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.caps
+}
+
+// perfLimit returns the limit of the metrics of the performance queries of
+// the current cycle, -1 to skip them, as of latestSamples.
+func (e *Endpoint) perfLimit() int {
+	if e.lowMemory {
+		return -1
+	}
+	return e.maxQueryMetrics
+}
+
+// Close logs out of the session of the endpoint, if the collector owns it.
+func (e *Endpoint) Close(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	c := e.client
+	e.client = nil
+
+	if c == nil || !e.Options.OwnsSession() {
+		return nil
+	}
+	return c.Logout(ctx)
+}
+
+// ParseEndpoints parses a comma separated list of ESX or vCenter URLs.
+func ParseEndpoints(s string, opts ClientOptions) ([]*Endpoint, error) {
+	urls, err := ParseURLs(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewEndpoints(urls, opts), nil
+}
+
+// ParseURLs parses a comma separated list of ESX or vCenter URLs, of at
+// least one.
+func ParseURLs(s string) ([]*url.URL, error) {
+	var urls []*url.URL
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+
+		u, err := url.Parse(e)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no endpoint configured")
+	}
+	return urls, nil
+}
+
+// NewEndpoints returns the endpoints of urls, of the options opts.
+func NewEndpoints(urls []*url.URL, opts ClientOptions) []*Endpoint {
+	endpoints := make([]*Endpoint, len(urls))
+	for i, u := range urls {
+		endpoints[i] = &Endpoint{URL: u, Options: opts, Tags: NewTagCache()}
+	}
+	return endpoints
+}
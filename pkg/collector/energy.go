@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// EnergyMeter attributes the power draw of hosts, that of their host_power
+// metrics, to the powered on virtual machines they run in proportion to their
+// CPU usage, an estimate as idle hosts still draw power, and integrates it
+// across collection cycles into the energy each virtual machine consumed.
+type EnergyMeter struct {
+	// Filter, if set, drops vm_energy fields.
+	Filter *FieldFilter
+
+	vms map[EntityRef]*vmEnergy
+}
+
+type vmEnergy struct {
+	ts    time.Time
+	watts float64
+	wh    float64
+}
+
+// NewEnergyMeter returns an EnergyMeter.
+func NewEnergyMeter() *EnergyMeter {
+	return &EnergyMeter{vms: make(map[EntityRef]*vmEnergy)}
+}
+
+// Attribute returns a vm_energy metric per powered on virtual machine of
+// metrics, those of a cycle, running on a host with a host_power metric,
+// sorted by vcenter and path. Their energy_wh is the energy consumed since
+// they were first attributed power, at the power of the previous cycle until
+// this one. Virtual machines no longer attributed power are forgotten, unless
+// no host of their vCenter was, as when collecting it failed.
+func (e *EnergyMeter) Attribute(metrics []Metric) []Metric {
+	type host struct {
+		watts float64
+		usage float64
+		vms   []Metric
+	}
+	hosts := make(map[EntityRef]*host)
+	for _, m := range metrics {
+		if m.Name == "host_power" && m.Entity != nil {
+			watts, _ := m.Float("power_watts")
+			hosts[*m.Entity] = &host{watts: watts}
+		}
+	}
+	for _, m := range metrics {
+		if m.Name != "vm" || m.Entity == nil || m.Tag("host_moid") == "" {
+			continue
+		}
+		h, ok := hosts[EntityRef{VCenter: m.Entity.VCenter, Type: "HostSystem", MOID: m.Tag("host_moid")}]
+		if !ok {
+			continue
+		}
+		// Only powered on virtual machines have a CPU usage
+		usage, ok := m.Float("overall_cpu_usage")
+		if !ok || usage <= 0 {
+			continue
+		}
+		h.usage += usage
+		h.vms = append(h.vms, m)
+	}
+
+	attributed := make(map[EntityRef]bool)
+	vcenters := make(map[string]bool)
+	var out []Metric
+	for ref, h := range hosts {
+		vcenters[ref.VCenter] = true
+		for _, m := range h.vms {
+			usage, _ := m.Float("overall_cpu_usage")
+			watts := h.watts * usage / h.usage
+
+			ve, ok := e.vms[*m.Entity]
+			if !ok {
+				ve = &vmEnergy{ts: m.Time}
+				e.vms[*m.Entity] = ve
+			}
+			if m.Time.After(ve.ts) {
+				ve.wh += ve.watts * m.Time.Sub(ve.ts).Hours()
+			}
+			ve.ts, ve.watts = m.Time, watts
+			attributed[*m.Entity] = true
+
+			tags := map[string]string{
+				"name":      m.Tag("name"),
+				"host_moid": m.Tag("host_moid"),
+				"vcenter":   m.Tag("vcenter"),
+				"moid":      m.Tag("moid"),
+				"path":      m.Tag("path"),
+			}
+			em, err := NewMetric("vm_energy", tags, map[string]interface{}{"power_watts": watts, "energy_wh": ve.wh}, m.Time)
+			if err != nil {
+				slog.Warn("energy attribution failed", "name", tags["name"], "err", err)
+				continue
+			}
+			for k := range em.Fields {
+				if !e.Filter.Keep("vm_energy", k) {
+					delete(em.Fields, k)
+				}
+			}
+			em.Entity = m.Entity
+			out = append(out, em)
+		}
+	}
+
+	for ref := range e.vms {
+		if !attributed[ref] && vcenters[ref.VCenter] {
+			delete(e.vms, ref)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tags["vcenter"] != out[j].Tags["vcenter"] {
+			return out[i].Tags["vcenter"] < out[j].Tags["vcenter"]
+		}
+		if out[i].Tags["path"] != out[j].Tags["path"] {
+			return out[i].Tags["path"] < out[j].Tags["path"]
+		}
+		return out[i].Tags["moid"] < out[j].Tags["moid"]
+	})
+	return out
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEnergyAttribute(t *testing.T) {
+	e := NewEnergyMeter()
+	start := time.Now()
+
+	host := func(watts int64, ts time.Time) Metric {
+		return Metric{
+			Name:   "host_power",
+			Fields: map[string]interface{}{"power_watts": watts, "power_cap_watts": int64(0), "capped": false},
+			Time:   ts,
+			Entity: &EntityRef{VCenter: "vc", Type: "HostSystem", MOID: "host-1"},
+		}
+	}
+	vm := func(moid string, usage int64, ts time.Time) Metric {
+		return Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": moid, "vcenter": "vc", "moid": moid, "path": "/DC0/vm/" + moid, "host_moid": "host-1"},
+			Fields: map[string]interface{}{"overall_cpu_usage": usage},
+			Time:   ts,
+			Entity: &EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: moid},
+		}
+	}
+
+	var metrics []Metric
+	for i := 0; i < 3; i++ {
+		ts := start.Add(time.Duration(i) * 30 * time.Minute)
+		metrics = e.Attribute([]Metric{host(400, ts), vm("vm-1", 3000, ts), vm("vm-2", 1000, ts), vm("vm-3", 0, ts)})
+	}
+
+	if len(metrics) != 2 {
+		t.Fatalf("%d metrics, expected 2", len(metrics))
+	}
+	expect := map[string][2]float64{"vm-1": {300, 300}, "vm-2": {100, 100}}
+	for _, m := range metrics {
+		watts, _ := m.Float("power_watts")
+		wh, _ := m.Float("energy_wh")
+		want := expect[m.Tag("moid")]
+		if watts != want[0] || math.Abs(wh-want[1]) > 1e-9 {
+			t.Errorf("%s power_watts=%g energy_wh=%g, expected %g and %g", m.Tag("moid"), watts, wh, want[0], want[1])
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// CollectorError is the failure of a single collector against an endpoint.
+type CollectorError struct {
+	Endpoint  string
+	Collector string
+	Err       error
+}
+
+func (e *CollectorError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Endpoint, e.Collector, e.Err)
+}
+
+func (e *CollectorError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is the failures of a collection.
+type Errors []error
+
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// PanicError is a panic recovered from a collector.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// FailedCollectors counts the collector runs that errs account for, a failed
+// connection counting as the failure of every collector of its endpoint.
+func FailedCollectors(errs []error) int {
+	n := 0
+	for _, err := range errs {
+		var ce *CollectorError
+		if !errors.As(err, &ce) {
+			continue
+		}
+		if ce.Collector == "connect" {
+			n += len(Gatherers)
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// ErrorKind is the cause of a failure.
+type ErrorKind int
+
+const (
+	// OtherError is any failure not otherwise classified.
+	OtherError ErrorKind = iota
+	// AuthError is a rejected login or session.
+	AuthError
+	// ConnectivityError is a failure to reach or trust the endpoint.
+	ConnectivityError
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case AuthError:
+		return "auth"
+	case ConnectivityError:
+		return "connectivity"
+	}
+	return "other"
+}
+
+// Kind classifies err.
+func Kind(err error) ErrorKind {
+	// SOAP faults are not wrapped with %w by govmomi, so are matched on
+	// every error of the chain
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		var fault interface{}
+		if soap.IsSoapFault(e) {
+			fault = soap.ToSoapFault(e).VimFault()
+		} else if soap.IsVimFault(e) {
+			fault = soap.ToVimFault(e)
+		}
+
+		switch fault.(type) {
+		case types.InvalidLogin, *types.InvalidLogin, types.NotAuthenticated, *types.NotAuthenticated:
+			return AuthError
+		}
+	}
+
+	var netErr net.Error
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	switch {
+	case errors.As(err, &netErr), errors.As(err, &unknownAuthority), errors.As(err, &hostname),
+		errors.As(err, &invalid), errors.As(err, &verification):
+		return ConnectivityError
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, syscall.ECONNREFUSED):
+		return ConnectivityError
+	}
+	return OtherError
+}
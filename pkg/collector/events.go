@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Event is an event of the event history of an endpoint, such as a VM
+// powering on or a host disconnecting.
+type Event struct {
+	Time    time.Time `json:"timestamp"`
+	VCenter string    `json:"vcenter"`
+	Key     int32     `json:"key"`
+	// ChainID is the key of the first event of the operation of the event,
+	// shared by the events of a task such as a migration.
+	ChainID int32 `json:"chain_id,omitempty"`
+	// Type is the type of the event, such as VmPoweredOnEvent, or the event
+	// type ID of extended events, such as
+	// com.vmware.vc.HA.ClusterFailoverActionInitiatedEvent.
+	Type string `json:"type"`
+	// Severity is the category of the event: info, warning, error or user.
+	Severity        string `json:"severity,omitempty"`
+	Message         string `json:"message"`
+	User            string `json:"user,omitempty"`
+	Datacenter      string `json:"datacenter,omitempty"`
+	ComputeResource string `json:"compute_resource,omitempty"`
+	Host            string `json:"host,omitempty"`
+	VM              string `json:"vm,omitempty"`
+	Datastore       string `json:"datastore,omitempty"`
+	// Entity is the most specific entity of the event, a VM over its host.
+	Entity *EntityRef `json:"entity,omitempty"`
+}
+
+// eventPageSize is the number of events read per call.
+const eventPageSize = 1000
+
+// EventOverlap is how much earlier than the end of the previous window of
+// events of an endpoint the next window begins. The begin time of a window is
+// exclusive, so that events on the bound of windows sharing it are read by
+// neither: windows overlap, the events read twice being left out by key.
+const EventOverlap = time.Second
+
+// Events returns the events of the endpoint of c created after since and
+// until, oldest first.
+func Events(ctx context.Context, c *govmomi.Client, since, until time.Time) ([]Event, error) {
+	m := event.NewManager(c.Client)
+	hc, err := m.CreateCollectorForEvents(ctx, types.EventFilterSpec{
+		Time: &types.EventFilterSpecByTime{BeginTime: &since, EndTime: &until},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer hc.Destroy(ctx)
+
+	if err := hc.Rewind(ctx); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for {
+		page, err := hc.ReadNextEvents(ctx, eventPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return events, nil
+		}
+		for _, be := range page {
+			events = append(events, newEvent(ctx, m, c.URL().Host, be))
+		}
+	}
+}
+
+func newEvent(ctx context.Context, m *event.Manager, vcenter string, be types.BaseEvent) Event {
+	e := be.GetEvent()
+	ev := Event{
+		Time:    e.CreatedTime,
+		VCenter: vcenter,
+		Key:     e.Key,
+		ChainID: e.ChainId,
+		Type:    reflect.Indirect(reflect.ValueOf(be)).Type().Name(),
+		Message: e.FullFormattedMessage,
+		User:    e.UserName,
+	}
+	if ex, ok := be.(*types.EventEx); ok {
+		ev.Type = ex.EventTypeId
+	}
+	// Unknown categories are left out rather than failing the event
+	if severity, err := m.EventCategory(ctx, be); err == nil {
+		ev.Severity = severity
+	}
+
+	entity := func(ref *types.ManagedObjectReference) {
+		if ref != nil && ev.Entity == nil {
+			ev.Entity = NewEntityRef(vcenter, *ref)
+		}
+	}
+	if a := e.Vm; a != nil {
+		ev.VM = a.Name
+		entity(&a.Vm)
+	}
+	if a := e.Host; a != nil {
+		ev.Host = a.Name
+		entity(&a.Host)
+	}
+	if a := e.Ds; a != nil {
+		ev.Datastore = a.Name
+		entity(&a.Datastore)
+	}
+	if a := e.ComputeResource; a != nil {
+		ev.ComputeResource = a.Name
+		entity(&a.ComputeResource)
+	}
+	if a := e.Datacenter; a != nil {
+		ev.Datacenter = a.Name
+		entity(&a.Datacenter)
+	}
+	return ev
+}
+
+// CollectEvents returns the events of every endpoint created since the
+// previous call, up to until. The first call starts the window of each
+// endpoint, returning none of its events.
+func (c *Collector) CollectEvents(ctx context.Context, until time.Time) ([]Event, Errors) {
+	var events []Event
+	var errs Errors
+	for _, e := range c.Endpoints {
+		if e.eventsSince.IsZero() {
+			e.eventsSince = until
+			continue
+		}
+
+		client, err := e.Client(ctx)
+		if err != nil {
+			errs = append(errs, &CollectorError{Endpoint: e.URL.Host, Collector: "events", Err: err})
+			continue
+		}
+		evs, err := Events(ctx, client, e.eventsSince.Add(-EventOverlap), until)
+		if err != nil {
+			errs = append(errs, &CollectorError{Endpoint: e.URL.Host, Collector: "events", Err: err})
+			continue
+		}
+
+		// Windows overlap, events are numbered in order
+		for _, ev := range evs {
+			if ev.Key > e.lastEventKey {
+				events = append(events, ev)
+				e.lastEventKey = ev.Key
+			}
+		}
+		e.eventsSince = until
+	}
+	return events, errs
+}
@@ -0,0 +1,169 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ExplainKinds lists the kinds of entity Explain accepts.
+var ExplainKinds = []string{"datastore", "host", "vm"}
+
+// Explanation is everything collected of a single entity.
+type Explanation struct {
+	Entity EntityRef `json:"entity"`
+	Path   string    `json:"path"`
+	// Properties is the managed object as retrieved by its gatherer.
+	Properties interface{} `json:"properties"`
+	// Metric is the metric collected, before any renaming.
+	Metric Metric `json:"metric"`
+	// Dropped lists the fields removed by the filter of the collector.
+	Dropped []string `json:"dropped,omitempty"`
+	// Counters lists the performance counters available on the entity,
+	// none of which are collected.
+	Counters []string `json:"counters,omitempty"`
+}
+
+// Explain gathers the entity of kind with the given name or inventory path
+// on e as a collection would, returning everything that went into its
+// metric. A relative name is looked up in the default datacenter.
+func (c *Collector) Explain(ctx context.Context, e *Endpoint, kind, name string) (*Explanation, error) {
+	client, err := e.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f := find.NewFinder(client.Client, true)
+	if !strings.HasPrefix(name, "/") {
+		dc, err := f.DefaultDatacenter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		f.SetDatacenter(dc)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+	// Filtering is done below to report what it dropped
+	acc := &Accumulator{Time: time.Now(), Strict: c.Strict}
+
+	var ref types.ManagedObjectReference
+	var path string
+	var props []string
+	var dst interface{}
+
+	switch kind {
+	case "datastore":
+		ds, err := f.Datastore(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := GatherDataStoreMetrics(ctx, client, pc, []*object.Datastore{ds}, e.Tags, acc); err != nil {
+			return nil, err
+		}
+		ref, path, props, dst = ds.Reference(), ds.InventoryPath, dataStoreProperties, &mo.Datastore{}
+	case "host":
+		host, err := f.HostSystem(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := GatherHostMetrics(ctx, client, pc, []*object.HostSystem{host}, e.Tags, acc); err != nil {
+			return nil, err
+		}
+		ref, path, props, dst = host.Reference(), host.InventoryPath, supportedProperties(client, hostProperties), &mo.HostSystem{}
+	case "vm":
+		vm, err := f.VirtualMachine(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := GatherVMMetrics(ctx, client, pc, []*object.VirtualMachine{vm}, e.Tags, acc); err != nil {
+			return nil, err
+		}
+		ref, path, props, dst = vm.Reference(), vm.InventoryPath, vmProperties, &mo.VirtualMachine{}
+	default:
+		return nil, fmt.Errorf("unknown entity kind %q", kind)
+	}
+
+	if err := pc.RetrieveOne(ctx, ref, props, dst); err != nil {
+		return nil, err
+	}
+
+	// Secondary measurements, such as vm_perf, follow the metric of the
+	// entity
+	metrics := acc.Metrics()
+	if len(metrics) == 0 || metrics[0].Name != kind {
+		return nil, fmt.Errorf("%s %s gathered %d metrics", kind, name, len(metrics))
+	}
+
+	x := &Explanation{
+		Entity:     *NewEntityRef(client.URL().Host, ref),
+		Path:       path,
+		Properties: dst,
+		Metric:     metrics[0],
+	}
+
+	for k := range x.Metric.Fields {
+		if !c.Filter.Keep(kind, k) {
+			x.Dropped = append(x.Dropped, k)
+			delete(x.Metric.Fields, k)
+		}
+	}
+	sort.Strings(x.Dropped)
+
+	// Performance counters are informative only
+	x.Counters, err = availableCounters(ctx, client, ref)
+	if err != nil {
+		slog.Warn("listing performance counters failed", "endpoint", client.URL().Host, "entity", ref.Value, "err", err)
+	}
+
+	return x, nil
+}
+
+// availableCounters returns the sorted names of the performance counters
+// available on ref, at its real-time interval if it has one and the 5 minute
+// historical interval otherwise.
+func availableCounters(ctx context.Context, c *govmomi.Client, ref types.ManagedObjectReference) ([]string, error) {
+	m := performance.NewManager(c.Client)
+
+	s, err := m.ProviderSummary(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	interval := int32(300)
+	if s.CurrentSupported {
+		interval = s.RefreshRate
+	}
+
+	ids, err := m.AvailableMetric(ctx, ref, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := m.CounterInfoByKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, id := range ids {
+		info, ok := infos[id.CounterId]
+		if !ok || seen[info.Name()] {
+			continue
+		}
+		seen[info.Name()] = true
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
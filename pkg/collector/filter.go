@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FieldFilter selects the fields emitted for each measurement, to cut the
+// storage cost of fields nobody queries.
+type FieldFilter struct {
+	allow map[string][]string
+	deny  map[string][]string
+}
+
+// NewFieldFilter returns a FieldFilter of measurement.pattern allow and deny
+// lists, patterns being path.Match globs such as "vm.*cpu*". A measurement
+// with an allow list only keeps the fields it matches; fields a deny list
+// matches are always dropped.
+func NewFieldFilter(allow, deny []string) (*FieldFilter, error) {
+	f := &FieldFilter{
+		allow: make(map[string][]string),
+		deny:  make(map[string][]string),
+	}
+
+	parse := func(patterns []string, m map[string][]string) error {
+		for _, p := range patterns {
+			measurement, pattern, ok := strings.Cut(p, ".")
+			if !ok || pattern == "" {
+				return fmt.Errorf("invalid field pattern %q: expected measurement.pattern", p)
+			}
+			if _, ok := Fields[measurement]; !ok {
+				return fmt.Errorf("invalid field pattern %q: unknown measurement", p)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid field pattern %q: %s", p, err)
+			}
+			m[measurement] = append(m[measurement], pattern)
+		}
+		return nil
+	}
+
+	if err := parse(allow, f.allow); err != nil {
+		return nil, err
+	}
+	if err := parse(deny, f.deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Keep returns whether field of measurement is emitted. A nil FieldFilter
+// keeps every field.
+func (f *FieldFilter) Keep(measurement, field string) bool {
+	if f == nil {
+		return true
+	}
+
+	match := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, field); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if allow, ok := f.allow[measurement]; ok && !match(allow) {
+		return false
+	}
+	return !match(f.deny[measurement])
+}
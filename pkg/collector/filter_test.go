@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFieldFilter(t *testing.T) {
+	f, err := NewFieldFilter([]string{"vm.*cpu*", "vm.mem_*"}, []string{"vm.max_cpu_usage", "datastore.freespace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		measurement, field string
+		keep               bool
+	}{
+		{"vm", "num_cpu", true},
+		{"vm", "mem_mb", true},
+		{"vm", "max_cpu_usage", false},
+		{"vm", "storage_uncommitted", false},
+		{"datastore", "capacity", true},
+		{"datastore", "freespace", false},
+	} {
+		if keep := f.Keep(tt.measurement, tt.field); keep != tt.keep {
+			t.Errorf("%s.%s: keep=%t, expected %t", tt.measurement, tt.field, keep, tt.keep)
+		}
+	}
+
+	if _, err := NewFieldFilter([]string{"virtualmachine.num_cpu"}, nil); err == nil {
+		t.Error("expected unknown measurement error")
+	}
+}
+
+func TestAccumulatorFilter(t *testing.T) {
+	f, err := NewFieldFilter(nil, []string{"datastore.freespace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc := &Accumulator{Time: time.Now(), Filter: f}
+	records := map[string]interface{}{"capacity": int64(2), "freespace": int64(1), "used_percent": 50.0, "sioc_enabled": false, "sioc_congestion_threshold_ms": 0}
+	if err := acc.Add("datastore", nil, nil, records); err != nil {
+		t.Fatal(err)
+	}
+
+	m := acc.Metrics()[0]
+	if _, ok := m.Fields["freespace"]; ok {
+		t.Error("denied field emitted")
+	}
+	if _, ok := m.Fields["capacity"]; !ok {
+		t.Error("missing capacity")
+	}
+}
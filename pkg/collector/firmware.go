@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// hostFirmwareProperties are the properties retrieved of hosts for their
+// firmware and driver info metrics.
+var hostFirmwareProperties = []string{"hardware.biosInfo", "config.network.pnic", "config.storageDevice.hostBusAdapter"}
+
+// FirmwareInfo is an info metric of the firmware or a driver of a host: its
+// measurement, host_firmware, host_nic or host_hba, and the tags of its
+// versions, each metric having a value of 1.
+type FirmwareInfo struct {
+	Measurement string
+	Tags        map[string]string
+}
+
+// HostFirmwareInfo returns the info metrics of the BIOS, physical NICs and
+// host bus adapters of a connected host, for tracking their versions against
+// the hardware compatibility list. vSphere reports the driver and firmware
+// versions of NICs, but only the driver of host bus adapters.
+func HostFirmwareInfo(host mo.HostSystem) []FirmwareInfo {
+	var infos []FirmwareInfo
+
+	if host.Hardware != nil && host.Hardware.BiosInfo != nil {
+		bios := host.Hardware.BiosInfo
+		tags := map[string]string{
+			"bios_vendor":  bios.Vendor,
+			"bios_version": bios.BiosVersion,
+		}
+		if bios.ReleaseDate != nil {
+			tags["bios_release_date"] = bios.ReleaseDate.Format("2006-01-02")
+		}
+		if bios.FirmwareMajorRelease != 0 || bios.FirmwareMinorRelease != 0 {
+			tags["firmware_release"] = fmt.Sprintf("%d.%d", bios.FirmwareMajorRelease, bios.FirmwareMinorRelease)
+		}
+		infos = append(infos, FirmwareInfo{Measurement: "host_firmware", Tags: tags})
+	}
+
+	if host.Config == nil {
+		return infos
+	}
+	if host.Config.Network != nil {
+		for _, nic := range host.Config.Network.Pnic {
+			infos = append(infos, FirmwareInfo{Measurement: "host_nic", Tags: map[string]string{
+				"device":           nic.Device,
+				"pci":              nic.Pci,
+				"driver":           nic.Driver,
+				"driver_version":   nic.DriverVersion,
+				"firmware_version": nic.FirmwareVersion,
+			}})
+		}
+	}
+	if host.Config.StorageDevice != nil {
+		for _, b := range host.Config.StorageDevice.HostBusAdapter {
+			hba := b.GetHostHostBusAdapter()
+			infos = append(infos, FirmwareInfo{Measurement: "host_hba", Tags: map[string]string{
+				"device": hba.Device,
+				"pci":    hba.Pci,
+				"type":   hbaType(b),
+				"model":  hba.Model,
+				"driver": hba.Driver,
+			}})
+		}
+	}
+	return infos
+}
+
+// hbaType returns the type of a host bus adapter, such as fc or iscsi.
+func hbaType(b types.BaseHostHostBusAdapter) string {
+	switch b.(type) {
+	case *types.HostFibreChannelOverEthernetHba:
+		return "fcoe"
+	case *types.HostFibreChannelHba:
+		return "fc"
+	case *types.HostInternetScsiHba:
+		return "iscsi"
+	case *types.HostParallelScsiHba:
+		return "scsi"
+	case *types.HostBlockHba:
+		return "block"
+	case *types.HostSerialAttachedHba:
+		return "sas"
+	case *types.HostPcieHba:
+		return "pcie"
+	default:
+		return "other"
+	}
+}
+
+// hostHardwareProperties are the properties retrieved of hosts for their
+// host_hardware info metrics.
+var hostHardwareProperties = []string{"hardware.systemInfo", "customValue"}
+
+// HostHardwareTags returns the tags of the host_hardware info metric of a
+// host, for joining its metrics to asset management systems: its system
+// UUID, serial number, service tag and asset tag, as reported by its
+// firmware, and bmc, the address of its BMC, such as an iLO or iDRAC. vSphere
+// does not know BMC addresses, so bmc is recorded in a custom attribute.
+func HostHardwareTags(host mo.HostSystem, bmc string) map[string]string {
+	tags := map[string]string{"bmc_address": bmc}
+	if host.Hardware == nil {
+		return tags
+	}
+
+	info := host.Hardware.SystemInfo
+	tags["uuid"] = info.Uuid
+	tags["serial_number"] = info.SerialNumber
+	for _, id := range info.OtherIdentifyingInfo {
+		desc := id.IdentifierType.GetElementDescription()
+		switch desc.Key {
+		case "ServiceTag":
+			tags["service_tag"] = id.IdentifierValue
+		case "AssetTag":
+			tags["asset_tag"] = id.IdentifierValue
+		case "SerialNumberTag", "EnclosureSerialNumberTag":
+			// Before vSphere 6.7, the serial number is only one of these
+			if tags["serial_number"] == "" {
+				tags["serial_number"] = id.IdentifierValue
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"log/slog"
+	"path"
+	"sort"
+	"time"
+)
+
+// Forecaster keeps a rolling window of the utilization of datastores, and of
+// the CPU and memory of clusters summed over their hosts, across collection
+// cycles, forecasting when each fills up from its linear trend. Clusters are
+// the compute resources hosts are inventoried under, standalone hosts their
+// own.
+type Forecaster struct {
+	Window time.Duration
+	// Filter, if set, drops forecast fields.
+	Filter *FieldFilter
+
+	series map[forecastKey]*forecastSeries
+}
+
+type forecastKey struct {
+	vcenter, path, resource string
+}
+
+type forecastSeries struct {
+	kind, name string
+	entity     *EntityRef
+	times      []time.Time
+	values     []float64
+}
+
+// NewForecaster returns a Forecaster over window.
+func NewForecaster(window time.Duration) *Forecaster {
+	return &Forecaster{Window: window, series: make(map[forecastKey]*forecastSeries)}
+}
+
+// Forecast adds the utilization of the datastores and hosts of metrics, those
+// of a cycle, to the window, returning a forecast metric per datastore and
+// cluster resource. Series not updated for a whole window are forgotten.
+func (f *Forecaster) Forecast(metrics []Metric) []Metric {
+	type usage struct {
+		used, capacity float64
+		ts             time.Time
+		name           string
+	}
+	clusters := make(map[forecastKey]*usage)
+	add := func(key forecastKey, name string, used, capacity float64, ts time.Time) {
+		u, ok := clusters[key]
+		if !ok {
+			u = &usage{name: name, ts: ts}
+			clusters[key] = u
+		}
+		u.used += used
+		u.capacity += capacity
+	}
+
+	for _, m := range metrics {
+		switch m.Name {
+		case "datastore":
+			used, ok := m.Float("used_percent")
+			if !ok {
+				capacity, _ := m.Float("capacity")
+				free, ok := m.Float("freespace")
+				if !ok || capacity == 0 {
+					continue
+				}
+				used = 100 * (capacity - free) / capacity
+			}
+			key := forecastKey{vcenter: m.Tag("vcenter"), path: m.Tag("path"), resource: "storage"}
+			f.observe(key, "datastore", m.Tag("name"), m.Entity, m.Time, used)
+
+		case "host":
+			// Hosts without a cluster are not inventoried
+			host := m.Tag("path")
+			if host == "" {
+				continue
+			}
+			cluster := path.Dir(host)
+			vcenter := m.Tag("vcenter")
+
+			usage, ok1 := m.Float("overall_cpu_usage")
+			mhz, ok2 := m.Float("cpu_mhz")
+			cores, ok3 := m.Float("num_cpu_cores")
+			if ok1 && ok2 && ok3 {
+				add(forecastKey{vcenter: vcenter, path: cluster, resource: "cpu"}, path.Base(cluster), usage, mhz*cores, m.Time)
+			}
+			// Memory usage is in MB, the size in bytes
+			usage, ok1 = m.Float("overall_mem_usage")
+			size, ok2 := m.Float("mem_size")
+			if ok1 && ok2 {
+				add(forecastKey{vcenter: vcenter, path: cluster, resource: "mem"}, path.Base(cluster), usage*(1<<20), size, m.Time)
+			}
+		}
+	}
+	for key, u := range clusters {
+		if u.capacity > 0 {
+			f.observe(key, "cluster", u.name, nil, u.ts, 100*u.used/u.capacity)
+		}
+	}
+
+	keys := make([]forecastKey, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.vcenter != b.vcenter {
+			return a.vcenter < b.vcenter
+		}
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.resource < b.resource
+	})
+
+	var forecasts []Metric
+	for _, key := range keys {
+		s := f.series[key]
+		last := s.times[len(s.times)-1]
+		if time.Since(last) > f.Window {
+			delete(f.series, key)
+			continue
+		}
+
+		slope := s.slope()
+		days := -1.0
+		if used := s.values[len(s.values)-1]; slope > 0 {
+			days = (100 - used) / slope
+			if days < 0 {
+				days = 0
+			}
+		}
+
+		tags := map[string]string{
+			"kind":     s.kind,
+			"resource": key.resource,
+			"name":     s.name,
+			"vcenter":  key.vcenter,
+			"path":     key.path,
+		}
+		records := map[string]interface{}{
+			"used_percent":          s.values[len(s.values)-1],
+			"slope_percent_per_day": slope,
+			"days_until_full":       days,
+			"samples":               len(s.values),
+		}
+		m, err := NewMetric("forecast", tags, records, last)
+		if err != nil {
+			slog.Warn("forecast failed", "name", s.name, "err", err)
+			continue
+		}
+		for k := range m.Fields {
+			if !f.Filter.Keep("forecast", k) {
+				delete(m.Fields, k)
+			}
+		}
+		m.Entity = s.entity
+		forecasts = append(forecasts, m)
+	}
+	return forecasts
+}
+
+// observe adds the used percentage of a resource at ts to its series,
+// dropping the samples older than the window.
+func (f *Forecaster) observe(key forecastKey, kind, name string, entity *EntityRef, ts time.Time, used float64) {
+	s, ok := f.series[key]
+	if !ok {
+		s = &forecastSeries{kind: kind, name: name}
+		f.series[key] = s
+	}
+	s.name, s.entity = name, entity
+	if n := len(s.times); n != 0 && !ts.After(s.times[n-1]) {
+		return
+	}
+	s.times = append(s.times, ts)
+	s.values = append(s.values, used)
+
+	i := 0
+	for i < len(s.times)-1 && ts.Sub(s.times[i]) > f.Window {
+		i++
+	}
+	s.times, s.values = s.times[i:], s.values[i:]
+}
+
+// slope returns the least squares slope of the series in percent per day, 0
+// with fewer than two samples.
+func (s *forecastSeries) slope() float64 {
+	n := float64(len(s.times))
+	if n < 2 {
+		return 0
+	}
+
+	var sx, sy, sxx, sxy float64
+	for i, t := range s.times {
+		x := t.Sub(s.times[0]).Hours() / 24
+		y := s.values[i]
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / d
+}
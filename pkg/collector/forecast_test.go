@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestForecast(t *testing.T) {
+	f := NewForecaster(7 * 24 * time.Hour)
+	start := time.Now().Add(-2 * 24 * time.Hour)
+
+	var forecasts []Metric
+	for day, used := range []float64{50, 60, 70} {
+		ts := start.Add(time.Duration(day) * 24 * time.Hour)
+		metrics := []Metric{
+			{Name: "datastore", Tags: map[string]string{"name": "ds0", "vcenter": "vc", "path": "/DC0/datastore/ds0"}, Fields: map[string]interface{}{"used_percent": used}, Time: ts},
+			{Name: "host", Tags: map[string]string{"name": "h0", "vcenter": "vc", "path": "/DC0/host/C0/h0"}, Fields: map[string]interface{}{
+				"overall_cpu_usage": int64(1000), "cpu_mhz": int64(1000), "num_cpu_cores": int64(4),
+			}, Time: ts},
+		}
+		forecasts = f.Forecast(metrics)
+	}
+
+	if len(forecasts) != 2 {
+		t.Fatalf("%d forecasts, expected the datastore and the cluster CPU", len(forecasts))
+	}
+	for _, m := range forecasts {
+		days, _ := m.Float("days_until_full")
+		switch m.Tag("kind") {
+		case "datastore":
+			if slope, _ := m.Float("slope_percent_per_day"); math.Abs(slope-10) > 1e-9 || math.Abs(days-3) > 1e-9 {
+				t.Errorf("datastore slope=%g days=%g, expected 10 and 3", slope, days)
+			}
+		case "cluster":
+			if m.Tag("name") != "C0" || m.Tag("resource") != "cpu" || days != -1 {
+				t.Errorf("cluster forecast %v %v, expected a flat C0 CPU", m.Tags, m.Fields)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"log/slog"
+	"runtime"
+	"sort"
+
+	"github.com/vmware/govmomi/object"
+)
+
+// sampleVMs returns at most max of vms, 0 for all, along with how many were
+// left out. The subset is the same every cycle, of the lowest managed object
+// IDs, so that the series of the virtual machines kept don't come and go.
+func sampleVMs(vms []*object.VirtualMachine, max int) ([]*object.VirtualMachine, int) {
+	if max <= 0 || len(vms) <= max {
+		return vms, 0
+	}
+
+	sampled := append([]*object.VirtualMachine(nil), vms...)
+	sort.Slice(sampled, func(i, j int) bool {
+		a, b := sampled[i].Reference().Value, sampled[j].Reference().Value
+		if len(a) != len(b) {
+			// vm-9 before vm-10
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return sampled[:max], len(vms) - max
+}
+
+// heapBytes returns the bytes of the heap in use.
+func heapBytes() int64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.HeapInuse)
+}
+
+// selfMeasurements are the metrics of the collector itself, which LimitSeries
+// always keeps so that the series it drops are reported.
+var selfMeasurements = map[string]bool{
+	"collector":  true,
+	"cycle":      true,
+	"guardrail":  true,
+	"build_info": true,
+}
+
+// coreMeasurements are those of the entities of Gatherers, which LimitSeries
+// keeps before secondary ones.
+var coreMeasurements = map[string]bool{
+	"datastore": true,
+	"host":      true,
+	"vm":        true,
+	"cluster":   true,
+}
+
+// LimitSeries returns metrics with at most max series, fields of a metric,
+// 0 for no limit, along with the number of series dropped. The metrics of
+// the collector itself are always kept, and count first; those of secondary
+// measurements, such as vm_perf or vm_cost, are dropped first, core ones only
+// when they exceed what is left on their own; the metrics kept keep their
+// order.
+func LimitSeries(metrics []Metric, max int) ([]Metric, int) {
+	total, self, core := 0, 0, 0
+	for _, m := range metrics {
+		total += len(m.Fields)
+		switch {
+		case selfMeasurements[m.Name]:
+			self += len(m.Fields)
+		case coreMeasurements[m.Name]:
+			core += len(m.Fields)
+		}
+	}
+	if max <= 0 || total <= max {
+		return metrics, 0
+	}
+
+	// Core series past what the collector leaves are dropped in order, and
+	// secondary ones fit in what both leave
+	trim := self+core > max
+	coreRoom := max - self
+	room := max - self - core
+	kept := metrics[:0:0]
+	n := 0
+	for _, m := range metrics {
+		switch {
+		case selfMeasurements[m.Name]:
+		case coreMeasurements[m.Name]:
+			if trim {
+				if coreRoom < len(m.Fields) {
+					continue
+				}
+				coreRoom -= len(m.Fields)
+			}
+		default:
+			if room < len(m.Fields) {
+				continue
+			}
+			room -= len(m.Fields)
+		}
+		kept = append(kept, m)
+		n += len(m.Fields)
+	}
+
+	slog.Warn("series exceed the limit of a cycle, dropping some", "series", total, "max_series", max, "dropped", total-n)
+	return kept, total - n
+}
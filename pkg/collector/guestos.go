@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+)
+
+// windowsVersions are the versions of Windows guest IDs, whose numbers are
+// those of the kernel generations rather than of the releases.
+var windowsVersions = map[string]string{
+	"windows2022srvNext": "2025",
+	"windows2019srvNext": "2022",
+	"windows2019srv":     "2019",
+	"windows9Server":     "2016",
+	"windows8Server":     "2012",
+	"windows7Server":     "2008r2",
+	"winLonghorn":        "2008",
+	"winNetEnterprise":   "2003",
+	"winNetStandard":     "2003",
+	"winNetDatacenter":   "2003",
+	"winNetWeb":          "2003",
+	"windows11":          "11",
+	"windows9":           "10",
+	"windows8":           "8",
+	"windows7":           "7",
+	"winVista":           "vista",
+	"winXPPro":           "xp",
+}
+
+// guestFamilies are the OS families of guest ID prefixes, lower case.
+var guestFamilies = []struct{ prefix, family string }{
+	{"win", "windows"},
+	{"darwin", "macos"},
+	{"freebsd", "bsd"},
+	{"solaris", "solaris"},
+	{"netware", "other"},
+	{"os2", "other"},
+	{"dos", "other"},
+	{"vmkernel", "other"},
+}
+
+// windowsRelease matches the release of Windows full guest names, such as
+// "Microsoft Windows Server 2019 (64-bit)".
+var windowsRelease = regexp.MustCompile(`Windows (?:Server )?(\d+|XP|Vista)`)
+
+// guestSuffix matches the suffix of guest IDs, such as _64Guest.
+var guestSuffix = regexp.MustCompile(`_?(64)?Guest$`)
+
+// NormalizeGuestOS returns the OS family of a guest, windows, linux, bsd,
+// macos, solaris or other, and its normalized version, such as 2019 for
+// Windows Server 2019 or rhel8 for Linux, when known, from the guest ID and
+// full name of its configuration.
+func NormalizeGuestOS(guestID, fullName string) (family, version string) {
+	id := guestSuffix.ReplaceAllString(guestID, "")
+	lower := strings.ToLower(id)
+
+	family = "linux"
+	for _, f := range guestFamilies {
+		if strings.HasPrefix(lower, f.prefix) {
+			family = f.family
+			break
+		}
+	}
+	// Such as otherGuest or otherGuest64, of no known OS
+	if id == "" || lower == "other" || strings.HasPrefix(lower, "otherguest") {
+		return guestFamilyOf(fullName), ""
+	}
+
+	switch family {
+	case "windows":
+		if v, ok := windowsVersions[id]; ok {
+			return family, v
+		}
+		if m := windowsRelease.FindStringSubmatch(fullName); m != nil {
+			return family, strings.ToLower(m[1])
+		}
+		return family, ""
+	case "linux":
+		// Such as otherLinux or other4xLinux, of no distribution
+		if strings.HasPrefix(lower, "other") {
+			return family, ""
+		}
+		return family, lower
+	case "other":
+		return family, ""
+	default:
+		return family, lower
+	}
+}
+
+// guestFamilyOf returns the OS family of a full guest name.
+func guestFamilyOf(fullName string) string {
+	name := strings.ToLower(fullName)
+	switch {
+	case strings.Contains(name, "windows"):
+		return "windows"
+	case strings.Contains(name, "linux"):
+		return "linux"
+	case strings.Contains(name, "bsd"):
+		return "bsd"
+	case strings.Contains(name, "mac os"), strings.Contains(name, "macos"):
+		return "macos"
+	case strings.Contains(name, "solaris"):
+		return "solaris"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,263 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// hostProperties are the properties retrieved of hosts.
+var hostProperties = concat([]string{"name", "summary", "runtime", "config.powerSystemInfo"}, hostSecurityProperties, hostFirmwareProperties, hostMemoryProperties, hostNUMAProperties)
+
+// GatherHostMetrics adds the metrics of hosts to acc.
+func GatherHostMetrics(ctx context.Context, c *govmomi.Client, pc *property.Collector, hosts []*object.HostSystem, tc *TagCache, acc *Accumulator) error {
+	return gatherHostMetrics(ctx, c, pc, hosts, tc, false, "", 0, acc)
+}
+
+// gatherHostMetrics adds the metrics of hosts to acc, along with their
+// host_hardware info metrics when hardware is set, of the BMC addresses of
+// custom attribute bmcAttribute, if any. Failing to list custom attributes
+// is logged, leaving BMC addresses out, rather than failing the cycle.
+// Statistics are queried in batches beneath maxMetrics, 0 for no limit.
+func gatherHostMetrics(ctx context.Context, c *govmomi.Client, pc *property.Collector, hosts []*object.HostSystem, tc *TagCache, hardware bool, bmcAttribute string, maxMetrics int, acc *Accumulator) error {
+	// Convert hosts into list of references
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(hosts))
+	for _, host := range hosts {
+		refs = append(refs, host.Reference())
+		paths[host.Reference()] = host.InventoryPath
+	}
+
+	// Retrieve summary and runtime properties for all hosts
+	props := supportedProperties(c, hostProperties)
+	if hardware {
+		props = concat(props, hostHardwareProperties)
+	}
+	var hst []mo.HostSystem
+	err := pc.Retrieve(ctx, refs, props, &hst)
+	if err != nil {
+		return err
+	}
+	slog.Debug("retrieved hosts", "endpoint", c.URL().Host, "count", len(hst))
+
+	var fields map[int32]string
+	if hardware && bmcAttribute != "" {
+		if fields, err = customFieldNames(ctx, c); err != nil {
+			slog.Warn("retrieving custom attributes failed", "endpoint", c.URL().Host, "err", err)
+		}
+	}
+
+	connected := make(map[types.ManagedObjectReference]map[string]string)
+	pmem := make(map[types.ManagedObjectReference]int64)
+	scratch := make(map[string]string)
+	for _, host := range hst {
+		resetTags(scratch)
+		records := HostRecords(host, scratch)
+		scratch["vcenter"] = c.URL().Host
+		// Names are not unique across folders and datacenters
+		scratch["moid"] = host.Reference().Value
+		scratch["path"] = paths[host.Reference()]
+
+		tags := tc.Intern(host.Reference(), scratch)
+		if err := acc.Add("host", NewEntityRef(c.URL().Host, host.Reference()), tags, records); err != nil {
+			return err
+		}
+		if host.Runtime.ConnectionState == types.HostSystemConnectionStateConnected {
+			if err := acc.add("host_security", NewEntityRef(c.URL().Host, host.Reference()), tags, HostSecurityRecords(host), false); err != nil {
+				return err
+			}
+			for _, info := range HostFirmwareInfo(host) {
+				info.Tags["name"] = host.Name
+				for _, k := range entityTags {
+					info.Tags[k] = tags[k]
+				}
+				if err := acc.add(info.Measurement, NewEntityRef(c.URL().Host, host.Reference()), info.Tags, map[string]interface{}{"value": 1}, false); err != nil {
+					return err
+				}
+			}
+			tiering, tiers := HostMemoryTiers(host)
+			for _, t := range tiers {
+				tierTags := map[string]string{"name": host.Name, "tier": t.Name, "tier_type": t.Type, "tiering": tiering}
+				for _, k := range entityTags {
+					tierTags[k] = tags[k]
+				}
+				if err := acc.add("host_memory_tier", NewEntityRef(c.URL().Host, host.Reference()), tierTags, map[string]interface{}{"size_bytes": t.SizeBytes}, false); err != nil {
+					return err
+				}
+			}
+			if err := acc.add("host_numa", NewEntityRef(c.URL().Host, host.Reference()), tags, HostNUMARecords(host), false); err != nil {
+				return err
+			}
+			for _, n := range HostNUMANodes(host) {
+				nodeTags := map[string]string{"name": host.Name, "node": strconv.Itoa(n.ID)}
+				for _, k := range entityTags {
+					nodeTags[k] = tags[k]
+				}
+				records := map[string]interface{}{"cpus": n.CPUs, "memory_bytes": n.MemoryBytes}
+				if err := acc.add("host_numa_node", NewEntityRef(c.URL().Host, host.Reference()), nodeTags, records, false); err != nil {
+					return err
+				}
+			}
+			if hardware {
+				var bmc string
+				for _, v := range host.CustomValue {
+					if sv, ok := v.(*types.CustomFieldStringValue); ok && fields[sv.Key] == bmcAttribute {
+						bmc = sv.Value
+					}
+				}
+				hwTags := HostHardwareTags(host, bmc)
+				hwTags["name"] = host.Name
+				for _, k := range entityTags {
+					hwTags[k] = tags[k]
+				}
+				if err := acc.add("host_hardware", NewEntityRef(c.URL().Host, host.Reference()), hwTags, map[string]interface{}{"value": 1}, false); err != nil {
+					return err
+				}
+			}
+			if b := hostPMemBytes(host); b > 0 {
+				pmem[host.Reference()] = b
+			}
+			connected[host.Reference()] = tags
+		}
+	}
+
+	return gatherHostPerf(ctx, c, connected, pmem, maxMetrics, acc)
+}
+
+// hostPerfCounters are the real-time performance counters of the host_power
+// and host_sched metrics, followed by that of the host_pmem metrics.
+var hostPerfCounters = []string{"power.power.average", "power.powerCap.average", "cpu.ready.summation", "cpu.readiness.average", "cpu.latency.average", "pmem.available.reservation.latest"}
+
+// gatherHostPerf adds the host_power and host_sched metrics of the connected
+// hosts of connected, and the host_pmem metrics of those with the persistent
+// memory capacity of pmem, with the tags of their host metric. Hosts without
+// power or scheduling statistics, such as those whose BMC does not report
+// them, are left out of those measurements; failing to query them is logged
+// rather than failing the cycle.
+func gatherHostPerf(ctx context.Context, c *govmomi.Client, connected map[types.ManagedObjectReference]map[string]string, pmem map[types.ManagedObjectReference]int64, maxMetrics int, acc *Accumulator) error {
+	refs := make([]types.ManagedObjectReference, 0, len(connected))
+	for ref := range connected {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Value < refs[j].Value })
+
+	counters := hostPerfCounters[:len(hostPerfCounters)-1]
+	if len(pmem) != 0 {
+		counters = hostPerfCounters
+	}
+	samples, err := latestSamples(ctx, c, refs, counters, maxMetrics)
+	if err != nil {
+		slog.Warn("querying host statistics failed", "endpoint", c.URL().Host, "err", err)
+		return nil
+	}
+
+	for _, ref := range refs {
+		if power, ok := samples[ref]["power.power.average"]; ok {
+			// A cap of 0 is an uncapped host
+			capWatts := samples[ref]["power.powerCap.average"]
+			records := map[string]interface{}{
+				"power_watts":     power,
+				"power_cap_watts": capWatts,
+				"capped":          capWatts > 0,
+			}
+			if err := acc.add("host_power", NewEntityRef(c.URL().Host, ref), connected[ref], records, false); err != nil {
+				return err
+			}
+		}
+
+		ready, rok := samples[ref]["cpu.ready.summation"]
+		readiness, dok := samples[ref]["cpu.readiness.average"]
+		latency, lok := samples[ref]["cpu.latency.average"]
+		if rok && dok && lok {
+			// Percentages are in hundredths, the ready time of the
+			// virtual machines of the host over the 20 second interval
+			records := map[string]interface{}{
+				"cpu_ready_ms":          ready,
+				"cpu_readiness_percent": float64(readiness) / 100,
+				"cpu_latency_percent":   float64(latency) / 100,
+			}
+			if err := acc.add("host_sched", NewEntityRef(c.URL().Host, ref), connected[ref], records, false); err != nil {
+				return err
+			}
+		}
+
+		capacity, ok := pmem[ref]
+		if !ok {
+			continue
+		}
+		if available, ok := samples[ref]["pmem.available.reservation.latest"]; ok {
+			records := map[string]interface{}{
+				"capacity_bytes":  capacity,
+				"available_bytes": available << 20,
+			}
+			if err := acc.add("host_pmem", NewEntityRef(c.URL().Host, ref), connected[ref], records, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// concat returns the concatenation of lists.
+func concat(lists ...[]string) []string {
+	var all []string
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// HostRecords fills tags and returns the records of a single host. A host that
+// is disconnected or not responding only reports its availability, as 0. A
+// connected host lacking its hardware summary, as while it reconnects, still
+// reports the rest and the "degraded" tag is set.
+func HostRecords(host mo.HostSystem, tags map[string]string) map[string]interface{} {
+	records := make(map[string]interface{}, len(Fields["host"]))
+
+	tags["name"] = host.Name
+	tags["connection_state"] = string(host.Runtime.ConnectionState)
+	tags["power_state"] = string(host.Runtime.PowerState)
+	tags["overall_status"] = string(host.Summary.OverallStatus)
+
+	if host.Runtime.ConnectionState != types.HostSystemConnectionStateConnected {
+		records["available"] = 0
+		return records
+	}
+	records["available"] = 1
+	records["in_maintenance_mode"] = host.Runtime.InMaintenanceMode
+
+	if hw := host.Summary.Hardware; hw != nil {
+		tags["vendor"] = hw.Vendor
+		tags["model"] = hw.Model
+		tags["cpu_model"] = hw.CpuModel
+
+		records["cpu_mhz"] = hw.CpuMhz
+		records["num_cpu_cores"] = hw.NumCpuCores
+		records["num_cpu_threads"] = hw.NumCpuThreads
+		records["mem_size"] = hw.MemorySize
+	} else {
+		tags["degraded"] = "true"
+	}
+
+	if host.Config != nil && host.Config.PowerSystemInfo != nil && host.Config.PowerSystemInfo.CurrentPolicy.ShortName != "" {
+		tags["power_policy"] = host.Config.PowerSystemInfo.CurrentPolicy.ShortName
+	}
+
+	if p := host.Summary.Config.Product; p != nil {
+		tags["version"] = p.Version
+		tags["build"] = p.Build
+	}
+
+	records["overall_cpu_usage"] = host.Summary.QuickStats.OverallCpuUsage
+	records["overall_mem_usage"] = host.Summary.QuickStats.OverallMemoryUsage
+	records["uptime_sec"] = host.Summary.QuickStats.Uptime
+
+	return records
+}
@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// IdleDetector keeps a rolling window of the CPU, network and disk activity of
+// virtual machines across collection cycles, scoring how idle each is: the
+// percentage of its samples and resources below the idle levels. Network and
+// disk activity are those of vm_perf metrics, none for powered off virtual
+// machines, so a VM left powered off scores 100.
+type IdleDetector struct {
+	Window time.Duration
+	// CPUPercent, NetKBps and DiskKBps are the levels of activity below
+	// which a resource of a sample is idle.
+	CPUPercent, NetKBps, DiskKBps float64
+	// Filter, if set, drops vm_idle fields.
+	Filter *FieldFilter
+
+	vms map[EntityRef]*idleSeries
+}
+
+type idleSample struct {
+	ts             time.Time
+	cpu, net, disk float64
+}
+
+type idleSeries struct {
+	tags    map[string]string
+	samples []idleSample
+}
+
+// NewIdleDetector returns an IdleDetector over window, with idle levels of 5%
+// of CPU and 10 KBps of network and disk.
+func NewIdleDetector(window time.Duration) *IdleDetector {
+	return &IdleDetector{
+		Window:     window,
+		CPUPercent: 5,
+		NetKBps:    10,
+		DiskKBps:   10,
+		vms:        make(map[EntityRef]*idleSeries),
+	}
+}
+
+// Score adds the activity of the virtual machines of metrics, those of a
+// cycle, to the window, returning a vm_idle metric per virtual machine,
+// sorted by vcenter and path. Virtual machines not seen for a whole window,
+// as of the latest cycle, are forgotten; disconnected ones are skipped.
+func (d *IdleDetector) Score(metrics []Metric) []Metric {
+	perf := make(map[EntityRef]Metric)
+	for _, m := range metrics {
+		if m.Name == "vm_perf" && m.Entity != nil {
+			perf[*m.Entity] = m
+		}
+	}
+
+	var now time.Time
+	for _, m := range metrics {
+		if m.Name != "vm" || m.Entity == nil {
+			continue
+		}
+		if available, ok := m.Int("available"); ok && available == 0 {
+			continue
+		}
+
+		s := idleSample{ts: m.Time}
+		usage, _ := m.Float("overall_cpu_usage")
+		if max, ok := m.Float("max_cpu_usage"); ok && max > 0 {
+			s.cpu = 100 * usage / max
+		}
+		if p, ok := perf[*m.Entity]; ok {
+			s.net, _ = p.Float("net_usage_kbps")
+			s.disk, _ = p.Float("disk_usage_kbps")
+		}
+		d.observe(*m.Entity, m.Tags, s)
+		if m.Time.After(now) {
+			now = m.Time
+		}
+	}
+
+	refs := make([]EntityRef, 0, len(d.vms))
+	for ref, vs := range d.vms {
+		if last := vs.samples[len(vs.samples)-1].ts; now.Sub(last) > d.Window {
+			delete(d.vms, ref)
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := d.vms[refs[i]], d.vms[refs[j]]
+		if a.tags["vcenter"] != b.tags["vcenter"] {
+			return a.tags["vcenter"] < b.tags["vcenter"]
+		}
+		if a.tags["path"] != b.tags["path"] {
+			return a.tags["path"] < b.tags["path"]
+		}
+		return refs[i].MOID < refs[j].MOID
+	})
+
+	scores := make([]Metric, 0, len(refs))
+	for _, ref := range refs {
+		vs := d.vms[ref]
+		var idle, cpu, net, disk float64
+		for _, s := range vs.samples {
+			if s.cpu < d.CPUPercent {
+				idle++
+			}
+			if s.net < d.NetKBps {
+				idle++
+			}
+			if s.disk < d.DiskKBps {
+				idle++
+			}
+			cpu += s.cpu
+			net += s.net
+			disk += s.disk
+		}
+		n := float64(len(vs.samples))
+
+		records := map[string]interface{}{
+			"idle_score":        100 * idle / (3 * n),
+			"cpu_usage_percent": cpu / n,
+			"net_usage_kbps":    net / n,
+			"disk_usage_kbps":   disk / n,
+			"samples":           len(vs.samples),
+		}
+		m, err := NewMetric("vm_idle", vs.tags, records, vs.samples[len(vs.samples)-1].ts)
+		if err != nil {
+			slog.Warn("idle score failed", "name", vs.tags["name"], "err", err)
+			continue
+		}
+		for k := range m.Fields {
+			if !d.Filter.Keep("vm_idle", k) {
+				delete(m.Fields, k)
+			}
+		}
+		entity := ref
+		m.Entity = &entity
+		scores = append(scores, m)
+	}
+	return scores
+}
+
+// observe adds s to the series of ref, dropping the samples older than the
+// window.
+func (d *IdleDetector) observe(ref EntityRef, tags map[string]string, s idleSample) {
+	vs, ok := d.vms[ref]
+	if !ok {
+		vs = &idleSeries{}
+		d.vms[ref] = vs
+	}
+	vs.tags = map[string]string{
+		"name":    tags["name"],
+		"vcenter": tags["vcenter"],
+		"moid":    tags["moid"],
+		"path":    tags["path"],
+	}
+	if n := len(vs.samples); n != 0 && !s.ts.After(vs.samples[n-1].ts) {
+		return
+	}
+	vs.samples = append(vs.samples, s)
+
+	i := 0
+	for i < len(vs.samples)-1 && s.ts.Sub(vs.samples[i].ts) > d.Window {
+		i++
+	}
+	vs.samples = vs.samples[i:]
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleScore(t *testing.T) {
+	d := NewIdleDetector(24 * time.Hour)
+	start := time.Now().Add(-time.Hour)
+
+	vm := func(moid string, usage int64, ts time.Time) Metric {
+		return Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": moid, "vcenter": "vc", "moid": moid, "path": "/DC0/vm/" + moid},
+			Fields: map[string]interface{}{"overall_cpu_usage": usage, "max_cpu_usage": int64(2000), "available": int64(1)},
+			Time:   ts,
+			Entity: &EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: moid},
+		}
+	}
+
+	var scores []Metric
+	for i := 0; i < 3; i++ {
+		ts := start.Add(time.Duration(i) * 20 * time.Minute)
+		busy := vm("vm-1", 1000, ts)
+		perf := Metric{
+			Name:   "vm_perf",
+			Tags:   busy.Tags,
+			Fields: map[string]interface{}{"net_usage_kbps": int64(500), "disk_usage_kbps": int64(1)},
+			Time:   ts,
+			Entity: busy.Entity,
+		}
+		scores = d.Score([]Metric{busy, perf, vm("vm-2", 10, ts)})
+	}
+
+	if len(scores) != 2 {
+		t.Fatalf("%d scores, expected 2", len(scores))
+	}
+	expect := map[string]float64{"vm-1": 100.0 / 3, "vm-2": 100}
+	for _, m := range scores {
+		score, _ := m.Float("idle_score")
+		if score != expect[m.Tag("moid")] {
+			t.Errorf("%s idle_score=%g, expected %g", m.Tag("moid"), score, expect[m.Tag("moid")])
+		}
+		if m.Fields["samples"] != int64(3) {
+			t.Errorf("%s samples=%v, expected 3", m.Tag("moid"), m.Fields["samples"])
+		}
+	}
+}
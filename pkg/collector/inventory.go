@@ -0,0 +1,327 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// InventoryEntry is an entity discovered on an endpoint with its key summary
+// fields.
+type InventoryEntry struct {
+	Endpoint   string                 `json:"endpoint"`
+	Datacenter string                 `json:"datacenter"`
+	Kind       string                 `json:"kind"`
+	Name       string                 `json:"name"`
+	Path       string                 `json:"path"`
+	MOID       string                 `json:"moid"`
+	Summary    map[string]interface{} `json:"summary,omitempty"`
+	// Attributes are the custom attributes of virtual machines, by name.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Tags are the vSphere tags of virtual machines, as category:tag, set by
+	// InventoryTags.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Inventory lists the datacenters of the endpoint of c, followed by the
+// clusters, hosts, datastores and virtual machines of each.
+func Inventory(ctx context.Context, c *govmomi.Client) ([]InventoryEntry, error) {
+	f := find.NewFinder(c.Client, true)
+	pc := property.DefaultCollector(c.Client)
+
+	dcs, err := f.DatacenterList(ctx, "*")
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := customFieldNames(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []InventoryEntry
+	for _, dc := range dcs {
+		entry := func(kind string, ref types.ManagedObjectReference, name, path string, summary map[string]interface{}) *InventoryEntry {
+			entries = append(entries, InventoryEntry{
+				Endpoint:   c.URL().Host,
+				Datacenter: dc.Name(),
+				Kind:       kind,
+				Name:       name,
+				Path:       path,
+				MOID:       ref.Value,
+				Summary:    summary,
+			})
+			return &entries[len(entries)-1]
+		}
+
+		entry("datacenter", dc.Reference(), dc.Name(), dc.InventoryPath, nil)
+		f.SetDatacenter(dc)
+
+		if err := inventoryClusters(ctx, f, pc, entry); err != nil {
+			return nil, err
+		}
+		if err := inventoryHosts(ctx, f, pc, entry); err != nil {
+			return nil, err
+		}
+		if err := inventoryDataStores(ctx, f, pc, entry); err != nil {
+			return nil, err
+		}
+		if err := inventoryVMs(ctx, f, pc, fields, entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// inventoryFunc adds an entry, returning it until the next is added.
+type inventoryFunc func(kind string, ref types.ManagedObjectReference, name, path string, summary map[string]interface{}) *InventoryEntry
+
+// customFieldNames returns the names of the custom fields of the endpoint of
+// c by key, none on ESX.
+func customFieldNames(ctx context.Context, c *govmomi.Client) (map[int32]string, error) {
+	if c.ServiceContent.CustomFieldsManager == nil {
+		return nil, nil
+	}
+
+	m, err := object.GetCustomFieldsManager(c.Client)
+	if err != nil {
+		return nil, err
+	}
+	defs, err := m.Field(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int32]string, len(defs))
+	for _, def := range defs {
+		names[def.Key] = def.Name
+	}
+	return names, nil
+}
+
+func inventoryClusters(ctx context.Context, f *find.Finder, pc *property.Collector, entry inventoryFunc) error {
+	clusters, err := f.ClusterComputeResourceList(ctx, "*")
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(clusters))
+	for _, cluster := range clusters {
+		refs = append(refs, cluster.Reference())
+		paths[cluster.Reference()] = cluster.InventoryPath
+	}
+
+	var ccr []mo.ClusterComputeResource
+	if err := pc.Retrieve(ctx, refs, []string{"name", "summary", "configuration"}, &ccr); err != nil {
+		return err
+	}
+
+	for _, cluster := range ccr {
+		summary := make(map[string]interface{})
+		if cluster.Summary != nil {
+			s := cluster.Summary.GetComputeResourceSummary()
+			summary["num_hosts"] = s.NumHosts
+			summary["num_effective_hosts"] = s.NumEffectiveHosts
+			summary["total_cpu_mhz"] = s.TotalCpu
+			summary["total_mem"] = s.TotalMemory
+		}
+		if drs := cluster.Configuration.DrsConfig.Enabled; drs != nil {
+			summary["drs_enabled"] = *drs
+		}
+		if ha := cluster.Configuration.DasConfig.Enabled; ha != nil {
+			summary["ha_enabled"] = *ha
+		}
+		entry("cluster", cluster.Reference(), cluster.Name, paths[cluster.Reference()], summary)
+	}
+	return nil
+}
+
+func inventoryHosts(ctx context.Context, f *find.Finder, pc *property.Collector, entry inventoryFunc) error {
+	hosts, err := f.HostSystemList(ctx, "*")
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(hosts))
+	for _, host := range hosts {
+		refs = append(refs, host.Reference())
+		paths[host.Reference()] = host.InventoryPath
+	}
+
+	var hst []mo.HostSystem
+	if err := pc.Retrieve(ctx, refs, []string{"name", "summary"}, &hst); err != nil {
+		return err
+	}
+
+	for _, host := range hst {
+		summary := make(map[string]interface{})
+		if rt := host.Summary.Runtime; rt != nil {
+			summary["connection_state"] = string(rt.ConnectionState)
+			summary["power_state"] = string(rt.PowerState)
+		}
+		if p := host.Summary.Config.Product; p != nil {
+			summary["version"] = p.Version
+		}
+		if hw := host.Summary.Hardware; hw != nil {
+			summary["model"] = hw.Model
+			summary["num_cpu_cores"] = hw.NumCpuCores
+			summary["mem_size"] = hw.MemorySize
+		}
+		entry("host", host.Reference(), host.Name, paths[host.Reference()], summary)
+	}
+	return nil
+}
+
+func inventoryDataStores(ctx context.Context, f *find.Finder, pc *property.Collector, entry inventoryFunc) error {
+	dss, err := f.DatastoreList(ctx, "*")
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(dss))
+	for _, ds := range dss {
+		refs = append(refs, ds.Reference())
+		paths[ds.Reference()] = ds.InventoryPath
+	}
+
+	var dst []mo.Datastore
+	if err := pc.Retrieve(ctx, refs, []string{"name", "summary"}, &dst); err != nil {
+		return err
+	}
+
+	for _, ds := range dst {
+		summary := map[string]interface{}{
+			"type":       ds.Summary.Type,
+			"accessible": ds.Summary.Accessible,
+			"capacity":   ds.Summary.Capacity,
+			"freespace":  ds.Summary.FreeSpace,
+		}
+		entry("datastore", ds.Reference(), ds.Name, paths[ds.Reference()], summary)
+	}
+	return nil
+}
+
+func inventoryVMs(ctx context.Context, f *find.Finder, pc *property.Collector, fields map[int32]string, entry inventoryFunc) error {
+	vms, err := f.VirtualMachineList(ctx, "*")
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(vms))
+	for _, vm := range vms {
+		refs = append(refs, vm.Reference())
+		paths[vm.Reference()] = vm.InventoryPath
+	}
+
+	var vmt []mo.VirtualMachine
+	if err := pc.Retrieve(ctx, refs, []string{"name", "summary", "customValue"}, &vmt); err != nil {
+		return err
+	}
+
+	for _, vm := range vmt {
+		summary := map[string]interface{}{
+			"power_state":     string(vm.Summary.Runtime.PowerState),
+			"guest_full_name": vm.Summary.Config.GuestFullName,
+			"num_cpu":         vm.Summary.Config.NumCpu,
+			"mem_mb":          vm.Summary.Config.MemorySizeMB,
+		}
+		if vm.Summary.Runtime.Host != nil {
+			summary["host"] = vm.Summary.Runtime.Host.Value
+		}
+		if vm.Summary.Guest != nil && vm.Summary.Guest.IpAddress != "" {
+			summary["ip_address"] = vm.Summary.Guest.IpAddress
+		}
+		e := entry("vm", vm.Reference(), vm.Name, paths[vm.Reference()], summary)
+
+		for _, v := range vm.CustomValue {
+			if sv, ok := v.(*types.CustomFieldStringValue); ok && fields[sv.Key] != "" {
+				if e.Attributes == nil {
+					e.Attributes = make(map[string]string)
+				}
+				e.Attributes[fields[sv.Key]] = sv.Value
+			}
+		}
+	}
+	return nil
+}
+
+// InventoryTags sets the vSphere tags of the virtual machines of entries on
+// the endpoint of e, logging in to its vAPI endpoint with the credentials of
+// its URL.
+func InventoryTags(ctx context.Context, e *Endpoint, entries []InventoryEntry) error {
+	if e.URL.User == nil {
+		return fmt.Errorf("listing the tags of %s requires credentials in its URL", e.URL.Host)
+	}
+	c, err := e.Client(ctx)
+	if err != nil {
+		return err
+	}
+	if !e.Capabilities().Tagging {
+		return fmt.Errorf("listing the tags of %s requires the vAPI tagging service of vCenter 6.5 or later, which doesn't answer", e.URL.Host)
+	}
+
+	var refs []mo.Reference
+	index := make(map[string]int)
+	for i, entry := range entries {
+		if entry.Endpoint == c.URL().Host && entry.Kind == "vm" {
+			refs = append(refs, types.ManagedObjectReference{Type: "VirtualMachine", Value: entry.MOID})
+			index[entry.MOID] = i
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	rc := rest.NewClient(c.Client)
+	if err := rc.Login(ctx, e.URL.User); err != nil {
+		return fmt.Errorf("vapi login: %w", err)
+	}
+	defer rc.Logout(ctx)
+
+	m := tags.NewManager(rc)
+	categories, err := m.GetCategories(ctx)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string, len(categories))
+	for _, cat := range categories {
+		names[cat.ID] = cat.Name
+	}
+
+	attached, err := m.GetAttachedTagsOnObjects(ctx, refs)
+	if err != nil {
+		return err
+	}
+	for _, a := range attached {
+		i, ok := index[a.ObjectID.Reference().Value]
+		if !ok {
+			continue
+		}
+		for _, t := range a.Tags {
+			entries[i].Tags = append(entries[i].Tags, names[t.CategoryID]+":"+t.Name)
+		}
+		sort.Strings(entries[i].Tags)
+	}
+	return nil
+}
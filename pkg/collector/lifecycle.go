@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// creationEvents are the types of the events of virtual machines added to the
+// inventory, whose user created them.
+var creationEvents = []string{"VmCreatedEvent", "VmClonedEvent", "VmDeployedEvent", "VmRegisteredEvent"}
+
+// Creators returns the users who created the virtual machines of the
+// endpoint of c, by entity, from the creation events vCenter still retains.
+// Those created before, or by vCenter itself, are left out.
+func Creators(ctx context.Context, c *govmomi.Client) (map[EntityRef]string, error) {
+	m := event.NewManager(c.Client)
+	hc, err := m.CreateCollectorForEvents(ctx, types.EventFilterSpec{Type: creationEvents})
+	if err != nil {
+		return nil, err
+	}
+	defer hc.Destroy(ctx)
+
+	if err := hc.Rewind(ctx); err != nil {
+		return nil, err
+	}
+
+	creators := make(map[EntityRef]string)
+	for {
+		page, err := hc.ReadNextEvents(ctx, eventPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return creators, nil
+		}
+		// Oldest first, the latest creation of a re-registered VM wins
+		for _, be := range page {
+			e := be.GetEvent()
+			if e.Vm != nil && e.UserName != "" {
+				creators[*NewEntityRef(c.URL().Host, e.Vm.Vm)] = e.UserName
+			}
+		}
+	}
+}
+
+// CreatorTracker tags the vm metrics of collection cycles with the user who
+// created their virtual machine, as created_by, from the creation events
+// vCenter retains and then from the events of each cycle.
+type CreatorTracker struct {
+	creators map[EntityRef]string
+	// loaded are the endpoints whose retained events were read.
+	loaded map[string]bool
+}
+
+// NewCreatorTracker returns a CreatorTracker.
+func NewCreatorTracker() *CreatorTracker {
+	return &CreatorTracker{creators: make(map[EntityRef]string), loaded: make(map[string]bool)}
+}
+
+// Load reads the retained creation events of the endpoints of col not read
+// yet, returning the errors of those that failed, to be read again on the
+// next call.
+func (t *CreatorTracker) Load(ctx context.Context, col *Collector) Errors {
+	var errs Errors
+	for _, e := range col.Endpoints {
+		if t.loaded[e.URL.Host] {
+			continue
+		}
+		client, err := e.Client(ctx)
+		if err == nil {
+			var creators map[EntityRef]string
+			if creators, err = Creators(ctx, client); err == nil {
+				for ref, user := range creators {
+					t.creators[ref] = user
+				}
+				t.loaded[e.URL.Host] = true
+				continue
+			}
+		}
+		errs = append(errs, &CollectorError{Endpoint: e.URL.Host, Collector: "creators", Err: err})
+	}
+	return errs
+}
+
+// Track applies the creation events of events, those since the previous
+// cycle, and sets the created_by tag of the vm metrics of metrics. Tags maps
+// are copied before being set, as they may be shared.
+func (t *CreatorTracker) Track(events []Event, metrics []Metric) {
+	for _, ev := range events {
+		if ev.Entity != nil && ev.User != "" && slices.Contains(creationEvents, ev.Type) {
+			t.creators[*ev.Entity] = ev.User
+		}
+	}
+
+	for i := range metrics {
+		m := &metrics[i]
+		if m.Name != "vm" || m.Entity == nil {
+			continue
+		}
+		user, ok := t.creators[*m.Entity]
+		if !ok {
+			continue
+		}
+		tags := make(map[string]string, len(m.Tags)+1)
+		for k, v := range m.Tags {
+			tags[k] = v
+		}
+		tags["created_by"] = user
+		m.Tags = tags
+	}
+}
@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCreatorTrack(t *testing.T) {
+	tr := NewCreatorTracker()
+	ref := EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-1"}
+	tags := map[string]string{"name": "vm-1"}
+	metrics := []Metric{
+		{Name: "vm", Tags: tags, Entity: &ref, Time: time.Now()},
+		{Name: "vm_perf", Tags: tags, Entity: &ref, Time: time.Now()},
+	}
+
+	tr.Track([]Event{{Type: "VmClonedEvent", User: "VSPHERE.LOCAL\\alice", Entity: &ref}}, metrics)
+	if metrics[0].Tags["created_by"] != "VSPHERE.LOCAL\\alice" {
+		t.Errorf("created_by=%q", metrics[0].Tags["created_by"])
+	}
+	if _, ok := tags["created_by"]; ok {
+		t.Error("shared tags modified")
+	}
+	if _, ok := metrics[1].Tags["created_by"]; ok {
+		t.Error("vm_perf tagged")
+	}
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// TagMapping sets Tags, such as application, owner or sla_tier, on the
+// metrics whose tags match every glob of Match, by tag key.
+type TagMapping struct {
+	Match map[string]string `json:"match"`
+	Tags  map[string]string `json:"tags"`
+}
+
+// TagMapper tags the metrics of entities with the business context of
+// mapping files, until it lives in a CMDB.
+type TagMapper struct {
+	Mappings []TagMapping
+}
+
+// LoadTagMappings loads the TagMapper of a CSV file, of extension .csv, or
+// else of a JSON array of TagMapping. The header of a CSV file names the tag
+// matched by the globs of its first column, followed by the tags set from
+// the others, empty cells setting none:
+//
+//	name,application,owner,sla_tier
+//	web-*,shop,team-web,gold
+func LoadTagMappings(file string) (*TagMapper, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mappings []TagMapping
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		r := csv.NewReader(f)
+		r.TrimLeadingSpace = true
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		if len(records) == 0 || len(records[0]) < 2 {
+			return nil, fmt.Errorf("%s: expected a header of the tag matched and those set", file)
+		}
+		header := records[0]
+		for _, row := range records[1:] {
+			m := TagMapping{Match: map[string]string{header[0]: row[0]}, Tags: make(map[string]string)}
+			for i, v := range row[1:] {
+				if v != "" {
+					m.Tags[header[i+1]] = v
+				}
+			}
+			mappings = append(mappings, m)
+		}
+	} else if err := json.NewDecoder(f).Decode(&mappings); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	for i, m := range mappings {
+		if len(m.Match) == 0 {
+			return nil, fmt.Errorf("%s: mapping %d matches nothing", file, i+1)
+		}
+		for k, glob := range m.Match {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("%s: mapping %d: %s glob %q: %s", file, i+1, k, glob, err)
+			}
+		}
+	}
+	return &TagMapper{Mappings: mappings}, nil
+}
+
+// matches reports whether tags match every glob of m.
+func (m TagMapping) matches(tags map[string]string) bool {
+	for k, glob := range m.Match {
+		if ok, _ := path.Match(glob, tags[k]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Tag sets the tags of the mappings matching the metrics of entities, later
+// mappings overriding earlier ones. Tags set by the collector are kept. Tags
+// maps are copied before being set, as they may be shared.
+func (t *TagMapper) Tag(metrics []Metric) {
+	for i := range metrics {
+		m := &metrics[i]
+		if m.Entity == nil {
+			continue
+		}
+
+		var mapped map[string]string
+		for _, mapping := range t.Mappings {
+			if !mapping.matches(m.Tags) {
+				continue
+			}
+			if mapped == nil {
+				mapped = make(map[string]string)
+			}
+			for k, v := range mapping.Tags {
+				mapped[k] = v
+			}
+		}
+		if mapped == nil {
+			continue
+		}
+
+		tags := make(map[string]string, len(m.Tags)+len(mapped))
+		for k, v := range mapped {
+			tags[k] = v
+		}
+		for k, v := range m.Tags {
+			if v != "" || tags[k] == "" {
+				tags[k] = v
+			}
+		}
+		m.Tags = tags
+	}
+}
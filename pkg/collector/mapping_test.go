@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTagMapper(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "apps.csv")
+	if err := os.WriteFile(csvFile, []byte("name,application,owner,sla_tier\nweb-*,shop,team-web,gold\nweb-test,,,bronze\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "apps.json")
+	if err := os.WriteFile(jsonFile, []byte(`[{"match": {"resource_pool": "Prod*", "name": "db-*"}, "tags": {"application": "erp", "name": "renamed"}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	vm := func(name, pool string) Metric {
+		return Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": name, "resource_pool": pool},
+			Entity: &EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: name},
+		}
+	}
+	metrics := []Metric{vm("web-01", "Prod"), vm("web-test", "Test"), vm("db-01", "Production"), vm("db-02", "Test")}
+	shared := metrics[0].Tags
+
+	for _, file := range []string{csvFile, jsonFile} {
+		mapper, err := LoadTagMappings(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mapper.Tag(metrics)
+	}
+
+	expect := []map[string]string{
+		{"application": "shop", "owner": "team-web", "sla_tier": "gold"},
+		{"application": "shop", "owner": "team-web", "sla_tier": "bronze"},
+		{"application": "erp", "name": "db-01"},
+		{"application": ""},
+	}
+	for i, tags := range expect {
+		for k, v := range tags {
+			if metrics[i].Tags[k] != v {
+				t.Errorf("%s: %s=%q, expected %q", metrics[i].Tags["name"], k, metrics[i].Tags[k], v)
+			}
+		}
+	}
+	if _, ok := shared["application"]; ok {
+		t.Error("shared tags modified")
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`[{"match": {"name": "["}, "tags": {"application": "x"}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTagMappings(bad); err == nil {
+		t.Error("invalid glob accepted")
+	}
+}
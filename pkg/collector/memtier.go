@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// hostMemoryProperties are the properties retrieved of hosts for their
+// persistent memory and memory tiers.
+var hostMemoryProperties = []string{"hardware.persistentMemoryInfo", "hardware.memoryTieringType", "hardware.memoryTierInfo"}
+
+// MemoryTier is a tier of the memory of a host.
+type MemoryTier struct {
+	Name string
+	// Type is the type of the memory of the tier, such as DRAM, PMem or NVMe.
+	Type      string
+	SizeBytes int64
+}
+
+// HostMemoryTiers returns the memory tiering of a host, such as
+// hardwareMemoryTiering on vSphere 8 hosts tiering memory over NVMe, and its
+// tiers. Hosts of vSphere 7 only report their persistent memory, returned as
+// a PMem tier.
+func HostMemoryTiers(host mo.HostSystem) (string, []MemoryTier) {
+	hw := host.Hardware
+	if hw == nil {
+		return "", nil
+	}
+
+	var tiers []MemoryTier
+	for _, t := range hw.MemoryTierInfo {
+		tiers = append(tiers, MemoryTier{Name: t.Name, Type: t.Type, SizeBytes: t.Size})
+	}
+	if len(tiers) == 0 && hw.PersistentMemoryInfo != nil && hw.PersistentMemoryInfo.CapacityInMB > 0 {
+		tiers = append(tiers, MemoryTier{Name: "pmem", Type: "PMem", SizeBytes: hw.PersistentMemoryInfo.CapacityInMB << 20})
+	}
+	return hw.MemoryTieringType, tiers
+}
+
+// hostPMemBytes returns the capacity of the persistent memory of a host, 0
+// for none.
+func hostPMemBytes(host mo.HostSystem) int64 {
+	if host.Hardware == nil || host.Hardware.PersistentMemoryInfo == nil {
+		return 0
+	}
+	return host.Hardware.PersistentMemoryInfo.CapacityInMB << 20
+}
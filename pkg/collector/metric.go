@@ -0,0 +1,541 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// FieldType is the type a field is serialized as. Sinks such as InfluxDB
+// reject points whose field type differs from previous points, so a field
+// must always be emitted with the same type.
+type FieldType string
+
+const (
+	Integer FieldType = "integer"
+	Float   FieldType = "float"
+	Boolean FieldType = "boolean"
+	String  FieldType = "string"
+)
+
+// Fields declares the type of every field of every measurement.
+var Fields = map[string]map[string]FieldType{
+	"datastore": {
+		"capacity":     Integer,
+		"freespace":    Integer,
+		"used_percent": Float,
+
+		"sioc_enabled":                 Boolean,
+		"sioc_congestion_threshold_ms": Integer,
+	},
+	"datastore_host": {
+		"mounted":    Boolean,
+		"accessible": Boolean,
+	},
+	"host": {
+		"available":           Integer,
+		"in_maintenance_mode": Boolean,
+		"cpu_mhz":             Integer,
+		"num_cpu_cores":       Integer,
+		"num_cpu_threads":     Integer,
+		"mem_size":            Integer,
+		"overall_cpu_usage":   Integer,
+		"overall_mem_usage":   Integer,
+		"uptime_sec":          Integer,
+	},
+	"vm": {
+		"available":            Integer,
+		"mem_mb":               Integer,
+		"num_cpu":              Integer,
+		"num_cores_per_socket": Integer,
+		"cores_per_numa_node":  Integer,
+		"cpu_hot_add":          Boolean,
+		"memory_hot_add":       Boolean,
+		"cpu_reservation":      Integer,
+		"cpu_limit":            Integer,
+		"cpu_shares":           Integer,
+		"mem_reservation":      Integer,
+		"mem_limit":            Integer,
+		"mem_shares":           Integer,
+		"created_timestamp":    Integer,
+		"modified_timestamp":   Integer,
+		"change_version":       String,
+		"host_mem_usage":       Integer,
+		"guest_mem_usage":      Integer,
+		"overall_cpu_usage":    Integer,
+		"overall_cpu_demand":   Integer,
+		"swap_mem":             Integer,
+		"balloon_mem":          Integer,
+		"uptime_sec":           Integer,
+		"max_cpu_usage":        Integer,
+		"max_mem_usage":        Integer,
+		"question_pending":     Boolean,
+		"storage_committed":    Integer,
+		"storage_uncommitted":  Integer,
+		"snapshots":            Integer,
+		"snapshot_age_sec":     Integer,
+	},
+	"host_security": {
+		"lockdown_level":         Integer,
+		"ssh_enabled":            Boolean,
+		"shell_enabled":          Boolean,
+		"secure_boot":            Boolean,
+		"promiscuous_portgroups": Integer,
+	},
+	"vm_security": {
+		"encrypted":   Boolean,
+		"vtpm":        Boolean,
+		"secure_boot": Boolean,
+	},
+	"vm_disk": {
+		"capacity_bytes":   Integer,
+		"iops_limit":       Integer,
+		"iops_reservation": Integer,
+		"shares":           Integer,
+	},
+	"vm_protection": {
+		"ft_state":        String,
+		"ft_protected":    Boolean,
+		"replicated":      Boolean,
+		"rpo_minutes":     Integer,
+		"rpo_violated":    Boolean,
+		"srm_placeholder": Boolean,
+	},
+	"host_firmware": {
+		"value": Integer,
+	},
+	"host_hardware": {
+		"value": Integer,
+	},
+	"host_nic": {
+		"value": Integer,
+	},
+	"host_hba": {
+		"value": Integer,
+	},
+	"host_memory_tier": {
+		"size_bytes": Integer,
+	},
+	"host_pmem": {
+		"capacity_bytes":  Integer,
+		"available_bytes": Integer,
+	},
+	"host_numa": {
+		"numa_nodes":                Integer,
+		"hyperthreading_available":  Boolean,
+		"hyperthreading_active":     Boolean,
+		"hyperthreading_configured": Boolean,
+	},
+	"host_numa_node": {
+		"cpus":         Integer,
+		"memory_bytes": Integer,
+	},
+	"host_sched": {
+		"cpu_ready_ms":          Integer,
+		"cpu_readiness_percent": Float,
+		"cpu_latency_percent":   Float,
+	},
+	"host_power": {
+		"power_watts":     Integer,
+		"power_cap_watts": Integer,
+		"capped":          Boolean,
+	},
+	"vm_energy": {
+		"power_watts": Float,
+		"energy_wh":   Float,
+	},
+	"top": {
+		"value": Float,
+	},
+	"cluster": {
+		"num_hosts":             Integer,
+		"evc_enabled":           Boolean,
+		"esxi_builds":           Integer,
+		"mixed_versions":        Boolean,
+		"hosts_in_maintenance":  Integer,
+		"hosts_reboot_required": Integer,
+	},
+	"cluster_power": {
+		"dpm_enabled":            Boolean,
+		"dpm_threshold":          Integer,
+		"hosts_dpm_disabled":     Integer,
+		"hosts_standby":          Integer,
+		"hosts_powered_off":      Integer,
+		"hosts_high_performance": Integer,
+		"hosts_balanced":         Integer,
+		"hosts_low_power":        Integer,
+		"hosts_custom_policy":    Integer,
+	},
+	"vm_perf": {
+		"net_usage_kbps":  Integer,
+		"disk_usage_kbps": Integer,
+		"cpu_ready_ms":    Integer,
+	},
+	"vm_idle": {
+		"idle_score":        Float,
+		"cpu_usage_percent": Float,
+		"net_usage_kbps":    Float,
+		"disk_usage_kbps":   Float,
+		"samples":           Integer,
+	},
+	"collector": {
+		"errors": Integer,
+		"panics": Integer,
+	},
+	"guardrail": {
+		"vms_skipped":  Integer,
+		"perf_skipped": Boolean,
+		"heap_bytes":   Integer,
+	},
+	"vcenter_probe": {
+		"available":       Integer,
+		"api_latency_sec": Float,
+		"login_sec":       Float,
+	},
+	"cycle": {
+		"duration_sec":   Float,
+		"endpoints":      Integer,
+		"failures":       Integer,
+		"overruns":       Integer,
+		"series":         Integer,
+		"series_dropped": Integer,
+	},
+	"build_info": {
+		"value": Integer,
+	},
+	"tombstone": {
+		"value": Integer,
+	},
+	"vm_cost": {
+		"cpu_cost":     Float,
+		"memory_cost":  Float,
+		"storage_cost": Float,
+		"total_cost":   Float,
+	},
+	"resource_pool_cost": {
+		"cpu_cost":     Float,
+		"memory_cost":  Float,
+		"storage_cost": Float,
+		"total_cost":   Float,
+		"vms":          Integer,
+	},
+	"migration": {
+		"count":            Integer,
+		"duration_sec_avg": Float,
+		"duration_sec_max": Float,
+	},
+	"inventory_drift": {
+		"created":      Integer,
+		"deleted":      Integer,
+		"moved":        Integer,
+		"reconfigured": Integer,
+		"membership":   Integer,
+	},
+	"forecast": {
+		"used_percent":          Float,
+		"slope_percent_per_day": Float,
+		"days_until_full":       Float,
+		"samples":               Integer,
+	},
+}
+
+// Metric is a single point, the contract between gatherers and sinks: its
+// field values are always int64, float64, bool or string, as declared in
+// Fields for its measurement. Tags may be shared with the metrics of the same
+// entity in other cycles and must not be modified.
+type Metric struct {
+	// Name is the measurement.
+	Name   string                 `json:"name"`
+	Tags   map[string]string      `json:"tags,omitempty"`
+	Fields map[string]interface{} `json:"fields"`
+	Time   time.Time              `json:"timestamp"`
+	// Entity is the managed object measured, nil for the metrics of the
+	// collector itself.
+	Entity *EntityRef `json:"entity,omitempty"`
+}
+
+// EntityRef identifies a managed object of an endpoint.
+type EntityRef struct {
+	VCenter string `json:"vcenter"`
+	Type    string `json:"type"`
+	MOID    string `json:"moid"`
+}
+
+// NewEntityRef returns the EntityRef of ref on vcenter.
+func NewEntityRef(vcenter string, ref types.ManagedObjectReference) *EntityRef {
+	return &EntityRef{VCenter: vcenter, Type: ref.Type, MOID: ref.Value}
+}
+
+func (e *EntityRef) String() string {
+	return e.VCenter + "/" + e.Type + ":" + e.MOID
+}
+
+// Tag returns the value of tag k, "" if unset.
+func (m Metric) Tag(k string) string {
+	return m.Tags[k]
+}
+
+// Int returns the value of integer field k.
+func (m Metric) Int(k string) (int64, bool) {
+	v, ok := m.Fields[k].(int64)
+	return v, ok
+}
+
+// Float returns the value of numeric field k, converting integers.
+func (m Metric) Float(k string) (float64, bool) {
+	switch v := m.Fields[k].(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Bool returns the value of boolean field k.
+func (m Metric) Bool(k string) (bool, bool) {
+	v, ok := m.Fields[k].(bool)
+	return v, ok
+}
+
+// Str returns the value of string field k.
+func (m Metric) Str(k string) (string, bool) {
+	v, ok := m.Fields[k].(string)
+	return v, ok
+}
+
+// UnmarshalJSON decodes a Metric, restoring the field types declared in
+// Fields: JSON numbers of integer fields become int64 rather than float64.
+// Numbers of undeclared fields are int64 when integral.
+func (m *Metric) UnmarshalJSON(b []byte) error {
+	type metric Metric
+	var raw struct {
+		metric
+		Fields map[string]json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*m = Metric(raw.metric)
+	m.Fields = make(map[string]interface{}, len(raw.Fields))
+	for k, r := range raw.Fields {
+		d := json.NewDecoder(bytes.NewReader(r))
+		d.UseNumber()
+
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return fmt.Errorf("field %q: %w", k, err)
+		}
+
+		n, ok := v.(json.Number)
+		if !ok {
+			m.Fields[k] = v
+			continue
+		}
+
+		if t, ok := Fields[m.Name][k]; ok && t == Float {
+			v, err := n.Float64()
+			if err != nil {
+				return fmt.Errorf("field %q: %w", k, err)
+			}
+			m.Fields[k] = v
+		} else if i, err := n.Int64(); err == nil {
+			m.Fields[k] = i
+		} else if t == Integer {
+			return fmt.Errorf("field %q: %w", k, err)
+		} else if v, err := n.Float64(); err == nil {
+			m.Fields[k] = v
+		} else {
+			return fmt.Errorf("field %q: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// StrictMode is how schema drift, such as fields missing or changing type
+// after a vCenter upgrade, is handled.
+type StrictMode string
+
+const (
+	// StrictOff drops drifting fields silently.
+	StrictOff StrictMode = "off"
+	// StrictWarn drops drifting fields and logs a warning.
+	StrictWarn StrictMode = "warn"
+	// StrictFail fails the collector.
+	StrictFail StrictMode = "fail"
+)
+
+// ParseStrictMode parses a StrictMode.
+func ParseStrictMode(s string) (StrictMode, error) {
+	switch m := StrictMode(strings.ToLower(s)); m {
+	case StrictOff, StrictWarn, StrictFail:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid strict mode %q", s)
+}
+
+// DriftError lists the differences between a point and the fields declared
+// for its measurement.
+type DriftError struct {
+	Measurement string
+	Problems    []string
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%s: schema drift: %s", e.Measurement, strings.Join(e.Problems, ", "))
+}
+
+// NewMetric returns the Metric of records, converting each record to the type
+// declared for it in Fields. Undeclared records, records that cannot be
+// converted without loss and declared fields missing from records are left
+// out of the Metric and reported by a *DriftError. Missing fields are not
+// reported for points tagged as degraded or with an "available" record of 0,
+// which are partial by design.
+func NewMetric(measurement string, tags map[string]string, records map[string]interface{}, ts time.Time) (Metric, error) {
+	m := Metric{
+		Name:   measurement,
+		Tags:   tags,
+		Fields: make(map[string]interface{}, len(records)),
+		Time:   ts,
+	}
+
+	var problems []string
+	declared := Fields[measurement]
+	for k, v := range records {
+		t, ok := declared[k]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("undeclared field %q", k))
+			continue
+		}
+
+		fv, err := convertField(v, t)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("field %q: %s", k, err))
+			continue
+		}
+		m.Fields[k] = fv
+	}
+
+	partial := tags["degraded"] == "true" || m.Fields["available"] == int64(0)
+	if !partial {
+		for k := range declared {
+			if _, ok := records[k]; !ok {
+				problems = append(problems, fmt.Sprintf("missing field %q", k))
+			}
+		}
+	}
+
+	if len(problems) != 0 {
+		sort.Strings(problems)
+		return m, &DriftError{Measurement: measurement, Problems: problems}
+	}
+	return m, nil
+}
+
+// Accumulator accumulates the metrics of a collection cycle, all stamped with
+// the same time.
+type Accumulator struct {
+	Time time.Time
+	// Strict is how schema drift is handled.
+	Strict StrictMode
+	// Filter selects the fields kept, nil for all.
+	Filter *FieldFilter
+	// Progress counts the entities added, nil for none.
+	Progress *Progress
+
+	metrics []Metric
+}
+
+// Add adds the metric of entity, nil for none, from tags and records. Schema
+// drift is dropped, logged or returned depending on Strict, and the fields
+// Filter drops are dropped after.
+func (a *Accumulator) Add(measurement string, entity *EntityRef, tags map[string]string, records map[string]interface{}) error {
+	return a.add(measurement, entity, tags, records, entity != nil)
+}
+
+// add adds a metric like Add, counting its entity as collected if collected.
+// Secondary measurements of an entity, such as vm_perf, are not counted.
+func (a *Accumulator) add(measurement string, entity *EntityRef, tags map[string]string, records map[string]interface{}, collected bool) error {
+	m, err := NewMetric(measurement, tags, records, a.Time)
+	m.Entity = entity
+	if err != nil {
+		var de *DriftError
+		if !errors.As(err, &de) || a.Strict == StrictFail {
+			return err
+		}
+		if a.Strict == StrictWarn {
+			slog.Warn("schema drift", "measurement", measurement, "name", tags["name"], "problems", de.Problems)
+		}
+	}
+
+	for k := range m.Fields {
+		if !a.Filter.Keep(measurement, k) {
+			delete(m.Fields, k)
+		}
+	}
+
+	a.metrics = append(a.metrics, m)
+	if collected {
+		a.Progress.Collect()
+	}
+	return nil
+}
+
+// Metrics returns the metrics added so far.
+func (a *Accumulator) Metrics() []Metric {
+	return a.metrics
+}
+
+func convertField(v interface{}, t FieldType) (interface{}, error) {
+	switch t {
+	case Integer:
+		switch v := v.(type) {
+		case int:
+			return int64(v), nil
+		case int8:
+			return int64(v), nil
+		case int16:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case uint8:
+			return int64(v), nil
+		case uint16:
+			return int64(v), nil
+		case uint32:
+			return int64(v), nil
+		}
+	case Float:
+		switch v := v.(type) {
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case int32:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		}
+	case Boolean:
+		if v, ok := v.(bool); ok {
+			return v, nil
+		}
+	case String:
+		if v, ok := v.(string); ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", v, t)
+}
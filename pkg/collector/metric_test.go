@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewMetricTypes(t *testing.T) {
+	records := map[string]interface{}{
+		"available":   0,
+		"num_cpu":     int32(2),
+		"mem_mb":      int32(0),
+		"uptime_sec":  int32(10),
+		"swap_mem":    int32(0),
+		"max_cpu_usa": 1,
+	}
+
+	tags := map[string]string{"degraded": "true"}
+
+	if _, err := NewMetric("vm", tags, records, time.Now()); err == nil {
+		t.Error("expected undeclared field error")
+	}
+	delete(records, "max_cpu_usa")
+
+	m, err := NewMetric("vm", tags, records, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range m.Fields {
+		if _, ok := v.(int64); !ok {
+			t.Errorf("%s: %T", k, v)
+		}
+	}
+
+	if _, err := NewMetric("cycle", nil, map[string]interface{}{"overruns": 1.5}, time.Now()); err == nil {
+		t.Error("expected conversion error")
+	}
+
+	records["available"] = 1
+	if _, err := NewMetric("vm", nil, records, time.Now()); err == nil {
+		t.Error("expected missing field error")
+	}
+}
+
+func TestMetricJSON(t *testing.T) {
+	m := Metric{
+		Name:   "vm",
+		Tags:   map[string]string{"name": "DC0_H0_VM0"},
+		Fields: map[string]interface{}{"num_cpu": int64(2), "annotation": "x", "ratio": 0.5},
+		Time:   time.Unix(1700000000, 42).UTC(),
+		Entity: &EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-42"},
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Metric
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("got %#v, expected %#v", got, m)
+	}
+
+	if n, ok := got.Int("num_cpu"); !ok || n != 2 {
+		t.Errorf("num_cpu=%v", got.Fields["num_cpu"])
+	}
+	if f, ok := got.Float("num_cpu"); !ok || f != 2 {
+		t.Errorf("num_cpu=%v as float", f)
+	}
+}
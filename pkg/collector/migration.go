@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// migrationStarts are the events starting migrations, by kind of migration.
+var migrationStarts = map[string]string{
+	"VmBeingHotMigratedEvent": "vmotion",
+	"VmBeingMigratedEvent":    "vmotion",
+	"VmBeingRelocatedEvent":   "storage_vmotion",
+}
+
+// migrationEnds are the events of completed migrations, by kind of migration.
+var migrationEnds = map[string]string{
+	"VmMigratedEvent":    "vmotion",
+	"DrsVmMigratedEvent": "vmotion",
+	"VmRelocatedEvent":   "storage_vmotion",
+}
+
+// migrationTimeout is how long the start of a migration is kept waiting for
+// it to complete.
+const migrationTimeout = 24 * time.Hour
+
+// MigrationTracker counts the vMotions and Storage vMotions completed per
+// cluster across collection cycles, from the events of each, timing those
+// whose start event it saw, possibly in a previous cycle.
+type MigrationTracker struct {
+	// Filter, if set, drops migration fields.
+	Filter *FieldFilter
+
+	starts map[migrationChain]time.Time
+}
+
+type migrationChain struct {
+	vcenter string
+	chain   int32
+}
+
+// NewMigrationTracker returns a MigrationTracker.
+func NewMigrationTracker() *MigrationTracker {
+	return &MigrationTracker{starts: make(map[migrationChain]time.Time)}
+}
+
+// Track returns a migration metric at ts per vCenter, cluster, kind and
+// initiator of the migrations completed by events, those since the previous
+// cycle, sorted by its tags. The initiator is drs for migrations DRS
+// recommended and user for others; durations are 0 when no migration of a
+// metric was timed.
+func (t *MigrationTracker) Track(events []Event, ts time.Time) []Metric {
+	type key struct{ vcenter, cluster, kind, initiator string }
+	type stats struct {
+		count      int
+		timed      int
+		total, max float64
+	}
+	completed := make(map[key]*stats)
+
+	for _, ev := range events {
+		chain := migrationChain{ev.VCenter, ev.ChainID}
+		if _, ok := migrationStarts[ev.Type]; ok {
+			t.starts[chain] = ev.Time
+			continue
+		}
+		kind, ok := migrationEnds[ev.Type]
+		if !ok {
+			continue
+		}
+
+		k := key{ev.VCenter, ev.ComputeResource, kind, "user"}
+		if ev.Type == "DrsVmMigratedEvent" {
+			k.initiator = "drs"
+		}
+		s, ok := completed[k]
+		if !ok {
+			s = &stats{}
+			completed[k] = s
+		}
+		s.count++
+		if start, ok := t.starts[chain]; ok {
+			d := ev.Time.Sub(start).Seconds()
+			s.timed++
+			s.total += d
+			if d > s.max {
+				s.max = d
+			}
+			delete(t.starts, chain)
+		}
+	}
+	for chain, start := range t.starts {
+		if ts.Sub(start) > migrationTimeout {
+			delete(t.starts, chain)
+		}
+	}
+
+	keys := make([]key, 0, len(completed))
+	for k := range completed {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.vcenter != b.vcenter {
+			return a.vcenter < b.vcenter
+		}
+		if a.cluster != b.cluster {
+			return a.cluster < b.cluster
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.initiator < b.initiator
+	})
+
+	metrics := make([]Metric, 0, len(keys))
+	for _, k := range keys {
+		s := completed[k]
+		records := map[string]interface{}{
+			"count":            s.count,
+			"duration_sec_avg": 0.0,
+			"duration_sec_max": s.max,
+		}
+		if s.timed != 0 {
+			records["duration_sec_avg"] = s.total / float64(s.timed)
+		}
+		tags := map[string]string{"vcenter": k.vcenter, "cluster": k.cluster, "kind": k.kind, "initiator": k.initiator}
+		m, err := NewMetric("migration", tags, records, ts)
+		if err != nil {
+			slog.Warn("migration metric failed", "cluster", k.cluster, "err", err)
+			continue
+		}
+		for f := range m.Fields {
+			if !t.Filter.Keep("migration", f) {
+				delete(m.Fields, f)
+			}
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
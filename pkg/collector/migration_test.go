@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrationTrack(t *testing.T) {
+	tr := NewMigrationTracker()
+	start := time.Now()
+	ev := func(typ string, chain int32, at time.Duration) Event {
+		return Event{Time: start.Add(at), VCenter: "vc", Type: typ, ChainID: chain, ComputeResource: "DC0_C0"}
+	}
+
+	// The second migration completes in the next cycle
+	if metrics := tr.Track([]Event{
+		ev("VmBeingHotMigratedEvent", 1, 0),
+		ev("DrsVmMigratedEvent", 1, 30*time.Second),
+		ev("VmBeingHotMigratedEvent", 2, 40*time.Second),
+	}, start.Add(time.Minute)); len(metrics) != 1 || metrics[0].Tag("initiator") != "drs" {
+		t.Fatalf("first cycle %v", metrics)
+	}
+
+	metrics := tr.Track([]Event{
+		ev("VmMigratedEvent", 2, 100*time.Second),
+		ev("VmMigratedEvent", 3, 110*time.Second),
+	}, start.Add(2*time.Minute))
+	if len(metrics) != 1 {
+		t.Fatalf("%d metrics, expected 1", len(metrics))
+	}
+	m := metrics[0]
+	if m.Tag("cluster") != "DC0_C0" || m.Tag("kind") != "vmotion" || m.Tag("initiator") != "user" {
+		t.Errorf("tags %v", m.Tags)
+	}
+	if m.Fields["count"] != int64(2) || m.Fields["duration_sec_avg"] != 60.0 || m.Fields["duration_sec_max"] != 60.0 {
+		t.Errorf("fields %v", m.Fields)
+	}
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// hostNUMAProperties are the properties retrieved of hosts for their NUMA
+// nodes and hyperthreading.
+var hostNUMAProperties = []string{"hardware.numaInfo", "config.hyperThread"}
+
+// NUMANode is a NUMA node of a host.
+type NUMANode struct {
+	ID          int
+	CPUs        int
+	MemoryBytes int64
+}
+
+// HostNUMANodes returns the NUMA nodes of a host, none for hosts not
+// reporting them.
+func HostNUMANodes(host mo.HostSystem) []NUMANode {
+	if host.Hardware == nil || host.Hardware.NumaInfo == nil {
+		return nil
+	}
+
+	var nodes []NUMANode
+	for _, n := range host.Hardware.NumaInfo.NumaNode {
+		nodes = append(nodes, NUMANode{ID: int(n.TypeId), CPUs: len(n.CpuID), MemoryBytes: n.MemoryRangeLength})
+	}
+	return nodes
+}
+
+// HostNUMARecords returns the records of the host_numa metric of a host: its
+// NUMA nodes and whether hyperthreading is available, active, and configured
+// to be active after the next reboot. The vSphere API does not report the
+// memory used of nodes, only their size, that of host_numa_node metrics.
+func HostNUMARecords(host mo.HostSystem) map[string]interface{} {
+	records := map[string]interface{}{
+		"numa_nodes":                int64(len(HostNUMANodes(host))),
+		"hyperthreading_available":  false,
+		"hyperthreading_active":     false,
+		"hyperthreading_configured": false,
+	}
+	if host.Config != nil && host.Config.HyperThread != nil {
+		ht := host.Config.HyperThread
+		records["hyperthreading_available"] = ht.Available
+		records["hyperthreading_active"] = ht.Active
+		records["hyperthreading_configured"] = ht.Config
+	}
+	return records
+}
@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// realtimeInterval is the interval of the real-time statistics of hosts and
+// powered on virtual machines, in seconds.
+const realtimeInterval = 20
+
+// maxQueryMetricsOption is the vCenter advanced setting limiting the metrics,
+// entities times counters, of a performance query.
+const maxQueryMetricsOption = "config.vpxd.stats.maxQueryMetrics"
+
+// defaultMaxQueryMetrics is the limit of vCenters not setting
+// maxQueryMetricsOption.
+const defaultMaxQueryMetrics = 256
+
+// MaxQueryMetrics returns the limit of the metrics of a performance query of
+// c: the maxQueryMetrics setting of a vCenter, 0 for none as of ESX and
+// vCenters setting it to -1. vCenters not setting it have the default limit,
+// which is also returned along with any error reading it.
+func MaxQueryMetrics(ctx context.Context, c *govmomi.Client) (int, error) {
+	if !c.IsVC() || c.ServiceContent.Setting == nil {
+		return 0, nil
+	}
+
+	options, err := object.NewOptionManager(c.Client, *c.ServiceContent.Setting).Query(ctx, maxQueryMetricsOption)
+	if err != nil || len(options) == 0 {
+		if isInvalidName(err) {
+			err = nil
+		}
+		return defaultMaxQueryMetrics, err
+	}
+
+	limit, err := strconv.Atoi(fmt.Sprint(options[0].GetOptionValue().Value))
+	if err != nil {
+		return defaultMaxQueryMetrics, fmt.Errorf("%s: %s", maxQueryMetricsOption, err)
+	}
+	if limit < 0 {
+		return 0, nil
+	}
+	return limit, nil
+}
+
+// maxPerfCounters returns the most counters of a performance query.
+func maxPerfCounters() int {
+	return max(len(vmPerfCounters), len(hostPerfCounters))
+}
+
+// perfBatches splits refs into batches querying at most limit metrics of
+// counters each, a single batch for a limit of 0. When even a single entity
+// exceeds the limit, entities are queried one by one, vCenter failing the
+// queries.
+func perfBatches(refs []types.ManagedObjectReference, counters, limit int) [][]types.ManagedObjectReference {
+	size := len(refs)
+	if limit > 0 && counters > 0 {
+		size = limit / counters
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	var batches [][]types.ManagedObjectReference
+	for len(refs) > size {
+		batches = append(batches, refs[:size])
+		refs = refs[size:]
+	}
+	return append(batches, refs)
+}
+
+// latestSamples returns the latest real-time value of the aggregate instance
+// of counters, such as "net.usage.average", by entity of refs and counter
+// name, in batches beneath limit metrics, as of MaxQueryMetrics, none for a
+// negative limit. Entities or counters without statistics are left out, as
+// are the counters the endpoint doesn't have, such as those of older versions.
+func latestSamples(ctx context.Context, c *govmomi.Client, refs []types.ManagedObjectReference, counters []string, limit int) (map[types.ManagedObjectReference]map[string]int64, error) {
+	if len(refs) == 0 || limit < 0 {
+		return nil, nil
+	}
+
+	// Querying a counter the endpoint doesn't have fails the whole query
+	m := performance.NewManager(c.Client)
+	info, err := m.CounterInfoByName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make([]string, 0, len(counters))
+	for _, name := range counters {
+		if _, ok := info[name]; ok {
+			known = append(known, name)
+		} else {
+			slog.Debug("performance counter unsupported", "endpoint", c.URL().Host, "counter", name)
+		}
+	}
+	if counters = known; len(counters) == 0 {
+		return nil, nil
+	}
+	if limit > 0 && len(counters) > limit {
+		slog.Warn("performance counters exceed the metrics of a query", "endpoint", c.URL().Host, "counters", len(counters), "max_query_metrics", limit)
+	}
+
+	spec := types.PerfQuerySpec{MaxSample: 1, IntervalId: realtimeInterval}
+	var series []performance.EntityMetric
+	for _, batch := range perfBatches(refs, len(counters), limit) {
+		samples, err := m.SampleByName(ctx, spec, counters, batch)
+		if err != nil {
+			return nil, err
+		}
+		bseries, err := m.ToMetricSeries(ctx, samples)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, bseries...)
+	}
+
+	values := make(map[types.ManagedObjectReference]map[string]int64, len(series))
+	for _, em := range series {
+		for _, s := range em.Value {
+			if s.Instance != "" || len(s.Value) == 0 {
+				continue
+			}
+			if values[em.Entity] == nil {
+				values[em.Entity] = make(map[string]int64, len(counters))
+			}
+			values[em.Entity][s.Name] = s.Value[len(s.Value)-1]
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// CheckPermissions checks the session of every endpoint holds, on the
+// inventory root, the privileges each gatherer requires, and writes a report
+// of what is missing to w. It returns false if any privilege is missing or
+// could not be checked.
+func CheckPermissions(ctx context.Context, endpoints []*Endpoint, w io.Writer) bool {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tCOLLECTOR\tSTATUS\tMISSING")
+
+	ok := true
+	for _, e := range endpoints {
+		c, err := e.Client(ctx)
+		if err != nil {
+			ok = false
+			fmt.Fprintf(tw, "%s\t-\terror\t%s\n", e.URL.Host, Redact(err.Error()))
+			continue
+		}
+
+		if c.ServiceContent.AuthorizationManager == nil {
+			ok = false
+			fmt.Fprintf(tw, "%s\t-\terror\tno authorization manager\n", e.URL.Host)
+			continue
+		}
+
+		for _, g := range Gatherers {
+			missing, err := MissingPrivileges(ctx, c, c.ServiceContent.RootFolder, g.Privileges)
+			switch {
+			case err != nil:
+				ok = false
+				fmt.Fprintf(tw, "%s\t%s\terror\t%s\n", e.URL.Host, g.Name, Redact(err.Error()))
+			case len(missing) != 0:
+				ok = false
+				fmt.Fprintf(tw, "%s\t%s\tmissing\t%s\n", e.URL.Host, g.Name, strings.Join(missing, ","))
+			default:
+				fmt.Fprintf(tw, "%s\t%s\tok\t\n", e.URL.Host, g.Name)
+			}
+		}
+	}
+
+	tw.Flush()
+	return ok
+}
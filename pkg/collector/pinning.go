@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// LoadKnownHosts adds the thumbprints of a known hosts file, in the format of
+// govc's GOVC_TLS_KNOWN_HOSTS (a host and its thumbprint per line), to
+// thumbprints. Hosts already in thumbprints are left as is.
+func LoadKnownHosts(file string, thumbprints map[string]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: expected host and thumbprint", file, line)
+		}
+		if _, ok := thumbprints[fields[0]]; !ok {
+			thumbprints[fields[0]] = fields[1]
+		}
+	}
+	return scanner.Err()
+}
+
+// hostThumbprint returns the thumbprint pinned for the host of u, by host and
+// port, host alone, or for every host.
+func hostThumbprint(thumbprints map[string]string, u *url.URL) string {
+	for _, host := range []string{u.Host, u.Hostname(), ""} {
+		if t, ok := thumbprints[host]; ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// pinCertificate makes tc accept only a server certificate whose SHA-1 or
+// SHA-256 thumbprint is thumbprint, instead of verifying its chain.
+func pinCertificate(tc *tls.Config, thumbprint string) {
+	want := normalizeThumbprint(thumbprint)
+
+	tc.InsecureSkipVerify = true
+	tc.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+		if len(raw) == 0 {
+			return errors.New("no server certificate")
+		}
+
+		sha1sum := sha1.Sum(raw[0])
+		sha256sum := sha256.Sum256(raw[0])
+		if want == formatThumbprint(sha1sum[:]) || want == formatThumbprint(sha256sum[:]) {
+			return nil
+		}
+		return fmt.Errorf("server certificate thumbprint %s does not match pinned %s", formatThumbprint(sha256sum[:]), thumbprint)
+	}
+}
+
+// normalizeThumbprint returns thumbprint as upper case, colon separated hex.
+func normalizeThumbprint(thumbprint string) string {
+	hex := strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(thumbprint))
+
+	var b strings.Builder
+	for i := 0; i < len(hex); i += 2 {
+		if i != 0 {
+			b.WriteByte(':')
+		}
+		b.WriteString(hex[i:min(i+2, len(hex))])
+	}
+	return b.String()
+}
+
+func formatThumbprint(sum []byte) string {
+	var b strings.Builder
+	for i, c := range sum {
+		if i != 0 {
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, "%02X", c)
+	}
+	return b.String()
+}
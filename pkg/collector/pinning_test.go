@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPinCertificate(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	raw := s.Certificate().Raw
+	sha1sum := sha1.Sum(raw)
+	sha256sum := sha256.Sum256(raw)
+
+	tests := []struct {
+		thumbprint string
+		ok         bool
+	}{
+		{formatThumbprint(sha1sum[:]), true},
+		{formatThumbprint(sha256sum[:]), true},
+		{strings.ToLower(strings.ReplaceAll(formatThumbprint(sha256sum[:]), ":", "")), true},
+		{strings.Repeat("00:", 19) + "00", false},
+	}
+
+	for _, test := range tests {
+		tc := new(tls.Config)
+		pinCertificate(tc, test.thumbprint)
+
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: tc}}
+		res, err := c.Get(s.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("%s: err=%v", test.thumbprint, err)
+		}
+	}
+}
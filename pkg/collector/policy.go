@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/pbm"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// StoragePolicy is the SPBM storage policy of a virtual machine or virtual
+// disk, and whether it complies with it: compliant, nonCompliant, outOfDate,
+// notApplicable or unknown.
+type StoragePolicy struct {
+	Name       string
+	Compliance string
+}
+
+// storagePolicies returns the storage policies of the home directories and
+// virtual disks of vms with their config, by the key of their PBM object, the
+// MOID of virtual machines and MOID:device key of disks. Objects without a
+// policy are left out.
+func storagePolicies(ctx context.Context, c *govmomi.Client, vms []mo.VirtualMachine) (map[string]StoragePolicy, error) {
+	pc, err := pbm.NewClient(ctx, c.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	uuid := c.ServiceContent.About.InstanceUuid
+	var refs []pbmtypes.PbmServerObjectRef
+	for _, vm := range vms {
+		if vm.Config == nil {
+			continue
+		}
+		refs = append(refs, pbmtypes.PbmServerObjectRef{
+			ObjectType: string(pbmtypes.PbmObjectTypeVirtualMachine),
+			Key:        vm.Self.Value,
+			ServerUuid: uuid,
+		})
+		for _, d := range vm.Config.Hardware.Device {
+			if disk, ok := d.(*types.VirtualDisk); ok {
+				refs = append(refs, pbmtypes.PbmServerObjectRef{
+					ObjectType: string(pbmtypes.PbmObjectTypeVirtualDiskId),
+					Key:        diskKey(vm.Self.Value, disk.Key),
+					ServerUuid: uuid,
+				})
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	associated, err := pc.QueryAssociatedProfiles(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	var ids []pbmtypes.PbmProfileId
+	seen := make(map[string]bool)
+	for _, a := range associated {
+		for _, id := range a.ProfileId {
+			if !seen[id.UniqueId] {
+				seen[id.UniqueId] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	profiles, err := pc.RetrieveContent(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(profiles))
+	for _, p := range profiles {
+		names[p.GetPbmProfile().ProfileId.UniqueId] = p.GetPbmProfile().Name
+	}
+
+	policies := make(map[string]StoragePolicy, len(associated))
+	for _, a := range associated {
+		if len(a.ProfileId) != 0 {
+			policies[a.Object.Key] = StoragePolicy{Name: names[a.ProfileId[0].UniqueId], Compliance: "unknown"}
+		}
+	}
+
+	compliance, err := pc.FetchComplianceResult(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range compliance {
+		if p, ok := policies[r.Entity.Key]; ok {
+			p.Compliance = r.ComplianceStatus
+			policies[r.Entity.Key] = p
+		}
+	}
+	return policies, nil
+}
+
+// diskKey returns the key of the PBM object of the virtual disk of device key
+// key of the virtual machine of MOID moid.
+func diskKey(moid string, key int32) string {
+	return fmt.Sprintf("%s:%d", moid, key)
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/methods"
+)
+
+// Prober measures the API latency and login round-trip time of endpoints
+// every interval, independently of collection cycles, so that a slow vCenter
+// can be told apart from a slow collector.
+type Prober struct {
+	Endpoints []*Endpoint
+	Interval  time.Duration
+
+	mu     sync.Mutex
+	latest map[string]Metric
+}
+
+// NewProber returns a Prober of endpoints every interval.
+func NewProber(endpoints []*Endpoint, interval time.Duration) *Prober {
+	return &Prober{Endpoints: endpoints, Interval: interval, latest: make(map[string]Metric)}
+}
+
+// Run probes every endpoint in parallel every interval, until ctx is done.
+// Each probe times out after an interval.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		var wg sync.WaitGroup
+		for _, e := range p.Endpoints {
+			wg.Add(1)
+			go func(e *Endpoint) {
+				defer wg.Done()
+				pctx, cancel := context.WithTimeout(ctx, p.Interval)
+				defer cancel()
+
+				m := Probe(pctx, e, time.Now())
+				p.mu.Lock()
+				p.latest[e.URL.Host] = m
+				p.mu.Unlock()
+			}(e)
+		}
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Metrics returns the vcenter_probe metric of the latest probe of every
+// endpoint, sorted by vcenter.
+func (p *Prober) Metrics() []Metric {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	metrics := make([]Metric, 0, len(p.latest))
+	for _, m := range p.latest {
+		metrics = append(metrics, m)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Tags["vcenter"] < metrics[j].Tags["vcenter"] })
+	return metrics
+}
+
+// Probe returns the vcenter_probe metric of e at ts: the latency of a
+// CurrentTime call over its session and the time to log in and out of a new
+// one. login_sec is left out, and the degraded tag set, for endpoints whose
+// session is not the collector's own, which it can't log in to again. When
+// either fails, available is 0 and the latencies are left out.
+func Probe(ctx context.Context, e *Endpoint, ts time.Time) Metric {
+	tags := map[string]string{"vcenter": e.URL.Host}
+	down := func(err error) Metric {
+		slog.Warn("probing endpoint failed", "endpoint", e.URL.Host, "err", err)
+		m, _ := NewMetric("vcenter_probe", tags, map[string]interface{}{"available": 0}, ts)
+		return m
+	}
+
+	c, err := e.Client(ctx)
+	if err != nil {
+		return down(err)
+	}
+	start := time.Now()
+	if _, err := methods.GetCurrentTime(ctx, c.Client); err != nil {
+		return down(err)
+	}
+	records := map[string]interface{}{
+		"available":       1,
+		"api_latency_sec": time.Since(start).Seconds(),
+	}
+
+	if e.Options.OwnsSession() && e.Options.CloneTicket == "" {
+		start = time.Now()
+		lc, err := NewClient(ctx, e.URL, e.Options)
+		if err != nil {
+			return down(err)
+		}
+		if err := lc.Logout(ctx); err != nil {
+			return down(err)
+		}
+		records["login_sec"] = time.Since(start).Seconds()
+	} else {
+		tags["degraded"] = "true"
+	}
+
+	m, err := NewMetric("vcenter_probe", tags, records, ts)
+	if err != nil {
+		slog.Warn("invalid probe metric", "endpoint", e.URL.Host, "err", err)
+	}
+	return m
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Progress counts the entities of a collection as it runs, for reporting on
+// long runs. It is safe for concurrent use and a nil Progress counts nothing.
+type Progress struct {
+	start      time.Time
+	discovered atomic.Int64
+	collected  atomic.Int64
+}
+
+// NewProgress returns a Progress of a collection starting now.
+func NewProgress() *Progress {
+	return &Progress{start: time.Now()}
+}
+
+// Discover counts n entities found by a gatherer.
+func (p *Progress) Discover(n int) {
+	if p != nil {
+		p.discovered.Add(int64(n))
+	}
+}
+
+// Collect counts an entity whose metric was added.
+func (p *Progress) Collect() {
+	if p != nil {
+		p.collected.Add(1)
+	}
+}
+
+// ProgressReport is a snapshot of a Progress.
+type ProgressReport struct {
+	Discovered int64
+	Collected  int64
+	Elapsed    time.Duration
+	// ETA estimates the time left to collect the entities discovered so far
+	// from the rate so far, 0 until one is collected.
+	ETA time.Duration
+}
+
+// Report returns the progress so far.
+func (p *Progress) Report() ProgressReport {
+	r := ProgressReport{
+		Discovered: p.discovered.Load(),
+		Collected:  p.collected.Load(),
+		Elapsed:    time.Since(p.start),
+	}
+	if r.Collected != 0 && r.Discovered > r.Collected {
+		r.ETA = time.Duration(float64(r.Elapsed) * float64(r.Discovered-r.Collected) / float64(r.Collected))
+	}
+	return r
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// srmExtension is the extension key of Site Recovery Manager, which manages
+// the placeholder virtual machines of the recovery site.
+const srmExtension = "com.vmware.vcDr"
+
+// VMProtectionRecords returns the vm_protection records of a virtual machine
+// with its config: its Fault Tolerance state and whether its secondary runs,
+// whether vSphere Replication replicates it and at which RPO, and whether it
+// is a Site Recovery Manager placeholder. rpo_violated is false, as it is only
+// known from events, see RPOTracker.
+func VMProtectionRecords(vm mo.VirtualMachine) map[string]interface{} {
+	ft := vm.Summary.Runtime.FaultToleranceState
+	if ft == "" {
+		ft = types.VirtualMachineFaultToleranceStateNotConfigured
+	}
+
+	replicated := false
+	rpo := 0
+	for _, o := range vm.Config.ExtraConfig {
+		opt := o.GetOptionValue()
+		switch opt.Key {
+		case "hbr_filter.destination":
+			replicated = true
+		case "hbr_filter.rpo":
+			if s, ok := opt.Value.(string); ok {
+				rpo, _ = strconv.Atoi(s)
+			}
+		}
+	}
+
+	srm := vm.Config.ManagedBy != nil && vm.Config.ManagedBy.ExtensionKey == srmExtension
+
+	return map[string]interface{}{
+		"ft_state":        string(ft),
+		"ft_protected":    ft == types.VirtualMachineFaultToleranceStateRunning,
+		"replicated":      replicated,
+		"rpo_minutes":     rpo,
+		"rpo_violated":    false,
+		"srm_placeholder": srm,
+	}
+}
+
+// RPO violation events of vSphere Replication.
+const (
+	rpoViolatedEvent = "com.vmware.vcHms.rpoViolatedEvent"
+	rpoRestoredEvent = "com.vmware.vcHms.rpoRestoredEvent"
+)
+
+// RPOTracker keeps which virtual machines violate their vSphere Replication
+// RPO across collection cycles, from the RPO violated and restored events of
+// each.
+type RPOTracker struct {
+	violated map[EntityRef]bool
+}
+
+// NewRPOTracker returns an RPOTracker.
+func NewRPOTracker() *RPOTracker {
+	return &RPOTracker{violated: make(map[EntityRef]bool)}
+}
+
+// Track applies events, those since the previous cycle, and sets the
+// rpo_violated field of the vm_protection metrics of metrics. Virtual
+// machines no longer replicated are forgotten.
+func (t *RPOTracker) Track(events []Event, metrics []Metric) {
+	for _, ev := range events {
+		if ev.Entity == nil {
+			continue
+		}
+		switch ev.Type {
+		case rpoViolatedEvent:
+			t.violated[*ev.Entity] = true
+		case rpoRestoredEvent:
+			delete(t.violated, *ev.Entity)
+		}
+	}
+
+	for _, m := range metrics {
+		if m.Name != "vm_protection" || m.Entity == nil || !t.violated[*m.Entity] {
+			continue
+		}
+		if replicated, _ := m.Bool("replicated"); !replicated {
+			delete(t.violated, *m.Entity)
+			continue
+		}
+		if _, ok := m.Fields["rpo_violated"]; ok {
+			m.Fields["rpo_violated"] = true
+		}
+	}
+}
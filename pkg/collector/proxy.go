@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Outbound is the HTTP client of sinks and credential sources.
+var Outbound = &http.Client{
+	Transport: http.DefaultTransport.(*http.Transport).Clone(),
+	Timeout:   time.Minute,
+}
+
+// ProxyFunc returns a proxy selection function for http.Transport. When proxy
+// is set, an http, https or socks5 URL, it is used for every host not
+// excluded by NO_PROXY; otherwise HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply.
+func ProxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if password, ok := u.User.Password(); ok {
+		AddSecret(password)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+
+	cfg := &httpproxy.Config{
+		HTTPProxy:  proxy,
+		HTTPSProxy: proxy,
+		NoProxy:    getenvAny("NO_PROXY", "no_proxy"),
+	}
+	fn := cfg.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}, nil
+}
+
+func getenvAny(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "xxxxx"
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+
+	// userinfoPattern matches the password of a URL, as in error messages of
+	// net/http for URLs with credentials.
+	userinfoPattern = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]*):[^@/\s]*@`)
+)
+
+// AddSecret registers a password, token or session ID to scrub from logs and
+// errors.
+func AddSecret(s string) {
+	if len(s) < 4 {
+		// Too short to redact without mangling unrelated text
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	for _, secret := range secrets {
+		if secret == s {
+			return
+		}
+	}
+	secrets = append(secrets, s)
+}
+
+// Redact returns s with URL passwords and registered secrets removed.
+func Redact(s string) string {
+	s = userinfoPattern.ReplaceAllString(s, "${1}:"+redacted+"@")
+
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// NewRedactHandler returns a slog.Handler redacting the message and
+// attributes of records before passing them to h.
+func NewRedactHandler(h slog.Handler) slog.Handler {
+	return redactHandler{h}
+}
+
+type redactHandler struct {
+	h slog.Handler
+}
+
+func (r redactHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return r.h.Enabled(ctx, l)
+}
+
+func (r redactHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, Redact(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return r.h.Handle(ctx, out)
+}
+
+func (r redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = redactAttr(a)
+	}
+	return redactHandler{r.h.WithAttrs(out)}
+}
+
+func (r redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{r.h.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		out := make([]slog.Attr, len(group))
+		for i, g := range group {
+			out[i] = redactAttr(g)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(x.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Redact(x.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// NameData is the data of the templates of a Renamer.
+type NameData struct {
+	// Measurement is the name of the metric as collected.
+	Measurement string
+	// Type is the managed object type of the entity, such as VirtualMachine,
+	// "" for the metrics of the collector itself.
+	Type string
+	// Datacenter is the datacenter of the entity, from its inventory path.
+	Datacenter string
+	VCenter    string
+	// Name is the name of the entity.
+	Name string
+	Tags map[string]string
+}
+
+// Renamer renames metrics and sets tags from Go templates, to shape the
+// output to existing schemas, such as "{{.Datacenter}}_{{.Measurement}}".
+type Renamer struct {
+	measurement *template.Template
+	tags        map[string]*template.Template
+}
+
+// NewRenamer returns a Renamer naming metrics by measurement, "" to keep
+// their name, and setting the tags of tags, as key=template.
+func NewRenamer(measurement string, tags []string) (*Renamer, error) {
+	r := &Renamer{tags: make(map[string]*template.Template)}
+
+	var err error
+	if measurement != "" {
+		if r.measurement, err = template.New("measurement").Option("missingkey=zero").Parse(measurement); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range tags {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid tag template %q: expected key=template", t)
+		}
+		if r.tags[k], err = template.New(k).Option("missingkey=zero").Parse(v); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Rename renames metrics in place. Tags maps are copied before being set,
+// as they may be shared. Metrics whose templates fail, or name them "", keep
+// their name and tags, the error returned counting them.
+func (r *Renamer) Rename(metrics []Metric) error {
+	if r.measurement == nil && len(r.tags) == 0 {
+		return nil
+	}
+
+	var b bytes.Buffer
+	execute := func(t *template.Template, data *NameData) (string, error) {
+		b.Reset()
+		if err := t.Execute(&b, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+
+	var failed int
+	var first error
+	for i := range metrics {
+		if err := r.rename(&metrics[i], execute); err != nil {
+			if failed++; first == nil {
+				first = err
+			}
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d metrics keep their names: %w", failed, first)
+	}
+	return nil
+}
+
+// rename renames m, leaving it alone on errors.
+func (r *Renamer) rename(m *Metric, execute func(*template.Template, *NameData) (string, error)) error {
+	data := nameData(m)
+
+	name := m.Name
+	if r.measurement != nil {
+		var err error
+		if name, err = execute(r.measurement, data); err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("measurement template: empty name for %s", m.Name)
+		}
+	}
+
+	if len(r.tags) != 0 {
+		tags := make(map[string]string, len(m.Tags)+len(r.tags))
+		for k, v := range m.Tags {
+			tags[k] = v
+		}
+		for k, t := range r.tags {
+			v, err := execute(t, data)
+			if err != nil {
+				return err
+			}
+			tags[k] = v
+		}
+		m.Tags = tags
+	}
+	m.Name = name
+	return nil
+}
+
+func nameData(m *Metric) *NameData {
+	data := &NameData{
+		Measurement: m.Name,
+		VCenter:     m.Tags["vcenter"],
+		Name:        m.Tags["name"],
+		Tags:        m.Tags,
+	}
+	if m.Entity != nil {
+		data.Type = m.Entity.Type
+		data.VCenter = m.Entity.VCenter
+	}
+
+	// Inventory paths start with the datacenter: /DC0/vm/VM0
+	if path := strings.TrimPrefix(m.Tags["path"], "/"); path != "" {
+		data.Datacenter, _, _ = strings.Cut(path, "/")
+	}
+	return data
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestRenamer(t *testing.T) {
+	r, err := NewRenamer("{{.Datacenter}}_{{.Measurement}}", []string{"host={{.VCenter}}:{{.Name}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := map[string]string{"name": "VM0", "path": "/DC0/vm/VM0"}
+	metrics := []Metric{{
+		Name:   "vm",
+		Tags:   tags,
+		Entity: &EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-42"},
+	}}
+
+	if err := r.Rename(metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if metrics[0].Name != "DC0_vm" {
+		t.Errorf("name=%q", metrics[0].Name)
+	}
+	if metrics[0].Tags["host"] != "vc:VM0" {
+		t.Errorf("host=%q", metrics[0].Tags["host"])
+	}
+	if _, ok := tags["host"]; ok {
+		t.Error("shared tags modified")
+	}
+
+	if _, err := NewRenamer("", []string{"{{.Name}}"}); err == nil {
+		t.Error("expected invalid tag template error")
+	}
+}
+
+func TestRenamerEmptyName(t *testing.T) {
+	r, err := NewRenamer("{{.Datacenter}}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The metrics of the collector itself have no path
+	metrics := []Metric{
+		{Name: "vm", Tags: map[string]string{"path": "/DC0/vm/VM0"}},
+		{Name: "cycle"},
+	}
+	if err := r.Rename(metrics); err == nil {
+		t.Error("expected empty name error")
+	}
+	if metrics[0].Name != "DC0" || metrics[1].Name != "cycle" {
+		t.Errorf("names %q and %q, expected DC0 and cycle", metrics[0].Name, metrics[1].Name)
+	}
+}
@@ -0,0 +1,316 @@
+package collector
+
+import (
+	"sort"
+)
+
+// Units declares the unit of every field of every measurement, "" for
+// dimensionless values such as flags.
+var Units = map[string]map[string]string{
+	"datastore": {
+		"capacity":     "bytes",
+		"freespace":    "bytes",
+		"used_percent": "percent",
+
+		"sioc_enabled":                 "",
+		"sioc_congestion_threshold_ms": "milliseconds",
+	},
+	"datastore_host": {
+		"mounted":    "",
+		"accessible": "",
+	},
+	"host": {
+		"available":           "",
+		"in_maintenance_mode": "",
+		"cpu_mhz":             "MHz",
+		"num_cpu_cores":       "count",
+		"num_cpu_threads":     "count",
+		"mem_size":            "bytes",
+		"overall_cpu_usage":   "MHz",
+		"overall_mem_usage":   "MB",
+		"uptime_sec":          "seconds",
+	},
+	"vm": {
+		"available":            "",
+		"mem_mb":               "MB",
+		"num_cpu":              "count",
+		"num_cores_per_socket": "count",
+		"cores_per_numa_node":  "count",
+		"cpu_hot_add":          "",
+		"memory_hot_add":       "",
+		"cpu_reservation":      "MHz",
+		"cpu_limit":            "MHz",
+		"cpu_shares":           "count",
+		"mem_reservation":      "MB",
+		"mem_limit":            "MB",
+		"mem_shares":           "count",
+		"created_timestamp":    "seconds",
+		"modified_timestamp":   "seconds",
+		"change_version":       "",
+		"host_mem_usage":       "MB",
+		"guest_mem_usage":      "MB",
+		"overall_cpu_usage":    "MHz",
+		"overall_cpu_demand":   "MHz",
+		"swap_mem":             "MB",
+		"balloon_mem":          "MB",
+		"uptime_sec":           "seconds",
+		"max_cpu_usage":        "MHz",
+		"max_mem_usage":        "MB",
+		"question_pending":     "",
+		"storage_committed":    "bytes",
+		"storage_uncommitted":  "bytes",
+		"snapshots":            "count",
+		"snapshot_age_sec":     "seconds",
+	},
+	"host_security": {
+		"lockdown_level":         "",
+		"ssh_enabled":            "",
+		"shell_enabled":          "",
+		"secure_boot":            "",
+		"promiscuous_portgroups": "count",
+	},
+	"vm_security": {
+		"encrypted":   "",
+		"vtpm":        "",
+		"secure_boot": "",
+	},
+	"vm_disk": {
+		"capacity_bytes":   "bytes",
+		"iops_limit":       "IOPS",
+		"iops_reservation": "IOPS",
+		"shares":           "count",
+	},
+	"vm_protection": {
+		"ft_state":        "",
+		"ft_protected":    "",
+		"replicated":      "",
+		"rpo_minutes":     "minutes",
+		"rpo_violated":    "",
+		"srm_placeholder": "",
+	},
+	"host_firmware": {
+		"value": "",
+	},
+	"host_hardware": {
+		"value": "",
+	},
+	"host_nic": {
+		"value": "",
+	},
+	"host_hba": {
+		"value": "",
+	},
+	"host_memory_tier": {
+		"size_bytes": "bytes",
+	},
+	"host_pmem": {
+		"capacity_bytes":  "bytes",
+		"available_bytes": "bytes",
+	},
+	"host_numa": {
+		"numa_nodes":                "count",
+		"hyperthreading_available":  "",
+		"hyperthreading_active":     "",
+		"hyperthreading_configured": "",
+	},
+	"host_numa_node": {
+		"cpus":         "count",
+		"memory_bytes": "bytes",
+	},
+	"host_sched": {
+		"cpu_ready_ms":          "milliseconds",
+		"cpu_readiness_percent": "percent",
+		"cpu_latency_percent":   "percent",
+	},
+	"host_power": {
+		"power_watts":     "watts",
+		"power_cap_watts": "watts",
+		"capped":          "",
+	},
+	"vm_energy": {
+		"power_watts": "watts",
+		"energy_wh":   "Wh",
+	},
+	"top": {
+		// Of the field ranked
+		"value": "",
+	},
+	"cluster": {
+		"num_hosts":             "count",
+		"evc_enabled":           "",
+		"esxi_builds":           "count",
+		"mixed_versions":        "",
+		"hosts_in_maintenance":  "count",
+		"hosts_reboot_required": "count",
+	},
+	"cluster_power": {
+		"dpm_enabled":            "",
+		"dpm_threshold":          "",
+		"hosts_dpm_disabled":     "count",
+		"hosts_standby":          "count",
+		"hosts_powered_off":      "count",
+		"hosts_high_performance": "count",
+		"hosts_balanced":         "count",
+		"hosts_low_power":        "count",
+		"hosts_custom_policy":    "count",
+	},
+	"vm_perf": {
+		"net_usage_kbps":  "KBps",
+		"disk_usage_kbps": "KBps",
+		"cpu_ready_ms":    "milliseconds",
+	},
+	"vm_idle": {
+		"idle_score":        "percent",
+		"cpu_usage_percent": "percent",
+		"net_usage_kbps":    "KBps",
+		"disk_usage_kbps":   "KBps",
+		"samples":           "count",
+	},
+	"collector": {
+		"errors": "count",
+		"panics": "count",
+	},
+	"guardrail": {
+		"vms_skipped":  "count",
+		"perf_skipped": "",
+		"heap_bytes":   "bytes",
+	},
+	"vcenter_probe": {
+		"available":       "",
+		"api_latency_sec": "seconds",
+		"login_sec":       "seconds",
+	},
+	"cycle": {
+		"duration_sec":   "seconds",
+		"endpoints":      "count",
+		"failures":       "count",
+		"overruns":       "count",
+		"series":         "count",
+		"series_dropped": "count",
+	},
+	"build_info": {
+		"value": "",
+	},
+	"tombstone": {
+		"value": "",
+	},
+	"vm_cost": {
+		"cpu_cost":     "currency",
+		"memory_cost":  "currency",
+		"storage_cost": "currency",
+		"total_cost":   "currency",
+	},
+	"resource_pool_cost": {
+		"cpu_cost":     "currency",
+		"memory_cost":  "currency",
+		"storage_cost": "currency",
+		"total_cost":   "currency",
+		"vms":          "count",
+	},
+	"migration": {
+		"count":            "count",
+		"duration_sec_avg": "seconds",
+		"duration_sec_max": "seconds",
+	},
+	"inventory_drift": {
+		"created":      "count",
+		"deleted":      "count",
+		"moved":        "count",
+		"reconfigured": "count",
+		"membership":   "count",
+	},
+	"forecast": {
+		"used_percent":          "percent",
+		"slope_percent_per_day": "percent",
+		"days_until_full":       "days",
+		"samples":               "count",
+	},
+}
+
+// entityTags are the tags of the metrics of every entity.
+var entityTags = []string{"vcenter", "moid", "path"}
+
+// hostTags are the tags of hosts, which their host_security, host_power,
+// host_pmem, host_numa and host_sched metrics share.
+var hostTags = append([]string{"name", "connection_state", "power_state", "overall_status", "vendor", "model", "cpu_model", "version", "build", "power_policy", "degraded"}, entityTags...)
+
+// vmTags are the tags of virtual machines, which their vm_security,
+// vm_protection and vm_perf metrics share.
+var vmTags = append([]string{"name", "connection_state", "power_state", "overall_status", "guest_heartbeat", "vm_path_name", "guest_full_name", "guest_id", "os_family", "os_version", "ip_address", "hostname", "is_guest_tools_running", "latency_sensitivity", "resource_pool", "host_moid", "created_by", "storage_policy", "storage_compliance", "degraded", "vcd_org", "vcd_vdc", "vcd_vapp"}, entityTags...)
+
+// TagKeys declares the tags every measurement may have, including the vcd_
+// tenant tags of virtual machines set by package vcd.
+var TagKeys = map[string][]string{
+	"datastore":          append([]string{"name", "type", "url"}, entityTags...),
+	"datastore_host":     append([]string{"name", "host", "host_moid", "cluster", "access_mode"}, entityTags...),
+	"host":               hostTags,
+	"host_security":      hostTags,
+	"host_power":         hostTags,
+	"host_pmem":          hostTags,
+	"host_numa":          hostTags,
+	"host_sched":         hostTags,
+	"host_numa_node":     append([]string{"name", "node"}, entityTags...),
+	"host_memory_tier":   append([]string{"name", "tier", "tier_type", "tiering"}, entityTags...),
+	"host_firmware":      append([]string{"name", "bios_vendor", "bios_version", "bios_release_date", "firmware_release"}, entityTags...),
+	"host_hardware":      append([]string{"name", "uuid", "serial_number", "service_tag", "asset_tag", "bmc_address"}, entityTags...),
+	"host_nic":           append([]string{"name", "device", "pci", "driver", "driver_version", "firmware_version"}, entityTags...),
+	"host_hba":           append([]string{"name", "device", "pci", "type", "model", "driver"}, entityTags...),
+	"vm":                 vmTags,
+	"cluster":            append([]string{"name", "evc_mode"}, entityTags...),
+	"cluster_power":      append([]string{"name", "dpm_behavior"}, entityTags...),
+	"vm_security":        vmTags,
+	"vm_protection":      vmTags,
+	"vm_disk":            append([]string{"name", "disk", "datastore", "storage_policy", "storage_compliance", "shares_level"}, entityTags...),
+	"vm_perf":            vmTags,
+	"vm_idle":            append([]string{"name"}, entityTags...),
+	"vm_energy":          append([]string{"name", "host_moid"}, entityTags...),
+	"top":                {"vcenter", "cluster", "ranking", "rank", "name", "moid"},
+	"collector":          {"vcenter", "collector"},
+	"vcenter_probe":      {"vcenter", "degraded"},
+	"guardrail":          {"vcenter"},
+	"cycle":              nil,
+	"build_info":         {"version", "commit", "date", "go_version"},
+	"tombstone":          append([]string{"measurement", "name"}, entityTags...),
+	"forecast":           {"kind", "resource", "name", "vcenter", "path"},
+	"vm_cost":            append([]string{"name", "resource_pool", "tier", "currency"}, entityTags...),
+	"resource_pool_cost": {"vcenter", "resource_pool", "currency"},
+	"migration":          {"vcenter", "cluster", "kind", "initiator"},
+	"inventory_drift":    {"vcenter"},
+}
+
+// MeasurementSchema describes a measurement.
+type MeasurementSchema struct {
+	Measurement string        `json:"measurement"`
+	Tags        []string      `json:"tags"`
+	Fields      []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a field.
+type FieldSchema struct {
+	Name string    `json:"name"`
+	Type FieldType `json:"type"`
+	Unit string    `json:"unit"`
+}
+
+// Schema describes every measurement that can be emitted, sorted by name,
+// with the fields filter keeps, nil for all.
+func Schema(filter *FieldFilter) []MeasurementSchema {
+	var schema []MeasurementSchema
+	for measurement, fields := range Fields {
+		ms := MeasurementSchema{Measurement: measurement, Tags: append([]string{}, TagKeys[measurement]...)}
+		sort.Strings(ms.Tags)
+
+		for name, t := range fields {
+			if !filter.Keep(measurement, name) {
+				continue
+			}
+			ms.Fields = append(ms.Fields, FieldSchema{Name: name, Type: t, Unit: Units[measurement][name]})
+		}
+		sort.Slice(ms.Fields, func(i, j int) bool { return ms.Fields[i].Name < ms.Fields[j].Name })
+
+		schema = append(schema, ms)
+	}
+
+	sort.Slice(schema, func(i, j int) bool { return schema[i].Measurement < schema[j].Measurement })
+	return schema
+}
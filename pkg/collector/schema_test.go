@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSchemaDeclared(t *testing.T) {
+	for measurement, fields := range Fields {
+		if _, ok := TagKeys[measurement]; !ok {
+			t.Errorf("%s: tags not declared", measurement)
+		}
+		for name := range fields {
+			if _, ok := Units[measurement][name]; !ok {
+				t.Errorf("%s.%s: unit not declared", measurement, name)
+			}
+		}
+	}
+	for measurement, units := range Units {
+		for name := range units {
+			if _, ok := Fields[measurement][name]; !ok {
+				t.Errorf("%s.%s: unit of undeclared field", measurement, name)
+			}
+		}
+	}
+}
+
+func TestSchemaTags(t *testing.T) {
+	_, e := newSimulator(t, 1)
+
+	metrics, err := New([]*Endpoint{e}).Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declared := make(map[string]map[string]bool)
+	for measurement, keys := range TagKeys {
+		declared[measurement] = make(map[string]bool)
+		for _, k := range keys {
+			declared[measurement][k] = true
+		}
+	}
+
+	for _, m := range metrics {
+		for k := range m.Tags {
+			if !declared[m.Name][k] {
+				t.Errorf("%s: undeclared tag %q", m.Name, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// VMProblem returns why the virtual machine of a vm metric needs triage: a
+// question pending an answer, VMware Tools stopped or its guest heartbeat
+// lost while powered on, or else "".
+func VMProblem(m Metric) string {
+	if m.Name != "vm" {
+		return ""
+	}
+	if pending, _ := m.Fields["question_pending"].(bool); pending {
+		return "question_pending"
+	}
+	if m.Tags["power_state"] != "poweredOn" {
+		return ""
+	}
+	if m.Tags["is_guest_tools_running"] == "guestToolsNotRunning" {
+		return "tools_stopped"
+	}
+	if m.Tags["guest_heartbeat"] == "red" {
+		return "heartbeat_lost"
+	}
+	return ""
+}
+
+// Screenshot returns a PNG screenshot of the console of the virtual machine
+// of ref, which requires the VirtualMachine.Interact.CreateScreenshot
+// privilege.
+func (c *Collector) Screenshot(ctx context.Context, ref EntityRef) ([]byte, error) {
+	if ref.Type != "VirtualMachine" {
+		return nil, fmt.Errorf("%s %s is not a virtual machine", ref.Type, ref.MOID)
+	}
+	for _, e := range c.Endpoints {
+		if !strings.EqualFold(e.URL.Host, ref.VCenter) {
+			continue
+		}
+		client, err := e.Client(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		u := *client.URL()
+		u.User = nil
+		u.Path = "/screen"
+		u.RawQuery = "id=" + ref.MOID
+		body, _, err := client.Client.Download(ctx, &u, &soap.DefaultDownload)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	return nil, fmt.Errorf("unknown vcenter %q", ref.VCenter)
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// hostSecurityProperties are the properties retrieved of hosts for their
+// host_security metrics.
+var hostSecurityProperties = []string{"config.lockdownMode", "config.service", "config.network.portgroup", "capability.uefiSecureBoot"}
+
+// lockdownLevels are the host_security lockdown_level of lockdown modes.
+var lockdownLevels = map[types.HostLockdownMode]int{
+	types.HostLockdownModeLockdownDisabled: 0,
+	types.HostLockdownModeLockdownNormal:   1,
+	types.HostLockdownModeLockdownStrict:   2,
+}
+
+// HostSecurityRecords returns the host_security records of a connected host:
+// its lockdown level, 0 when disabled, 1 normal and 2 strict, whether SSH and
+// the ESXi shell are running and UEFI secure boot is supported, and the number
+// of its standard switch port groups allowing promiscuous mode. Port groups of
+// distributed switches are not the host's.
+func HostSecurityRecords(host mo.HostSystem) map[string]interface{} {
+	records := map[string]interface{}{
+		"lockdown_level":         0,
+		"ssh_enabled":            false,
+		"shell_enabled":          false,
+		"secure_boot":            false,
+		"promiscuous_portgroups": 0,
+	}
+
+	if cfg := host.Config; cfg != nil {
+		records["lockdown_level"] = lockdownLevels[cfg.LockdownMode]
+
+		if cfg.Service != nil {
+			for _, s := range cfg.Service.Service {
+				switch s.Key {
+				case "TSM-SSH":
+					records["ssh_enabled"] = s.Running
+				case "TSM":
+					records["shell_enabled"] = s.Running
+				}
+			}
+		}
+
+		if cfg.Network != nil {
+			promiscuous := 0
+			for _, pg := range cfg.Network.Portgroup {
+				if sp := pg.ComputedPolicy.Security; sp != nil && sp.AllowPromiscuous != nil && *sp.AllowPromiscuous {
+					promiscuous++
+				}
+			}
+			records["promiscuous_portgroups"] = promiscuous
+		}
+	}
+
+	if hc := host.Capability; hc != nil && hc.UefiSecureBoot != nil {
+		records["secure_boot"] = *hc.UefiSecureBoot
+	}
+	return records
+}
+
+// VMSecurityRecords returns the vm_security records of a virtual machine with
+// its config: whether it is encrypted, has a virtual TPM and boots with UEFI
+// secure boot.
+func VMSecurityRecords(vm mo.VirtualMachine) map[string]interface{} {
+	vtpm := false
+	for _, d := range vm.Config.Hardware.Device {
+		if _, ok := d.(*types.VirtualTPM); ok {
+			vtpm = true
+			break
+		}
+	}
+
+	secureBoot := false
+	if b := vm.Config.BootOptions; b != nil && b.EfiSecureBootEnabled != nil {
+		secureBoot = *b.EfiSecureBootEnabled
+	}
+
+	return map[string]interface{}{
+		"encrypted":   vm.Config.KeyId != nil,
+		"vtpm":        vtpm,
+		"secure_boot": secureBoot,
+	}
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+)
+
+// SelfTestStep is the outcome of a step of SelfTest: connecting, finding the
+// default datacenter, or running a gatherer once.
+type SelfTestStep struct {
+	Endpoint string        `json:"endpoint"`
+	Step     string        `json:"step"`
+	Duration time.Duration `json:"duration_ns"`
+	// Detail is the product of the endpoint for connect, and the path of
+	// the datacenter for datacenter.
+	Detail   string     `json:"detail,omitempty"`
+	Metrics  int        `json:"metrics"`
+	Coverage []Coverage `json:"coverage,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Coverage is how many of the declared fields of a measurement the metrics
+// of a gatherer populated.
+type Coverage struct {
+	Measurement string `json:"measurement"`
+	Metrics     int    `json:"metrics"`
+	// Degraded are the metrics tagged degraded or unavailable, those of
+	// entities whose properties could not all be retrieved.
+	Degraded int `json:"degraded"`
+	Declared int `json:"declared"`
+	// Missing lists the declared fields no metric populated.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Passed returns whether the step succeeded and, for a gatherer, populated
+// every declared field of its measurements.
+func (s *SelfTestStep) Passed() bool {
+	if s.Error != "" {
+		return false
+	}
+	for _, c := range s.Coverage {
+		if len(c.Missing) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SelfTest connects to e and runs every gatherer once, timing each step and
+// checking the coverage of the fields of its metrics, to validate a new
+// vCenter version before rollout. Unlike collections, the metrics are
+// discarded, the filter of the collector is not applied and schema drift is
+// logged. Steps after a failed connect or datacenter step are not run; a
+// gatherer finding no entities passes, as do those skipped in direct mode.
+func (c *Collector) SelfTest(ctx context.Context, e *Endpoint) []SelfTestStep {
+	host := e.URL.Host
+
+	start := time.Now()
+	client, err := e.Client(ctx)
+	connect := SelfTestStep{Endpoint: host, Step: "connect", Duration: time.Since(start)}
+	if err != nil {
+		connect.Error = Redact(err.Error())
+		return []SelfTestStep{connect}
+	}
+	connect.Detail = client.ServiceContent.About.FullName
+	steps := []SelfTestStep{connect}
+	caps := e.Capabilities()
+
+	start = time.Now()
+	f := find.NewFinder(client.Client, true)
+	dc, err := f.DefaultDatacenter(ctx)
+	datacenter := SelfTestStep{Endpoint: host, Step: "datacenter", Duration: time.Since(start)}
+	if err != nil {
+		datacenter.Error = Redact(err.Error())
+		return append(steps, datacenter)
+	}
+	datacenter.Detail = dc.InventoryPath
+	steps = append(steps, datacenter)
+	f.SetDatacenter(dc)
+
+	for _, g := range Gatherers {
+		if reason := caps.unsupported(g.Name); reason != "" {
+			steps = append(steps, SelfTestStep{Endpoint: host, Step: g.Name, Detail: "skipped: " + reason})
+			continue
+		}
+		acc := &Accumulator{Time: time.Now(), Strict: StrictWarn}
+		start := time.Now()
+		err := runGatherer(ctx, g, client, f, e, acc)
+		s := SelfTestStep{
+			Endpoint: host,
+			Step:     g.Name,
+			Duration: time.Since(start),
+			Metrics:  len(acc.Metrics()),
+			Coverage: coverage(acc.Metrics()),
+		}
+		if err != nil && !isNotFound(err) {
+			s.Error = Redact(err.Error())
+		}
+		steps = append(steps, s)
+	}
+
+	e.Tags.Prune()
+	return steps
+}
+
+// coverage returns the coverage of every measurement of metrics, sorted by
+// measurement.
+func coverage(metrics []Metric) []Coverage {
+	type seen struct {
+		Coverage
+		fields map[string]bool
+	}
+	byName := make(map[string]*seen)
+	for _, m := range metrics {
+		s, ok := byName[m.Name]
+		if !ok {
+			s = &seen{Coverage: Coverage{Measurement: m.Name, Declared: len(Fields[m.Name])}, fields: make(map[string]bool)}
+			byName[m.Name] = s
+		}
+		s.Metrics++
+		if m.Tag("degraded") == "true" || m.Fields["available"] == int64(0) {
+			s.Degraded++
+		}
+		for k := range m.Fields {
+			s.fields[k] = true
+		}
+	}
+
+	out := make([]Coverage, 0, len(byName))
+	for name, s := range byName {
+		for k := range Fields[name] {
+			if !s.fields[k] {
+				s.Missing = append(s.Missing, k)
+			}
+		}
+		sort.Strings(s.Missing)
+		out = append(out, s.Coverage)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Measurement < out[j].Measurement })
+	return out
+}
@@ -0,0 +1,198 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// SnapshotEntry is a snapshot of a virtual machine.
+type SnapshotEntry struct {
+	Endpoint    string    `json:"endpoint"`
+	VM          string    `json:"vm"`
+	VMPath      string    `json:"vm_path"`
+	VMMOID      string    `json:"vm_moid"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	MOID        string    `json:"moid"`
+	Created     time.Time `json:"created"`
+	// SizeBytes is the size of the memory and state files of the snapshot
+	// and of the delta disks it froze, 0 if vCenter has no file layout of
+	// it.
+	SizeBytes int64 `json:"size_bytes"`
+	// Datastore is the datastore of the state file of the snapshot.
+	Datastore string `json:"datastore"`
+	// Attributes are the custom attributes of the virtual machine, by name.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Tags are the vSphere tags of the virtual machine, as category:tag.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Snapshots lists the snapshots of the virtual machines of every datacenter
+// of the endpoint of c.
+func Snapshots(ctx context.Context, c *govmomi.Client) ([]SnapshotEntry, error) {
+	f := find.NewFinder(c.Client, true)
+	pc := property.DefaultCollector(c.Client)
+
+	dcs, err := f.DatacenterList(ctx, "*")
+	if err != nil {
+		return nil, err
+	}
+	fields, err := customFieldNames(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []SnapshotEntry
+	for _, dc := range dcs {
+		f.SetDatacenter(dc)
+		vms, err := f.VirtualMachineList(ctx, "*")
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var refs []types.ManagedObjectReference
+		paths := make(map[types.ManagedObjectReference]string, len(vms))
+		for _, vm := range vms {
+			refs = append(refs, vm.Reference())
+			paths[vm.Reference()] = vm.InventoryPath
+		}
+
+		var vmt []mo.VirtualMachine
+		if err := pc.Retrieve(ctx, refs, []string{"name", "snapshot", "layoutEx", "customValue"}, &vmt); err != nil {
+			return nil, err
+		}
+
+		for _, vm := range vmt {
+			if vm.Snapshot == nil {
+				continue
+			}
+
+			var attributes map[string]string
+			for _, v := range vm.CustomValue {
+				if sv, ok := v.(*types.CustomFieldStringValue); ok && fields[sv.Key] != "" {
+					if attributes == nil {
+						attributes = make(map[string]string)
+					}
+					attributes[fields[sv.Key]] = sv.Value
+				}
+			}
+
+			parents := make(map[string]string)
+			walkSnapshotTree(vm.Snapshot.RootSnapshotList, "", func(t types.VirtualMachineSnapshotTree, parent string) {
+				parents[t.Snapshot.Value] = parent
+			})
+			sizes := snapshotSizes(vm.LayoutEx, parents)
+
+			walkSnapshotTree(vm.Snapshot.RootSnapshotList, "", func(t types.VirtualMachineSnapshotTree, _ string) {
+				s := sizes[t.Snapshot.Value]
+				entries = append(entries, SnapshotEntry{
+					Endpoint:    c.URL().Host,
+					VM:          vm.Name,
+					VMPath:      paths[vm.Reference()],
+					VMMOID:      vm.Reference().Value,
+					Name:        t.Name,
+					Description: t.Description,
+					MOID:        t.Snapshot.Value,
+					Created:     t.CreateTime,
+					SizeBytes:   s.size,
+					Datastore:   s.datastore,
+					Attributes:  attributes,
+				})
+			})
+		}
+	}
+	return entries, nil
+}
+
+// walkSnapshotTree calls fn for every snapshot of trees with the MOID of its
+// parent, "" for roots, parents first.
+func walkSnapshotTree(trees []types.VirtualMachineSnapshotTree, parent string, fn func(t types.VirtualMachineSnapshotTree, parent string)) {
+	for _, t := range trees {
+		fn(t, parent)
+		walkSnapshotTree(t.ChildSnapshotList, t.Snapshot.Value, fn)
+	}
+}
+
+type snapshotSize struct {
+	size      int64
+	datastore string
+}
+
+// snapshotSizes returns the size and datastore of the snapshots of layout by
+// snapshot MOID: the size of their state and memory files and of the disk
+// files of their chains the chains of their parent, from parents, do not
+// have. Root snapshots froze every disk file but the base disks.
+func snapshotSizes(layout *types.VirtualMachineFileLayoutEx, parents map[string]string) map[string]snapshotSize {
+	sizes := make(map[string]snapshotSize)
+	if layout == nil {
+		return sizes
+	}
+
+	files := make(map[int32]types.VirtualMachineFileLayoutExFileInfo, len(layout.File))
+	for _, f := range layout.File {
+		files[f.Key] = f
+	}
+	chains := make(map[string]map[int32]bool, len(layout.Snapshot))
+	for _, s := range layout.Snapshot {
+		chain := make(map[int32]bool)
+		for _, d := range s.Disk {
+			for _, unit := range d.Chain {
+				for _, k := range unit.FileKey {
+					chain[k] = true
+				}
+			}
+		}
+		chains[s.Key.Value] = chain
+	}
+
+	for _, s := range layout.Snapshot {
+		parent, ok := chains[parents[s.Key.Value]]
+		if !ok {
+			parent = make(map[int32]bool)
+			for _, d := range s.Disk {
+				if len(d.Chain) != 0 {
+					for _, k := range d.Chain[0].FileKey {
+						parent[k] = true
+					}
+				}
+			}
+		}
+
+		var size int64
+		for k := range chains[s.Key.Value] {
+			if !parent[k] {
+				size += files[k].Size
+			}
+		}
+
+		data := files[s.DataKey]
+		size += data.Size
+		if m, ok := files[s.MemoryKey]; ok && s.MemoryKey != s.DataKey && m.Type == "snapshotMemory" {
+			size += m.Size
+		}
+
+		sizes[s.Key.Value] = snapshotSize{size: size, datastore: datastoreName(data.Name)}
+	}
+	return sizes
+}
+
+// datastoreName returns the datastore of a datastore path such as
+// "[ds1] vm/vm.vmsn".
+func datastoreName(path string) string {
+	if !strings.HasPrefix(path, "[") {
+		return ""
+	}
+	if i := strings.Index(path, "]"); i > 0 {
+		return path[1:i]
+	}
+	return ""
+}
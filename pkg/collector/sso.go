@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/sts"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// SSOMode is how credentials are exchanged for a vCenter SSO SAML token.
+type SSOMode string
+
+const (
+	// SSONone logs in with the credentials directly.
+	SSONone SSOMode = ""
+	// SSOBearer logs in with a bearer token.
+	SSOBearer SSOMode = "bearer"
+	// SSOHolderOfKey logs in with a holder-of-key token, bound to the client
+	// certificate or to an ephemeral one.
+	SSOHolderOfKey SSOMode = "hok"
+)
+
+// ParseSSOMode parses an SSOMode.
+func ParseSSOMode(s string) (SSOMode, error) {
+	switch m := SSOMode(strings.ToLower(s)); m {
+	case SSONone, SSOBearer, SSOHolderOfKey:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid SSO mode %q", s)
+}
+
+// loginByToken logs in with a SAML token issued by the vCenter STS for req.
+func loginByToken(ctx context.Context, c *govmomi.Client, req sts.TokenRequest) error {
+	tokens, err := sts.NewClient(ctx, c.Client)
+	if err != nil {
+		return err
+	}
+
+	signer, err := tokens.Issue(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	header := soap.Header{Security: signer}
+	return c.SessionManager.LoginByToken(c.WithHeader(ctx, header))
+}
+
+// ephemeralCertificate returns a self-signed certificate to bind holder-of-key
+// tokens to, when no client certificate is configured.
+func ephemeralCertificate() (tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "vsphere-collector"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// diskIORecords sets the shares_level tag and the records of the storage I/O
+// allocation of a virtual disk: its IOPS limit, -1 for unlimited, its IOPS
+// reservation and its SIOC shares, those Storage I/O Control divides the
+// throughput of a congested datastore by.
+func diskIORecords(disk *types.VirtualDisk, tags map[string]string, records map[string]interface{}) {
+	records["iops_limit"] = int64(-1)
+	records["iops_reservation"] = int64(0)
+	records["shares"] = int64(0)
+
+	io := disk.StorageIOAllocation
+	if io == nil {
+		return
+	}
+	if io.Limit != nil {
+		records["iops_limit"] = *io.Limit
+	}
+	if io.Reservation != nil {
+		records["iops_reservation"] = int64(*io.Reservation)
+	}
+	if io.Shares != nil {
+		records["shares"] = int64(io.Shares.Shares)
+		tags["shares_level"] = string(io.Shares.Level)
+	}
+}
+
+// datastoreIORMRecords sets the records of the Storage I/O Control of a
+// datastore: whether it is enabled and its congestion threshold, the latency
+// beyond which it throttles virtual disks by their shares, both 0 for
+// datastores not reporting it.
+func datastoreIORMRecords(ds mo.Datastore, records map[string]interface{}) {
+	records["sioc_enabled"] = false
+	records["sioc_congestion_threshold_ms"] = int64(0)
+	if iorm := ds.IormConfiguration; iorm != nil {
+		records["sioc_enabled"] = iorm.Enabled
+		records["sioc_congestion_threshold_ms"] = int64(iorm.CongestionThreshold)
+	}
+}
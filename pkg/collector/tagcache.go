@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type tagEntry struct {
+	tags map[string]string
+	gen  uint64
+}
+
+// TagCache interns the tag sets of entities across collection cycles, so that
+// an unchanged entity reuses the map built for it in a previous cycle instead
+// of allocating a new one every interval.
+type TagCache struct {
+	mu      sync.Mutex
+	gen     uint64
+	entries map[types.ManagedObjectReference]*tagEntry
+}
+
+// NewTagCache returns an empty TagCache.
+func NewTagCache() *TagCache {
+	return &TagCache{
+		entries: make(map[types.ManagedObjectReference]*tagEntry),
+	}
+}
+
+// Intern returns the cached tag set of ref if it is equal to tags, otherwise
+// it stores and returns a copy of tags. The tags map itself is never retained,
+// so callers can reuse it as scratch space for the next entity.
+func (c *TagCache) Intern(ref types.ManagedObjectReference, tags map[string]string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[ref]
+	if ok && equalTags(e.tags, tags) {
+		e.gen = c.gen
+		return e.tags
+	}
+
+	m := make(map[string]string, len(tags))
+	for k, v := range tags {
+		m[k] = v
+	}
+	c.entries[ref] = &tagEntry{tags: m, gen: c.gen}
+
+	return m
+}
+
+// Prune drops the tag sets of entities that were not interned since the
+// previous call to Prune, e.g. removed virtual machines.
+func (c *TagCache) Prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for ref, e := range c.entries {
+		if e.gen != c.gen {
+			delete(c.entries, ref)
+		}
+	}
+	c.gen++
+}
+
+func equalTags(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+func resetTags(tags map[string]string) {
+	for k := range tags {
+		delete(tags, k)
+	}
+}
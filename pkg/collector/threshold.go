@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State is the state of a value against a Threshold.
+type State int
+
+const (
+	OK State = iota
+	Warning
+	Critical
+)
+
+func (s State) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warning:
+		return "WARNING"
+	case Critical:
+		return "CRITICAL"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Threshold is a warning and critical level of a numeric field.
+type Threshold struct {
+	Measurement string
+	Field       string
+	// Below is whether values below the levels breach them, rather than above.
+	Below    bool
+	Warning  float64
+	Critical float64
+}
+
+// ParseThreshold parses a threshold of the form measurement.field>warn:crit,
+// or < for values breaching below the levels, such as
+// "datastore.used_percent>85:95". Levels of fields ending in _sec may be
+// durations such as 7d or 36h.
+func ParseThreshold(s string) (Threshold, error) {
+	var t Threshold
+
+	i := strings.IndexAny(s, "<>")
+	if i < 0 {
+		return t, fmt.Errorf("invalid threshold %q: expected measurement.field>warn:crit", s)
+	}
+	t.Below = s[i] == '<'
+
+	name, levels := s[:i], s[i+1:]
+	j := strings.IndexByte(name, '.')
+	if j <= 0 || j == len(name)-1 {
+		return t, fmt.Errorf("invalid threshold %q: expected measurement.field", s)
+	}
+	t.Measurement, t.Field = name[:j], name[j+1:]
+
+	if _, ok := Fields[t.Measurement][t.Field]; !ok {
+		return t, fmt.Errorf("invalid threshold %q: unknown field", s)
+	}
+
+	warn, crit, ok := strings.Cut(levels, ":")
+	if !ok {
+		return t, fmt.Errorf("invalid threshold %q: expected warn:crit levels", s)
+	}
+
+	var err error
+	durations := strings.HasSuffix(t.Field, "_sec")
+	if t.Warning, err = parseLevel(warn, durations); err != nil {
+		return t, fmt.Errorf("invalid threshold %q: %s", s, err)
+	}
+	if t.Critical, err = parseLevel(crit, durations); err != nil {
+		return t, fmt.Errorf("invalid threshold %q: %s", s, err)
+	}
+	return t, nil
+}
+
+// parseLevel parses a number, or a duration in seconds if durations is set.
+// Durations may be in days, such as 7d.
+func parseLevel(s string, durations bool) (float64, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+	if !durations {
+		return 0, fmt.Errorf("invalid level %q", s)
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		v, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid level %q", s)
+		}
+		return v * 24 * 60 * 60, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid level %q", s)
+	}
+	return d.Seconds(), nil
+}
+
+func (t Threshold) String() string {
+	op := ">"
+	if t.Below {
+		op = "<"
+	}
+	return fmt.Sprintf("%s.%s%s%g:%g", t.Measurement, t.Field, op, t.Warning, t.Critical)
+}
+
+// State returns the state of v.
+func (t Threshold) State(v float64) State {
+	breaches := func(level float64) bool {
+		if t.Below {
+			return v < level
+		}
+		return v > level
+	}
+
+	switch {
+	case breaches(t.Critical):
+		return Critical
+	case breaches(t.Warning):
+		return Warning
+	}
+	return OK
+}
+
+// Result is the state of the field of a metric against a threshold.
+type Result struct {
+	Threshold Threshold
+	Metric    Metric
+	Value     float64
+	State     State
+}
+
+// Evaluate evaluates thresholds against every metric with the field they
+// apply to.
+func Evaluate(metrics []Metric, thresholds []Threshold) []Result {
+	var results []Result
+	for _, t := range thresholds {
+		for _, m := range metrics {
+			if m.Name != t.Measurement {
+				continue
+			}
+			v, ok := m.Float(t.Field)
+			if !ok {
+				continue
+			}
+			results = append(results, Result{Threshold: t, Metric: m, Value: v, State: t.State(v)})
+		}
+	}
+	return results
+}
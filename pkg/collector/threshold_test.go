@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	th, err := ParseThreshold("vm.snapshot_age_sec>7d:36h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Warning != 7*24*60*60 || th.Critical != 36*60*60 || th.Below {
+		t.Errorf("got %+v", th)
+	}
+
+	for _, s := range []string{"datastore.used_percent", "datastore>85:95", "datastore.used>85:95", "datastore.used_percent>85", "datastore.used_percent>1d:2d"} {
+		if _, err := ParseThreshold(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	th, err := ParseThreshold("datastore.used_percent>85:95")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var metrics []Metric
+	for _, used := range []float64{50, 90, 99} {
+		metrics = append(metrics, Metric{Name: "datastore", Fields: map[string]interface{}{"used_percent": used}, Time: time.Now()})
+	}
+	metrics = append(metrics, Metric{Name: "vm", Fields: map[string]interface{}{"used_percent": 99.0}})
+
+	results := Evaluate(metrics, []Threshold{th})
+	if len(results) != 3 {
+		t.Fatalf("results=%d, expected 3", len(results))
+	}
+	for i, expect := range []State{OK, Warning, Critical} {
+		if results[i].State != expect {
+			t.Errorf("%g: %s, expected %s", results[i].Value, results[i].State, expect)
+		}
+	}
+}
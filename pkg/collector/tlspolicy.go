@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// TLSPolicy restricts the TLS versions and cipher suites connections may
+// negotiate, e.g. to satisfy FIPS or corporate crypto policies. Combined with
+// a FIPS 140 enabled Go runtime, it limits connections to approved algorithms.
+type TLSPolicy struct {
+	// MinVersion is the minimum TLS version, 0 for the Go default.
+	MinVersion uint16
+	// CipherSuites lists the TLS 1.0-1.2 cipher suites allowed, nil for the
+	// Go defaults. TLS 1.3 suites are not configurable.
+	CipherSuites []uint16
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSPolicy parses a minimum version ("1.0" to "1.3") and a comma
+// separated list of cipher suite names, such as
+// TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384. Empty values keep the Go defaults.
+func ParseTLSPolicy(minVersion, ciphers string) (TLSPolicy, error) {
+	var p TLSPolicy
+
+	if minVersion != "" {
+		v, ok := tlsVersions[strings.TrimPrefix(minVersion, "TLS")]
+		if !ok {
+			return p, fmt.Errorf("invalid TLS version %q", minVersion)
+		}
+		p.MinVersion = v
+	}
+
+	suites := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		suites[s.Name] = s.ID
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		suites[s.Name] = s.ID
+	}
+
+	for _, name := range strings.Split(ciphers, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := suites[name]
+		if !ok {
+			return p, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		p.CipherSuites = append(p.CipherSuites, id)
+	}
+
+	return p, nil
+}
+
+// Apply restricts tc to the policy.
+func (p TLSPolicy) Apply(tc *tls.Config) {
+	if p.MinVersion != 0 {
+		tc.MinVersion = p.MinVersion
+	}
+	if p.CipherSuites != nil {
+		tc.CipherSuites = p.CipherSuites
+	}
+}
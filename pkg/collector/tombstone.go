@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Tombstoner notices the entities gone from the inventory across collection
+// cycles, marking the end of their series with a tombstone metric so that
+// dashboards end them rather than flat-lining their last value.
+type Tombstoner struct {
+	// entities are the tags of the entities of the previous cycle, by
+	// measurement and entity
+	entities map[tombstoneKey]map[string]string
+}
+
+type tombstoneKey struct {
+	measurement string
+	entity      EntityRef
+}
+
+// NewTombstoner returns a Tombstoner.
+func NewTombstoner() *Tombstoner {
+	return &Tombstoner{entities: make(map[tombstoneKey]map[string]string)}
+}
+
+// Bury returns a tombstone metric at ts for every entity of the previous
+// cycle missing from metrics, those of a cycle, sorted by measurement and
+// path. Entities are those of the metrics of the measurements of Gatherers;
+// those of a gatherer that failed against their endpoint, as of errs, are
+// carried over rather than buried.
+func (t *Tombstoner) Bury(metrics []Metric, errs Errors, ts time.Time) []Metric {
+	gatherers := make(map[string]bool, len(Gatherers))
+	for _, g := range Gatherers {
+		gatherers[g.Name] = true
+	}
+	failed := make(map[[2]string]bool)
+	for _, err := range errs {
+		var ce *CollectorError
+		if errors.As(err, &ce) {
+			failed[[2]string{ce.Endpoint, ce.Collector}] = true
+		}
+	}
+
+	entities := make(map[tombstoneKey]map[string]string, len(t.entities))
+	for _, m := range metrics {
+		if !gatherers[m.Name] || m.Entity == nil {
+			continue
+		}
+		tags := map[string]string{"measurement": m.Name, "name": m.Tags["name"]}
+		for _, k := range entityTags {
+			tags[k] = m.Tags[k]
+		}
+		entities[tombstoneKey{m.Name, *m.Entity}] = tags
+	}
+
+	var tombstones []Metric
+	for key, tags := range t.entities {
+		if _, ok := entities[key]; ok {
+			continue
+		}
+		if failed[[2]string{key.entity.VCenter, key.measurement}] || failed[[2]string{key.entity.VCenter, "connect"}] {
+			entities[key] = tags
+			continue
+		}
+
+		entity := key.entity
+		m, err := NewMetric("tombstone", tags, map[string]interface{}{"value": 1}, ts)
+		if err != nil {
+			continue
+		}
+		m.Entity = &entity
+		tombstones = append(tombstones, m)
+	}
+	t.entities = entities
+
+	sort.Slice(tombstones, func(i, j int) bool {
+		a, b := tombstones[i].Tags, tombstones[j].Tags
+		if a["measurement"] != b["measurement"] {
+			return a["measurement"] < b["measurement"]
+		}
+		return a["path"] < b["path"]
+	})
+	return tombstones
+}
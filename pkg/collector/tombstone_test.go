@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTombstonerBury(t *testing.T) {
+	vm := func(vcenter, moid string) Metric {
+		return Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": moid, "vcenter": vcenter, "moid": moid, "path": "/DC0/vm/" + moid},
+			Entity: &EntityRef{VCenter: vcenter, Type: "VirtualMachine", MOID: moid},
+		}
+	}
+	tb := NewTombstoner()
+	start := time.Now()
+
+	if metrics := tb.Bury([]Metric{vm("vc1", "vm-1"), vm("vc1", "vm-2"), vm("vc2", "vm-3")}, nil, start); len(metrics) != 0 {
+		t.Fatalf("first cycle %v", metrics)
+	}
+
+	// vc2 failed to collect its virtual machines, which are not buried
+	errs := Errors{&CollectorError{Endpoint: "vc2", Collector: "vm"}}
+	metrics := tb.Bury([]Metric{vm("vc1", "vm-1")}, errs, start.Add(time.Minute))
+	if len(metrics) != 1 {
+		t.Fatalf("%d tombstones, expected 1: %v", len(metrics), metrics)
+	}
+	m := metrics[0]
+	if m.Name != "tombstone" || m.Tags["measurement"] != "vm" || m.Tags["moid"] != "vm-2" || m.Entity.MOID != "vm-2" || m.Fields["value"] != int64(1) {
+		t.Errorf("tombstone %v", m)
+	}
+
+	if metrics := tb.Bury([]Metric{vm("vc1", "vm-1")}, nil, start.Add(2*time.Minute)); len(metrics) != 1 || metrics[0].Tags["moid"] != "vm-3" {
+		t.Errorf("third cycle %v, expected vm-3 buried", metrics)
+	}
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+)
+
+// rankings are the rankings of a Ranker: the field of the measurement virtual
+// machines are ranked by.
+var rankings = []struct {
+	ranking, measurement, field string
+}{
+	{"cpu_ready", "vm_perf", "cpu_ready_ms"},
+	{"balloon", "vm", "balloon_mem"},
+	{"storage", "vm", "storage_committed"},
+}
+
+// Ranker ranks the virtual machines of every cluster by their CPU ready time,
+// ballooned memory and committed storage, so that dashboards of large estates
+// don't sort the series of every virtual machine themselves.
+type Ranker struct {
+	// N is the virtual machines ranked per cluster and ranking.
+	N int
+	// Filter, if set, drops top fields.
+	Filter *FieldFilter
+}
+
+// NewRanker returns a Ranker of the top n virtual machines.
+func NewRanker(n int) *Ranker {
+	return &Ranker{N: n}
+}
+
+// Rank returns the top metrics of metrics, those of a cycle: for every
+// cluster and ranking, the N virtual machines of the highest values, tagged
+// with their rank from 1. The clusters of virtual machines are those of their
+// hosts in datastore_host metrics, "" for standalone hosts; values of 0 are
+// left out. Metrics are sorted by vcenter, cluster, ranking and rank.
+func (r *Ranker) Rank(metrics []Metric) []Metric {
+	if r.N <= 0 || !r.Filter.Keep("top", "value") {
+		return nil
+	}
+
+	type hostKey struct{ vcenter, host string }
+	clusters := make(map[hostKey]string)
+	for _, m := range metrics {
+		if m.Name == "datastore_host" {
+			clusters[hostKey{m.Tag("vcenter"), m.Tag("host_moid")}] = m.Tag("cluster")
+		}
+	}
+
+	type groupKey struct {
+		vcenter, cluster string
+		ranking          int
+	}
+	groups := make(map[groupKey][]Metric)
+	for _, m := range metrics {
+		if m.Entity == nil {
+			continue
+		}
+		for i, rk := range rankings {
+			if m.Name != rk.measurement {
+				continue
+			}
+			if v, ok := m.Float(rk.field); !ok || v <= 0 {
+				continue
+			}
+			k := groupKey{m.Tag("vcenter"), clusters[hostKey{m.Tag("vcenter"), m.Tag("host_moid")}], i}
+			groups[k] = append(groups[k], m)
+		}
+	}
+
+	keys := make([]groupKey, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].vcenter != keys[j].vcenter {
+			return keys[i].vcenter < keys[j].vcenter
+		}
+		if keys[i].cluster != keys[j].cluster {
+			return keys[i].cluster < keys[j].cluster
+		}
+		return keys[i].ranking < keys[j].ranking
+	})
+
+	var out []Metric
+	for _, k := range keys {
+		rk := rankings[k.ranking]
+		vms := groups[k]
+		sort.SliceStable(vms, func(i, j int) bool {
+			a, _ := vms[i].Float(rk.field)
+			b, _ := vms[j].Float(rk.field)
+			if a != b {
+				return a > b
+			}
+			return vms[i].Entity.MOID < vms[j].Entity.MOID
+		})
+		if len(vms) > r.N {
+			vms = vms[:r.N]
+		}
+
+		for i, m := range vms {
+			v, _ := m.Float(rk.field)
+			tags := map[string]string{
+				"vcenter": k.vcenter,
+				"cluster": k.cluster,
+				"ranking": rk.ranking,
+				"rank":    strconv.Itoa(i + 1),
+				"name":    m.Tag("name"),
+				"moid":    m.Tag("moid"),
+			}
+			tm, err := NewMetric("top", tags, map[string]interface{}{"value": v}, m.Time)
+			if err != nil {
+				slog.Warn("ranking virtual machines failed", "ranking", rk.ranking, "err", err)
+				continue
+			}
+			out = append(out, tm)
+		}
+	}
+	return out
+}
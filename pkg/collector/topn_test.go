@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRank(t *testing.T) {
+	now := time.Now()
+	vm := func(moid, host string, balloon int64) Metric {
+		return Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": moid, "vcenter": "vc", "moid": moid, "host_moid": host},
+			Fields: map[string]interface{}{"balloon_mem": balloon, "storage_committed": int64(0)},
+			Time:   now,
+			Entity: &EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: moid},
+		}
+	}
+	host := func(moid, cluster string) Metric {
+		return Metric{
+			Name: "datastore_host",
+			Tags: map[string]string{"vcenter": "vc", "host_moid": moid, "cluster": cluster},
+		}
+	}
+
+	metrics := []Metric{
+		host("host-1", "prod"), host("host-2", "prod"), host("host-3", "test"),
+		vm("vm-1", "host-1", 100), vm("vm-2", "host-2", 300), vm("vm-3", "host-1", 200),
+		vm("vm-4", "host-3", 50), vm("vm-5", "host-3", 0),
+	}
+	top := NewRanker(2).Rank(metrics)
+
+	expect := []struct{ cluster, rank, name string }{
+		{"prod", "1", "vm-2"},
+		{"prod", "2", "vm-3"},
+		{"test", "1", "vm-4"},
+	}
+	if len(top) != len(expect) {
+		t.Fatalf("%d top metrics, expected %d: %v", len(top), len(expect), top)
+	}
+	for i, e := range expect {
+		m := top[i]
+		if m.Tag("ranking") != "balloon" || m.Tag("cluster") != e.cluster || m.Tag("rank") != e.rank || m.Tag("name") != e.name {
+			t.Errorf("top %d: %v, expected %s %s of cluster %s", i, m.Tags, e.name, e.rank, e.cluster)
+		}
+	}
+	if v, _ := top[0].Float("value"); v != 300 {
+		t.Errorf("value %v, expected 300", v)
+	}
+}
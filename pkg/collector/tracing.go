@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces collections through the global OpenTelemetry tracer
+// provider, which does nothing unless the application sets one.
+var tracer = otel.Tracer("github.com/mlabouardy/vsphere-collector/pkg/collector")
+
+// endSpan ends span, recording err unless nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, Redact(err.Error()))
+	}
+	span.End()
+}
+
+// tracingRoundTripper traces every call made through its soap.RoundTripper as
+// a span.
+type tracingRoundTripper struct {
+	rt       soap.RoundTripper
+	endpoint string
+}
+
+func (t *tracingRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	method := methodName(req)
+	ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("rpc.system", "soap"),
+		attribute.String("rpc.method", method),
+		attribute.String("vsphere.endpoint", t.endpoint),
+	))
+	if target := auditTarget(req); target != "" {
+		span.SetAttributes(attribute.String("vsphere.target", target))
+	}
+
+	err := t.rt.RoundTrip(ctx, req, res)
+	if err == nil && res.Fault() != nil {
+		span.SetStatus(codes.Error, Redact(res.Fault().String))
+	}
+	endSpan(span, err)
+	return err
+}
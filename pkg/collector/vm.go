@@ -0,0 +1,449 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// vmProperties are the properties retrieved of virtual machines.
+var vmProperties = []string{"name", "config", "summary", "snapshot", "resourcePool"}
+
+// vmStateProperties are the properties retrieved of powered off virtual
+// machines when only powered on ones are collected: their summary but its
+// quick stats, leaving out their config and snapshots.
+var vmStateProperties = []string{"name", "summary.config", "summary.runtime", "summary.guest", "summary.storage", "summary.overallStatus", "resourcePool"}
+
+// GatherVMMetrics adds the metrics of virtual machines to acc.
+func GatherVMMetrics(ctx context.Context, c *govmomi.Client, pc *property.Collector, vms []*object.VirtualMachine, tc *TagCache, acc *Accumulator) error {
+	return gatherVMMetrics(ctx, c, pc, vms, tc, false, false, false, 0, acc)
+}
+
+// gatherVMMetrics adds the metrics of virtual machines to acc, along with
+// their storage policies and vm_disk metrics when policies is set, or their
+// vm_disk metrics alone when diskLimits is. Failing to resolve the policies
+// is logged, leaving them out, rather than failing the cycle. Powered off
+// virtual machines only have the vm metrics of their state when poweredOnOnly
+// is set, see vmStateRecords. Statistics are queried in batches beneath
+// maxMetrics, 0 for no limit.
+func gatherVMMetrics(ctx context.Context, c *govmomi.Client, pc *property.Collector, vms []*object.VirtualMachine, tc *TagCache, policies, diskLimits, poweredOnOnly bool, maxMetrics int, acc *Accumulator) error {
+	// Convert virtual machines into list of references
+	var refs []types.ManagedObjectReference
+	paths := make(map[types.ManagedObjectReference]string, len(vms))
+	for _, vm := range vms {
+		refs = append(refs, vm.Reference())
+		paths[vm.Reference()] = vm.InventoryPath
+	}
+
+	vmt, err := retrieveVMs(ctx, pc, refs, poweredOnOnly)
+	if err != nil {
+		return err
+	}
+	slog.Debug("retrieved virtual machines", "endpoint", c.URL().Host, "count", len(vmt))
+	pools := resourcePoolNames(ctx, c, pc, vmt)
+	var spbm map[string]StoragePolicy
+	if policies {
+		if spbm, err = storagePolicies(ctx, c, vmt); err != nil {
+			slog.Warn("retrieving storage policies failed", "endpoint", c.URL().Host, "err", err)
+		}
+	}
+
+	on := make(map[types.ManagedObjectReference]map[string]string)
+	scratch := make(map[string]string)
+	for _, vm := range vmt {
+		resetTags(scratch)
+		var records map[string]interface{}
+		if poweredOnOnly && vm.Summary.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+			records = vmStateRecords(vm, scratch)
+		} else {
+			records = VMRecords(vm, scratch)
+		}
+		scratch["vcenter"] = c.URL().Host
+		// Names are not unique across folders and datacenters
+		scratch["moid"] = vm.Reference().Value
+		scratch["path"] = paths[vm.Reference()]
+		if vm.ResourcePool != nil {
+			scratch["resource_pool"] = pools[*vm.ResourcePool]
+		}
+		if p, ok := spbm[vm.Reference().Value]; ok {
+			scratch["storage_policy"] = p.Name
+			scratch["storage_compliance"] = p.Compliance
+		}
+
+		tags := tc.Intern(vm.Reference(), scratch)
+		if err := acc.Add("vm", NewEntityRef(c.URL().Host, vm.Reference()), tags, records); err != nil {
+			return err
+		}
+		if vm.Config != nil {
+			if err := acc.add("vm_security", NewEntityRef(c.URL().Host, vm.Reference()), tags, VMSecurityRecords(vm), false); err != nil {
+				return err
+			}
+			if err := acc.add("vm_protection", NewEntityRef(c.URL().Host, vm.Reference()), tags, VMProtectionRecords(vm), false); err != nil {
+				return err
+			}
+			if policies || diskLimits {
+				if err := gatherVMDisks(c, vm, tags, spbm, acc); err != nil {
+					return err
+				}
+			}
+		}
+		if vm.Summary.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn {
+			on[vm.Reference()] = tags
+		}
+	}
+
+	return gatherVMPerf(ctx, c, on, maxMetrics, acc)
+}
+
+// retrieveVMs retrieves the vmProperties of the virtual machines of refs, or
+// only those of vmStateProperties of the powered off ones when poweredOnOnly
+// is set, those of powered on ones being retrieved again.
+func retrieveVMs(ctx context.Context, pc *property.Collector, refs []types.ManagedObjectReference, poweredOnOnly bool) ([]mo.VirtualMachine, error) {
+	var vmt []mo.VirtualMachine
+	if !poweredOnOnly {
+		err := pc.Retrieve(ctx, refs, vmProperties, &vmt)
+		return vmt, err
+	}
+
+	if err := pc.Retrieve(ctx, refs, vmStateProperties, &vmt); err != nil {
+		return nil, err
+	}
+	var on []types.ManagedObjectReference
+	off := vmt[:0]
+	for _, vm := range vmt {
+		if vm.Summary.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn {
+			on = append(on, vm.Reference())
+		} else {
+			off = append(off, vm)
+		}
+	}
+	if len(on) == 0 {
+		return off, nil
+	}
+
+	var ont []mo.VirtualMachine
+	if err := pc.Retrieve(ctx, on, vmProperties, &ont); err != nil {
+		return nil, err
+	}
+	return append(off, ont...), nil
+}
+
+// resourcePoolNames returns the names of the resource pools of vms by
+// reference. Failing to retrieve them is logged, leaving the resource_pool tag
+// out, rather than failing the cycle.
+func resourcePoolNames(ctx context.Context, c *govmomi.Client, pc *property.Collector, vms []mo.VirtualMachine) map[types.ManagedObjectReference]string {
+	seen := make(map[types.ManagedObjectReference]bool)
+	var refs []types.ManagedObjectReference
+	for _, vm := range vms {
+		if vm.ResourcePool != nil && !seen[*vm.ResourcePool] {
+			seen[*vm.ResourcePool] = true
+			refs = append(refs, *vm.ResourcePool)
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	var pools []mo.ResourcePool
+	if err := pc.Retrieve(ctx, refs, []string{"name"}, &pools); err != nil {
+		slog.Warn("retrieving resource pools failed", "endpoint", c.URL().Host, "err", err)
+		return nil
+	}
+	names := make(map[types.ManagedObjectReference]string, len(pools))
+	for _, p := range pools {
+		names[p.Reference()] = p.Name
+	}
+	return names
+}
+
+// gatherVMDisks adds a vm_disk metric per virtual disk of vm, which has its
+// config, with the tags of its vm metric, its storage policy of spbm and its
+// storage I/O allocation.
+func gatherVMDisks(c *govmomi.Client, vm mo.VirtualMachine, tags map[string]string, spbm map[string]StoragePolicy, acc *Accumulator) error {
+	for _, d := range vm.Config.Hardware.Device {
+		disk, ok := d.(*types.VirtualDisk)
+		if !ok {
+			continue
+		}
+		diskTags := map[string]string{"name": vm.Name}
+		for _, k := range entityTags {
+			diskTags[k] = tags[k]
+		}
+		if info := disk.DeviceInfo; info != nil {
+			diskTags["disk"] = info.GetDescription().Label
+		}
+		if b, ok := disk.Backing.(types.BaseVirtualDeviceFileBackingInfo); ok {
+			diskTags["datastore"] = datastoreName(b.GetVirtualDeviceFileBackingInfo().FileName)
+		}
+		if p, ok := spbm[diskKey(vm.Self.Value, disk.Key)]; ok {
+			diskTags["storage_policy"] = p.Name
+			diskTags["storage_compliance"] = p.Compliance
+		}
+		records := map[string]interface{}{"capacity_bytes": disk.CapacityInBytes}
+		diskIORecords(disk, diskTags, records)
+		if err := acc.add("vm_disk", NewEntityRef(c.URL().Host, vm.Reference()), diskTags, records, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vmPerfCounters are the real-time performance counters of the vm_perf
+// measurement, by field.
+var vmPerfCounters = map[string]string{
+	"net_usage_kbps":  "net.usage.average",
+	"disk_usage_kbps": "disk.usage.average",
+	// Over the 20 second real-time interval
+	"cpu_ready_ms": "cpu.ready.summation",
+}
+
+// gatherVMPerf adds the vm_perf metrics of the powered on virtual machines
+// of on, with the tags of their vm metric. Only powered on virtual machines
+// have real-time statistics; failing to query them is logged rather than
+// failing the cycle, as the statistics of vCenter lag behind its inventory.
+func gatherVMPerf(ctx context.Context, c *govmomi.Client, on map[types.ManagedObjectReference]map[string]string, maxMetrics int, acc *Accumulator) error {
+	refs := make([]types.ManagedObjectReference, 0, len(on))
+	for ref := range on {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Value < refs[j].Value })
+	counters := make([]string, 0, len(vmPerfCounters))
+	for _, counter := range vmPerfCounters {
+		counters = append(counters, counter)
+	}
+
+	samples, err := latestSamples(ctx, c, refs, counters, maxMetrics)
+	if err != nil {
+		slog.Warn("querying virtual machine statistics failed", "endpoint", c.URL().Host, "err", err)
+		return nil
+	}
+
+	for _, ref := range refs {
+		values, ok := samples[ref]
+		if !ok {
+			continue
+		}
+		records := make(map[string]interface{}, len(vmPerfCounters))
+		for field, counter := range vmPerfCounters {
+			if v, ok := values[counter]; ok {
+				records[field] = v
+			}
+		}
+		if len(records) != len(vmPerfCounters) {
+			continue
+		}
+		if err := acc.add("vm_perf", NewEntityRef(c.URL().Host, ref), on[ref], records, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VMRecords fills tags and returns the records of a single virtual machine.
+// Powered-off, orphaned or mid-clone virtual machines may lack their config or
+// parts of their summary; whatever is available is still returned and the
+// "degraded" tag is set.
+func VMRecords(vm mo.VirtualMachine, tags map[string]string) map[string]interface{} {
+	records := make(map[string]interface{}, len(Fields["vm"]))
+	degraded := false
+
+	tags["name"] = vm.Name
+	tags["connection_state"] = string(vm.Summary.Runtime.ConnectionState)
+	tags["overall_status"] = string(vm.Summary.OverallStatus)
+	tags["power_state"] = string(vm.Summary.Runtime.PowerState)
+	tags["guest_heartbeat"] = string(vm.Summary.QuickStats.GuestHeartbeatStatus)
+	tags["vm_path_name"] = vm.Summary.Config.VmPathName
+	if vm.Summary.Runtime.Host != nil {
+		tags["host_moid"] = vm.Summary.Runtime.Host.Value
+	}
+
+	if vm.Config != nil {
+		tags["guest_full_name"] = vm.Config.GuestFullName
+		tags["guest_id"] = vm.Config.GuestId
+		tags["os_family"], tags["os_version"] = NormalizeGuestOS(vm.Config.GuestId, vm.Config.GuestFullName)
+
+		records["mem_mb"] = vm.Config.Hardware.MemoryMB
+		records["num_cpu"] = vm.Config.Hardware.NumCPU
+		// Cores per socket left to be assigned at power on are 0
+		records["num_cores_per_socket"] = int32(0)
+		if n := vm.Config.Hardware.NumCoresPerSocket; n != nil {
+			records["num_cores_per_socket"] = *n
+		}
+		vmTopologyRecords(vm.Config, tags, records)
+
+		// Unknown creation dates, of virtual machines created before
+		// vSphere 6.7, are 0
+		records["created_timestamp"] = int64(0)
+		if vm.Config.CreateDate != nil {
+			records["created_timestamp"] = vm.Config.CreateDate.Unix()
+		}
+		records["modified_timestamp"] = vm.Config.Modified.Unix()
+		records["change_version"] = vm.Config.ChangeVersion
+	} else {
+		degraded = true
+	}
+
+	if vm.Summary.Guest != nil {
+		tags["ip_address"] = vm.Summary.Guest.IpAddress
+		tags["hostname"] = vm.Summary.Guest.HostName
+		tags["is_guest_tools_running"] = vm.Summary.Guest.ToolsRunningStatus
+	}
+
+	records["host_mem_usage"] = vm.Summary.QuickStats.HostMemoryUsage
+	records["guest_mem_usage"] = vm.Summary.QuickStats.GuestMemoryUsage
+	records["overall_cpu_usage"] = vm.Summary.QuickStats.OverallCpuUsage
+	records["overall_cpu_demand"] = vm.Summary.QuickStats.OverallCpuDemand
+	records["swap_mem"] = vm.Summary.QuickStats.SwappedMemory
+	records["balloon_mem"] = vm.Summary.QuickStats.BalloonedMemory
+	records["uptime_sec"] = vm.Summary.QuickStats.UptimeSeconds
+	records["max_cpu_usage"] = vm.Summary.Runtime.MaxCpuUsage
+	records["max_mem_usage"] = vm.Summary.Runtime.MaxMemoryUsage
+	records["question_pending"] = vm.Summary.Runtime.Question != nil
+
+	if vm.Summary.Storage != nil {
+		records["storage_committed"] = vm.Summary.Storage.Committed
+		records["storage_uncommitted"] = vm.Summary.Storage.Uncommitted
+	} else {
+		degraded = true
+	}
+
+	// The oldest snapshot is the one most likely forgotten
+	var snapshots int
+	var oldest time.Time
+	if vm.Snapshot != nil {
+		snapshots, oldest = walkSnapshots(vm.Snapshot.RootSnapshotList, oldest)
+	}
+	records["snapshots"] = snapshots
+	records["snapshot_age_sec"] = int64(0)
+	if snapshots != 0 {
+		records["snapshot_age_sec"] = int64(time.Since(oldest).Seconds())
+	}
+
+	if degraded {
+		tags["degraded"] = "true"
+	}
+
+	// Distinguishes a disconnected virtual machine from a removed one
+	if vm.Summary.Runtime.ConnectionState == types.VirtualMachineConnectionStateConnected {
+		records["available"] = 1
+	} else {
+		records["available"] = 0
+	}
+
+	return records
+}
+
+// vmStateRecords fills tags and returns the records of a powered off virtual
+// machine retrieved with vmStateProperties: those of VMRecords of its summary
+// alone, its size being that of its summary config, without quick stats or
+// snapshots.
+func vmStateRecords(vm mo.VirtualMachine, tags map[string]string) map[string]interface{} {
+	records := make(map[string]interface{}, len(Fields["vm"]))
+
+	tags["name"] = vm.Name
+	tags["connection_state"] = string(vm.Summary.Runtime.ConnectionState)
+	tags["overall_status"] = string(vm.Summary.OverallStatus)
+	tags["power_state"] = string(vm.Summary.Runtime.PowerState)
+	tags["vm_path_name"] = vm.Summary.Config.VmPathName
+	if vm.Summary.Runtime.Host != nil {
+		tags["host_moid"] = vm.Summary.Runtime.Host.Value
+	}
+	tags["guest_full_name"] = vm.Summary.Config.GuestFullName
+	tags["guest_id"] = vm.Summary.Config.GuestId
+	tags["os_family"], tags["os_version"] = NormalizeGuestOS(vm.Summary.Config.GuestId, vm.Summary.Config.GuestFullName)
+	if vm.Summary.Guest != nil {
+		tags["ip_address"] = vm.Summary.Guest.IpAddress
+		tags["hostname"] = vm.Summary.Guest.HostName
+		tags["is_guest_tools_running"] = vm.Summary.Guest.ToolsRunningStatus
+	}
+
+	records["mem_mb"] = vm.Summary.Config.MemorySizeMB
+	records["num_cpu"] = vm.Summary.Config.NumCpu
+	records["max_cpu_usage"] = vm.Summary.Runtime.MaxCpuUsage
+	records["max_mem_usage"] = vm.Summary.Runtime.MaxMemoryUsage
+	records["question_pending"] = vm.Summary.Runtime.Question != nil
+	if vm.Summary.Storage != nil {
+		records["storage_committed"] = vm.Summary.Storage.Committed
+		records["storage_uncommitted"] = vm.Summary.Storage.Uncommitted
+	} else {
+		tags["degraded"] = "true"
+	}
+
+	if vm.Summary.Runtime.ConnectionState == types.VirtualMachineConnectionStateConnected {
+		records["available"] = 1
+	} else {
+		records["available"] = 0
+	}
+	return records
+}
+
+// vmTopologyRecords fills the tags and records of the CPU topology and resource
+// settings of cfg: cores per vNUMA node, 0 when sized automatically, hot-add,
+// latency sensitivity, and CPU and memory reservations, limits, -1 for
+// unlimited, and shares.
+func vmTopologyRecords(cfg *types.VirtualMachineConfigInfo, tags map[string]string, records map[string]interface{}) {
+	coresPerNode := int64(0)
+	if cfg.NumaInfo != nil && cfg.NumaInfo.CoresPerNumaNode != nil {
+		coresPerNode = int64(*cfg.NumaInfo.CoresPerNumaNode)
+	}
+	// Set by hand before vSphere 8
+	for _, o := range cfg.ExtraConfig {
+		opt := o.GetOptionValue()
+		if s, ok := opt.Value.(string); ok && opt.Key == "numa.vcpu.maxPerVirtualNode" && coresPerNode == 0 {
+			coresPerNode, _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	records["cores_per_numa_node"] = coresPerNode
+	records["cpu_hot_add"] = cfg.CpuHotAddEnabled != nil && *cfg.CpuHotAddEnabled
+	records["memory_hot_add"] = cfg.MemoryHotAddEnabled != nil && *cfg.MemoryHotAddEnabled
+
+	tags["latency_sensitivity"] = string(types.LatencySensitivitySensitivityLevelNormal)
+	if cfg.LatencySensitivity != nil && cfg.LatencySensitivity.Level != "" {
+		tags["latency_sensitivity"] = string(cfg.LatencySensitivity.Level)
+	}
+
+	allocation := func(prefix string, a *types.ResourceAllocationInfo) {
+		reservation, limit, shares := int64(0), int64(-1), int64(0)
+		if a != nil {
+			if a.Reservation != nil {
+				reservation = *a.Reservation
+			}
+			if a.Limit != nil {
+				limit = *a.Limit
+			}
+			if a.Shares != nil {
+				shares = int64(a.Shares.Shares)
+			}
+		}
+		records[prefix+"_reservation"] = reservation
+		records[prefix+"_limit"] = limit
+		records[prefix+"_shares"] = shares
+	}
+	allocation("cpu", cfg.CpuAllocation)
+	allocation("mem", cfg.MemoryAllocation)
+}
+
+// walkSnapshots counts the snapshots of trees, returning their number and the
+// creation time of the oldest, or oldest if earlier.
+func walkSnapshots(trees []types.VirtualMachineSnapshotTree, oldest time.Time) (int, time.Time) {
+	n := 0
+	for _, t := range trees {
+		n++
+		if oldest.IsZero() || t.CreateTime.Before(oldest) {
+			oldest = t.CreateTime
+		}
+
+		var children int
+		children, oldest = walkSnapshots(t.ChildSnapshotList, oldest)
+		n += children
+	}
+	return n, oldest
+}
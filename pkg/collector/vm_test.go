@@ -0,0 +1,498 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func benchmarkVM() mo.VirtualMachine {
+	var vm mo.VirtualMachine
+
+	vm.Name = "DC0_H0_VM0"
+	vm.Config = &types.VirtualMachineConfigInfo{
+		GuestFullName: "Other Linux (64-bit)",
+		GuestId:       "otherLinux64Guest",
+	}
+	vm.Config.Hardware.MemoryMB = 4096
+	vm.Config.Hardware.NumCPU = 2
+	vm.Config.Hardware.NumCoresPerSocket = types.NewInt32(1)
+	vm.Summary.Guest = &types.VirtualMachineGuestSummary{
+		IpAddress:          "10.0.0.10",
+		HostName:           "vm0.example.com",
+		ToolsRunningStatus: "guestToolsRunning",
+	}
+	vm.Summary.Storage = &types.VirtualMachineStorageSummary{
+		Committed:   1 << 30,
+		Uncommitted: 4 << 30,
+	}
+	vm.Summary.Config.VmPathName = "[LocalDS_0] DC0_H0_VM0/DC0_H0_VM0.vmx"
+	vm.Summary.Runtime.ConnectionState = types.VirtualMachineConnectionStateConnected
+	vm.Summary.OverallStatus = types.ManagedEntityStatusGreen
+
+	return vm
+}
+
+func TestVMRecordsNilConfig(t *testing.T) {
+	vm := benchmarkVM()
+	vm.Config = nil
+	vm.Summary.Guest = nil
+
+	tags := make(map[string]string)
+	records := VMRecords(vm, tags)
+
+	if tags["degraded"] != "true" {
+		t.Errorf("degraded=%q", tags["degraded"])
+	}
+	if _, ok := records["mem_mb"]; ok {
+		t.Error("unexpected mem_mb record")
+	}
+	if _, ok := records["storage_committed"]; !ok {
+		t.Error("missing storage_committed record")
+	}
+}
+
+func TestHostRecordsNilHardware(t *testing.T) {
+	var host mo.HostSystem
+	host.Runtime.ConnectionState = types.HostSystemConnectionStateConnected
+	host.Summary.QuickStats.Uptime = 60
+
+	tags := make(map[string]string)
+	records := HostRecords(host, tags)
+
+	if tags["degraded"] != "true" {
+		t.Errorf("degraded=%q", tags["degraded"])
+	}
+	if _, ok := records["cpu_mhz"]; ok {
+		t.Error("unexpected cpu_mhz record")
+	}
+	if records["uptime_sec"] != int32(60) {
+		t.Errorf("uptime_sec=%v", records["uptime_sec"])
+	}
+}
+
+func TestSnapshotSizes(t *testing.T) {
+	unit := func(keys ...int32) types.VirtualMachineFileLayoutExDiskUnit {
+		return types.VirtualMachineFileLayoutExDiskUnit{FileKey: keys}
+	}
+	disk := func(units ...types.VirtualMachineFileLayoutExDiskUnit) []types.VirtualMachineFileLayoutExDiskLayout {
+		return []types.VirtualMachineFileLayoutExDiskLayout{{Key: 2000, Chain: units}}
+	}
+
+	layout := &types.VirtualMachineFileLayoutEx{
+		File: []types.VirtualMachineFileLayoutExFileInfo{
+			{Key: 1, Name: "[ds1] vm/vm-flat.vmdk", Size: 100},
+			{Key: 2, Name: "[ds1] vm/vm-000001-delta.vmdk", Size: 10},
+			{Key: 3, Name: "[ds1] vm/vm-Snapshot1.vmsn", Size: 1},
+			{Key: 4, Name: "[ds2] vm/vm-Snapshot2.vmsn", Size: 2},
+		},
+		Snapshot: []types.VirtualMachineFileLayoutExSnapshotLayout{
+			{Key: types.ManagedObjectReference{Value: "snapshot-1"}, DataKey: 3, Disk: disk(unit(1))},
+			{Key: types.ManagedObjectReference{Value: "snapshot-2"}, DataKey: 4, Disk: disk(unit(1), unit(2))},
+		},
+	}
+
+	sizes := snapshotSizes(layout, map[string]string{"snapshot-1": "", "snapshot-2": "snapshot-1"})
+	expect := map[string]snapshotSize{
+		"snapshot-1": {size: 1, datastore: "ds1"},
+		"snapshot-2": {size: 12, datastore: "ds2"},
+	}
+	for moid, e := range expect {
+		if sizes[moid] != e {
+			t.Errorf("%s %+v, expected %+v", moid, sizes[moid], e)
+		}
+	}
+}
+
+func BenchmarkVMRecords(b *testing.B) {
+	vm := benchmarkVM()
+
+	b.ReportAllocs()
+	tags := make(map[string]string)
+	for i := 0; i < b.N; i++ {
+		resetTags(tags)
+		VMRecords(vm, tags)
+	}
+}
+
+func BenchmarkGatherVMMetrics(b *testing.B) {
+	ctx := context.Background()
+	_, e := newSimulator(b, 500)
+
+	c, err := NewClient(ctx, e.URL, e.Options)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	f := find.NewFinder(c.Client, true)
+	dc, err := f.DefaultDatacenter(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	f.SetDatacenter(dc)
+
+	vms, err := f.VirtualMachineList(ctx, "*")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	pc := property.DefaultCollector(c.Client)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		acc := &Accumulator{Time: time.Now()}
+		if err := GatherVMMetrics(ctx, c, pc, vms, e.Tags, acc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSecurityRecords(t *testing.T) {
+	vm := benchmarkVM()
+	vm.Config.KeyId = &types.CryptoKeyId{KeyId: "key"}
+	vm.Config.Hardware.Device = []types.BaseVirtualDevice{&types.VirtualTPM{}}
+	records := VMSecurityRecords(vm)
+	if records["encrypted"] != true || records["vtpm"] != true || records["secure_boot"] != false {
+		t.Errorf("vm records %v", records)
+	}
+
+	var host mo.HostSystem
+	allow := true
+	host.Config = &types.HostConfigInfo{
+		LockdownMode: types.HostLockdownModeLockdownStrict,
+		Service:      &types.HostServiceInfo{Service: []types.HostService{{Key: "TSM-SSH", Running: true}}},
+		Network: &types.HostNetworkInfo{Portgroup: []types.HostPortGroup{
+			{ComputedPolicy: types.HostNetworkPolicy{Security: &types.HostNetworkSecurityPolicy{AllowPromiscuous: &allow}}},
+			{ComputedPolicy: types.HostNetworkPolicy{}},
+		}},
+	}
+	records = HostSecurityRecords(host)
+	if records["lockdown_level"] != 2 || records["ssh_enabled"] != true || records["shell_enabled"] != false || records["promiscuous_portgroups"] != 1 {
+		t.Errorf("host records %v", records)
+	}
+}
+
+func TestHostFirmwareInfo(t *testing.T) {
+	var host mo.HostSystem
+	host.Hardware = &types.HostHardwareInfo{BiosInfo: &types.HostBIOSInfo{BiosVersion: "U46", Vendor: "HPE"}}
+	host.Config = &types.HostConfigInfo{
+		Network: &types.HostNetworkInfo{Pnic: []types.PhysicalNic{{Device: "vmnic0", Driver: "i40en", DriverVersion: "2.1.5.0", FirmwareVersion: "8.50"}}},
+		StorageDevice: &types.HostStorageDeviceInfo{HostBusAdapter: []types.BaseHostHostBusAdapter{
+			&types.HostFibreChannelHba{HostHostBusAdapter: types.HostHostBusAdapter{Device: "vmhba1", Driver: "lpfc"}},
+		}},
+	}
+
+	infos := HostFirmwareInfo(host)
+	if len(infos) != 3 {
+		t.Fatalf("%d infos, expected 3", len(infos))
+	}
+	if infos[0].Measurement != "host_firmware" || infos[0].Tags["bios_version"] != "U46" {
+		t.Errorf("bios %+v", infos[0])
+	}
+	if infos[1].Measurement != "host_nic" || infos[1].Tags["driver_version"] != "2.1.5.0" || infos[1].Tags["firmware_version"] != "8.50" {
+		t.Errorf("nic %+v", infos[1])
+	}
+	if infos[2].Measurement != "host_hba" || infos[2].Tags["type"] != "fc" || infos[2].Tags["driver"] != "lpfc" {
+		t.Errorf("hba %+v", infos[2])
+	}
+}
+
+func TestVMProtection(t *testing.T) {
+	vm := benchmarkVM()
+	vm.Summary.Runtime.FaultToleranceState = types.VirtualMachineFaultToleranceStateRunning
+	vm.Config.ExtraConfig = []types.BaseOptionValue{
+		&types.OptionValue{Key: "hbr_filter.destination", Value: "10.0.0.20"},
+		&types.OptionValue{Key: "hbr_filter.rpo", Value: "15"},
+	}
+	records := VMProtectionRecords(vm)
+	if records["ft_state"] != "running" || records["ft_protected"] != true || records["replicated"] != true || records["rpo_minutes"] != 15 {
+		t.Errorf("records %v", records)
+	}
+
+	ref := EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-1"}
+	m, err := NewMetric("vm_protection", nil, records, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Entity = &ref
+
+	tr := NewRPOTracker()
+	tr.Track([]Event{{Type: "com.vmware.vcHms.rpoViolatedEvent", Entity: &ref}}, []Metric{m})
+	if m.Fields["rpo_violated"] != true {
+		t.Error("rpo_violated not set")
+	}
+}
+
+func TestHostMemoryTiers(t *testing.T) {
+	var host mo.HostSystem
+	host.Hardware = &types.HostHardwareInfo{PersistentMemoryInfo: &types.HostPersistentMemoryInfo{CapacityInMB: 1024}}
+	if tiering, tiers := HostMemoryTiers(host); tiering != "" || len(tiers) != 1 || tiers[0].Type != "PMem" || tiers[0].SizeBytes != 1<<30 {
+		t.Errorf("pmem tiering %q tiers %+v", tiering, tiers)
+	}
+
+	host.Hardware = &types.HostHardwareInfo{
+		MemoryTieringType: "hardwareMemoryTiering",
+		MemoryTierInfo: []types.HostMemoryTierInfo{
+			{Name: "DRAM", Type: "DRAM", Size: 64 << 30},
+			{Name: "NVMe", Type: "NVMe", Size: 256 << 30},
+		},
+	}
+	if tiering, tiers := HostMemoryTiers(host); tiering != "hardwareMemoryTiering" || len(tiers) != 2 || tiers[1].SizeBytes != 256<<30 {
+		t.Errorf("tiering %q tiers %+v", tiering, tiers)
+	}
+}
+
+func TestHostNUMA(t *testing.T) {
+	host := mo.HostSystem{
+		Hardware: &types.HostHardwareInfo{
+			NumaInfo: &types.HostNumaInfo{
+				NumNodes: 2,
+				NumaNode: []types.HostNumaNode{
+					{TypeId: 0, CpuID: []int16{0, 1, 2, 3}, MemoryRangeLength: 64 << 30},
+					{TypeId: 1, CpuID: []int16{4, 5, 6, 7}, MemoryRangeLength: 32 << 30},
+				},
+			},
+		},
+		Config: &types.HostConfigInfo{
+			HyperThread: &types.HostHyperThreadScheduleInfo{Available: true, Active: false, Config: true},
+		},
+	}
+
+	nodes := HostNUMANodes(host)
+	if len(nodes) != 2 || nodes[1] != (NUMANode{ID: 1, CPUs: 4, MemoryBytes: 32 << 30}) {
+		t.Errorf("nodes %+v", nodes)
+	}
+
+	records := HostNUMARecords(host)
+	if records["numa_nodes"] != int64(2) || records["hyperthreading_available"] != true || records["hyperthreading_active"] != false || records["hyperthreading_configured"] != true {
+		t.Errorf("records %v", records)
+	}
+	if _, err := NewMetric("host_numa", nil, records, time.Now()); err != nil {
+		t.Error(err)
+	}
+
+	if records := HostNUMARecords(mo.HostSystem{}); records["numa_nodes"] != int64(0) || records["hyperthreading_active"] != false {
+		t.Errorf("records of a host without hardware %v", records)
+	}
+}
+
+func TestVMTopologyRecords(t *testing.T) {
+	vm := benchmarkVM()
+	enabled := true
+	reservation := int64(2000)
+	vm.Config.CpuHotAddEnabled = &enabled
+	vm.Config.LatencySensitivity = &types.LatencySensitivity{Level: types.LatencySensitivitySensitivityLevelHigh}
+	vm.Config.CpuAllocation = &types.ResourceAllocationInfo{Reservation: &reservation, Shares: &types.SharesInfo{Shares: 2000}}
+	vm.Config.ExtraConfig = []types.BaseOptionValue{&types.OptionValue{Key: "numa.vcpu.maxPerVirtualNode", Value: "4"}}
+
+	tags := make(map[string]string)
+	records := VMRecords(vm, tags)
+	if tags["latency_sensitivity"] != "high" {
+		t.Errorf("latency_sensitivity=%q", tags["latency_sensitivity"])
+	}
+	expect := map[string]interface{}{
+		"cores_per_numa_node": int64(4), "cpu_hot_add": true, "memory_hot_add": false,
+		"cpu_reservation": int64(2000), "cpu_shares": int64(2000), "mem_limit": int64(-1),
+	}
+	for k, v := range expect {
+		if records[k] != v {
+			t.Errorf("%s=%v, expected %v", k, records[k], v)
+		}
+	}
+}
+
+func TestNormalizeGuestOS(t *testing.T) {
+	for _, c := range []struct{ id, name, family, version string }{
+		{"windows2019srv_64Guest", "Microsoft Windows Server 2019 (64-bit)", "windows", "2019"},
+		{"windows9Server64Guest", "Microsoft Windows Server 2016 (64-bit)", "windows", "2016"},
+		{"windows9_64Guest", "Microsoft Windows 10 (64-bit)", "windows", "10"},
+		{"windowsHyperVGuest", "Microsoft Windows Server 2012 (64-bit)", "windows", "2012"},
+		{"rhel8_64Guest", "Red Hat Enterprise Linux 8 (64-bit)", "linux", "rhel8"},
+		{"ubuntu64Guest", "Ubuntu Linux (64-bit)", "linux", "ubuntu"},
+		{"otherLinux64Guest", "Other Linux (64-bit)", "linux", ""},
+		{"freebsd13_64Guest", "FreeBSD 13 (64-bit)", "bsd", "freebsd13"},
+		{"otherGuest64", "Other (64-bit)", "other", ""},
+		{"", "Microsoft Windows Server 2022 (64-bit)", "windows", ""},
+	} {
+		if family, version := NormalizeGuestOS(c.id, c.name); family != c.family || version != c.version {
+			t.Errorf("%s: %s/%s, expected %s/%s", c.id, family, version, c.family, c.version)
+		}
+	}
+}
+
+func TestHostHardwareTags(t *testing.T) {
+	var host mo.HostSystem
+	host.Hardware = &types.HostHardwareInfo{SystemInfo: types.HostSystemInfo{
+		Uuid: "4c4c4544",
+		OtherIdentifyingInfo: []types.HostSystemIdentificationInfo{
+			{IdentifierValue: "7XK2H3", IdentifierType: &types.ElementDescription{Key: "ServiceTag"}},
+			{IdentifierValue: "CZ1234", IdentifierType: &types.ElementDescription{Key: "SerialNumberTag"}},
+		},
+	}}
+
+	tags := HostHardwareTags(host, "10.0.0.99")
+	if tags["service_tag"] != "7XK2H3" || tags["serial_number"] != "CZ1234" || tags["bmc_address"] != "10.0.0.99" || tags["uuid"] != "4c4c4544" {
+		t.Errorf("tags %v", tags)
+	}
+}
+
+func TestClusterRecords(t *testing.T) {
+	host := func(version string, maintenance, reboot bool) mo.HostSystem {
+		var h mo.HostSystem
+		h.Summary.Config.Product = &types.AboutInfo{Version: version, Build: "1"}
+		h.Summary.RebootRequired = reboot
+		h.Runtime.InMaintenanceMode = maintenance
+		h.Runtime.ConnectionState = types.HostSystemConnectionStateConnected
+		return h
+	}
+
+	var cluster mo.ClusterComputeResource
+	cluster.Name = "DC0_C0"
+	cluster.Summary = &types.ClusterComputeResourceSummary{CurrentEVCModeKey: "intel-icelake"}
+
+	tags := make(map[string]string)
+	records := ClusterRecords(cluster, []mo.HostSystem{host("8.0.2", false, false), host("8.0.3", true, true), host("8.0.3", false, false)}, tags)
+	if tags["evc_mode"] != "intel-icelake" {
+		t.Errorf("evc_mode=%q", tags["evc_mode"])
+	}
+	expect := map[string]interface{}{
+		"num_hosts": 3, "evc_enabled": true, "esxi_builds": 2, "mixed_versions": true,
+		"hosts_in_maintenance": 1, "hosts_reboot_required": 1,
+	}
+	for k, v := range expect {
+		if records[k] != v {
+			t.Errorf("%s=%v, expected %v", k, records[k], v)
+		}
+	}
+}
+
+func TestClusterPowerRecords(t *testing.T) {
+	host := func(state types.HostSystemPowerState, policy string) mo.HostSystem {
+		var h mo.HostSystem
+		h.Runtime.PowerState = state
+		h.Config = &types.HostConfigInfo{}
+		if policy != "" {
+			h.Config.PowerSystemInfo = &types.PowerSystemInfo{}
+			h.Config.PowerSystemInfo.CurrentPolicy.ShortName = policy
+		}
+		return h
+	}
+
+	enabled, disabled := true, false
+	var cluster mo.ClusterComputeResource
+	cluster.Name = "DC0_C0"
+	cluster.ConfigurationEx = &types.ClusterConfigInfoEx{
+		DpmConfigInfo: &types.ClusterDpmConfigInfo{Enabled: &enabled, DefaultDpmBehavior: types.DpmBehaviorAutomated, HostPowerActionRate: 3},
+		DpmHostConfig: []types.ClusterDpmHostConfigInfo{{Enabled: &disabled}, {Enabled: &enabled}},
+	}
+
+	tags := make(map[string]string)
+	hosts := []mo.HostSystem{
+		host(types.HostSystemPowerStatePoweredOn, "dynamic"),
+		host(types.HostSystemPowerStatePoweredOn, "static"),
+		host(types.HostSystemPowerStateStandBy, "dynamic"),
+		host(types.HostSystemPowerStatePoweredOff, ""),
+	}
+	records := ClusterPowerRecords(cluster, hosts, tags)
+	if tags["dpm_behavior"] != "automated" {
+		t.Errorf("dpm_behavior=%q", tags["dpm_behavior"])
+	}
+	expect := map[string]interface{}{
+		"dpm_enabled": true, "dpm_threshold": int32(3), "hosts_dpm_disabled": 1, "hosts_standby": 1, "hosts_powered_off": 1,
+		"hosts_high_performance": 1, "hosts_balanced": 2, "hosts_low_power": 0, "hosts_custom_policy": 0,
+	}
+	for k, v := range expect {
+		if records[k] != v {
+			t.Errorf("%s=%v, expected %v", k, records[k], v)
+		}
+	}
+	if _, err := NewMetric("cluster_power", tags, records, time.Now()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVMProblem(t *testing.T) {
+	vm := func(question bool, power, tools, heartbeat string) Metric {
+		return Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"power_state": power, "is_guest_tools_running": tools, "guest_heartbeat": heartbeat},
+			Fields: map[string]interface{}{"question_pending": question},
+		}
+	}
+
+	tests := []struct {
+		m      Metric
+		expect string
+	}{
+		{vm(false, "poweredOn", "guestToolsRunning", "green"), ""},
+		{vm(true, "poweredOn", "guestToolsRunning", "green"), "question_pending"},
+		{vm(false, "poweredOn", "guestToolsNotRunning", "gray"), "tools_stopped"},
+		{vm(false, "poweredOff", "guestToolsNotRunning", "gray"), ""},
+		{vm(false, "poweredOn", "guestToolsRunning", "red"), "heartbeat_lost"},
+	}
+	for i, test := range tests {
+		if problem := VMProblem(test.m); problem != test.expect {
+			t.Errorf("%d: problem %q, expected %q", i, problem, test.expect)
+		}
+	}
+}
+
+func TestLimitSeries(t *testing.T) {
+	metric := func(name string, fields int) Metric {
+		m := Metric{Name: name, Fields: make(map[string]interface{}, fields)}
+		for i := 0; i < fields; i++ {
+			m.Fields[string(rune('a'+i))] = int64(i)
+		}
+		return m
+	}
+	metrics := []Metric{metric("vm", 3), metric("vm_perf", 2), metric("vm", 3), metric("vm_perf", 2)}
+
+	if kept, dropped := LimitSeries(metrics, 0); len(kept) != 4 || dropped != 0 {
+		t.Errorf("no limit: %d kept, %d dropped", len(kept), dropped)
+	}
+	if kept, dropped := LimitSeries(metrics, 8); len(kept) != 3 || dropped != 2 || kept[1].Name != "vm_perf" || kept[2].Name != "vm" {
+		t.Errorf("limit 8: %v kept, %d dropped", kept, dropped)
+	}
+	if kept, dropped := LimitSeries(metrics, 4); len(kept) != 1 || dropped != 7 || kept[0].Name != "vm" {
+		t.Errorf("limit 4: %v kept, %d dropped", kept, dropped)
+	}
+
+	// The collector's own metrics survive core ones exceeding the limit
+	metrics = []Metric{metric("cycle", 2), metric("vm", 3), metric("guardrail", 2), metric("vm", 3), metric("vm_perf", 2)}
+	kept, dropped := LimitSeries(metrics, 5)
+	if len(kept) != 2 || dropped != 8 || kept[0].Name != "cycle" || kept[1].Name != "guardrail" {
+		t.Errorf("limit 5: %v kept, %d dropped", kept, dropped)
+	}
+	if kept, dropped := LimitSeries(metrics, 7); len(kept) != 3 || dropped != 5 || kept[1].Name != "vm" {
+		t.Errorf("limit 7: %v kept, %d dropped", kept, dropped)
+	}
+}
+
+func TestDiskIORecords(t *testing.T) {
+	limit, reservation := int64(500), int32(100)
+	disk := &types.VirtualDisk{
+		StorageIOAllocation: &types.StorageIOAllocationInfo{
+			Limit:       &limit,
+			Reservation: &reservation,
+			Shares:      &types.SharesInfo{Shares: 2000, Level: types.SharesLevelHigh},
+		},
+	}
+	tags := make(map[string]string)
+	records := make(map[string]interface{})
+	diskIORecords(disk, tags, records)
+	if records["iops_limit"] != int64(500) || records["iops_reservation"] != int64(100) || records["shares"] != int64(2000) || tags["shares_level"] != "high" {
+		t.Errorf("records %v, tags %v", records, tags)
+	}
+
+	clear(records)
+	diskIORecords(&types.VirtualDisk{}, tags, records)
+	if records["iops_limit"] != int64(-1) || records["shares"] != int64(0) {
+		t.Errorf("records of an unthrottled disk %v", records)
+	}
+}
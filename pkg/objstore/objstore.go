@@ -0,0 +1,63 @@
+// Package objstore stores objects, such as console screenshots or batches of
+// metrics, in a directory or an S3-compatible bucket.
+//
+//	store, err := objstore.Open("s3://bucket/prefix?region=eu-west-1")
+//	...
+//	err = store.Put(ctx, "vc/vm-42.png", data, "image/png")
+package objstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store stores objects by key, a slash-separated path.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// Open returns the Store of location: an s3:// URL, see NewS3, or else a
+// directory.
+func Open(location string) (Store, error) {
+	if strings.HasPrefix(location, "s3://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3(u)
+	}
+	if location == "" {
+		return nil, fmt.Errorf("missing object store location")
+	}
+	return Dir(location), nil
+}
+
+// Dir stores objects as files below a directory, creating their parent
+// directories.
+type Dir string
+
+// Put writes data to the file of key, replacing it atomically.
+func (d Dir) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,171 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// S3 stores objects in a bucket of Amazon S3 or an S3-compatible object
+// storage, such as MinIO, signing requests with AWS Signature Version 4.
+type S3 struct {
+	// Endpoint is the base URL of the object storage, objects being
+	// addressed by path: Endpoint/Bucket/Prefix/key.
+	Endpoint *url.URL
+	Bucket   string
+	Prefix   string
+	Region   string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// NewS3 returns the S3 store of u, s3://bucket/prefix, with optional region
+// and endpoint query parameters, defaulting to AWS_REGION and the AWS
+// endpoint of the region. Credentials are AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func NewS3(u *url.URL) (*S3, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing bucket in %q", u.Redacted())
+	}
+
+	s := &S3{
+		Bucket:       u.Host,
+		Prefix:       strings.Trim(u.Path, "/"),
+		Region:       u.Query().Get("region"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.Region == "" {
+		s.Region = os.Getenv("AWS_REGION")
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return nil, fmt.Errorf("missing AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY")
+	}
+	collector.AddSecret(s.SecretKey)
+
+	endpoint := u.Query().Get("endpoint")
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	var err error
+	if s.Endpoint, err = url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put uploads data as the object of key below the prefix.
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	u := *s.Endpoint
+	u.Path = "/" + path.Join(s.Bucket, s.Prefix, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, data, time.Now())
+
+	res, err := collector.Outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("putting %s: %s: %s", u.Path, res.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// sign sets the Authorization header of req, with a body of payload, at t.
+func (s *S3) sign(req *http.Request, payload []byte, t time.Time) {
+	t = t.UTC()
+	date := t.Format("20060102")
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", hash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	var names []string
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+	signed := strings.Join(names, ";")
+
+	// The path is sent as signed, EscapedPath leaving characters such as the
+	// = of Hive partitions unescaped
+	uri := uriEncode(req.URL.Path)
+	req.URL.RawPath = uri
+
+	canonical := strings.Join([]string{
+		req.Method,
+		uri,
+		req.URL.Query().Encode(),
+		headers.String(),
+		signed,
+		hash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	sum = sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{date, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Del("Host")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// uriEncode returns p percent-encoded as the canonical URI of a signature,
+// every byte escaped but the unreserved A-Za-z0-9-._~ and the slashes between
+// segments.
+func uriEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
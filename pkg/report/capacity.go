@@ -0,0 +1,218 @@
+package report
+
+import (
+	"path"
+	"sort"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Capacity is the utilization of clusters and datastores as of the latest
+// cycle of a report, with their growth over every cycle and the top
+// consumers of the latest.
+type Capacity struct {
+	Generated time.Time `json:"generated"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Cycles    int       `json:"cycles"`
+
+	Clusters   []ClusterCapacity   `json:"clusters"`
+	Datastores []DatastoreCapacity `json:"datastores"`
+
+	// TopCPU, TopMemory and TopStorage are the virtual machines using the
+	// most CPU in MHz, host memory in MB and committed storage in bytes.
+	TopCPU     []Consumer `json:"top_cpu"`
+	TopMemory  []Consumer `json:"top_memory"`
+	TopStorage []Consumer `json:"top_storage"`
+}
+
+// DefaultTop is the number of top consumers of every resource of capacity
+// reports.
+const DefaultTop = 10
+
+// Growth is the linear trend of the used percentage of a resource.
+type Growth struct {
+	PercentPerDay float64 `json:"percent_per_day"`
+	// DaysUntilFull is -1 for resources not growing.
+	DaysUntilFull float64 `json:"days_until_full"`
+}
+
+// ClusterCapacity is the CPU and memory of the hosts of a cluster.
+type ClusterCapacity struct {
+	VCenter string `json:"vcenter"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Hosts   int    `json:"hosts"`
+
+	CPUCapacityMHz float64 `json:"cpu_capacity_mhz"`
+	CPUUsedMHz     float64 `json:"cpu_used_mhz"`
+	CPUUsedPercent float64 `json:"cpu_used_percent"`
+	CPUGrowth      Growth  `json:"cpu_growth"`
+
+	MemoryCapacityBytes float64 `json:"memory_capacity_bytes"`
+	MemoryUsedBytes     float64 `json:"memory_used_bytes"`
+	MemoryUsedPercent   float64 `json:"memory_used_percent"`
+	MemoryGrowth        Growth  `json:"memory_growth"`
+}
+
+// DatastoreCapacity is the storage of a datastore.
+type DatastoreCapacity struct {
+	VCenter       string  `json:"vcenter"`
+	Name          string  `json:"name"`
+	Path          string  `json:"path"`
+	CapacityBytes float64 `json:"capacity_bytes"`
+	FreeBytes     float64 `json:"free_bytes"`
+	UsedPercent   float64 `json:"used_percent"`
+	Growth        Growth  `json:"growth"`
+}
+
+// Consumer is a virtual machine using a resource.
+type Consumer struct {
+	VCenter string  `json:"vcenter"`
+	Name    string  `json:"name"`
+	Path    string  `json:"path"`
+	Value   float64 `json:"value"`
+}
+
+// CapacityReport returns the capacity of the cycles of metrics with the top
+// consumers of every resource, growths being those of a collector.Forecaster
+// replaying the cycles in order. Clusters are the compute resources hosts are
+// inventoried under, as for forecasts.
+func CapacityReport(metrics []collector.Metric, top int, generated time.Time) *Capacity {
+	cycles := make(map[time.Time][]collector.Metric)
+	var times []time.Time
+	for _, m := range metrics {
+		if m.Name != "datastore" && m.Name != "host" && m.Name != "vm" {
+			continue
+		}
+		if _, ok := cycles[m.Time]; !ok {
+			times = append(times, m.Time)
+		}
+		cycles[m.Time] = append(cycles[m.Time], m)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	c := &Capacity{Generated: generated, Cycles: len(times)}
+	if len(times) == 0 {
+		return c
+	}
+	c.From, c.To = times[0], times[len(times)-1]
+
+	// Forecasts forget series not updated for a window as of now
+	f := collector.NewForecaster(time.Since(c.From) + time.Hour)
+	var forecasts []collector.Metric
+	for _, ts := range times {
+		forecasts = f.Forecast(cycles[ts])
+	}
+	growths := make(map[[3]string]Growth)
+	for _, m := range forecasts {
+		var g Growth
+		g.PercentPerDay, _ = m.Float("slope_percent_per_day")
+		g.DaysUntilFull, _ = m.Float("days_until_full")
+		growths[[3]string{m.Tag("vcenter"), m.Tag("path"), m.Tag("resource")}] = g
+	}
+	growth := func(vcenter, path, resource string) Growth {
+		if g, ok := growths[[3]string{vcenter, path, resource}]; ok {
+			return g
+		}
+		return Growth{DaysUntilFull: -1}
+	}
+
+	clusters := make(map[[2]string]*ClusterCapacity)
+	var vms []collector.Metric
+	for _, m := range cycles[c.To] {
+		vcenter := m.Tag("vcenter")
+		switch m.Name {
+		case "datastore":
+			capacity, _ := m.Float("capacity")
+			free, _ := m.Float("freespace")
+			ds := DatastoreCapacity{
+				VCenter:       vcenter,
+				Name:          m.Tag("name"),
+				Path:          m.Tag("path"),
+				CapacityBytes: capacity,
+				FreeBytes:     free,
+				Growth:        growth(vcenter, m.Tag("path"), "storage"),
+			}
+			if capacity > 0 {
+				ds.UsedPercent = 100 * (capacity - free) / capacity
+			}
+			c.Datastores = append(c.Datastores, ds)
+
+		case "host":
+			if m.Tag("path") == "" {
+				continue
+			}
+			p := path.Dir(m.Tag("path"))
+			cl, ok := clusters[[2]string{vcenter, p}]
+			if !ok {
+				cl = &ClusterCapacity{
+					VCenter:      vcenter,
+					Name:         path.Base(p),
+					Path:         p,
+					CPUGrowth:    growth(vcenter, p, "cpu"),
+					MemoryGrowth: growth(vcenter, p, "mem"),
+				}
+				clusters[[2]string{vcenter, p}] = cl
+			}
+			cl.Hosts++
+			mhz, _ := m.Float("cpu_mhz")
+			cores, _ := m.Float("num_cpu_cores")
+			cpu, _ := m.Float("overall_cpu_usage")
+			size, _ := m.Float("mem_size")
+			mem, _ := m.Float("overall_mem_usage")
+			cl.CPUCapacityMHz += mhz * cores
+			cl.CPUUsedMHz += cpu
+			cl.MemoryCapacityBytes += size
+			// Memory usage is in MB, the size in bytes
+			cl.MemoryUsedBytes += mem * (1 << 20)
+
+		case "vm":
+			vms = append(vms, m)
+		}
+	}
+
+	for _, cl := range clusters {
+		if cl.CPUCapacityMHz > 0 {
+			cl.CPUUsedPercent = 100 * cl.CPUUsedMHz / cl.CPUCapacityMHz
+		}
+		if cl.MemoryCapacityBytes > 0 {
+			cl.MemoryUsedPercent = 100 * cl.MemoryUsedBytes / cl.MemoryCapacityBytes
+		}
+		c.Clusters = append(c.Clusters, *cl)
+	}
+	sort.Slice(c.Clusters, func(i, j int) bool {
+		if c.Clusters[i].VCenter != c.Clusters[j].VCenter {
+			return c.Clusters[i].VCenter < c.Clusters[j].VCenter
+		}
+		return c.Clusters[i].Path < c.Clusters[j].Path
+	})
+	sort.Slice(c.Datastores, func(i, j int) bool {
+		if c.Datastores[i].VCenter != c.Datastores[j].VCenter {
+			return c.Datastores[i].VCenter < c.Datastores[j].VCenter
+		}
+		return c.Datastores[i].Path < c.Datastores[j].Path
+	})
+
+	c.TopCPU = topConsumers(vms, "overall_cpu_usage", top)
+	c.TopMemory = topConsumers(vms, "host_mem_usage", top)
+	c.TopStorage = topConsumers(vms, "storage_committed", top)
+	return c
+}
+
+// topConsumers returns the n virtual machines of vms with the largest value
+// of field.
+func topConsumers(vms []collector.Metric, field string, n int) []Consumer {
+	var consumers []Consumer
+	for _, m := range vms {
+		if v, ok := m.Float(field); ok {
+			consumers = append(consumers, Consumer{VCenter: m.Tag("vcenter"), Name: m.Tag("name"), Path: m.Tag("path"), Value: v})
+		}
+	}
+	sort.SliceStable(consumers, func(i, j int) bool { return consumers[i].Value > consumers[j].Value })
+	if len(consumers) > n {
+		consumers = consumers[:n]
+	}
+	return consumers
+}
@@ -0,0 +1,220 @@
+package report
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Heatmap is the density and allocation of virtual machines per host and
+// datastore as of the latest cycle of a report, for capacity planning.
+type Heatmap struct {
+	Generated time.Time `json:"generated"`
+	Time      time.Time `json:"time"`
+
+	// Cells are the virtual machines of every host and datastore pair
+	// running any, their matrix.
+	Cells      []HeatmapCell   `json:"cells"`
+	Hosts      []HostHeat      `json:"hosts"`
+	Datastores []DatastoreHeat `json:"datastores"`
+}
+
+// Allocation is what virtual machines are allocated.
+type Allocation struct {
+	VMs          int   `json:"vms"`
+	PoweredOnVMs int   `json:"powered_on_vms"`
+	VCPUs        int64 `json:"vcpus"`
+	MemoryMB     int64 `json:"memory_mb"`
+	// ProvisionedBytes is the committed and uncommitted storage of the
+	// virtual machines.
+	ProvisionedBytes int64 `json:"provisioned_bytes"`
+}
+
+// HeatmapCell is the allocation of the virtual machines of a host whose
+// files are on a datastore, that of their configuration file.
+type HeatmapCell struct {
+	VCenter   string `json:"vcenter"`
+	Cluster   string `json:"cluster"`
+	Host      string `json:"host"`
+	Datastore string `json:"datastore"`
+	Allocation
+}
+
+// HostHeat is the allocation of the virtual machines of a host against its
+// CPU cores and memory.
+type HostHeat struct {
+	VCenter          string `json:"vcenter"`
+	Cluster          string `json:"cluster"`
+	Name             string `json:"name"`
+	Path             string `json:"path"`
+	CPUCores         int64  `json:"cpu_cores"`
+	MemoryCapacityMB int64  `json:"memory_capacity_mb"`
+	Allocation
+	VCPUsPerCore           float64 `json:"vcpus_per_core"`
+	MemoryAllocatedPercent float64 `json:"memory_allocated_percent"`
+}
+
+// DatastoreHeat is the allocation of the virtual machines of a datastore
+// against its capacity.
+type DatastoreHeat struct {
+	VCenter       string `json:"vcenter"`
+	Name          string `json:"name"`
+	Path          string `json:"path"`
+	CapacityBytes int64  `json:"capacity_bytes"`
+	Allocation
+	ProvisionedPercent float64 `json:"provisioned_percent"`
+}
+
+// add adds the allocation of the vm metric m.
+func (a *Allocation) add(m collector.Metric) {
+	a.VMs++
+	if m.Tag("power_state") == "poweredOn" {
+		a.PoweredOnVMs++
+	}
+	cpus, _ := m.Float("num_cpu")
+	mem, _ := m.Float("mem_mb")
+	committed, _ := m.Float("storage_committed")
+	uncommitted, _ := m.Float("storage_uncommitted")
+	a.VCPUs += int64(cpus)
+	a.MemoryMB += int64(mem)
+	a.ProvisionedBytes += int64(committed + uncommitted)
+}
+
+// HeatmapReport returns the heatmap of the latest cycle of metrics. Virtual
+// machines are on the datastore of their configuration file; the cluster of
+// a host is the compute resource it is inventoried under, as for capacity
+// reports, "" for those of virtual machines on hosts without a host metric.
+func HeatmapReport(metrics []collector.Metric, generated time.Time) *Heatmap {
+	h := &Heatmap{Generated: generated, Cells: []HeatmapCell{}, Hosts: []HostHeat{}, Datastores: []DatastoreHeat{}}
+	for _, m := range metrics {
+		if m.Name == "vm" && m.Time.After(h.Time) {
+			h.Time = m.Time
+		}
+	}
+	if h.Time.IsZero() {
+		return h
+	}
+
+	type key [2]string
+	hosts := make(map[key]*HostHeat)
+	datastores := make(map[key]*DatastoreHeat)
+	for _, m := range metrics {
+		if !m.Time.Equal(h.Time) {
+			continue
+		}
+		vcenter := m.Tag("vcenter")
+		switch m.Name {
+		case "host":
+			cores, _ := m.Float("num_cpu_cores")
+			size, _ := m.Float("mem_size")
+			host := &HostHeat{
+				VCenter:          vcenter,
+				Name:             m.Tag("name"),
+				Path:             m.Tag("path"),
+				CPUCores:         int64(cores),
+				MemoryCapacityMB: int64(size) >> 20,
+			}
+			if host.Path != "" {
+				host.Cluster = path.Base(path.Dir(host.Path))
+			}
+			hosts[key{vcenter, m.Tag("moid")}] = host
+		case "datastore":
+			capacity, _ := m.Float("capacity")
+			datastores[key{vcenter, m.Tag("name")}] = &DatastoreHeat{
+				VCenter:       vcenter,
+				Name:          m.Tag("name"),
+				Path:          m.Tag("path"),
+				CapacityBytes: int64(capacity),
+			}
+		}
+	}
+
+	cells := make(map[[3]string]*HeatmapCell)
+	for _, m := range metrics {
+		if m.Name != "vm" || !m.Time.Equal(h.Time) {
+			continue
+		}
+		vcenter := m.Tag("vcenter")
+		ds := vmDatastore(m.Tag("vm_path_name"))
+		host, ok := hosts[key{vcenter, m.Tag("host_moid")}]
+		if !ok {
+			host = &HostHeat{VCenter: vcenter, Name: m.Tag("host_moid")}
+			hosts[key{vcenter, m.Tag("host_moid")}] = host
+		}
+		host.add(m)
+		if d, ok := datastores[key{vcenter, ds}]; ok {
+			d.add(m)
+		}
+
+		k := [3]string{vcenter, host.Name, ds}
+		c, ok := cells[k]
+		if !ok {
+			c = &HeatmapCell{VCenter: vcenter, Cluster: host.Cluster, Host: host.Name, Datastore: ds}
+			cells[k] = c
+		}
+		c.add(m)
+	}
+
+	for _, c := range cells {
+		h.Cells = append(h.Cells, *c)
+	}
+	sort.Slice(h.Cells, func(i, j int) bool {
+		a, b := h.Cells[i], h.Cells[j]
+		if a.VCenter != b.VCenter {
+			return a.VCenter < b.VCenter
+		}
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		return a.Datastore < b.Datastore
+	})
+
+	for _, host := range hosts {
+		if host.CPUCores > 0 {
+			host.VCPUsPerCore = float64(host.VCPUs) / float64(host.CPUCores)
+		}
+		if host.MemoryCapacityMB > 0 {
+			host.MemoryAllocatedPercent = 100 * float64(host.MemoryMB) / float64(host.MemoryCapacityMB)
+		}
+		h.Hosts = append(h.Hosts, *host)
+	}
+	sort.Slice(h.Hosts, func(i, j int) bool {
+		if h.Hosts[i].VCenter != h.Hosts[j].VCenter {
+			return h.Hosts[i].VCenter < h.Hosts[j].VCenter
+		}
+		if h.Hosts[i].Cluster != h.Hosts[j].Cluster {
+			return h.Hosts[i].Cluster < h.Hosts[j].Cluster
+		}
+		return h.Hosts[i].Name < h.Hosts[j].Name
+	})
+
+	for _, d := range datastores {
+		if d.CapacityBytes > 0 {
+			d.ProvisionedPercent = 100 * float64(d.ProvisionedBytes) / float64(d.CapacityBytes)
+		}
+		h.Datastores = append(h.Datastores, *d)
+	}
+	sort.Slice(h.Datastores, func(i, j int) bool {
+		if h.Datastores[i].VCenter != h.Datastores[j].VCenter {
+			return h.Datastores[i].VCenter < h.Datastores[j].VCenter
+		}
+		return h.Datastores[i].Name < h.Datastores[j].Name
+	})
+	return h
+}
+
+// vmDatastore returns the datastore of a datastore path, such as ds1 of
+// "[ds1] vm1/vm1.vmx".
+func vmDatastore(p string) string {
+	if !strings.HasPrefix(p, "[") {
+		return ""
+	}
+	name, _, _ := strings.Cut(p[1:], "]")
+	return name
+}
@@ -0,0 +1,137 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// funcs are the template functions of HTML reports.
+var funcs = template.FuncMap{
+	"bytes": formatBytes,
+	"duration": func(sec int64) string {
+		d := time.Duration(sec) * time.Second
+		if d >= 24*time.Hour {
+			return fmt.Sprintf("%dd", d/(24*time.Hour))
+		}
+		return d.Truncate(time.Minute).String()
+	},
+	"time":    func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 UTC") },
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"growth": func(g Growth) string {
+		if g.DaysUntilFull < 0 {
+			return fmt.Sprintf("%+.2f%%/day, not filling", g.PercentPerDay)
+		}
+		return fmt.Sprintf("%+.2f%%/day, full in %.0f days", g.PercentPerDay, g.DaysUntilFull)
+	},
+	"mhz":  func(v float64) string { return fmt.Sprintf("%.1f GHz", v/1000) },
+	"mb":   func(v float64) string { return formatBytes(int64(v * (1 << 20))) },
+	"size": func(v float64) string { return formatBytes(int64(v)) },
+	// bar renders a used percentage as a gauge, red from 85%
+	"bar": func(v float64) template.HTML {
+		color := "#3b82f6"
+		if v >= 85 {
+			color = "#dc2626"
+		}
+		w := v
+		if w > 100 {
+			w = 100
+		}
+		return template.HTML(fmt.Sprintf(`<div class="bar"><div style="width:%.0f%%;background:%s"></div></div>`, w, color))
+	},
+}
+
+// style is the stylesheet of HTML reports, inlined so they can be mailed.
+const style = `body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#222;margin:2em}
+h1{font-size:1.5em}h2{font-size:1.15em;margin-top:2em}
+table{border-collapse:collapse;width:100%;font-size:.9em}
+th,td{border-bottom:1px solid #ddd;padding:.35em .6em;text-align:left}
+th{background:#f4f4f4}td.num{text-align:right}.muted{color:#777}
+.bar{width:8em;height:.7em;background:#eee;display:inline-block}.bar div{height:100%}`
+
+var snapshotsHTML = template.Must(template.New("snapshots").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Snapshot policy report</title><style>` + style + `</style></head>
+<body>
+<h1>Snapshot policy report</h1>
+<p class="muted">Generated {{time .Generated}}. Snapshots {{with .Policy.MaxAge}}older than {{.}}{{end}}{{if and .Policy.MaxAge .Policy.MaxSizeBytes}} or {{end}}{{with .Policy.MaxSizeBytes}}larger than {{bytes .}}{{end}}, by {{.Policy.Owner}} and datastore.</p>
+{{range .Groups}}
+<h2>{{or .Owner "No owner"}} on {{or .Datastore "unknown datastore"}}: {{len .Snapshots}} snapshots, {{bytes .SizeBytes}}</h2>
+<table>
+<tr><th>Virtual machine</th><th>Snapshot</th><th>Created</th><th>Age</th><th>Size</th><th>Over</th><th>vCenter</th></tr>
+{{range .Snapshots}}<tr><td>{{.VMPath}}</td><td>{{.Name}}</td><td>{{time .Created}}</td><td class="num">{{duration .AgeSec}}</td><td class="num">{{bytes .SizeBytes}}</td><td>{{range $i, $r := .Reasons}}{{if $i}}, {{end}}{{$r}}{{end}}</td><td>{{.Endpoint}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No snapshot is over the limits.</p>
+{{end}}
+</body></html>
+`))
+
+// SnapshotsHTML writes groups as a self-contained HTML report of policy,
+// generated at.
+func SnapshotsHTML(w io.Writer, groups []SnapshotGroup, policy SnapshotPolicy, generated time.Time) error {
+	return snapshotsHTML.Execute(w, struct {
+		Groups    []SnapshotGroup
+		Policy    SnapshotPolicy
+		Generated time.Time
+	}{groups, policy, generated})
+}
+
+// formatBytes returns n in binary units, such as 1.5 GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+var capacityHTML = template.Must(template.New("capacity").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Capacity report</title><style>` + style + `</style></head>
+<body>
+<h1>Capacity report</h1>
+<p class="muted">Generated {{time .Generated}} from {{.Cycles}} collection cycles{{if .Cycles}}, {{time .From}} to {{time .To}}{{end}}. Growth is the linear trend over these cycles.</p>
+
+<h2>Clusters</h2>
+<table>
+<tr><th>Cluster</th><th>Hosts</th><th>CPU</th><th></th><th>CPU growth</th><th>Memory</th><th></th><th>Memory growth</th><th>vCenter</th></tr>
+{{range .Clusters}}<tr><td>{{.Name}}</td><td class="num">{{.Hosts}}</td><td class="num">{{mhz .CPUUsedMHz}} / {{mhz .CPUCapacityMHz}} ({{percent .CPUUsedPercent}})</td><td>{{bar .CPUUsedPercent}}</td><td>{{growth .CPUGrowth}}</td><td class="num">{{size .MemoryUsedBytes}} / {{size .MemoryCapacityBytes}} ({{percent .MemoryUsedPercent}})</td><td>{{bar .MemoryUsedPercent}}</td><td>{{growth .MemoryGrowth}}</td><td>{{.VCenter}}</td></tr>
+{{else}}<tr><td colspan="9" class="muted">No hosts.</td></tr>
+{{end}}</table>
+
+<h2>Datastores</h2>
+<table>
+<tr><th>Datastore</th><th>Used</th><th></th><th>Free</th><th>Growth</th><th>vCenter</th></tr>
+{{range .Datastores}}<tr><td>{{.Name}}</td><td class="num">{{size .CapacityBytes}} ({{percent .UsedPercent}})</td><td>{{bar .UsedPercent}}</td><td class="num">{{size .FreeBytes}}</td><td>{{growth .Growth}}</td><td>{{.VCenter}}</td></tr>
+{{else}}<tr><td colspan="6" class="muted">No datastores.</td></tr>
+{{end}}</table>
+
+<h2>Top CPU consumers</h2>
+<table>
+<tr><th>Virtual machine</th><th>CPU</th><th>vCenter</th></tr>
+{{range .TopCPU}}<tr><td>{{.Path}}</td><td class="num">{{mhz .Value}}</td><td>{{.VCenter}}</td></tr>
+{{end}}</table>
+
+<h2>Top memory consumers</h2>
+<table>
+<tr><th>Virtual machine</th><th>Host memory</th><th>vCenter</th></tr>
+{{range .TopMemory}}<tr><td>{{.Path}}</td><td class="num">{{mb .Value}}</td><td>{{.VCenter}}</td></tr>
+{{end}}</table>
+
+<h2>Top storage consumers</h2>
+<table>
+<tr><th>Virtual machine</th><th>Committed storage</th><th>vCenter</th></tr>
+{{range .TopStorage}}<tr><td>{{.Path}}</td><td class="num">{{size .Value}}</td><td>{{.VCenter}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// CapacityHTML writes c as a self-contained HTML report.
+func CapacityHTML(w io.Writer, c *Capacity) error {
+	return capacityHTML.Execute(w, c)
+}
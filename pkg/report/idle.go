@@ -0,0 +1,77 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// IdleOptions configures Idle.
+type IdleOptions struct {
+	// Window is the rolling window of the idle scores, 0 for every cycle.
+	Window time.Duration
+	// MinScore is the idle score of candidates.
+	MinScore float64
+	// MinSamples is the number of samples a VM needs to be a candidate.
+	MinSamples int
+}
+
+// Candidate is a virtual machine to reclaim.
+type Candidate struct {
+	VCenter string `json:"vcenter"`
+	Name    string `json:"name"`
+	MOID    string `json:"moid"`
+	Path    string `json:"path"`
+	Samples int64  `json:"samples"`
+
+	IdleScore       float64 `json:"idle_score"`
+	CPUUsagePercent float64 `json:"cpu_usage_percent"`
+	NetUsageKBps    float64 `json:"net_usage_kbps"`
+	DiskUsageKBps   float64 `json:"disk_usage_kbps"`
+}
+
+// Idle returns the virtual machines of metrics with an idle score of at
+// least the minimum as of the latest cycle, most idle first. Scores are those
+// of a collector.IdleDetector replaying the cycles of metrics in order.
+func Idle(metrics []collector.Metric, opts IdleOptions) []Candidate {
+	cycles := make(map[time.Time][]collector.Metric)
+	var times []time.Time
+	for _, m := range metrics {
+		if m.Name != "vm" && m.Name != "vm_perf" {
+			continue
+		}
+		if _, ok := cycles[m.Time]; !ok {
+			times = append(times, m.Time)
+		}
+		cycles[m.Time] = append(cycles[m.Time], m)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	window := opts.Window
+	if window <= 0 && len(times) != 0 {
+		window = times[len(times)-1].Sub(times[0]) + time.Nanosecond
+	}
+	d := collector.NewIdleDetector(window)
+	var scores []collector.Metric
+	for _, ts := range times {
+		scores = d.Score(cycles[ts])
+	}
+
+	var candidates []Candidate
+	for _, m := range scores {
+		c := Candidate{VCenter: m.Tag("vcenter"), Name: m.Tag("name"), MOID: m.Tag("moid"), Path: m.Tag("path")}
+		c.Samples, _ = m.Int("samples")
+		c.IdleScore, _ = m.Float("idle_score")
+		c.CPUUsagePercent, _ = m.Float("cpu_usage_percent")
+		c.NetUsageKBps, _ = m.Float("net_usage_kbps")
+		c.DiskUsageKBps, _ = m.Float("disk_usage_kbps")
+		if c.IdleScore < opts.MinScore || c.Samples < int64(opts.MinSamples) {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].IdleScore > candidates[j].IdleScore })
+	return candidates
+}
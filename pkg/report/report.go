@@ -0,0 +1,62 @@
+// Package report derives reports from the metrics of past collection cycles,
+// as written by serve --format json, such as the virtual machines to right
+// size or to reclaim.
+//
+//	metrics, err := report.ReadMetrics(f)
+//	...
+//	recs := report.Rightsizing(metrics, report.RightsizingOptions{Percentile: 95, Headroom: 0.2})
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// ReadMetrics reads metrics encoded as JSON lines. Lines other than metrics,
+// such as the comments of dry runs, are skipped.
+func ReadMetrics(r io.Reader) ([]collector.Metric, error) {
+	var metrics []collector.Metric
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		b := sc.Bytes()
+		if len(b) == 0 || b[0] != '{' {
+			continue
+		}
+
+		var m collector.Metric
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, sc.Err()
+}
+
+// entityKey identifies the entity of a metric across cycles.
+func entityKey(m collector.Metric) string {
+	if m.Entity != nil {
+		return m.Entity.VCenter + "/" + m.Entity.MOID
+	}
+	return m.Tag("vcenter") + "/" + m.Tag("moid")
+}
+
+// percentile returns the p-th percentile of values, by nearest rank, sorting
+// them.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	i := int(math.Ceil(p/100*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return values[i]
+}
@@ -0,0 +1,255 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestReadMetrics(t *testing.T) {
+	metrics, err := ReadMetrics(strings.NewReader(`# dry run: sink stdout would write 1 metrics as application/x-ndjson
+{"name":"vm","tags":{"name":"vm0"},"fields":{"num_cpu":4},"timestamp":"2024-01-01T00:00:00Z"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 || metrics[0].Fields["num_cpu"] != int64(4) {
+		t.Errorf("metrics %+v", metrics)
+	}
+}
+
+func TestRightsizing(t *testing.T) {
+	vm := func(name string, demand, active int64) collector.Metric {
+		return collector.Metric{
+			Name: "vm",
+			Tags: map[string]string{"name": name},
+			Fields: map[string]interface{}{
+				"num_cpu": int64(8), "mem_mb": int64(16384), "max_cpu_usage": int64(8 * 2000),
+				"overall_cpu_demand": demand, "guest_mem_usage": active,
+			},
+			Time:   time.Now(),
+			Entity: &collector.EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-" + name},
+		}
+	}
+
+	var metrics []collector.Metric
+	for i := 0; i < 10; i++ {
+		metrics = append(metrics, vm("idle", 1000, 2000), vm("busy", 16000, 16000))
+	}
+
+	recs := Rightsizing(metrics, RightsizingOptions{Percentile: 95, Headroom: 0.2, MinSamples: 5})
+	if len(recs) != 2 {
+		t.Fatalf("%d recommendations, expected 2", len(recs))
+	}
+
+	busy, idle := recs[0], recs[1]
+	if idle.Verdict != OverProvisioned || idle.SuggestedCPUs != 1 || idle.SuggestedMemoryMB != 2560 {
+		t.Errorf("idle %+v", idle)
+	}
+	if busy.Verdict != UnderProvisioned || busy.SuggestedCPUs != 10 {
+		t.Errorf("busy %+v", busy)
+	}
+}
+
+func TestIdle(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	vm := func(name string, usage int64, ts time.Time) collector.Metric {
+		return collector.Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": name, "vcenter": "vc", "moid": "vm-" + name},
+			Fields: map[string]interface{}{"overall_cpu_usage": usage, "max_cpu_usage": int64(2000), "available": int64(1)},
+			Time:   ts,
+			Entity: &collector.EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-" + name},
+		}
+	}
+
+	var metrics []collector.Metric
+	for i := 0; i < 4; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		metrics = append(metrics, vm("idle", 0, ts), vm("busy", 1500, ts))
+	}
+
+	candidates := Idle(metrics, IdleOptions{MinScore: 90, MinSamples: 4})
+	if len(candidates) != 1 || candidates[0].Name != "idle" || candidates[0].IdleScore != 100 {
+		t.Errorf("candidates %+v, expected idle only", candidates)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	now := time.Now()
+	entries := []collector.SnapshotEntry{
+		{VM: "a", Name: "old", Created: now.Add(-10 * 24 * time.Hour), Datastore: "ds1", Attributes: map[string]string{"Owner": "alice"}},
+		{VM: "b", Name: "big", Created: now.Add(-time.Hour), SizeBytes: 100 << 30, Datastore: "ds1", Tags: []string{"Owner:bob"}},
+		{VM: "c", Name: "fine", Created: now.Add(-time.Hour), SizeBytes: 1 << 30, Datastore: "ds1"},
+	}
+
+	policy := SnapshotPolicy{MaxAge: 7 * 24 * time.Hour, MaxSizeBytes: 50 << 30, Owner: "Owner"}
+	groups := Snapshots(entries, policy, now)
+	if len(groups) != 2 {
+		t.Fatalf("%d groups, expected 2: %+v", len(groups), groups)
+	}
+	if g := groups[0]; g.Owner != "alice" || g.Snapshots[0].Reasons[0] != "age" {
+		t.Errorf("group %+v, expected alice's old snapshot", g)
+	}
+	if g := groups[1]; g.Owner != "bob" || g.SizeBytes != 100<<30 || g.Snapshots[0].Reasons[0] != "size" {
+		t.Errorf("group %+v, expected bob's big snapshot", g)
+	}
+
+	var b strings.Builder
+	if err := SnapshotsHTML(&b, groups, policy, now); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "100.0 GiB") {
+		t.Errorf("HTML report misses the size of big:\n%s", b.String())
+	}
+}
+
+func TestCapacityReport(t *testing.T) {
+	start := time.Now().Add(-2 * 24 * time.Hour)
+
+	var metrics []collector.Metric
+	for day, free := range []float64{60, 50, 40} {
+		ts := start.Add(time.Duration(day) * 24 * time.Hour)
+		metrics = append(metrics,
+			collector.Metric{Name: "datastore", Tags: map[string]string{"name": "ds0", "vcenter": "vc", "path": "/DC0/datastore/ds0"}, Fields: map[string]interface{}{
+				"capacity": float64(100), "freespace": free, "used_percent": 100 - free,
+			}, Time: ts},
+			collector.Metric{Name: "host", Tags: map[string]string{"name": "h0", "vcenter": "vc", "path": "/DC0/host/C0/h0"}, Fields: map[string]interface{}{
+				"overall_cpu_usage": int64(1000), "cpu_mhz": int64(1000), "num_cpu_cores": int64(4), "overall_mem_usage": int64(1024), "mem_size": int64(4 << 30),
+			}, Time: ts},
+			collector.Metric{Name: "vm", Tags: map[string]string{"name": "vm0", "vcenter": "vc"}, Fields: map[string]interface{}{"overall_cpu_usage": int64(500)}, Time: ts},
+		)
+	}
+
+	c := CapacityReport(metrics, 5, time.Now())
+	if c.Cycles != 3 || len(c.Datastores) != 1 || len(c.Clusters) != 1 || len(c.TopCPU) != 1 {
+		t.Fatalf("capacity %+v", c)
+	}
+	if ds := c.Datastores[0]; ds.UsedPercent != 60 || ds.Growth.PercentPerDay < 9.99 || ds.Growth.DaysUntilFull < 3.99 {
+		t.Errorf("datastore %+v, expected 60%% used growing 10%%/day", ds)
+	}
+	if cl := c.Clusters[0]; cl.Name != "C0" || cl.CPUUsedPercent != 25 || cl.MemoryUsedPercent != 25 || cl.CPUGrowth.DaysUntilFull != -1 {
+		t.Errorf("cluster %+v, expected a flat C0 at 25%%", cl)
+	}
+
+	var b strings.Builder
+	if err := CapacityHTML(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "full in 4 days") {
+		t.Errorf("HTML report misses the growth of ds0:\n%s", b.String())
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	from := time.Date(2024, 5, 1, 7, 30, 0, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"0 7 * * 1", time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 1, 7, 45, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 8 15 * 7", time.Date(2024, 5, 5, 8, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * 2 *", time.Date(2025, 2, 1, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if next := s.Next(from); !next.Equal(tt.next) {
+			t.Errorf("%s: next %s, expected %s", tt.spec, next, tt.next)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestMailMessage(t *testing.T) {
+	m := &Mailer{From: "collector@example.com", To: []string{"ops@example.com"}}
+	msg, err := m.Message("vSphere reports", "Reports\n", []Attachment{{Name: "capacity.html", ContentType: "text/html", Data: []byte("<html></html>")}}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"To: ops@example.com\r\n", "multipart/mixed", `attachment; filename=capacity.html`, "PGh0bWw+PC9odG1sPg=="} {
+		if !strings.Contains(string(msg), s) {
+			t.Errorf("message misses %q:\n%s", s, msg)
+		}
+	}
+}
+
+func TestShowback(t *testing.T) {
+	costs := &collector.UnitCosts{Currency: "EUR", VCPU: 10, MemoryGB: 1, StorageGB: map[string]float64{"default": 0}}
+	month := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	vm := func(name, folder string, ts time.Time) collector.Metric {
+		return collector.Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": name, "path": folder + "/" + name},
+			Fields: map[string]interface{}{"num_cpu": int64(1), "mem_mb": int64(0)},
+			Time:   ts,
+			Entity: &collector.EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-" + name},
+		}
+	}
+
+	var metrics []collector.Metric
+	for i := 0; i < 4; i++ {
+		ts := month.Add(time.Duration(i) * 24 * time.Hour)
+		metrics = append(metrics, vm("web", "/DC0/vm/prod", ts))
+		if i < 2 {
+			metrics = append(metrics, vm("tmp", "/DC0/vm/dev", ts))
+		}
+	}
+	// Outside of the month
+	metrics = append(metrics, vm("old", "/DC0/vm/dev", month.Add(-time.Hour)))
+
+	s := ShowbackReport(metrics, ShowbackOptions{Costs: costs, GroupBy: "folder", Month: month})
+	if s.Cycles != 4 || len(s.Groups) != 2 {
+		t.Fatalf("%d cycles, groups %+v", s.Cycles, s.Groups)
+	}
+	if g := s.Groups[0]; g.Group != "/DC0/vm/prod" || g.VMs != 1 || g.TotalCost != 10 {
+		t.Errorf("prod %+v", g)
+	}
+	if g := s.Groups[1]; g.Group != "/DC0/vm/dev" || g.VMs != 1 || g.TotalCost != 5 {
+		t.Errorf("dev %+v", g)
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	old, ts := time.Now().Add(-time.Hour), time.Now()
+	vm := func(name, host, ds string, at time.Time) collector.Metric {
+		return collector.Metric{Name: "vm", Tags: map[string]string{
+			"name": name, "vcenter": "vc", "host_moid": host, "vm_path_name": "[" + ds + "] " + name + "/" + name + ".vmx", "power_state": "poweredOn",
+		}, Fields: map[string]interface{}{
+			"num_cpu": float64(4), "mem_mb": float64(2048), "storage_committed": float64(10 << 30), "storage_uncommitted": float64(0),
+		}, Time: at}
+	}
+	metrics := []collector.Metric{
+		vm("vm9", "host-1", "ds0", old),
+		{Name: "host", Tags: map[string]string{"name": "h0", "vcenter": "vc", "moid": "host-1", "path": "/DC0/host/C0/h0"}, Fields: map[string]interface{}{
+			"num_cpu_cores": float64(4), "mem_size": float64(8 << 30),
+		}, Time: ts},
+		{Name: "datastore", Tags: map[string]string{"name": "ds0", "vcenter": "vc"}, Fields: map[string]interface{}{"capacity": float64(100 << 30)}, Time: ts},
+		vm("vm0", "host-1", "ds0", ts), vm("vm1", "host-1", "ds0", ts), vm("vm2", "host-1", "ds1", ts),
+	}
+
+	h := HeatmapReport(metrics, time.Now())
+	if len(h.Cells) != 2 || len(h.Hosts) != 1 || len(h.Datastores) != 1 {
+		t.Fatalf("heatmap %+v", h)
+	}
+	if c := h.Cells[0]; c.Cluster != "C0" || c.Host != "h0" || c.Datastore != "ds0" || c.VMs != 2 || c.VCPUs != 8 {
+		t.Errorf("cell %+v, expected the 2 VMs of h0 on ds0", c)
+	}
+	if host := h.Hosts[0]; host.VMs != 3 || host.VCPUsPerCore != 3 || host.MemoryAllocatedPercent != 75 {
+		t.Errorf("host %+v, expected 3 VMs of 3 vCPUs per core and 75%% of its memory", host)
+	}
+	if ds := h.Datastores[0]; ds.VMs != 2 || ds.ProvisionedPercent != 20 {
+		t.Errorf("datastore %+v, expected 2 VMs provisioned 20%% of it", ds)
+	}
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Verdicts of a Recommendation.
+const (
+	OverProvisioned  = "over"
+	UnderProvisioned = "under"
+	RightSized       = "ok"
+)
+
+// RightsizingOptions configures Rightsizing.
+type RightsizingOptions struct {
+	// Percentile is the percentile of CPU and memory demand sized for.
+	Percentile float64
+	// Headroom is the fraction added to the demand, such as 0.2.
+	Headroom float64
+	// MinSamples is the number of samples a VM needs to be sized.
+	MinSamples int
+}
+
+// DefaultRightsizing sizes for the 95th percentile of demand plus 20%, of
+// virtual machines with at least 12 samples, an hour of cycles every 5
+// minutes.
+var DefaultRightsizing = RightsizingOptions{Percentile: 95, Headroom: 0.2, MinSamples: 12}
+
+// Recommendation is the suggested size of a virtual machine.
+type Recommendation struct {
+	VCenter string `json:"vcenter"`
+	Name    string `json:"name"`
+	MOID    string `json:"moid"`
+	Samples int    `json:"samples"`
+
+	CPUs          int64   `json:"cpus"`
+	CPUDemandMHz  float64 `json:"cpu_demand_mhz"`
+	SuggestedCPUs int64   `json:"suggested_cpus"`
+
+	MemoryMB          int64   `json:"memory_mb"`
+	MemoryDemandMB    float64 `json:"memory_demand_mb"`
+	SuggestedMemoryMB int64   `json:"suggested_memory_mb"`
+
+	// Verdict is over when either resource can shrink and none must grow,
+	// under when either must grow, and ok otherwise.
+	Verdict string `json:"verdict"`
+}
+
+// memoryStepMB is the granularity of suggested memory sizes.
+const memoryStepMB = 256
+
+// Rightsizing suggests a size for every virtual machine of metrics, sorted by
+// vcenter and name: enough vCPUs and memory for the percentile of its CPU
+// demand and active guest memory over the samples, plus headroom. vCPUs are
+// sized by the clock of the cores of the host, from the maximum CPU usage of
+// the VM. Samples of powered off VMs are left out.
+func Rightsizing(metrics []collector.Metric, opts RightsizingOptions) []Recommendation {
+	type samples struct {
+		rec      Recommendation
+		cpu, mem []float64
+		coreMHz  float64
+	}
+	vms := make(map[string]*samples)
+
+	for _, m := range metrics {
+		if m.Name != "vm" {
+			continue
+		}
+		if available, ok := m.Int("available"); ok && available == 0 {
+			continue
+		}
+		cpus, ok1 := m.Int("num_cpu")
+		mem, ok2 := m.Int("mem_mb")
+		demand, ok3 := m.Float("overall_cpu_demand")
+		active, ok4 := m.Float("guest_mem_usage")
+		max, ok5 := m.Float("max_cpu_usage")
+		if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || cpus == 0 || max == 0 {
+			continue
+		}
+
+		key := entityKey(m)
+		s, ok := vms[key]
+		if !ok {
+			s = &samples{}
+			vms[key] = s
+		}
+		// The latest configuration is the one resized
+		s.rec.VCenter, s.rec.Name, s.rec.MOID = m.Tag("vcenter"), m.Tag("name"), m.Tag("moid")
+		if m.Entity != nil {
+			s.rec.VCenter, s.rec.MOID = m.Entity.VCenter, m.Entity.MOID
+		}
+		s.rec.CPUs, s.rec.MemoryMB = cpus, mem
+		s.coreMHz = max / float64(cpus)
+		s.cpu = append(s.cpu, demand)
+		s.mem = append(s.mem, active)
+	}
+
+	var recs []Recommendation
+	for _, s := range vms {
+		if len(s.cpu) < opts.MinSamples {
+			continue
+		}
+
+		r := s.rec
+		r.Samples = len(s.cpu)
+		r.CPUDemandMHz = percentile(s.cpu, opts.Percentile)
+		r.MemoryDemandMB = percentile(s.mem, opts.Percentile)
+
+		r.SuggestedCPUs = int64(math.Ceil(r.CPUDemandMHz * (1 + opts.Headroom) / s.coreMHz))
+		if r.SuggestedCPUs < 1 {
+			r.SuggestedCPUs = 1
+		}
+		r.SuggestedMemoryMB = int64(math.Ceil(r.MemoryDemandMB*(1+opts.Headroom)/memoryStepMB)) * memoryStepMB
+		if r.SuggestedMemoryMB < memoryStepMB {
+			r.SuggestedMemoryMB = memoryStepMB
+		}
+
+		switch {
+		case r.SuggestedCPUs > r.CPUs || r.SuggestedMemoryMB > r.MemoryMB:
+			r.Verdict = UnderProvisioned
+		case r.SuggestedCPUs < r.CPUs || r.SuggestedMemoryMB < r.MemoryMB:
+			r.Verdict = OverProvisioned
+		default:
+			r.Verdict = RightSized
+		}
+		recs = append(recs, r)
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].VCenter != recs[j].VCenter {
+			return recs[i].VCenter < recs[j].VCenter
+		}
+		return recs[i].Name < recs[j].Name
+	})
+	return recs
+}
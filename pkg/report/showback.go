@@ -0,0 +1,113 @@
+package report
+
+import (
+	"path"
+	"sort"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// ShowbackOptions configures ShowbackReport.
+type ShowbackOptions struct {
+	Costs *collector.UnitCosts
+	// GroupBy is "folder", the folder of virtual machines, or a tag of their
+	// vm metrics such as resource_pool or vcd_org.
+	GroupBy string
+	// Month is the start of the month of the report.
+	Month time.Time
+}
+
+// Showback is the costs of a month by group of virtual machines.
+type Showback struct {
+	Month    string          `json:"month"`
+	Currency string          `json:"currency"`
+	GroupBy  string          `json:"group_by"`
+	Cycles   int             `json:"cycles"`
+	Groups   []ShowbackGroup `json:"groups"`
+}
+
+// ShowbackGroup is the costs of a group of virtual machines.
+type ShowbackGroup struct {
+	Group       string  `json:"group"`
+	VMs         int     `json:"vms"`
+	CPUCost     float64 `json:"cpu_cost"`
+	MemoryCost  float64 `json:"memory_cost"`
+	StorageCost float64 `json:"storage_cost"`
+	TotalCost   float64 `json:"total_cost"`
+}
+
+// ShowbackReport returns the costs of the virtual machines of the cycles of
+// metrics within the month, most expensive group first. Virtual machines are
+// charged their monthly costs prorated by the share of the cycles of the
+// month they were in, so those created or deleted within it are charged for
+// part of it. Groups are those of their latest metric.
+func ShowbackReport(metrics []collector.Metric, opts ShowbackOptions) *Showback {
+	end := opts.Month.AddDate(0, 1, 0)
+	s := &Showback{Month: opts.Month.Format("2006-01"), Currency: opts.Costs.Currency, GroupBy: opts.GroupBy}
+
+	type vm struct {
+		group                string
+		last                 time.Time
+		cpu, memory, storage float64
+	}
+	vms := make(map[string]*vm)
+	cycles := make(map[time.Time]bool)
+	for _, m := range metrics {
+		if m.Name != "vm" || m.Time.Before(opts.Month) || !m.Time.Before(end) {
+			continue
+		}
+		cycles[m.Time] = true
+
+		cpu, memory, storage, _, ok := opts.Costs.VMCost(m)
+		if !ok {
+			continue
+		}
+		key := entityKey(m)
+		v, ok := vms[key]
+		if !ok {
+			v = &vm{}
+			vms[key] = v
+		}
+		if !m.Time.Before(v.last) {
+			v.last = m.Time
+			if opts.GroupBy == "folder" {
+				v.group = path.Dir(m.Tag("path"))
+			} else {
+				v.group = m.Tag(opts.GroupBy)
+			}
+		}
+		v.cpu += cpu
+		v.memory += memory
+		v.storage += storage
+	}
+	s.Cycles = len(cycles)
+	if s.Cycles == 0 {
+		return s
+	}
+
+	groups := make(map[string]*ShowbackGroup)
+	n := float64(s.Cycles)
+	for _, v := range vms {
+		g, ok := groups[v.group]
+		if !ok {
+			g = &ShowbackGroup{Group: v.group}
+			groups[v.group] = g
+		}
+		g.VMs++
+		g.CPUCost += v.cpu / n
+		g.MemoryCost += v.memory / n
+		g.StorageCost += v.storage / n
+		g.TotalCost += (v.cpu + v.memory + v.storage) / n
+	}
+	for _, g := range groups {
+		s.Groups = append(s.Groups, *g)
+	}
+	sort.Slice(s.Groups, func(i, j int) bool {
+		if s.Groups[i].TotalCost != s.Groups[j].TotalCost {
+			return s.Groups[i].TotalCost > s.Groups[j].TotalCost
+		}
+		return s.Groups[i].Group < s.Groups[j].Group
+	})
+	return s
+}
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// SnapshotPolicy is the age and size limits of snapshots, 0 for none.
+type SnapshotPolicy struct {
+	MaxAge       time.Duration
+	MaxSizeBytes int64
+	// Owner is the custom attribute or vSphere tag category naming the owner
+	// of virtual machines.
+	Owner string
+}
+
+// DefaultSnapshotPolicy limits snapshots to 3 days, with owners named by the
+// Owner custom attribute or tag category.
+var DefaultSnapshotPolicy = SnapshotPolicy{MaxAge: 72 * time.Hour, Owner: "Owner"}
+
+// SnapshotViolation is a snapshot over the limits of a SnapshotPolicy.
+type SnapshotViolation struct {
+	collector.SnapshotEntry
+	AgeSec int64 `json:"age_sec"`
+	// Reasons are "age" and "size", the limits the snapshot is over.
+	Reasons []string `json:"reasons"`
+}
+
+// SnapshotGroup is the snapshot violations of an owner on a datastore.
+type SnapshotGroup struct {
+	Owner     string              `json:"owner"`
+	Datastore string              `json:"datastore"`
+	SizeBytes int64               `json:"size_bytes"`
+	Snapshots []SnapshotViolation `json:"snapshots"`
+}
+
+// Snapshots returns the snapshots of entries over the limits of policy as of
+// now, grouped by owner and datastore, sorted by owner, datastore and then
+// oldest first. Virtual machines without an owner are grouped under "".
+func Snapshots(entries []collector.SnapshotEntry, policy SnapshotPolicy, now time.Time) []SnapshotGroup {
+	type key struct{ owner, datastore string }
+	groups := make(map[key]*SnapshotGroup)
+
+	for _, e := range entries {
+		v := SnapshotViolation{SnapshotEntry: e, AgeSec: int64(now.Sub(e.Created).Seconds())}
+		if policy.MaxAge > 0 && now.Sub(e.Created) > policy.MaxAge {
+			v.Reasons = append(v.Reasons, "age")
+		}
+		if policy.MaxSizeBytes > 0 && e.SizeBytes > policy.MaxSizeBytes {
+			v.Reasons = append(v.Reasons, "size")
+		}
+		if len(v.Reasons) == 0 {
+			continue
+		}
+
+		k := key{owner: owner(e, policy.Owner), datastore: e.Datastore}
+		g, ok := groups[k]
+		if !ok {
+			g = &SnapshotGroup{Owner: k.owner, Datastore: k.datastore}
+			groups[k] = g
+		}
+		g.SizeBytes += e.SizeBytes
+		g.Snapshots = append(g.Snapshots, v)
+	}
+
+	sorted := make([]SnapshotGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g.Snapshots, func(i, j int) bool { return g.Snapshots[i].Created.Before(g.Snapshots[j].Created) })
+		sorted = append(sorted, *g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Owner != sorted[j].Owner {
+			return sorted[i].Owner < sorted[j].Owner
+		}
+		return sorted[i].Datastore < sorted[j].Datastore
+	})
+	return sorted
+}
+
+// owner returns the owner of the virtual machine of e: its custom attribute
+// called name, else the first of its tags of the category called name.
+func owner(e collector.SnapshotEntry, name string) string {
+	if v, ok := e.Attributes[name]; ok {
+		return v
+	}
+	for _, t := range e.Tags {
+		if category, tag, ok := strings.Cut(t, ":"); ok && category == name {
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/objstore"
+)
+
+// Archive formats.
+const (
+	ArchiveParquet = "parquet"
+	ArchiveNDJSON  = "ndjson"
+)
+
+// Archive is a Sink batching the metrics of cycles into compressed objects
+// of a Store, such as an S3-compatible bucket, for cheap long-term retention
+// and offline analytics with engines such as DuckDB or Athena.
+//
+// The metrics buffered for Interval are stored as an object per measurement
+// and UTC day, Hive-partitioned as measurement=vm/date=2024-05-01/ and named
+// by the time of their first metric, 20240501T130000Z.parquet, or
+// 20240501T130000Z.ndjson.gz for gzip-compressed JSON lines. A batch that
+// fails to be stored is dropped, so that an unreachable store doesn't grow
+// the memory of the collector.
+type Archive struct {
+	Store    objstore.Store
+	Format   string
+	Interval time.Duration
+	// Target, if set, partitions the objects further, as
+	// target=default.vc01/measurement=vm/, for the collectors of the
+	// targets of a controller sharing a store not to overwrite each other
+	Target string
+
+	mu      sync.Mutex
+	batch   []collector.Metric
+	started time.Time
+}
+
+// NewArchive returns an Archive sink flushing to store every interval, in
+// format.
+func NewArchive(store objstore.Store, format string, interval time.Duration) (*Archive, error) {
+	switch format {
+	case ArchiveParquet, ArchiveNDJSON:
+	default:
+		return nil, fmt.Errorf("invalid archive format %q", format)
+	}
+	return &Archive{Store: store, Format: format, Interval: interval}, nil
+}
+
+// Write buffers metrics, flushing the batch once it is Interval old.
+func (s *Archive) Write(ctx context.Context, metrics []collector.Metric) error {
+	s.mu.Lock()
+	if len(s.batch) == 0 {
+		s.started = time.Now()
+	}
+	s.batch = append(s.batch, metrics...)
+	due := time.Since(s.started) >= s.Interval
+	s.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// Flush stores the buffered metrics, for the batch of the last cycles on
+// shutdown.
+func (s *Archive) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.mu.Unlock()
+
+	type partition struct{ measurement, date string }
+	parts := make(map[partition][]collector.Metric)
+	for _, m := range batch {
+		p := partition{m.Name, m.Time.UTC().Format("2006-01-02")}
+		parts[p] = append(parts[p], m)
+	}
+	keys := make([]partition, 0, len(parts))
+	for p := range parts {
+		keys = append(keys, p)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].measurement != keys[j].measurement {
+			return keys[i].measurement < keys[j].measurement
+		}
+		return keys[i].date < keys[j].date
+	})
+
+	var errs []error
+	for _, p := range keys {
+		metrics := parts[p]
+		data, contentType, ext, err := s.encode(metrics)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		key := fmt.Sprintf("measurement=%s/date=%s/%s.%s", p.measurement, p.date, metrics[0].Time.UTC().Format("20060102T150405Z"), ext)
+		if s.Target != "" {
+			key = "target=" + s.Target + "/" + key
+		}
+		if err := s.Store.Put(ctx, key, data, contentType); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// encode returns metrics encoded in the format of s, with its content type
+// and file extension.
+func (s *Archive) encode(metrics []collector.Metric) ([]byte, string, string, error) {
+	var b bytes.Buffer
+	if s.Format == ArchiveParquet {
+		enc := Parquet{}
+		if err := enc.Encode(&b, metrics); err != nil {
+			return nil, "", "", err
+		}
+		return b.Bytes(), enc.ContentType(), "parquet", nil
+	}
+
+	zw := gzip.NewWriter(&b)
+	if err := (JSONLines{}).Encode(zw, metrics); err != nil {
+		return nil, "", "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", "", err
+	}
+	return b.Bytes(), "application/gzip", "ndjson.gz", nil
+}
@@ -0,0 +1,101 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// memStore is an objstore.Store keeping objects in memory.
+type memStore map[string][]byte
+
+func (s memStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	s[key] = data
+	return nil
+}
+
+func TestArchive(t *testing.T) {
+	store := memStore{}
+	archive, err := NewArchive(store, ArchiveNDJSON, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	metrics := []collector.Metric{
+		{Name: "vm", Tags: map[string]string{"name": "vm0"}, Fields: map[string]interface{}{"cpu_usage": int64(1)}, Time: day},
+		{Name: "host", Fields: map[string]interface{}{"cpu_usage": int64(2)}, Time: day},
+	}
+	if err := archive.Write(context.Background(), metrics); err != nil {
+		t.Fatal(err)
+	}
+	next := []collector.Metric{{Name: "vm", Tags: map[string]string{"name": "vm0"}, Fields: map[string]interface{}{"cpu_usage": int64(3)}, Time: day.Add(2 * time.Minute)}}
+	if err := archive.Write(context.Background(), next); err != nil {
+		t.Fatal(err)
+	}
+	if len(store) != 0 {
+		t.Fatalf("stored %d objects before the interval elapsed", len(store))
+	}
+
+	if err := archive.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k := range store {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	expect := []string{
+		"measurement=host/date=2024-05-01/20240501T235900Z.ndjson.gz",
+		"measurement=vm/date=2024-05-01/20240501T235900Z.ndjson.gz",
+		"measurement=vm/date=2024-05-02/20240502T000100Z.ndjson.gz",
+	}
+	if len(keys) != len(expect) {
+		t.Fatalf("keys %q, expected %q", keys, expect)
+	}
+	for i := range keys {
+		if keys[i] != expect[i] {
+			t.Errorf("key %q, expected %q", keys[i], expect[i])
+		}
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(store[expect[2]]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(zr)
+	var got []collector.Metric
+	for sc.Scan() {
+		var m collector.Metric
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	if len(got) != 1 || got[0].Fields["cpu_usage"] != int64(3) {
+		t.Errorf("archived %+v", got)
+	}
+
+	// The objects of a controller target are partitioned by it
+	archive.Target = "default.vc01"
+	if err := archive.Write(context.Background(), metrics[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store["target=default.vc01/measurement=host/date=2024-05-01/20240501T235900Z.ndjson.gz"]; !ok {
+		t.Errorf("no object of the target in %d objects", len(store))
+	}
+
+	if _, err := NewArchive(store, "csv", time.Hour); err == nil {
+		t.Error("csv format accepted")
+	}
+}
@@ -0,0 +1,256 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Aria is a Sink pushing the numeric fields of the metrics of vSphere
+// entities to VMware Aria Operations, formerly vRealize Operations, through
+// its suite API. Each field is a stat of a custom metric group of the
+// resource of the entity, Group|field, such as
+// vsphere-collector|snapshot_age_sec.
+//
+// Resources of the VMWARE adapter are matched by the managed object ID and
+// name of their entity. Metrics of entities Aria Operations has no resource
+// for are dropped.
+type Aria struct {
+	// URL is the base URL of the Aria Operations deployment.
+	URL        *url.URL
+	Username   string
+	Password   string
+	AuthSource string
+	Group      string
+
+	token   string
+	expires time.Time
+	// resources are the IDs of resources by entity type, MOID and name
+	resources map[string]map[ariaEntity]string
+}
+
+type ariaEntity struct {
+	moid, name string
+}
+
+// NewAria returns an Aria sink of the deployment at rawURL, authenticating
+// against authSource, "" for local users.
+func NewAria(rawURL, username, password, authSource, group string) (*Aria, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid Aria Operations URL %q", rawURL)
+	}
+	if group == "" {
+		group = "vsphere-collector"
+	}
+
+	return &Aria{
+		URL:        u,
+		Username:   username,
+		Password:   password,
+		AuthSource: authSource,
+		Group:      group,
+		resources:  make(map[string]map[ariaEntity]string),
+	}, nil
+}
+
+type ariaStat struct {
+	StatKey    string    `json:"statKey"`
+	Timestamps []int64   `json:"timestamps"`
+	Data       []float64 `json:"data"`
+}
+
+type ariaResourceStats struct {
+	ID    string     `json:"id"`
+	Stats []ariaStat `json:"stat-contents"`
+}
+
+// Write pushes the stats of metrics in a single request, listing the
+// resources of entity types not listed yet, or with unknown entities, first.
+func (a *Aria) Write(ctx context.Context, metrics []collector.Metric) error {
+	if err := a.login(ctx); err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool)
+	var content []ariaResourceStats
+	for _, m := range metrics {
+		if m.Entity == nil {
+			continue
+		}
+		e := ariaEntity{moid: m.Entity.MOID, name: m.Tag("name")}
+
+		id, ok := a.resources[m.Entity.Type][e]
+		if !ok && !listed[m.Entity.Type] {
+			listed[m.Entity.Type] = true
+			if err := a.listResources(ctx, m.Entity.Type); err != nil {
+				return err
+			}
+			id, ok = a.resources[m.Entity.Type][e]
+		}
+		if !ok {
+			continue
+		}
+
+		rs := ariaResourceStats{ID: id}
+		keys := make([]string, 0, len(m.Fields))
+		for k := range m.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v, ok := m.Float(k)
+			if !ok {
+				continue
+			}
+			rs.Stats = append(rs.Stats, ariaStat{
+				StatKey:    a.Group + "|" + k,
+				Timestamps: []int64{m.Time.UnixMilli()},
+				Data:       []float64{v},
+			})
+		}
+		if len(rs.Stats) != 0 {
+			content = append(content, rs)
+		}
+	}
+
+	if len(content) == 0 {
+		return nil
+	}
+	return a.do(ctx, http.MethodPost, "/suite-api/api/resources/stats", map[string]interface{}{"resource-stat-content": content}, nil)
+}
+
+// login acquires a token unless the current one is valid for another minute.
+func (a *Aria) login(ctx context.Context) error {
+	if a.token != "" && time.Until(a.expires) > time.Minute {
+		return nil
+	}
+	a.token = ""
+
+	req := map[string]string{"username": a.Username, "password": a.Password}
+	if a.AuthSource != "" {
+		req["authSource"] = a.AuthSource
+	}
+
+	var res struct {
+		Token    string `json:"token"`
+		Validity int64  `json:"validity"`
+	}
+	if err := a.do(ctx, http.MethodPost, "/suite-api/api/auth/token/acquire", req, &res); err != nil {
+		return fmt.Errorf("aria operations login: %w", err)
+	}
+
+	collector.AddSecret(res.Token)
+	a.token, a.expires = res.Token, time.UnixMilli(res.Validity)
+	return nil
+}
+
+// listResources lists the resources of the VMWARE adapter of resource kind
+// kind, the managed object type of their entity.
+func (a *Aria) listResources(ctx context.Context, kind string) error {
+	resources := make(map[ariaEntity]string)
+
+	const pageSize = 1000
+	for page := 0; ; page++ {
+		var res struct {
+			PageInfo struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"pageInfo"`
+			ResourceList []struct {
+				Identifier  string `json:"identifier"`
+				ResourceKey struct {
+					Name                string `json:"name"`
+					ResourceIdentifiers []struct {
+						IdentifierType struct {
+							Name string `json:"name"`
+						} `json:"identifierType"`
+						Value string `json:"value"`
+					} `json:"resourceIdentifiers"`
+				} `json:"resourceKey"`
+			} `json:"resourceList"`
+		}
+
+		query := map[string][]string{"adapterKind": {"VMWARE"}, "resourceKind": {kind}}
+		path := fmt.Sprintf("/suite-api/api/resources/query?page=%d&pageSize=%d", page, pageSize)
+		if err := a.do(ctx, http.MethodPost, path, query, &res); err != nil {
+			return err
+		}
+
+		for _, r := range res.ResourceList {
+			for _, id := range r.ResourceKey.ResourceIdentifiers {
+				if id.IdentifierType.Name == "VMEntityObjectID" {
+					resources[ariaEntity{moid: id.Value, name: r.ResourceKey.Name}] = r.Identifier
+				}
+			}
+		}
+
+		if (page+1)*pageSize >= res.PageInfo.TotalCount {
+			break
+		}
+	}
+
+	slog.Debug("listed aria operations resources", "kind", kind, "count", len(resources))
+	a.resources[kind] = resources
+	return nil
+}
+
+// do sends the JSON of body to path, decoding the JSON response into res
+// unless nil.
+func (a *Aria) do(ctx context.Context, method, path string, body, res interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	u := *a.URL
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	ref, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	u.Path += ref.Path
+	u.RawQuery = ref.RawQuery
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "vRealizeOpsToken "+a.token)
+	}
+
+	resp, err := collector.Outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Log in again on the next write
+		a.token = ""
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, ref.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
@@ -0,0 +1,69 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestAria(t *testing.T) {
+	var pushed []ariaResourceStats
+	mux := http.NewServeMux()
+	mux.HandleFunc("/suite-api/api/auth/token/acquire", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": "t0k3n", "validity": time.Now().Add(time.Hour).UnixMilli()})
+	})
+	mux.HandleFunc("/suite-api/api/resources/query", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"pageInfo": {"totalCount": 1}, "resourceList": [{"identifier": "uuid-1", "resourceKey": {"name": "vm0",
+			"resourceIdentifiers": [{"identifierType": {"name": "VMEntityObjectID"}, "value": "vm-42"}]}}]}`))
+	})
+	mux.HandleFunc("/suite-api/api/resources/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "vRealizeOpsToken t0k3n" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Content []ariaResourceStats `json:"resource-stat-content"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		pushed = append(pushed, body.Content...)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	aria, err := NewAria(s.URL, "admin", "secret", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := []collector.Metric{
+		{
+			Name:   "vm",
+			Tags:   map[string]string{"name": "vm0"},
+			Fields: map[string]interface{}{"snapshot_age_sec": int64(3600), "guest": "skipped"},
+			Time:   time.Now(),
+			Entity: &collector.EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-42"},
+		},
+		{
+			Name:   "vm",
+			Tags:   map[string]string{"name": "unknown"},
+			Fields: map[string]interface{}{"snapshot_age_sec": int64(1)},
+			Time:   time.Now(),
+			Entity: &collector.EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-43"},
+		},
+	}
+	if err := aria.Write(context.Background(), metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pushed) != 1 || pushed[0].ID != "uuid-1" || len(pushed[0].Stats) != 1 {
+		t.Fatalf("pushed %+v", pushed)
+	}
+	if st := pushed[0].Stats[0]; st.StatKey != "vsphere-collector|snapshot_age_sec" || st.Data[0] != 3600 {
+		t.Errorf("stat %+v", st)
+	}
+}
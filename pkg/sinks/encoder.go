@@ -0,0 +1,52 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// An Encoder serializes metrics for a sink.
+type Encoder interface {
+	Encode(w io.Writer, metrics []collector.Metric) error
+	// ContentType is the MIME type of the encoding.
+	ContentType() string
+}
+
+// NewEncoder returns the Encoder of format: line for InfluxDB line protocol,
+// json for JSON lines, graphite for Graphite plaintext, graphite-tags for
+// Graphite plaintext with tags or prometheus for the Prometheus text format.
+func NewEncoder(format string) (Encoder, error) {
+	switch format {
+	case "line", "":
+		return LineProtocol{}, nil
+	case "json":
+		return JSONLines{}, nil
+	case "graphite":
+		return Graphite{}, nil
+	case "graphite-tags":
+		return Graphite{Tags: true}, nil
+	case "prometheus":
+		return Prometheus{}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q", format)
+}
+
+// JSONLines encodes metrics as JSON, one metric per line.
+type JSONLines struct{}
+
+func (JSONLines) Encode(w io.Writer, metrics []collector.Metric) error {
+	enc := json.NewEncoder(w)
+	for _, m := range metrics {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (JSONLines) ContentType() string {
+	return "application/x-ndjson"
+}
@@ -0,0 +1,264 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// An EventSink writes the events of collection cycles to a log backend,
+// while their metrics go to a Sink.
+type EventSink interface {
+	// WriteEvents writes the events of a single cycle.
+	WriteEvents(ctx context.Context, events []collector.Event) error
+}
+
+// EventFile is an EventSink appending events to a file as JSON lines.
+type EventFile struct {
+	Path string
+}
+
+// NewEventFile returns an EventFile appending to path.
+func NewEventFile(path string) *EventFile {
+	return &EventFile{Path: path}
+}
+
+// WriteEvents appends events, opening the file for every write so it can be
+// rotated.
+func (s *EventFile) WriteEvents(ctx context.Context, events []collector.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Loki is an EventSink pushing events to Grafana Loki, as JSON log lines of
+// streams labelled by job, vcenter and severity.
+type Loki struct {
+	// URL is the base URL of Loki.
+	URL *url.URL
+	// Job is the job label of the streams.
+	Job string
+}
+
+// NewLoki returns a Loki sink pushing to the Loki at rawURL.
+func NewLoki(rawURL string) (*Loki, error) {
+	u, err := parseHTTPURL("Loki", rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Loki{URL: u, Job: "vsphere-collector"}, nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// WriteEvents pushes events in a single request.
+func (s *Loki) WriteEvents(ctx context.Context, events []collector.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	streams := make(map[[2]string]*lokiStream)
+	var order []*lokiStream
+	for _, e := range events {
+		key := [2]string{e.VCenter, e.Severity}
+		st, ok := streams[key]
+		if !ok {
+			st = &lokiStream{Stream: map[string]string{"job": s.Job, "vcenter": e.VCenter}}
+			if e.Severity != "" {
+				st.Stream["severity"] = e.Severity
+			}
+			streams[key] = st
+			order = append(order, st)
+		}
+
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), string(line)})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"streams": order})
+	if err != nil {
+		return err
+	}
+	return post(ctx, joinPath(s.URL, "/loki/api/v1/push"), "application/json", body, nil)
+}
+
+// Elasticsearch is an EventSink indexing events into an Elasticsearch or
+// OpenSearch index through the bulk API, with their time as @timestamp.
+// Credentials are those of its URL.
+type Elasticsearch struct {
+	// URL is the base URL of the cluster.
+	URL   *url.URL
+	Index string
+}
+
+// NewElasticsearch returns an Elasticsearch sink indexing into index of the
+// cluster at rawURL.
+func NewElasticsearch(rawURL, index string) (*Elasticsearch, error) {
+	u, err := parseHTTPURL("Elasticsearch", rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if index == "" {
+		index = "vsphere-events"
+	}
+	if p, ok := u.User.Password(); ok {
+		collector.AddSecret(p)
+	}
+	return &Elasticsearch{URL: u, Index: index}, nil
+}
+
+// WriteEvents indexes events in a single bulk request.
+func (s *Elasticsearch) WriteEvents(ctx context.Context, events []collector.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, e := range events {
+		action := map[string]interface{}{"index": map[string]string{"_index": s.Index}}
+		doc := struct {
+			Timestamp time.Time `json:"@timestamp"`
+			collector.Event
+		}{e.Time, e}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	var res struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := post(ctx, joinPath(s.URL, "/_bulk"), "application/x-ndjson", b.Bytes(), &res); err != nil {
+		return err
+	}
+	if !res.Errors {
+		return nil
+	}
+
+	failed := 0
+	var first json.RawMessage
+	for _, item := range res.Items {
+		for _, r := range item {
+			if r.Status/100 != 2 {
+				if failed == 0 {
+					first = r.Error
+				}
+				failed++
+			}
+		}
+	}
+	return fmt.Errorf("elasticsearch: %d of %d events failed to index: %s", failed, len(events), first)
+}
+
+// EventDryRun is an EventSink printing what the event sink it stands in for
+// would write, without writing it.
+type EventDryRun struct {
+	w    *bufio.Writer
+	name string
+}
+
+// NewEventDryRun returns an EventDryRun printing to w the events the event
+// sink called name would write.
+func NewEventDryRun(w io.Writer, name string) *EventDryRun {
+	return &EventDryRun{w: bufio.NewWriter(w), name: name}
+}
+
+// WriteEvents prints events as JSON lines after a "#" comment line naming the
+// sink.
+func (s *EventDryRun) WriteEvents(ctx context.Context, events []collector.Event) error {
+	fmt.Fprintf(s.w, "# dry run: event sink %s would write %d events\n", s.name, len(events))
+	enc := json.NewEncoder(s.w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+// parseHTTPURL parses the http or https URL of the service called name.
+func parseHTTPURL(name, rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s URL %q", name, collector.Redact(rawURL))
+	}
+	return u, nil
+}
+
+// joinPath returns the URL of path under base.
+func joinPath(base *url.URL, path string) string {
+	u := *base
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return u.String()
+}
+
+// post posts body, decoding the JSON response into res unless nil.
+func post(ctx context.Context, u, contentType string, body []byte, res interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := collector.Outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
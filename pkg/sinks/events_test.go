@@ -0,0 +1,84 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+var testEvents = []collector.Event{
+	{Time: time.Unix(1700000000, 0), VCenter: "vc", Key: 1, Type: "VmPoweredOnEvent", Severity: "info", Message: "vm0 on esx01 is powered on", VM: "vm0"},
+	{Time: time.Unix(1700000001, 0), VCenter: "vc", Key: 2, Type: "HostConnectionLostEvent", Severity: "error", Message: "Host esx01 is not responding", Host: "esx01"},
+}
+
+func TestLoki(t *testing.T) {
+	var body struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	loki, err := NewLoki(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loki.WriteEvents(context.Background(), testEvents); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body.Streams) != 2 || body.Streams[1].Stream["severity"] != "error" {
+		t.Fatalf("streams %+v, expected one per severity", body.Streams)
+	}
+	if v := body.Streams[0].Values[0]; v[0] != "1700000000000000000" || !strings.Contains(v[1], `"type":"VmPoweredOnEvent"`) {
+		t.Errorf("value %v", v)
+	}
+}
+
+func TestElasticsearchErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors": true, "items": [{"index": {"status": 201}}, {"index": {"status": 400, "error": {"type": "mapper_parsing_exception"}}}]}`))
+	}))
+	defer s.Close()
+
+	es, err := NewElasticsearch(s.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = es.WriteEvents(context.Background(), testEvents)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 events") {
+		t.Errorf("err=%v, expected the failed item", err)
+	}
+}
+
+func TestEventFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f := NewEventFile(path)
+	for i := 0; i < 2; i++ {
+		if err := f.WriteEvents(context.Background(), testEvents); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 4 {
+		t.Errorf("%d lines, expected 4 appended", n)
+	}
+}
@@ -0,0 +1,391 @@
+package sinks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// GrafanaDashboard is a Grafana dashboard, as read by Grafana from the JSON
+// files of a dashboard provider.
+type GrafanaDashboard struct {
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Refresh       string            `json:"refresh"`
+	Time          grafanaTime       `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label,omitempty"`
+	Type       string             `json:"type"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Query      string             `json:"query"`
+	Regex      string             `json:"regex,omitempty"`
+	Refresh    int                `json:"refresh,omitempty"`
+	Sort       int                `json:"sort,omitempty"`
+	Multi      bool               `json:"multi"`
+	IncludeAll bool               `json:"includeAll"`
+	AllValue   string             `json:"allValue,omitempty"`
+}
+
+type grafanaPanel struct {
+	ID          int                 `json:"id"`
+	Type        string              `json:"type"`
+	Title       string              `json:"title"`
+	GridPos     grafanaGridPos      `json:"gridPos"`
+	Datasource  *grafanaDatasource  `json:"datasource,omitempty"`
+	Targets     []grafanaTarget     `json:"targets,omitempty"`
+	FieldConfig *grafanaFieldConfig `json:"fieldConfig,omitempty"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	RefID string `json:"refId"`
+	// InfluxQL
+	Query        string `json:"query,omitempty"`
+	RawQuery     bool   `json:"rawQuery,omitempty"`
+	ResultFormat string `json:"resultFormat,omitempty"`
+	Alias        string `json:"alias,omitempty"`
+	// Graphite
+	Target string `json:"target,omitempty"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults struct {
+		Unit string `json:"unit"`
+	} `json:"defaults"`
+}
+
+// grafanaUnits maps the units of the schema to Grafana units.
+var grafanaUnits = map[string]string{
+	"":        "none",
+	"bytes":   "bytes",
+	"MB":      "decmbytes",
+	"MHz":     "suffix: MHz",
+	"count":   "short",
+	"percent": "percent",
+	"seconds": "s",
+	"days":    "d",
+}
+
+// grafanaEntity describes the vSphere entities of a measurement, and the
+// inventory path of their metrics, from which the dashboard variables are
+// extracted.
+type grafanaEntity struct {
+	title string
+	// variable is the variable of the names of the entities.
+	variable string
+	// folder is the folder of the entities under their datacenter.
+	folder string
+	// clustered entities are inventoried under their cluster, or their
+	// standalone compute resource.
+	clustered bool
+}
+
+var grafanaEntities = map[string]grafanaEntity{
+	"datastore": {title: "Datastores", variable: "datastore", folder: "datastore"},
+	"host":      {title: "Hosts", variable: "host", folder: "host", clustered: true},
+	"vm":        {title: "Virtual machines", variable: "vm", folder: "vm"},
+}
+
+// grafanaQuerier writes the queries of a Grafana datasource.
+type grafanaQuerier interface {
+	datasource() string
+	// tagValues queries the values of tag of measurement, filtered by the
+	// vcenter and, unless "", path regular expressions.
+	tagValues(measurement, tag, path string) string
+	// field queries field of measurement, filtered by the vcenter and path
+	// regular expressions, as a series per path.
+	field(measurement string, f collector.FieldSchema, path string) grafanaTarget
+	// series queries field of measurement, as a series per value of tags.
+	series(measurement string, f collector.FieldSchema, tags []string) grafanaTarget
+}
+
+// GrafanaDashboards returns a Grafana dashboard per collector, of the fields
+// of schema, and one of the collector itself, querying the metrics written in
+// format: line from InfluxDB 1.x or graphite-tags from Graphite. names maps
+// the measurements of schema to their name in the output, if renamed.
+//
+// Dashboards have a variable of the vCenter, datacenter and name of the
+// entities, and the cluster of hosts, extracted from the vcenter and path
+// tags.
+func GrafanaDashboards(format string, schema []collector.MeasurementSchema, names map[string]string) ([]*GrafanaDashboard, error) {
+	var q grafanaQuerier
+	switch format {
+	case "line", "":
+		q = influxQL{names: names}
+	case "graphite-tags":
+		q = graphiteTagged{names: names}
+	default:
+		return nil, fmt.Errorf("no Grafana dashboards of format %q: expected line or graphite-tags", format)
+	}
+	ds := &grafanaDatasource{Type: q.datasource(), UID: "${datasource}"}
+
+	var dashboards []*GrafanaDashboard
+	self := newGrafanaDashboard("vsphere-collector", "vSphere collector", q)
+	for _, ms := range schema {
+		e, ok := grafanaEntities[ms.Measurement]
+		if !ok {
+			if ms.Measurement == "collector" || ms.Measurement == "cycle" || ms.Measurement == "vcenter_probe" || ms.Measurement == "guardrail" {
+				self.addRow(ms.Measurement)
+				for _, f := range ms.Fields {
+					if f.Type == collector.String {
+						continue
+					}
+					self.addPanel(ms.Measurement+" "+f.Name, f, ds, q.series(ms.Measurement, f, ms.Tags))
+				}
+			}
+			continue
+		}
+
+		d := newGrafanaDashboard("vsphere-"+ms.Measurement, "vSphere "+e.title, q)
+		d.addVariables(ms.Measurement, e, ds, q)
+		path := e.path(true)
+		for _, f := range ms.Fields {
+			if f.Type == collector.String {
+				continue
+			}
+			d.addPanel(f.Name, f, ds, q.field(ms.Measurement, f, path))
+		}
+		dashboards = append(dashboards, d)
+	}
+	return append(dashboards, self), nil
+}
+
+func newGrafanaDashboard(uid, title string, q grafanaQuerier) *GrafanaDashboard {
+	return &GrafanaDashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"vsphere", "vsphere-collector"},
+		SchemaVersion: 39,
+		Refresh:       "1m",
+		Time:          grafanaTime{From: "now-6h", To: "now"},
+		Templating: grafanaTemplating{List: []grafanaVariable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: q.datasource()},
+		}},
+	}
+}
+
+// path returns the regular expression of the inventory paths of the entities
+// of e in the datacenters and clusters selected by the variables, and of the
+// names selected unless !entity.
+func (e grafanaEntity) path(entity bool) string {
+	path := "/(${datacenter:regex})/" + e.folder + "/"
+	if e.clustered {
+		path += "(${cluster:regex})/"
+	} else {
+		// Datastores and virtual machines may be in folders
+		path += "(.*/)?"
+	}
+	if entity {
+		path += "(${" + e.variable + ":regex})"
+	}
+	return path
+}
+
+func (d *GrafanaDashboard) addVariables(measurement string, e grafanaEntity, ds *grafanaDatasource, q grafanaQuerier) {
+	variable := func(name, label, query, regex string) grafanaVariable {
+		// Refresh on time range change, sort alphabetically, case insensitive
+		return grafanaVariable{
+			Name: name, Label: label, Type: "query", Datasource: ds, Query: query, Regex: regex,
+			Refresh: 2, Sort: 5, Multi: true, IncludeAll: true, AllValue: ".*",
+		}
+	}
+
+	d.Templating.List = append(d.Templating.List,
+		variable("vcenter", "vCenter", q.tagValues(measurement, "vcenter", ""), ""),
+		variable("datacenter", "Datacenter", q.tagValues(measurement, "path", ""), `/^\/([^\/]+)\//`),
+	)
+	if e.clustered {
+		d.Templating.List = append(d.Templating.List,
+			variable("cluster", "Cluster", q.tagValues(measurement, "path", "/(${datacenter:regex})/"), `/^\/[^\/]+\/`+e.folder+`\/([^\/]+)\//`))
+	}
+	d.Templating.List = append(d.Templating.List,
+		variable(e.variable, strings.TrimSuffix(e.title, "s"), q.tagValues(measurement, "path", e.path(false)), `/([^\/]+)$/`))
+}
+
+// addRow adds a row of title below the panels.
+func (d *GrafanaDashboard) addRow(title string) {
+	d.Panels = append(d.Panels, grafanaPanel{
+		ID:      len(d.Panels) + 1,
+		Type:    "row",
+		Title:   title,
+		GridPos: grafanaGridPos{H: 1, W: 24, Y: d.bottom()},
+	})
+}
+
+// addPanel adds a time series panel of target, two per line.
+func (d *GrafanaDashboard) addPanel(title string, f collector.FieldSchema, ds *grafanaDatasource, target grafanaTarget) {
+	pos := grafanaGridPos{H: 8, W: 12, Y: d.bottom()}
+	if n := len(d.Panels); n != 0 {
+		if last := d.Panels[n-1]; last.Type != "row" && last.GridPos.X == 0 {
+			pos.X, pos.Y = 12, last.GridPos.Y
+		}
+	}
+
+	fc := &grafanaFieldConfig{}
+	fc.Defaults.Unit = grafanaUnits[f.Unit]
+	target.RefID = "A"
+	d.Panels = append(d.Panels, grafanaPanel{
+		ID:          len(d.Panels) + 1,
+		Type:        "timeseries",
+		Title:       title,
+		GridPos:     pos,
+		Datasource:  ds,
+		Targets:     []grafanaTarget{target},
+		FieldConfig: fc,
+	})
+}
+
+// bottom returns the y of the bottom of the panels.
+func (d *GrafanaDashboard) bottom() int {
+	y := 0
+	for _, p := range d.Panels {
+		if b := p.GridPos.Y + p.GridPos.H; b > y {
+			y = b
+		}
+	}
+	return y
+}
+
+// influxQL queries the metrics of LineProtocol with InfluxQL.
+type influxQL struct {
+	names map[string]string
+}
+
+func (influxQL) datasource() string {
+	return "influxdb"
+}
+
+func (q influxQL) measurement(m string) string {
+	if name, ok := q.names[m]; ok {
+		m = name
+	}
+	return influxIdentifier(m)
+}
+
+// influxRegex returns the InfluxQL regular expression literal of re.
+func influxRegex(re string) string {
+	return "/^" + strings.ReplaceAll(re, "/", `\/`) + "/"
+}
+
+// influxIdentifier quotes an InfluxQL identifier.
+func influxIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(Sanitize(s), `"`, `\"`) + `"`
+}
+
+func (q influxQL) tagValues(measurement, tag, path string) string {
+	query := fmt.Sprintf(`SHOW TAG VALUES FROM %s WITH KEY = %s WHERE "vcenter" =~ %s`,
+		q.measurement(measurement), influxIdentifier(tag), influxRegex("(${vcenter:regex})$"))
+	if path != "" {
+		query += ` AND "path" =~ ` + influxRegex(path)
+	}
+	return query
+}
+
+func (q influxQL) field(measurement string, f collector.FieldSchema, path string) grafanaTarget {
+	return grafanaTarget{
+		Query: fmt.Sprintf(`SELECT %s FROM %s WHERE "vcenter" =~ %s AND "path" =~ %s AND $timeFilter GROUP BY time($__interval), "path" fill(none)`,
+			influxSelector(f), q.measurement(measurement), influxRegex("(${vcenter:regex})$"), influxRegex(path+"$")),
+		RawQuery:     true,
+		ResultFormat: "time_series",
+		Alias:        "$tag_path",
+	}
+}
+
+func (q influxQL) series(measurement string, f collector.FieldSchema, tags []string) grafanaTarget {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE $timeFilter GROUP BY time($__interval)`, influxSelector(f), q.measurement(measurement))
+	var alias []string
+	for _, t := range tags {
+		query += ", " + influxIdentifier(t)
+		alias = append(alias, "$tag_"+t)
+	}
+	if len(alias) == 0 {
+		alias = []string{f.Name}
+	}
+	return grafanaTarget{
+		Query:        query + " fill(none)",
+		RawQuery:     true,
+		ResultFormat: "time_series",
+		Alias:        strings.Join(alias, " "),
+	}
+}
+
+// influxSelector returns the aggregation of field f. Booleans cannot be
+// averaged.
+func influxSelector(f collector.FieldSchema) string {
+	if f.Type == collector.Boolean {
+		return "last(" + influxIdentifier(f.Name) + ")"
+	}
+	return "mean(" + influxIdentifier(f.Name) + ")"
+}
+
+// graphiteTagged queries the metrics of Graphite with Tags.
+type graphiteTagged struct {
+	names map[string]string
+}
+
+func (graphiteTagged) datasource() string {
+	return "graphite"
+}
+
+func (q graphiteTagged) name(measurement, field string) string {
+	if name, ok := q.names[measurement]; ok {
+		measurement = name
+	}
+	return graphitePath(measurement) + "." + graphitePath(field)
+}
+
+func (q graphiteTagged) tagValues(measurement, tag, path string) string {
+	// Series names are measurement.field
+	name := strings.ReplaceAll(q.name(measurement, ""), ".", `\.`)
+	query := fmt.Sprintf("tag_values(%s, name=~^%s, vcenter=~^(${vcenter:regex})$", graphiteTagKey(tag), name)
+	if path != "" {
+		query += ", path=~^" + path
+	}
+	return query + ")"
+}
+
+func (q graphiteTagged) field(measurement string, f collector.FieldSchema, path string) grafanaTarget {
+	return grafanaTarget{
+		Target: fmt.Sprintf("aliasByTags(seriesByTag('name=%s', 'vcenter=~^(${vcenter:regex})$', 'path=~^%s$'), 'path')", q.name(measurement, f.Name), path),
+	}
+}
+
+func (q graphiteTagged) series(measurement string, f collector.FieldSchema, tags []string) grafanaTarget {
+	target := fmt.Sprintf("seriesByTag('name=%s')", q.name(measurement, f.Name))
+	if len(tags) == 0 {
+		return grafanaTarget{Target: fmt.Sprintf("alias(%s, '%s')", target, f.Name)}
+	}
+	keys := make([]string, len(tags))
+	for i, t := range tags {
+		keys[i] = "'" + graphiteTagKey(t) + "'"
+	}
+	return grafanaTarget{Target: fmt.Sprintf("aliasByTags(%s, %s)", target, strings.Join(keys, ", "))}
+}
@@ -0,0 +1,54 @@
+package sinks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestGrafanaDashboards(t *testing.T) {
+	schema := []collector.MeasurementSchema{
+		{Measurement: "host", Tags: []string{"name", "path", "vcenter"}, Fields: []collector.FieldSchema{
+			{Name: "cpu_mhz", Type: collector.Integer, Unit: "MHz"},
+			{Name: "model", Type: collector.String},
+		}},
+		{Measurement: "cycle", Fields: []collector.FieldSchema{{Name: "duration_sec", Type: collector.Float, Unit: "seconds"}}},
+	}
+
+	for _, tc := range []struct {
+		format, expect string
+	}{
+		{"line", `SELECT mean("cpu_mhz") FROM "vsphere_host" WHERE "vcenter" =~ /^(${vcenter:regex})$/ AND "path" =~ /^\/(${datacenter:regex})\/host\/(${cluster:regex})\/(${host:regex})$/ AND $timeFilter GROUP BY time($__interval), "path" fill(none)`},
+		{"graphite-tags", `aliasByTags(seriesByTag('name=vsphere_host.cpu_mhz', 'vcenter=~^(${vcenter:regex})$', 'path=~^/(${datacenter:regex})/host/(${cluster:regex})/(${host:regex})$'), 'path')`},
+	} {
+		dashboards, err := GrafanaDashboards(tc.format, schema, map[string]string{"host": "vsphere_host"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dashboards) != 2 || dashboards[0].UID != "vsphere-host" || dashboards[1].UID != "vsphere-collector" {
+			t.Fatalf("%s: dashboards %+v", tc.format, dashboards)
+		}
+
+		d := dashboards[0]
+		if len(d.Panels) != 1 {
+			t.Fatalf("%s: %d panels, expected 1 without string fields", tc.format, len(d.Panels))
+		}
+		target := d.Panels[0].Targets[0]
+		if got := target.Query + target.Target; got != tc.expect {
+			t.Errorf("%s: query\n%s\nexpected\n%s", tc.format, got, tc.expect)
+		}
+
+		var variables []string
+		for _, v := range d.Templating.List {
+			variables = append(variables, v.Name)
+		}
+		if got := strings.Join(variables, ","); got != "datasource,vcenter,datacenter,cluster,host" {
+			t.Errorf("%s: variables %s", tc.format, got)
+		}
+	}
+
+	if _, err := GrafanaDashboards("json", schema, nil); err == nil {
+		t.Error("dashboards of json")
+	}
+}
@@ -0,0 +1,148 @@
+package sinks
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Graphite encodes metrics in the Carbon plaintext protocol, one line per
+// numeric field. Without Tags, the values of the tags are flattened into the
+// path, sorted by key, as measurement.tag_values....field. With Tags, the
+// path is measurement.field followed by the Graphite 1.1 ;key=value tags.
+// Boolean fields are written as 0 or 1 and string fields are left out.
+type Graphite struct {
+	Tags bool
+}
+
+func (g Graphite) Encode(w io.Writer, metrics []collector.Metric) error {
+	b := getBuffer()
+	defer putBuffer(b)
+	keys := getKeys()
+	defer putKeys(keys)
+
+	for _, m := range metrics {
+		*keys = g.writeMetric(b, m, (*keys)[:0])
+		if b.Len() >= lineBatch {
+			if _, err := w.Write(b.Bytes()); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (Graphite) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// writeMetric appends the lines of m to b, sorting its tag and field keys in
+// keys, which it returns for reuse.
+func (g Graphite) writeMetric(b *bytes.Buffer, m collector.Metric, keys []string) []string {
+	for k, v := range m.Tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var prefix, suffix strings.Builder
+	prefix.WriteString(graphitePath(m.Name))
+	for _, k := range keys {
+		if g.Tags {
+			suffix.WriteByte(';')
+			suffix.WriteString(graphiteTagKey(k))
+			suffix.WriteByte('=')
+			suffix.WriteString(graphiteTagValue(m.Tags[k]))
+		} else {
+			prefix.WriteByte('.')
+			prefix.WriteString(graphitePath(m.Tags[k]))
+		}
+	}
+
+	// The tags are in prefix and suffix
+	fields := keys[:0]
+	for k := range m.Fields {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	ts := strconv.FormatInt(m.Time.Unix(), 10)
+	for _, k := range fields {
+		v, ok := graphiteValue(m.Fields[k])
+		if !ok {
+			continue
+		}
+		b.WriteString(prefix.String())
+		b.WriteByte('.')
+		b.WriteString(graphitePath(k))
+		b.WriteString(suffix.String())
+		b.WriteByte(' ')
+		b.WriteString(v)
+		b.WriteByte(' ')
+		b.WriteString(ts)
+		b.WriteByte('\n')
+	}
+	return fields
+}
+
+func graphiteValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
+
+// graphitePath returns s as a single node of a path, replacing the
+// characters Carbon treats specially by underscores.
+func graphitePath(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, Sanitize(s))
+}
+
+// graphiteTagKey replaces the characters tag keys cannot have by
+// underscores.
+func graphiteTagKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ';', '!', '^', '=', ' ':
+			return '_'
+		}
+		return r
+	}, Sanitize(s))
+}
+
+// graphiteTagValue replaces the characters tag values cannot have by
+// underscores, including a leading ~.
+func graphiteTagValue(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ';', ' ':
+			return '_'
+		}
+		return r
+	}, Sanitize(s))
+	if strings.HasPrefix(s, "~") {
+		s = "_" + s[1:]
+	}
+	return s
+}
@@ -0,0 +1,34 @@
+package sinks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestGraphite(t *testing.T) {
+	m := collector.Metric{
+		Name:   "vm",
+		Tags:   map[string]string{"name": "web 01", "vcenter": "vc.example.com", "note": ""},
+		Fields: map[string]interface{}{"num_cpu": int64(2), "degraded": true, "annotation": "skipped"},
+		Time:   time.Unix(1700000000, 0),
+	}
+
+	for _, tc := range []struct {
+		enc    Graphite
+		expect string
+	}{
+		{Graphite{}, "vm.web_01.vc_example_com.degraded 1 1700000000\nvm.web_01.vc_example_com.num_cpu 2 1700000000\n"},
+		{Graphite{Tags: true}, "vm.degraded;name=web_01;vcenter=vc.example.com 1 1700000000\nvm.num_cpu;name=web_01;vcenter=vc.example.com 2 1700000000\n"},
+	} {
+		var b strings.Builder
+		if err := tc.enc.Encode(&b, []collector.Metric{m}); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tc.expect {
+			t.Errorf("tags=%v: got %q, expected %q", tc.enc.Tags, b.String(), tc.expect)
+		}
+	}
+}
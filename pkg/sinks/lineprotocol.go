@@ -0,0 +1,111 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// LineProtocol encodes metrics as InfluxDB line protocol, one point per line.
+type LineProtocol struct{}
+
+// lineBatch is the size past which Encode writes the points encoded so far.
+const lineBatch = 64 << 10
+
+func (LineProtocol) Encode(w io.Writer, metrics []collector.Metric) error {
+	b := getBuffer()
+	defer putBuffer(b)
+	keys := getKeys()
+	defer putKeys(keys)
+
+	for _, m := range metrics {
+		*keys = appendMetric(b, m, (*keys)[:0])
+		if b.Len() >= lineBatch {
+			if _, err := w.Write(b.Bytes()); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (LineProtocol) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// WriteMetric writes m as a single InfluxDB line protocol point.
+func WriteMetric(w io.Writer, m collector.Metric) error {
+	b := getBuffer()
+	defer putBuffer(b)
+	keys := getKeys()
+	defer putKeys(keys)
+
+	*keys = appendMetric(b, m, (*keys)[:0])
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// appendMetric appends m to b as a line protocol point, sorting its tag and
+// field keys in keys, which it returns for reuse.
+func appendMetric(b *bytes.Buffer, m collector.Metric, keys []string) []string {
+	b.WriteString(escapeMeasurement(m.Name))
+
+	for k := range m.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if m.Tags[k] == "" {
+			continue
+		}
+		b.WriteByte(',')
+		b.WriteString(escapeTag(k))
+		b.WriteByte('=')
+		b.WriteString(escapeTag(m.Tags[k]))
+	}
+
+	keys = keys[:0]
+	for k := range m.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(escapeTag(k))
+		b.WriteByte('=')
+		appendField(b, m.Fields[k])
+	}
+	b.WriteByte(' ')
+	b.Write(strconv.AppendInt(b.AvailableBuffer(), m.Time.UnixNano(), 10))
+	b.WriteByte('\n')
+	return keys
+}
+
+// appendField appends the line protocol value of v to b.
+func appendField(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case int64:
+		b.Write(strconv.AppendInt(b.AvailableBuffer(), v, 10))
+		b.WriteByte('i')
+	case float64:
+		b.Write(strconv.AppendFloat(b.AvailableBuffer(), v, 'g', -1, 64))
+	case bool:
+		b.Write(strconv.AppendBool(b.AvailableBuffer(), v))
+	case string:
+		b.WriteString(escapeString(v))
+	default:
+		b.WriteString(escapeString(fmt.Sprint(v)))
+	}
+}
@@ -0,0 +1,102 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestWritePointEscaping(t *testing.T) {
+	tags := map[string]string{
+		"name": "web, db=1",
+		"note": "line\nbreak",
+	}
+	fields := map[string]interface{}{
+		"annotation": `say "hi" \o/`,
+		"num_cpu":    int64(2),
+	}
+	m := collector.Metric{Name: "test", Tags: tags, Fields: fields, Time: time.Unix(0, 42)}
+
+	var b strings.Builder
+	if err := WriteMetric(&b, m); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `test,name=web\,\ db\=1,note=line\ break annotation="say \"hi\" \\o/",num_cpu=2i 42` + "\n"
+	if b.String() != expect {
+		t.Errorf("got %q, expected %q", b.String(), expect)
+	}
+}
+
+func BenchmarkWriteMetric(b *testing.B) {
+	m := collector.Metric{
+		Name: "vm",
+		Tags: map[string]string{
+			"name":    "DC0_H0_VM0",
+			"vcenter": "vcsa.example.com",
+			"guest":   "otherGuest",
+		},
+		Fields: map[string]interface{}{
+			"num_cpu":           int64(2),
+			"mem_mb":            int64(2048),
+			"overall_cpu_usage": int64(120),
+		},
+		Time: time.Now(),
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := WriteMetric(io.Discard, m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	metrics := make([]collector.Metric, 1000)
+	for i := range metrics {
+		metrics[i] = collector.Metric{
+			Name: "vm",
+			Tags: map[string]string{
+				"name":    "vm-" + strconv.Itoa(i),
+				"vcenter": "vcsa.example.com",
+				"moid":    "vm-" + strconv.Itoa(i),
+				"path":    "/DC0/vm/vm-" + strconv.Itoa(i),
+			},
+			Fields: map[string]interface{}{
+				"num_cpu":           int64(2),
+				"mem_mb":            int64(2048),
+				"overall_cpu_usage": int64(120),
+				"question_pending":  false,
+				"cpu_ready_pct":     1.5,
+			},
+			Time: time.Now(),
+		}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := (LineProtocol{}).Encode(io.Discard, metrics); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	m := collector.Metric{Name: "cycle", Fields: map[string]interface{}{"endpoints": int64(1)}, Time: time.Unix(0, 42)}
+
+	var b strings.Builder
+	if err := NewDryRun(&b, "stdout", LineProtocol{}).Write(context.Background(), []collector.Metric{m}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "# dry run: sink stdout would write 1 metrics as text/plain; charset=utf-8\ncycle endpoints=1i 42\n"
+	if b.String() != expect {
+		t.Errorf("got %q, expected %q", b.String(), expect)
+	}
+}
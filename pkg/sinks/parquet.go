@@ -0,0 +1,506 @@
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Parquet encodes metrics as an Apache Parquet file of a single row group,
+// a row per metric, for analytics engines such as DuckDB, Athena, pandas or
+// Spark. Its columns are:
+//
+//   - time, the timestamp of the metric in milliseconds
+//   - measurement
+//   - entity_type and entity_moid, the managed object measured, null for
+//     the metrics of the collector itself
+//   - a column per tag key and field of the metrics, null for the metrics
+//     not having it, sorted by name
+//
+// Tags and fields named as an earlier column are prefixed with tag_ and
+// field_. A field column has the type declared in collector.Fields, or else
+// that of its first value; values of another type are null, but integers of
+// float columns.
+//
+// Pages are compressed with gzip unless Uncompressed. Unlike other encoders,
+// the metrics of a call are written as a whole file, so a Parquet encoder
+// writes a single batch to a writer.
+type Parquet struct {
+	Uncompressed bool
+}
+
+func (Parquet) ContentType() string {
+	return "application/vnd.apache.parquet"
+}
+
+// Parquet physical, converted and codec types, and encodings, of the Thrift
+// definitions of the format.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetUncompressed = 0
+	parquetGzip         = 2
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetColumn is a column of a Parquet file and its values, nil for null.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	required  bool
+	values    []interface{}
+}
+
+// parquetChunk is the column chunk of a column in a row group.
+type parquetChunk struct {
+	offset, size, compressed, values int64
+}
+
+// parquetRowGroup is a row group of a Parquet file.
+type parquetRowGroup struct {
+	rows, size int64
+	chunks     []parquetChunk
+}
+
+func (e Parquet) Encode(w io.Writer, metrics []collector.Metric) error {
+	columns := parquetColumns(metrics)
+
+	// Files of no rows have no row group
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+	var groups []parquetRowGroup
+	if len(metrics) != 0 {
+		g, err := e.writeRowGroup(&file, 0, columns)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, g)
+	}
+	e.writeFooter(&file, columns, groups)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+func (e Parquet) codec() int32 {
+	if e.Uncompressed {
+		return parquetUncompressed
+	}
+	return parquetGzip
+}
+
+// writeRowGroup appends the column chunks of the values of columns to b, at
+// offset in the file, a single data page each.
+func (e Parquet) writeRowGroup(b *bytes.Buffer, offset int64, columns []*parquetColumn) (parquetRowGroup, error) {
+	g := parquetRowGroup{rows: int64(len(columns[0].values))}
+	for _, c := range columns {
+		body := c.page()
+		uncompressed := len(body)
+		if e.codec() == parquetGzip {
+			var z bytes.Buffer
+			zw := gzip.NewWriter(&z)
+			zw.Write(body)
+			if err := zw.Close(); err != nil {
+				return g, err
+			}
+			body = z.Bytes()
+		}
+
+		var h thriftWriter
+		h.begin()
+		h.i32(1, 0) // DATA_PAGE
+		h.i32(2, int32(uncompressed))
+		h.i32(3, int32(len(body)))
+		h.structField(5)
+		h.i32(1, int32(len(c.values)))
+		h.i32(2, parquetPlain)
+		h.i32(3, parquetRLE)
+		h.i32(4, parquetRLE)
+		h.end()
+		h.end()
+
+		chunk := parquetChunk{
+			offset:     offset + int64(b.Len()),
+			size:       int64(h.b.Len() + uncompressed),
+			compressed: int64(h.b.Len() + len(body)),
+			values:     int64(len(c.values)),
+		}
+		b.Write(h.b.Bytes())
+		b.Write(body)
+		g.chunks = append(g.chunks, chunk)
+		g.size += chunk.size
+	}
+	return g, nil
+}
+
+// writeFooter appends the file metadata of the schema of columns and of
+// groups to b, followed by its length and the trailing magic.
+func (e Parquet) writeFooter(b *bytes.Buffer, columns []*parquetColumn, groups []parquetRowGroup) {
+	var rows int64
+	for _, g := range groups {
+		rows += g.rows
+	}
+
+	var m thriftWriter
+	m.begin()
+	m.i32(1, 1)
+	m.list(2, thriftStruct, len(columns)+1)
+	m.begin()
+	m.str(4, "schema")
+	m.i32(5, int32(len(columns)))
+	m.end()
+	for _, c := range columns {
+		m.begin()
+		m.i32(1, c.typ)
+		if c.required {
+			m.i32(3, 0)
+		} else {
+			m.i32(3, 1)
+		}
+		m.str(4, c.name)
+		if c.converted >= 0 {
+			m.i32(6, c.converted)
+		}
+		m.end()
+	}
+	m.i64(3, rows)
+	m.list(4, thriftStruct, len(groups))
+	for _, g := range groups {
+		m.begin()
+		m.list(1, thriftStruct, len(columns))
+		for i, c := range columns {
+			chunk := g.chunks[i]
+			m.begin()
+			m.i64(2, chunk.offset)
+			m.structField(3)
+			m.i32(1, c.typ)
+			m.list(2, thriftI32, 2)
+			m.listI32(parquetPlain)
+			m.listI32(parquetRLE)
+			m.list(3, thriftBinary, 1)
+			m.listStr(c.name)
+			m.i32(4, e.codec())
+			m.i64(5, chunk.values)
+			m.i64(6, chunk.size)
+			m.i64(7, chunk.compressed)
+			m.i64(9, chunk.offset)
+			m.end()
+			m.end()
+		}
+		m.i64(2, g.size)
+		m.i64(3, g.rows)
+		m.end()
+	}
+	m.str(6, "vsphere-collector")
+	m.end()
+
+	b.Write(m.b.Bytes())
+	binary.Write(b, binary.LittleEndian, uint32(m.b.Len()))
+	b.WriteString("PAR1")
+}
+
+// parquetSchema returns a key of the schema of columns, equal for columns
+// of the same names and types.
+func parquetSchema(columns []*parquetColumn) string {
+	var b strings.Builder
+	for _, c := range columns {
+		fmt.Fprintf(&b, "%s:%d:%d:%t;", c.name, c.typ, c.converted, c.required)
+	}
+	return b.String()
+}
+
+// parquetColumns returns the columns of metrics and their values.
+func parquetColumns(metrics []collector.Metric) []*parquetColumn {
+	base := []*parquetColumn{
+		{name: "time", typ: parquetInt64, converted: parquetTimestampMillis, required: true},
+		{name: "measurement", typ: parquetByteArray, converted: parquetUTF8, required: true},
+		{name: "entity_type", typ: parquetByteArray, converted: parquetUTF8},
+		{name: "entity_moid", typ: parquetByteArray, converted: parquetUTF8},
+	}
+	names := make(map[string]bool)
+	for _, c := range base {
+		names[c.name] = true
+	}
+
+	tagKeys := make(map[string]bool)
+	fieldTypes := make(map[string]collector.FieldType)
+	for _, m := range metrics {
+		for k := range m.Tags {
+			tagKeys[k] = true
+		}
+		for k, v := range m.Fields {
+			if _, ok := fieldTypes[k]; ok {
+				continue
+			}
+			if t, ok := collector.Fields[m.Name][k]; ok {
+				fieldTypes[k] = t
+				continue
+			}
+			switch v.(type) {
+			case int64:
+				fieldTypes[k] = collector.Integer
+			case float64:
+				fieldTypes[k] = collector.Float
+			case bool:
+				fieldTypes[k] = collector.Boolean
+			default:
+				fieldTypes[k] = collector.String
+			}
+		}
+	}
+
+	tags := parquetNamed(tagKeys, names, "tag_", func(string) *parquetColumn {
+		return &parquetColumn{typ: parquetByteArray, converted: parquetUTF8}
+	})
+	keys := make(map[string]bool, len(fieldTypes))
+	for k := range fieldTypes {
+		keys[k] = true
+	}
+	fields := parquetNamed(keys, names, "field_", func(k string) *parquetColumn {
+		switch fieldTypes[k] {
+		case collector.Integer:
+			return &parquetColumn{typ: parquetInt64, converted: -1}
+		case collector.Float:
+			return &parquetColumn{typ: parquetDouble, converted: -1}
+		case collector.Boolean:
+			return &parquetColumn{typ: parquetBoolean, converted: -1}
+		}
+		return &parquetColumn{typ: parquetByteArray, converted: parquetUTF8}
+	})
+
+	tagOrder, fieldOrder := sortedKeys(tags), sortedKeys(fields)
+	for _, m := range metrics {
+		base[0].values = append(base[0].values, m.Time.UnixMilli())
+		base[1].values = append(base[1].values, m.Name)
+		if m.Entity != nil {
+			base[2].values = append(base[2].values, m.Entity.Type)
+			base[3].values = append(base[3].values, m.Entity.MOID)
+		} else {
+			base[2].values = append(base[2].values, nil)
+			base[3].values = append(base[3].values, nil)
+		}
+		for _, k := range tagOrder {
+			var v interface{}
+			if s, ok := m.Tags[k]; ok {
+				v = s
+			}
+			tags[k].values = append(tags[k].values, v)
+		}
+		for _, k := range fieldOrder {
+			c := fields[k]
+			c.values = append(c.values, c.value(m.Fields[k]))
+		}
+	}
+
+	columns := base
+	for _, k := range tagOrder {
+		columns = append(columns, tags[k])
+	}
+	for _, k := range fieldOrder {
+		columns = append(columns, fields[k])
+	}
+	return columns
+}
+
+// parquetNamed returns the columns of keys by key, named as the key or, if
+// already taken by names, with prefix. The names of the columns are added
+// to names.
+func parquetNamed(keys, names map[string]bool, prefix string, column func(k string) *parquetColumn) map[string]*parquetColumn {
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	columns := make(map[string]*parquetColumn, len(keys))
+	for _, k := range sorted {
+		c := column(k)
+		c.name = k
+		if names[k] {
+			c.name = prefix + k
+		}
+		names[c.name] = true
+		columns[k] = c
+	}
+	return columns
+}
+
+func sortedKeys(columns map[string]*parquetColumn) []string {
+	keys := make([]string, 0, len(columns))
+	for k := range columns {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// value returns v as a value of c, nil if of another type.
+func (c *parquetColumn) value(v interface{}) interface{} {
+	switch c.typ {
+	case parquetInt64:
+		if i, ok := v.(int64); ok {
+			return i
+		}
+	case parquetDouble:
+		switch f := v.(type) {
+		case float64:
+			return f
+		case int64:
+			return float64(f)
+		}
+	case parquetBoolean:
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	case parquetByteArray:
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// page returns the uncompressed body of the data page of c: the definition
+// levels of optional columns, followed by the plain encoding of the values
+// not null.
+func (c *parquetColumn) page() []byte {
+	var b bytes.Buffer
+	if !c.required {
+		// A bit-packed run of the levels of 8 values per byte, 1 for set
+		levels := make([]byte, (len(c.values)+7)/8)
+		for i, v := range c.values {
+			if v != nil {
+				levels[i/8] |= 1 << (i % 8)
+			}
+		}
+		var run bytes.Buffer
+		run.Write(binary.AppendUvarint(nil, uint64(len(levels))<<1|1))
+		run.Write(levels)
+		binary.Write(&b, binary.LittleEndian, uint32(run.Len()))
+		b.Write(run.Bytes())
+	}
+
+	var bits []byte
+	n := 0
+	for _, v := range c.values {
+		switch v := v.(type) {
+		case int64:
+			binary.Write(&b, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&b, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(&b, binary.LittleEndian, uint32(len(v)))
+			b.WriteString(v)
+		case bool:
+			if n%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v {
+				bits[n/8] |= 1 << (n % 8)
+			}
+			n++
+		}
+	}
+	b.Write(bits)
+	return b.Bytes()
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes structs in the Thrift compact protocol, that of the
+// metadata of Parquet files.
+type thriftWriter struct {
+	b bytes.Buffer
+	// last are the IDs of the last fields of the open structs
+	last []int16
+}
+
+// begin opens a struct, a top-level one or an element of a list.
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+// end closes the innermost open struct.
+func (t *thriftWriter) end() {
+	t.b.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		t.b.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.b.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.b.Write(binary.AppendVarint(nil, v))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listStr(s)
+}
+
+// structField opens a struct field, closed by end.
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// list starts a list field of n elements of typ, followed by the elements.
+func (t *thriftWriter) list(id int16, typ byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b.WriteByte(byte(n)<<4 | typ)
+		return
+	}
+	t.b.WriteByte(0xf0 | typ)
+	t.b.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) listStr(s string) {
+	t.b.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.b.WriteString(s)
+}
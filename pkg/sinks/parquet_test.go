@@ -0,0 +1,88 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestParquetColumns(t *testing.T) {
+	now := time.UnixMilli(1714600000123)
+	metrics := []collector.Metric{
+		{
+			Name:   "datastore",
+			Tags:   map[string]string{"name": "ds0", "measurement": "clash"},
+			Fields: map[string]interface{}{"capacity": int64(100), "ratio": 0.5},
+			Time:   now,
+			Entity: &collector.EntityRef{VCenter: "vc", Type: "Datastore", MOID: "datastore-1"},
+		},
+		{
+			Name:   "datastore",
+			Tags:   map[string]string{"name": "ds1"},
+			Fields: map[string]interface{}{"capacity": int64(200), "ratio": int64(1), "name": "field"},
+			Time:   now,
+		},
+	}
+
+	columns := parquetColumns(metrics)
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.name)
+	}
+	expect := []string{"time", "measurement", "entity_type", "entity_moid", "tag_measurement", "name", "capacity", "field_name", "ratio"}
+	if len(names) != len(expect) {
+		t.Fatalf("columns %q, expected %q", names, expect)
+	}
+	for i := range names {
+		if names[i] != expect[i] {
+			t.Errorf("column %d %q, expected %q", i, names[i], expect[i])
+		}
+	}
+
+	byName := make(map[string]*parquetColumn)
+	for _, c := range columns {
+		byName[c.name] = c
+	}
+	for name, expect := range map[string][]interface{}{
+		"time":        {int64(1714600000123), int64(1714600000123)},
+		"entity_moid": {"datastore-1", nil},
+		"name":        {"ds0", "ds1"},
+		"field_name":  {nil, "field"},
+		"capacity":    {int64(100), int64(200)},
+		// ratio takes the type of its first value, converting integers
+		"ratio": {0.5, 1.0},
+	} {
+		got := byName[name].values
+		for i := range expect {
+			if got[i] != expect[i] {
+				t.Errorf("%s: %v, expected %v", name, got, expect)
+				break
+			}
+		}
+	}
+}
+
+func TestParquet(t *testing.T) {
+	metrics := []collector.Metric{{Name: "vm", Fields: map[string]interface{}{"up": true}, Time: time.Now()}}
+	for _, enc := range []Parquet{{}, {Uncompressed: true}} {
+		var b bytes.Buffer
+		if err := enc.Encode(&b, metrics); err != nil {
+			t.Fatal(err)
+		}
+		data := b.Bytes()
+		if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+			t.Fatalf("missing magic: %q", data)
+		}
+		// The footer length precedes the trailing magic
+		footer := binary.LittleEndian.Uint32(data[len(data)-8:])
+		if int(footer) >= len(data)-12 {
+			t.Errorf("footer of %d bytes in a file of %d", footer, len(data))
+		}
+		if !bytes.Contains(data[len(data)-8-int(footer):], []byte("vsphere-collector")) {
+			t.Error("footer missing created_by")
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+	"github.com/mlabouardy/vsphere-collector/pkg/objstore"
+)
+
+// Rotations of Parquet files.
+const (
+	RotateCycle = "cycle"
+	RotateDay   = "day"
+)
+
+// ParquetFiles is a Sink writing metrics as Parquet files below a directory,
+// one per entity type, such as VirtualMachine or HostSystem, and cycle or
+// UTC day, for analyzing them directly with pandas or Spark:
+//
+//	date=2024-05-01/VirtualMachine.parquet         by day
+//	date=2024-05-01/VirtualMachine-130000.parquet  by cycle
+//
+// The metrics of the collector itself are those of the collector type.
+//
+// Daily files gain a row group every cycle, followed by their rewritten
+// footer, so that they can be read between cycles. The file of a day whose
+// columns change, as new tags or fields appear, is continued in a new part,
+// VirtualMachine-1.parquet, as is the file of a day the collector restarted
+// in; readers such as pandas, Spark or DuckDB union the parts of a
+// directory.
+type ParquetFiles struct {
+	Dir      string
+	Rotation string
+	// Target, if set, partitions the files further, as
+	// target=default.vc01/date=2024-05-01/, for the collectors of the
+	// targets of a controller sharing a directory not to overwrite each
+	// other
+	Target string
+
+	enc   Parquet
+	mu    sync.Mutex
+	files map[string]*parquetFile
+}
+
+// parquetFile is a daily Parquet file appended to.
+type parquetFile struct {
+	path   string
+	date   string
+	schema string
+	// columns are those of the schema, without values
+	columns []*parquetColumn
+	groups  []parquetRowGroup
+	// end is the offset of the footer
+	end int64
+}
+
+// NewParquetFiles returns a ParquetFiles sink writing below dir a file per
+// entity type and rotation, cycle or day.
+func NewParquetFiles(dir, rotation string) (*ParquetFiles, error) {
+	switch rotation {
+	case RotateCycle, RotateDay:
+	default:
+		return nil, fmt.Errorf("invalid Parquet rotation %q", rotation)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("missing Parquet directory")
+	}
+	return &ParquetFiles{Dir: dir, Rotation: rotation, files: make(map[string]*parquetFile)}, nil
+}
+
+// Write writes the metrics of every entity type to its file.
+func (s *ParquetFiles) Write(ctx context.Context, metrics []collector.Metric) error {
+	byType := make(map[string][]collector.Metric)
+	for _, m := range metrics {
+		typ := "collector"
+		if m.Entity != nil {
+			typ = m.Entity.Type
+		}
+		byType[typ] = append(byType[typ], m)
+	}
+	types := make([]string, 0, len(byType))
+	for typ := range byType {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, typ := range types {
+		var err error
+		if s.Rotation == RotateCycle {
+			err = s.writeCycle(ctx, typ, byType[typ])
+		} else {
+			err = s.appendDay(typ, byType[typ])
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", typ, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeCycle writes the file of the metrics of typ of a cycle.
+func (s *ParquetFiles) writeCycle(ctx context.Context, typ string, metrics []collector.Metric) error {
+	t := metrics[0].Time.UTC()
+	key := fmt.Sprintf("%s/%s-%s.parquet", s.partition(t.Format("2006-01-02")), typ, t.Format("150405"))
+
+	var b bytes.Buffer
+	if err := s.enc.Encode(&b, metrics); err != nil {
+		return err
+	}
+	return objstore.Dir(s.Dir).Put(ctx, key, b.Bytes(), s.enc.ContentType())
+}
+
+// appendDay appends a row group of the metrics of typ to the file of their
+// day, creating it or a new part of it if its columns changed.
+func (s *ParquetFiles) appendDay(typ string, metrics []collector.Metric) error {
+	date := metrics[0].Time.UTC().Format("2006-01-02")
+	columns := parquetColumns(metrics)
+	schema := parquetSchema(columns)
+
+	f := s.files[typ]
+	if f == nil || f.date != date || f.schema != schema {
+		path, err := s.create(typ, date)
+		if err != nil {
+			return err
+		}
+		f = &parquetFile{path: path, date: date, schema: schema, end: 4}
+		for _, c := range columns {
+			schemaOnly := *c
+			schemaOnly.values = nil
+			f.columns = append(f.columns, &schemaOnly)
+		}
+		s.files[typ] = f
+	}
+
+	var b bytes.Buffer
+	g, err := s.enc.writeRowGroup(&b, f.end, columns)
+	if err != nil {
+		return err
+	}
+	end := f.end + int64(b.Len())
+	groups := append(f.groups, g)
+	s.enc.writeFooter(&b, f.columns, groups)
+
+	file, err := os.OpenFile(f.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	// Truncating drops what a failed write may have left past the footer
+	_, err = file.WriteAt(b.Bytes(), f.end)
+	if err == nil {
+		err = file.Truncate(f.end + int64(b.Len()))
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	f.groups, f.end = groups, end
+	return nil
+}
+
+// partition returns the directory of the files of date below Dir, with
+// slashes.
+func (s *ParquetFiles) partition(date string) string {
+	if s.Target != "" {
+		return "target=" + s.Target + "/date=" + date
+	}
+	return "date=" + date
+}
+
+// create creates the first part of the file of typ and date that doesn't
+// exist yet, holding only the leading magic until its first row group.
+func (s *ParquetFiles) create(typ, date string) (string, error) {
+	dir := filepath.Join(s.Dir, filepath.FromSlash(s.partition(date)))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	for part := 0; ; part++ {
+		name := typ + ".parquet"
+		if part != 0 {
+			name = fmt.Sprintf("%s-%d.parquet", typ, part)
+		}
+		path := filepath.Join(dir, name)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := file.WriteString("PAR1"); err != nil {
+			file.Close()
+			return "", err
+		}
+		return path, file.Close()
+	}
+}
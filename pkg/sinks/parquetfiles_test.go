@@ -0,0 +1,102 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestParquetFiles(t *testing.T) {
+	start := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	cycle := func(t time.Time, fields map[string]interface{}) []collector.Metric {
+		return []collector.Metric{
+			{Name: "vm", Tags: map[string]string{"name": "vm0"}, Fields: fields, Time: t, Entity: &collector.EntityRef{VCenter: "vc", Type: "VirtualMachine", MOID: "vm-42"}},
+			{Name: "cycle", Fields: map[string]interface{}{"duration_ms": int64(1)}, Time: t},
+		}
+	}
+
+	for rotation, expect := range map[string][]string{
+		// The new field of the last cycle continues the day in a new part
+		RotateDay: {"VirtualMachine-1.parquet", "VirtualMachine.parquet", "collector.parquet"},
+		RotateCycle: {
+			"VirtualMachine-130000.parquet", "VirtualMachine-130100.parquet", "VirtualMachine-130200.parquet",
+			"collector-130000.parquet", "collector-130100.parquet", "collector-130200.parquet",
+		},
+	} {
+		dir := t.TempDir()
+		s, err := NewParquetFiles(dir, rotation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, fields := range []map[string]interface{}{
+			{"cpu_usage": int64(1)},
+			{"cpu_usage": int64(2)},
+			{"cpu_usage": int64(3), "tools_running": true},
+		} {
+			if err := s.Write(context.Background(), cycle(start.Add(time.Duration(i)*time.Minute), fields)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		entries, err := os.ReadDir(filepath.Join(dir, "date=2024-05-01"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		if len(names) != len(expect) {
+			t.Fatalf("%s: files %q, expected %q", rotation, names, expect)
+		}
+		for i := range names {
+			if names[i] != expect[i] {
+				t.Errorf("%s: file %q, expected %q", rotation, names[i], expect[i])
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "date=2024-05-01", names[i]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+				t.Errorf("%s: %s is not a Parquet file", rotation, names[i])
+			}
+		}
+	}
+
+	// A restarted collector doesn't append to the files of a previous run
+	dir := t.TempDir()
+	for run := 0; run < 2; run++ {
+		s, _ := NewParquetFiles(dir, RotateDay)
+		if err := s.Write(context.Background(), cycle(start, map[string]interface{}{"cpu_usage": int64(1)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "date=2024-05-01", "VirtualMachine-1.parquet")); err != nil {
+		t.Error(err)
+	}
+
+	// The files of a controller target are partitioned by it
+	for _, rotation := range []string{RotateDay, RotateCycle} {
+		s, _ := NewParquetFiles(dir, rotation)
+		s.Target = "default.vc01"
+		if err := s.Write(context.Background(), cycle(start, map[string]interface{}{"cpu_usage": int64(1)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"VirtualMachine.parquet", "VirtualMachine-130000.parquet"} {
+		if _, err := os.Stat(filepath.Join(dir, "target=default.vc01", "date=2024-05-01", name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := NewParquetFiles(dir, "hour"); err == nil {
+		t.Error("hour rotation accepted")
+	}
+}
@@ -0,0 +1,44 @@
+package sinks
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooled is the capacity past which buffers are not pooled, so that a
+// cycle of unusually large metrics doesn't pin its memory.
+const maxPooled = 1 << 20
+
+// bufferPool and keysPool hold the buffers and key slices encoders reuse
+// across metrics and cycles, most of the allocations of a cycle otherwise
+// being those of serializing its metrics.
+var (
+	bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	keysPool   = sync.Pool{New: func() interface{} {
+		keys := make([]string, 0, 32)
+		return &keys
+	}}
+)
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooled {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+func getKeys() *[]string {
+	return keysPool.Get().(*[]string)
+}
+
+func putKeys(keys *[]string) {
+	// Pooled keys would keep the tags of past metrics alive
+	clear((*keys)[:cap(*keys)])
+	*keys = (*keys)[:0]
+	keysPool.Put(keys)
+}
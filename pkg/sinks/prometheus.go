@@ -0,0 +1,104 @@
+package sinks
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Prometheus encodes metrics in the Prometheus text exposition format, as an
+// exporter serves them: a gauge per numeric field named
+// vsphere_<measurement>_<field>, labelled with the non-empty tags and
+// without timestamps, the scrape setting them. Boolean fields are written as
+// 0 or 1 and string fields are left out.
+type Prometheus struct{}
+
+func (Prometheus) Encode(w io.Writer, metrics []collector.Metric) error {
+	// Samples of a metric must be grouped under its TYPE line
+	samples := make(map[string][]string)
+	for _, m := range metrics {
+		labels := promLabels(m.Tags)
+		for k, f := range m.Fields {
+			v, ok := graphiteValue(f)
+			if !ok {
+				continue
+			}
+			name := promName("vsphere_" + m.Name + "_" + k)
+			samples[name] = append(samples[name], name+labels+" "+v+"\n")
+		}
+	}
+
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.Reset()
+		b.WriteString("# TYPE " + name + " gauge\n")
+		lines := samples[name]
+		sort.Strings(lines)
+		for _, line := range lines {
+			b.WriteString(line)
+		}
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (Prometheus) ContentType() string {
+	return "text/plain; version=0.0.4; charset=utf-8"
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabels returns the {key="value",...} labels of the non-empty tags,
+// sorted by key, or "" for none.
+func promLabels(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(promName(k))
+		b.WriteString(`="`)
+		b.WriteString(promEscaper.Replace(Sanitize(tags[k])))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// promName replaces the characters metric and label names cannot have by
+// underscores, prefixing names starting with a digit by one.
+func promName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
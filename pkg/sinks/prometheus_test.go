@@ -0,0 +1,35 @@
+package sinks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestPrometheus(t *testing.T) {
+	vm := func(name string, cpu int64) collector.Metric {
+		return collector.Metric{
+			Name:   "vm",
+			Tags:   map[string]string{"name": name, "vcenter": "vc.example.com", "note": ""},
+			Fields: map[string]interface{}{"num_cpu": cpu, "degraded": true, "annotation": "skipped"},
+			Time:   time.Unix(1700000000, 0),
+		}
+	}
+
+	var b strings.Builder
+	if err := (Prometheus{}).Encode(&b, []collector.Metric{vm(`web "01"`, 2), vm("db", 4)}); err != nil {
+		t.Fatal(err)
+	}
+	expect := `# TYPE vsphere_vm_degraded gauge
+vsphere_vm_degraded{name="db",vcenter="vc.example.com"} 1
+vsphere_vm_degraded{name="web \"01\"",vcenter="vc.example.com"} 1
+# TYPE vsphere_vm_num_cpu gauge
+vsphere_vm_num_cpu{name="db",vcenter="vc.example.com"} 4
+vsphere_vm_num_cpu{name="web \"01\"",vcenter="vc.example.com"} 2
+`
+	if b.String() != expect {
+		t.Errorf("got\n%s\nexpected\n%s", b.String(), expect)
+	}
+}
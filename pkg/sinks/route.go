@@ -0,0 +1,92 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Route selects the metrics written to a sink by the globs of their
+// measurement: those matching an Include glob, or any when there is none,
+// and no Exclude glob.
+type Route struct {
+	Include []string
+	Exclude []string
+}
+
+// Match reports whether the metrics of measurement take r.
+func (r *Route) Match(measurement string) bool {
+	for _, glob := range r.Exclude {
+		if ok, _ := path.Match(glob, measurement); ok {
+			return false
+		}
+	}
+	if len(r.Include) == 0 {
+		return true
+	}
+	for _, glob := range r.Include {
+		if ok, _ := path.Match(glob, measurement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRoutes parses sink=glob rules into the Route of each sink, such as
+// aria=vm_perf or stdout=!vm_perf, a leading ! excluding the measurements of
+// the glob. The rules of a sink add up.
+func ParseRoutes(rules []string) (map[string]*Route, error) {
+	routes := make(map[string]*Route)
+	for _, rule := range rules {
+		sink, glob, ok := strings.Cut(rule, "=")
+		sink, glob = strings.TrimSpace(sink), strings.TrimSpace(glob)
+		exclude := strings.HasPrefix(glob, "!")
+		glob = strings.TrimPrefix(glob, "!")
+		if !ok || sink == "" || glob == "" {
+			return nil, fmt.Errorf("invalid route %q: expected sink=glob", rule)
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid route %q: %s", rule, err)
+		}
+
+		r := routes[sink]
+		if r == nil {
+			r = &Route{}
+			routes[sink] = r
+		}
+		if exclude {
+			r.Exclude = append(r.Exclude, glob)
+		} else {
+			r.Include = append(r.Include, glob)
+		}
+	}
+	return routes, nil
+}
+
+// Routed is a Sink writing the metrics its Route selects to another.
+type Routed struct {
+	Sink  Sink
+	Route *Route
+}
+
+// Write writes the metrics the route selects, if any.
+func (s *Routed) Write(ctx context.Context, metrics []collector.Metric) error {
+	var routed []collector.Metric
+	for _, m := range metrics {
+		if s.Route.Match(m.Name) {
+			routed = append(routed, m)
+		}
+	}
+	if len(routed) == 0 {
+		return nil
+	}
+	return s.Sink.Write(ctx, routed)
+}
+
+// Flush flushes the sink routed to.
+func (s *Routed) Flush(ctx context.Context) error {
+	return Flush(ctx, s.Sink)
+}
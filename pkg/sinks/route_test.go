@@ -0,0 +1,43 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// recorder is a Sink recording the measurements written to it.
+type recorder []string
+
+func (r *recorder) Write(ctx context.Context, metrics []collector.Metric) error {
+	for _, m := range metrics {
+		*r = append(*r, m.Name)
+	}
+	return nil
+}
+
+func TestRoutes(t *testing.T) {
+	routes, err := ParseRoutes([]string{"influx=vm_perf", "influx=host*", "stdout=!vm_perf", "stdout=!host_power"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := []collector.Metric{{Name: "vm"}, {Name: "vm_perf"}, {Name: "host"}, {Name: "host_power"}}
+	for sink, expect := range map[string]string{"influx": "[vm_perf host host_power]", "stdout": "[vm host]"} {
+		var r recorder
+		if err := (&Routed{Sink: &r, Route: routes[sink]}).Write(context.Background(), metrics); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint([]string(r)); got != expect {
+			t.Errorf("%s: %s, expected %s", sink, got, expect)
+		}
+	}
+
+	for _, rule := range []string{"vm_perf", "aria=", "aria=[", "=vm"} {
+		if _, err := ParseRoutes([]string{rule}); err == nil {
+			t.Errorf("%q accepted", rule)
+		}
+	}
+}
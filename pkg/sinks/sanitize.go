@@ -0,0 +1,56 @@
+package sinks
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Sanitize normalizes a tag or field value before serialization: invalid
+// UTF-8 sequences are replaced by U+FFFD and control characters, such as
+// newlines embedded in VM annotations, by spaces.
+func Sanitize(s string) string {
+	clean := true
+	for _, r := range s {
+		if r == unicode.ReplacementChar || unicode.IsControl(r) {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+
+	s = strings.ToValidUTF8(s, string(unicode.ReplacementChar))
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// escapeMeasurement escapes a line protocol measurement name.
+func escapeMeasurement(s string) string {
+	return measurementEscaper.Replace(Sanitize(s))
+}
+
+// escapeTag escapes a line protocol tag key, tag value or field key.
+func escapeTag(s string) string {
+	s = tagEscaper.Replace(Sanitize(s))
+	// A trailing backslash would escape the following separator
+	if strings.HasSuffix(s, `\`) {
+		s += " "
+	}
+	return s
+}
+
+// escapeString quotes and escapes a line protocol string field value.
+func escapeString(s string) string {
+	return `"` + stringEscaper.Replace(Sanitize(s)) + `"`
+}
@@ -0,0 +1,28 @@
+// Package sinks writes collected metrics to backends.
+package sinks
+
+import (
+	"context"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// A Sink writes the metrics of collection cycles to a backend.
+type Sink interface {
+	// Write writes the metrics of a single cycle.
+	Write(ctx context.Context, metrics []collector.Metric) error
+}
+
+// A Flusher is a Sink buffering the metrics of cycles, flushed once the last
+// cycle ran.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Flush flushes s if it is a Flusher.
+func Flush(ctx context.Context, s Sink) error {
+	if f, ok := s.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Writer is a Sink encoding metrics to a writer, such as stdout.
+type Writer struct {
+	w   *bufio.Writer
+	enc Encoder
+}
+
+// NewWriter returns a Writer sink encoding metrics with enc to w.
+func NewWriter(w io.Writer, enc Encoder) *Writer {
+	return &Writer{w: bufio.NewWriter(w), enc: enc}
+}
+
+// Write encodes metrics and flushes them.
+func (s *Writer) Write(ctx context.Context, metrics []collector.Metric) error {
+	if err := s.enc.Encode(s.w, metrics); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// DryRun is a Sink printing what the sink it stands in for would write,
+// without writing it.
+type DryRun struct {
+	w    *bufio.Writer
+	name string
+	enc  Encoder
+}
+
+// NewDryRun returns a DryRun sink printing to w the metrics the sink called
+// name would write, as encoded by enc.
+func NewDryRun(w io.Writer, name string, enc Encoder) *DryRun {
+	return &DryRun{w: bufio.NewWriter(w), name: name, enc: enc}
+}
+
+// Write prints metrics after a "#" comment line naming the sink.
+func (s *DryRun) Write(ctx context.Context, metrics []collector.Metric) error {
+	fmt.Fprintf(s.w, "# dry run: sink %s would write %d metrics as %s\n", s.name, len(metrics), s.enc.ContentType())
+	if err := s.enc.Encode(s.w, metrics); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
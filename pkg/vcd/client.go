@@ -0,0 +1,179 @@
+package vcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// apiVersion is the Cloud Director API version requested, that of 10.3.
+const apiVersion = "36.0"
+
+// Client lists the virtual machines of a Cloud Director, as a system
+// administrator.
+type Client struct {
+	// URL is the base URL of Cloud Director.
+	URL      *url.URL
+	Username string
+	Password string
+
+	token string
+}
+
+// NewClient returns a client of the Cloud Director at rawURL, logging in as
+// the system administrator username.
+func NewClient(rawURL, username, password string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid Cloud Director URL %q", rawURL)
+	}
+	collector.AddSecret(password)
+	return &Client{URL: u, Username: username, Password: password}, nil
+}
+
+// Tenants lists the tenant of every virtual machine.
+func (c *Client) Tenants(ctx context.Context) (map[VM]Tenant, error) {
+	if c.token == "" {
+		if err := c.login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	type vdc struct {
+		name, org string
+	}
+	vdcs := make(map[string]vdc)
+	err := c.query(ctx, "adminOrgVdc", func(r map[string]interface{}) {
+		vdcs[str(r, "href")] = vdc{name: str(r, "name"), org: str(r, "orgName")}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vcenters := make(map[string]string)
+	err = c.query(ctx, "virtualCenter", func(r map[string]interface{}) {
+		if u, err := url.Parse(str(r, "url")); err == nil {
+			vcenters[str(r, "href")] = u.Hostname()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make(map[VM]Tenant)
+	err = c.query(ctx, "adminVM", func(r map[string]interface{}) {
+		// Templates are not running virtual machines
+		if r["isVAppTemplate"] == true {
+			return
+		}
+		v, ok := vdcs[str(r, "vdc")]
+		if !ok {
+			return
+		}
+		vm := VM{VCenter: vcenters[str(r, "vc")], MOID: str(r, "moref")}
+		tenants[vm] = Tenant{Org: v.org, VDC: v.name, VApp: str(r, "containerName")}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func str(r map[string]interface{}, k string) string {
+	s, _ := r[k].(string)
+	return s
+}
+
+// login opens a provider session of the System organization.
+func (c *Client) login(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/cloudapi/1.0.0/sessions/provider"), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username+"@System", c.Password)
+	req.Header.Set("Accept", "application/json;version="+apiVersion)
+
+	resp, err := collector.Outbound.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("cloud director login: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	token := resp.Header.Get("X-VMWARE-VCLOUD-ACCESS-TOKEN")
+	if token == "" {
+		return fmt.Errorf("cloud director login: no access token")
+	}
+
+	collector.AddSecret(token)
+	c.token = token
+	return nil
+}
+
+// query calls fn with every record of the query of type typ, page by page.
+func (c *Client) query(ctx context.Context, typ string, fn func(map[string]interface{})) error {
+	const pageSize = 128
+	for page := 1; ; page++ {
+		q := url.Values{
+			"type":     {typ},
+			"format":   {"records"},
+			"page":     {fmt.Sprint(page)},
+			"pageSize": {fmt.Sprint(pageSize)},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/api/query")+"?"+q.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/*+json;version="+apiVersion)
+		req.Header.Set("Authorization", "Bearer "+c.token)
+
+		resp, err := collector.Outbound.Do(req)
+		if err != nil {
+			return err
+		}
+
+		var res struct {
+			Total  int                      `json:"total"`
+			Record []map[string]interface{} `json:"record"`
+		}
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			// Log in again on the next listing
+			c.token = ""
+			err = fmt.Errorf("query %s: %s", typ, resp.Status)
+		case resp.StatusCode/100 != 2:
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			err = fmt.Errorf("query %s: %s: %s", typ, resp.Status, bytes.TrimSpace(msg))
+		default:
+			err = json.NewDecoder(resp.Body).Decode(&res)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range res.Record {
+			fn(r)
+		}
+		if page*pageSize >= res.Total {
+			return nil
+		}
+	}
+}
+
+func (c *Client) endpoint(path string) string {
+	return strings.TrimSuffix(c.URL.String(), "/") + path
+}
@@ -0,0 +1,102 @@
+// Package vcd tags the metrics of virtual machines managed by VMware Cloud
+// Director with their tenant: the organization, organization VDC and vApp
+// they belong to, for service providers to split metrics per tenant.
+//
+//	client, err := vcd.NewClient("https://vcd.example.com", "administrator", "secret")
+//	...
+//	tenants := vcd.NewTenants(client, 15*time.Minute)
+//	for each cycle {
+//		err := tenants.Tag(ctx, metrics)
+//	}
+package vcd
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+// Tags are the tags set on the metrics of virtual machines.
+var Tags = []string{"vcd_org", "vcd_vdc", "vcd_vapp"}
+
+// Tenant is the tenant of a virtual machine.
+type Tenant struct {
+	Org  string `json:"org"`
+	VDC  string `json:"vdc"`
+	VApp string `json:"vapp"`
+}
+
+// VM identifies a virtual machine across vCenters, by the host name of its
+// vCenter and its managed object ID.
+type VM struct {
+	VCenter string
+	MOID    string
+}
+
+// Tenants tags the metrics of virtual machines with their Tenant, listed
+// from Cloud Director every refresh interval.
+type Tenants struct {
+	Client  *Client
+	Refresh time.Duration
+
+	mu        sync.Mutex
+	tenants   map[VM]Tenant
+	refreshed time.Time
+}
+
+// NewTenants returns Tenants listed by c every refresh.
+func NewTenants(c *Client, refresh time.Duration) *Tenants {
+	return &Tenants{Client: c, Refresh: refresh}
+}
+
+// Tag sets the tenant tags of the vm and vm_perf metrics of virtual machines
+// managed by Cloud Director, listing them again first when the last listing is
+// older than the refresh interval. A failed listing is returned with the metrics
+// tagged from the previous one. Tags maps are copied before being set, as
+// they may be shared.
+func (t *Tenants) Tag(ctx context.Context, metrics []collector.Metric) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var err error
+	if t.tenants == nil || time.Since(t.refreshed) >= t.Refresh {
+		var tenants map[VM]Tenant
+		if tenants, err = t.Client.Tenants(ctx); err == nil {
+			slog.Debug("listed cloud director virtual machines", "count", len(tenants))
+			t.tenants, t.refreshed = tenants, time.Now()
+		}
+	}
+
+	for i := range metrics {
+		m := &metrics[i]
+		if (m.Name != "vm" && m.Name != "vm_perf") || m.Entity == nil {
+			continue
+		}
+		tenant, ok := t.tenants[VM{VCenter: hostname(m.Entity.VCenter), MOID: m.Entity.MOID}]
+		if !ok {
+			continue
+		}
+
+		tags := make(map[string]string, len(m.Tags)+len(Tags))
+		for k, v := range m.Tags {
+			tags[k] = v
+		}
+		tags["vcd_org"] = tenant.Org
+		tags["vcd_vdc"] = tenant.VDC
+		tags["vcd_vapp"] = tenant.VApp
+		m.Tags = tags
+	}
+	return err
+}
+
+// hostname returns host without its port, if any.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
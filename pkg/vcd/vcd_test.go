@@ -0,0 +1,57 @@
+package vcd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mlabouardy/vsphere-collector/pkg/collector"
+)
+
+func TestTag(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cloudapi/1.0.0/sessions/provider", func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "admin@System" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-VMWARE-VCLOUD-ACCESS-TOKEN", "t0k3n")
+	})
+	mux.HandleFunc("/api/query", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("type") {
+		case "adminOrgVdc":
+			w.Write([]byte(`{"total": 1, "record": [{"href": "https://vcd/api/vdc/1", "name": "acme-vdc", "orgName": "acme"}]}`))
+		case "virtualCenter":
+			w.Write([]byte(`{"total": 1, "record": [{"href": "https://vcd/api/vc/1", "url": "https://vc.example.com:443"}]}`))
+		case "adminVM":
+			w.Write([]byte(`{"total": 1, "record": [{"vdc": "https://vcd/api/vdc/1", "vc": "https://vcd/api/vc/1", "moref": "vm-42", "containerName": "web"}]}`))
+		}
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	c, err := NewClient(s.URL, "admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared := map[string]string{"name": "vm0"}
+	metrics := []collector.Metric{
+		{Name: "vm", Tags: shared, Entity: &collector.EntityRef{VCenter: "vc.example.com", Type: "VirtualMachine", MOID: "vm-42"}},
+		{Name: "vm", Tags: map[string]string{"name": "vm1"}, Entity: &collector.EntityRef{VCenter: "vc.example.com", Type: "VirtualMachine", MOID: "vm-43"}},
+	}
+	if err := NewTenants(c, 0).Tag(context.Background(), metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags := metrics[0].Tags; tags["vcd_org"] != "acme" || tags["vcd_vdc"] != "acme-vdc" || tags["vcd_vapp"] != "web" {
+		t.Errorf("tags %v", tags)
+	}
+	if _, ok := shared["vcd_org"]; ok {
+		t.Error("shared tags modified")
+	}
+	if _, ok := metrics[1].Tags["vcd_org"]; ok {
+		t.Error("VM not managed by Cloud Director tagged")
+	}
+}